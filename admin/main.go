@@ -0,0 +1,145 @@
+// Package admin serves a gRPC management API of a running collector, for fleet managers controlling many collectors:
+// status, streamed as it changes, remote pause and resume, and inspection of the effective config.
+//
+// The service is defined in admin.proto with well-known message types, so clients can be generated from it or call it
+// with tools such as grpcurl.
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/rfizzle/okta-collector/secrets"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// States of a collector
+const (
+	StatePolling = "polling"
+	StateWaiting = "waiting"
+	StatePaused  = "paused"
+)
+
+// Status of a collector
+type Status struct {
+	// Polling, waiting for the next poll, or paused
+	State string
+	// Health of the collector, ok or the reason it is degraded
+	Health string
+	// Number of the latest poll ended, and of the latest poll every output acknowledged
+	Polls     uint64
+	AckedPoll uint64
+	// Start and end of the latest poll, zero before the first
+	LastPollStarted time.Time
+	LastPollEnded   time.Time
+	// When the collector was paused, zero unless paused
+	PausedSince time.Time
+}
+
+// A collector managed by the API
+type Controller interface {
+	// Current status of the collector
+	Status() Status
+	// Pause collection once the current poll ended, until resumed
+	Pause()
+	// Resume paused collection
+	Resume()
+	// Effective config of every param, with secrets masked
+	Config() map[string]interface{}
+}
+
+// Register the admin params
+func InitCLIParams() {
+	flag.String("admin-grpc-address", "", "address to serve the grpc management api on, such as localhost:9091 (default disabled)")
+	flag.String("admin-grpc-token", "", "bearer token clients of the grpc management api must authenticate with")
+	flag.String("admin-grpc-tls-cert", "", "certificate file to serve the grpc management api over tls with")
+	flag.String("admin-grpc-tls-key", "", "private key file of the grpc management api tls certificate")
+}
+
+// Validate the admin params
+func ValidateCLIParams() error {
+	address := viper.GetString("admin-grpc-address")
+	if address == "" {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid admin grpc address param (--admin-grpc-address): %v", err))
+	}
+
+	// Anyone who can reach the API can pause collection, so only loopback addresses may go without a token
+	if viper.GetString("admin-grpc-token") == "" && !isLoopback(host) {
+		return errors.New("missing admin grpc token param (--admin-grpc-token) to serve the grpc management api on a non-loopback address")
+	}
+
+	if (viper.GetString("admin-grpc-tls-cert") == "") != (viper.GetString("admin-grpc-tls-key") == "") {
+		return errors.New("admin grpc tls cert (--admin-grpc-tls-cert) and key (--admin-grpc-tls-key) params must be set together")
+	}
+
+	// The token would cross the network in the clear without TLS
+	if viper.GetString("admin-grpc-tls-cert") == "" && !isLoopback(host) {
+		return errors.New("missing admin grpc tls cert (--admin-grpc-tls-cert) and key (--admin-grpc-tls-key) params to serve the grpc management api on a non-loopback address")
+	}
+
+	return nil
+}
+
+// Check if a host only accepts local connections
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Start serving the management API of a controller if enabled
+// Returns a function stopping the server, which does nothing when the API isn't served.
+func Serve(controller Controller) (func(), error) {
+	address := viper.GetString("admin-grpc-address")
+	if address == "" {
+		return func() {}, nil
+	}
+
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(authenticateUnary),
+		grpc.StreamInterceptor(authenticateStream),
+	}
+
+	if cert := viper.GetString("admin-grpc-tls-cert"); cert != "" {
+		creds, err := credentials.NewServerTLSFromFile(cert, viper.GetString("admin-grpc-tls-key"))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to serve grpc management api: %v", err))
+		}
+		options = append(options, grpc.Creds(creds))
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to serve grpc management api: %v", err))
+	}
+
+	server := grpc.NewServer(options...)
+	server.RegisterService(&serviceDesc, &service{controller: controller})
+
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("gRPC management API stopped: %v\n", err)
+		}
+	}()
+
+	return server.Stop, nil
+}
+
+// Latest token clients must authenticate with, refreshed if it references a secret
+func token() string {
+	return secrets.Value("admin-grpc-token")
+}
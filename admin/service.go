@@ -0,0 +1,203 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Full name of the management service, as defined in admin.proto
+const serviceName = "okta_collector.admin.v1.Admin"
+
+// Interval at which watched statuses are checked for changes
+const watchInterval = time.Second
+
+// Service of the management API of a controller
+type service struct {
+	controller Controller
+}
+
+// Handler of the management service, checked by the server on registration
+type adminServer interface {
+	watchStatus(stream grpc.ServerStream) error
+}
+
+// Description of the management service, registered without generated code since its messages are well-known types
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*adminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod("GetStatus", (*service).getStatus),
+		unaryMethod("Pause", (*service).pause),
+		unaryMethod("Resume", (*service).resume),
+		unaryMethod("GetConfig", (*service).getConfig),
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "WatchStatus", Handler: watchStatusHandler, ServerStreams: true},
+	},
+	Metadata: "admin.proto",
+}
+
+// Describe a method taking an empty request and answering a struct
+func unaryMethod(name string, method func(s *service, ctx context.Context) (*structpb.Struct, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(emptypb.Empty)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return method(srv.(*service), ctx)
+			}
+			if interceptor == nil {
+				return handler(ctx, in)
+			}
+
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + name}
+			return interceptor(ctx, in, info, handler)
+		},
+	}
+}
+
+func watchStatusHandler(srv interface{}, stream grpc.ServerStream) error {
+	in := new(emptypb.Empty)
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+
+	return srv.(*service).watchStatus(stream)
+}
+
+func (s *service) getStatus(ctx context.Context) (*structpb.Struct, error) {
+	return statusStruct(s.controller.Status())
+}
+
+func (s *service) pause(ctx context.Context) (*structpb.Struct, error) {
+	s.controller.Pause()
+	return statusStruct(s.controller.Status())
+}
+
+func (s *service) resume(ctx context.Context) (*structpb.Struct, error) {
+	s.controller.Resume()
+	return statusStruct(s.controller.Status())
+}
+
+func (s *service) getConfig(ctx context.Context) (*structpb.Struct, error) {
+	config, err := structpb.NewStruct(structValues(s.controller.Config()))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to encode config: %v", err)
+	}
+
+	return config, nil
+}
+
+// Stream the status once, then every time it changes until the client goes away
+func (s *service) watchStatus(stream grpc.ServerStream) error {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	var sent Status
+	for first := true; ; first = false {
+		if current := s.controller.Status(); first || current != sent {
+			message, err := statusStruct(current)
+			if err != nil {
+				return err
+			}
+			if err := stream.SendMsg(message); err != nil {
+				return err
+			}
+			sent = current
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Encode a status, leaving out times that are zero
+func statusStruct(st Status) (*structpb.Struct, error) {
+	fields := map[string]interface{}{
+		"state":     st.State,
+		"health":    st.Health,
+		"polls":     float64(st.Polls),
+		"ackedPoll": float64(st.AckedPoll),
+	}
+	for key, at := range map[string]time.Time{"lastPollStarted": st.LastPollStarted, "lastPollEnded": st.LastPollEnded, "pausedSince": st.PausedSince} {
+		if !at.IsZero() {
+			fields[key] = at.UTC().Format(time.RFC3339Nano)
+		}
+	}
+
+	message, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to encode status: %v", err)
+	}
+
+	return message, nil
+}
+
+// Convert config values to the types a struct can hold, string slices to lists
+func structValues(config map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		if v, ok := value.([]string); ok {
+			list := make([]interface{}, len(v))
+			for i, s := range v {
+				list[i] = s
+			}
+			value = list
+		}
+		values[key] = value
+	}
+
+	return values
+}
+
+// Check the bearer token of a unary call
+func authenticateUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// Check the bearer token of a streaming call
+func authenticateStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := authenticate(ss.Context()); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
+
+// Check that a call carries the admin token as a bearer token in its authorization metadata, when a token is set
+func authenticate(ctx context.Context) error {
+	expected := token()
+	if expected == "" {
+		return nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		given := strings.TrimPrefix(value, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1 {
+			return nil
+		}
+	}
+
+	return status.Error(codes.Unauthenticated, "invalid or missing admin token")
+}
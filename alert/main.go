@@ -0,0 +1,74 @@
+// Package alert emits the alerts of detections to the alert outputs.
+package alert
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rfizzle/okta-collector/output"
+)
+
+// Prefix of the event type of alerts, followed by the detection that raised them
+const eventTypePrefix = "collector.alert."
+
+// The rule of a detection that raised an alert
+type Rule struct {
+	Id          string   `json:"id,omitempty"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Level       string   `json:"level,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// An alert record
+// Alerts share the uuid, published and eventType fields of System Log events so outputs can batch and route them.
+type Alert struct {
+	Uuid      string                 `json:"uuid"`
+	Published string                 `json:"published"`
+	EventType string                 `json:"eventType"`
+	Detection string                 `json:"detection"`
+	Rule      Rule                   `json:"rule"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Events    []json.RawMessage      `json:"events,omitempty"`
+}
+
+// Create an alert of a detection, such as sigma, with the events that raised it
+func New(detection string, rule Rule, events ...[]byte) *Alert {
+	a := &Alert{
+		Uuid:      NewUuid(),
+		Published: time.Now().UTC().Format(time.RFC3339Nano),
+		EventType: eventTypePrefix + detection,
+		Detection: detection,
+		Rule:      rule,
+	}
+
+	for _, event := range events {
+		a.Events = append(a.Events, json.RawMessage(event))
+	}
+
+	return a
+}
+
+// Emit an alert to the alert outputs
+func Emit(a *Alert) {
+	encoded, err := json.Marshal(a)
+	if err != nil {
+		log.Printf("Unable to encode %s alert: %v\n", a.Rule.Title, err)
+		return
+	}
+
+	output.WriteAlert(encoded)
+}
+
+// Generate a random version 4 UUID
+func NewUuid() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
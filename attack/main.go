@@ -0,0 +1,150 @@
+// Package attack tags Okta System Log events with MITRE ATT&CK techniques.
+package attack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/rfizzle/okta-collector/match"
+)
+
+// An ATT&CK technique
+type Technique struct {
+	Id      string   `json:"id"`
+	Name    string   `json:"name"`
+	Tactics []string `json:"tactics"`
+}
+
+// A mapping of events to techniques
+// Event types support * wildcards. Mappings without an outcome match every outcome.
+type Mapping struct {
+	EventType  string   `json:"eventType"`
+	Outcome    string   `json:"outcome,omitempty"`
+	Techniques []string `json:"techniques"`
+}
+
+// A mapping file, extending the bundled techniques and mappings
+// Its mappings are matched before the bundled ones, so a mapping without techniques untags an event type.
+type mappingFile struct {
+	Techniques []Technique `json:"techniques"`
+	Mappings   []Mapping   `json:"mappings"`
+}
+
+// Bundled techniques by ID
+var bundledTechniques = []Technique{
+	{"T1078.004", "Valid Accounts: Cloud Accounts", []string{"Defense Evasion", "Persistence", "Privilege Escalation", "Initial Access"}},
+	{"T1098", "Account Manipulation", []string{"Persistence", "Privilege Escalation"}},
+	{"T1098.001", "Account Manipulation: Additional Cloud Credentials", []string{"Persistence", "Privilege Escalation"}},
+	{"T1098.003", "Account Manipulation: Additional Cloud Roles", []string{"Persistence", "Privilege Escalation"}},
+	{"T1110", "Brute Force", []string{"Credential Access"}},
+	{"T1136.003", "Create Account: Cloud Account", []string{"Persistence"}},
+	{"T1484.002", "Domain or Tenant Policy Modification: Trust Modification", []string{"Defense Evasion", "Privilege Escalation"}},
+	{"T1556", "Modify Authentication Process", []string{"Credential Access", "Defense Evasion", "Persistence"}},
+	{"T1562.007", "Impair Defenses: Disable or Modify Cloud Firewall", []string{"Defense Evasion"}},
+	{"T1562.008", "Impair Defenses: Disable or Modify Cloud Logs", []string{"Defense Evasion"}},
+	{"T1621", "Multi-Factor Authentication Request Generation", []string{"Credential Access"}},
+}
+
+// Bundled mappings of Okta event types and outcomes
+var bundledMappings = []Mapping{
+	{EventType: "user.session.start", Outcome: "FAILURE", Techniques: []string{"T1110"}},
+	{EventType: "user.authentication.*", Outcome: "FAILURE", Techniques: []string{"T1110"}},
+	{EventType: "user.account.lock", Techniques: []string{"T1110"}},
+	{EventType: "security.threat.detected", Techniques: []string{"T1110"}},
+	{EventType: "user.mfa.okta_verify.deny_push", Techniques: []string{"T1621"}},
+	{EventType: "user.mfa.factor.deactivate", Techniques: []string{"T1556"}},
+	{EventType: "user.mfa.factor.reset_all", Techniques: []string{"T1556"}},
+	{EventType: "system.mfa.factor.deactivate", Techniques: []string{"T1556"}},
+	{EventType: "policy.lifecycle.update", Techniques: []string{"T1556"}},
+	{EventType: "policy.lifecycle.deactivate", Techniques: []string{"T1556"}},
+	{EventType: "policy.lifecycle.delete", Techniques: []string{"T1556"}},
+	{EventType: "policy.rule.update", Techniques: []string{"T1556"}},
+	{EventType: "policy.rule.deactivate", Techniques: []string{"T1556"}},
+	{EventType: "policy.rule.delete", Techniques: []string{"T1556"}},
+	{EventType: "user.account.privilege.grant", Techniques: []string{"T1098.003"}},
+	{EventType: "group.privilege.grant", Techniques: []string{"T1098.003"}},
+	{EventType: "group.user_membership.add", Techniques: []string{"T1098"}},
+	{EventType: "user.account.reset_password", Techniques: []string{"T1098"}},
+	{EventType: "system.api_token.create", Techniques: []string{"T1098.001"}},
+	{EventType: "user.lifecycle.create", Techniques: []string{"T1136.003"}},
+	{EventType: "user.session.impersonation.initiate", Techniques: []string{"T1078.004"}},
+	{EventType: "user.session.access_admin_app", Techniques: []string{"T1078.004"}},
+	{EventType: "system.idp.lifecycle.create", Techniques: []string{"T1484.002"}},
+	{EventType: "system.idp.lifecycle.update", Techniques: []string{"T1484.002"}},
+	{EventType: "zone.update", Techniques: []string{"T1562.007"}},
+	{EventType: "zone.deactivate", Techniques: []string{"T1562.007"}},
+	{EventType: "zone.delete", Techniques: []string{"T1562.007"}},
+	{EventType: "system.log_stream.lifecycle.deactivate", Techniques: []string{"T1562.008"}},
+	{EventType: "system.log_stream.lifecycle.delete", Techniques: []string{"T1562.008"}},
+}
+
+// Maps events to techniques
+type Mapper struct {
+	techniques map[string]Technique
+	mappings   []Mapping
+}
+
+// Create a mapper from the bundled mappings, extended by a mapping file if a path is set
+func NewMapper(mappingPath string) (*Mapper, error) {
+	m := &Mapper{techniques: make(map[string]Technique)}
+	for _, t := range bundledTechniques {
+		m.techniques[t.Id] = t
+	}
+
+	if mappingPath != "" {
+		content, err := ioutil.ReadFile(mappingPath)
+		if err != nil {
+			return nil, err
+		}
+
+		var file mappingFile
+		if err := json.Unmarshal(content, &file); err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid mapping file %s: %v", mappingPath, err))
+		}
+
+		for _, t := range file.Techniques {
+			m.techniques[t.Id] = t
+		}
+		m.mappings = append(m.mappings, file.Mappings...)
+	}
+	m.mappings = append(m.mappings, bundledMappings...)
+
+	// Check every mapping
+	for _, mapping := range m.mappings {
+		if !match.ValidPattern(mapping.EventType) || mapping.EventType == "" {
+			return nil, errors.New(fmt.Sprintf("invalid mapping event type %s", mapping.EventType))
+		}
+
+		for _, id := range mapping.Techniques {
+			if _, ok := m.techniques[id]; !ok {
+				return nil, errors.New(fmt.Sprintf("unknown technique %s mapped to %s", id, mapping.EventType))
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// Techniques of an event, from the first mapping that matches it
+func (m *Mapper) Techniques(eventType, outcome string) []Technique {
+	for _, mapping := range m.mappings {
+		if !match.Pattern(mapping.EventType, eventType) {
+			continue
+		}
+
+		if mapping.Outcome != "" && !strings.EqualFold(mapping.Outcome, outcome) {
+			continue
+		}
+
+		var techniques []Technique
+		for _, id := range mapping.Techniques {
+			techniques = append(techniques, m.techniques[id])
+		}
+		return techniques
+	}
+
+	return nil
+}
@@ -0,0 +1,235 @@
+// Package cim adds Splunk Common Information Model (CIM) fields to Okta System Log events.
+package cim
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// CIM data models events are mapped to
+const (
+	AuthenticationModel = "Authentication"
+	ChangeModel         = "Change"
+)
+
+// Okta event type prefixes mapped to CIM data models
+var eventTypePrefixModels = map[string]string{
+	"user.authentication.":    AuthenticationModel,
+	"user.session.start":      AuthenticationModel,
+	"app.oauth2.token.":       AuthenticationModel,
+	"user.lifecycle.":         ChangeModel,
+	"user.account.":           ChangeModel,
+	"user.mfa.factor.":        ChangeModel,
+	"group.":                  ChangeModel,
+	"application.":            ChangeModel,
+	"app.user_membership.":    ChangeModel,
+	"policy.lifecycle.":       ChangeModel,
+	"policy.rule.":            ChangeModel,
+	"zone.":                   ChangeModel,
+	"system.api_token.":       ChangeModel,
+	"system.org.":             ChangeModel,
+	"user.session.clear":      ChangeModel,
+	"user.session.end":        AuthenticationModel,
+	"policy.evaluate_sign_on": AuthenticationModel,
+}
+
+// Change actions by the last segment of an Okta event type
+var changeActions = map[string]string{
+	"create":  "created",
+	"add":     "created",
+	"delete":  "deleted",
+	"remove":  "deleted",
+	"update":  "modified",
+	"lock":    "modified",
+	"unlock":  "modified",
+	"suspend": "modified",
+}
+
+// CIM fields added to an event
+type Fields struct {
+	Action         string `json:"action,omitempty"`
+	App            string `json:"app"`
+	Dest           string `json:"dest,omitempty"`
+	Src            string `json:"src,omitempty"`
+	SrcUser        string `json:"src_user,omitempty"`
+	User           string `json:"user,omitempty"`
+	Signature      string `json:"signature,omitempty"`
+	SignatureId    string `json:"signature_id,omitempty"`
+	Status         string `json:"status,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+	ChangeType     string `json:"change_type,omitempty"`
+	Object         string `json:"object,omitempty"`
+	ObjectId       string `json:"object_id,omitempty"`
+	ObjectCategory string `json:"object_category,omitempty"`
+	VendorProduct  string `json:"vendor_product"`
+	DataModel      string `json:"cim_data_model,omitempty"`
+}
+
+// Fields of an Okta event used for mapping
+type oktaEvent struct {
+	EventType      string `json:"eventType"`
+	DisplayMessage string `json:"displayMessage"`
+	Actor          struct {
+		Id          string `json:"id"`
+		AlternateId string `json:"alternateId"`
+	} `json:"actor"`
+	Client struct {
+		IpAddress string `json:"ipAddress"`
+	} `json:"client"`
+	Outcome struct {
+		Result string `json:"result"`
+		Reason string `json:"reason"`
+	} `json:"outcome"`
+	Target []struct {
+		Id          string `json:"id"`
+		Type        string `json:"type"`
+		AlternateId string `json:"alternateId"`
+		DisplayName string `json:"displayName"`
+	} `json:"target"`
+}
+
+// Map a raw Okta event to CIM fields
+func FromEvent(raw []byte) (*Fields, error) {
+	var okta oktaEvent
+	if err := json.Unmarshal(raw, &okta); err != nil {
+		return nil, err
+	}
+
+	fields := &Fields{
+		App:           "okta",
+		Src:           okta.Client.IpAddress,
+		User:          okta.Actor.AlternateId,
+		Signature:     okta.DisplayMessage,
+		SignatureId:   okta.EventType,
+		Status:        status(okta.Outcome.Result),
+		Reason:        okta.Outcome.Reason,
+		VendorProduct: "Okta",
+		DataModel:     lookupModel(okta.EventType),
+	}
+
+	switch fields.DataModel {
+	case AuthenticationModel:
+		fields.Action = fields.Status
+		fields.Dest = "okta"
+
+		// Single sign on targets the app signed in to
+		for _, target := range okta.Target {
+			if target.Type == "AppInstance" {
+				fields.Dest = target.DisplayName
+				break
+			}
+		}
+	case ChangeModel:
+		fields.Action = changeAction(okta.EventType, fields.Status)
+		fields.ChangeType = "AAA"
+		fields.SrcUser = okta.Actor.AlternateId
+		fields.User = ""
+
+		// The changed object is the first target
+		if len(okta.Target) > 0 {
+			target := okta.Target[0]
+			fields.Object = target.DisplayName
+			fields.ObjectId = target.Id
+			fields.ObjectCategory = objectCategory(target.Type)
+			if target.Type == "User" {
+				fields.User = target.AlternateId
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+// Add CIM fields to a raw Okta event, leaving the original fields untouched
+func AddFields(raw []byte) ([]byte, error) {
+	fields, err := FromEvent(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Splice the CIM fields into the end of the event object
+	event := bytes.TrimSpace(raw)
+	if len(event) < 2 || event[0] != '{' || event[len(event)-1] != '}' {
+		return nil, errors.New("event is not a JSON object")
+	}
+
+	end := len(event) - 1
+	separator := []byte(",")
+	if len(bytes.TrimSpace(event[1:end])) == 0 {
+		separator = nil
+	}
+
+	result := make([]byte, 0, len(event)+len(encoded))
+	result = append(result, event[:end]...)
+	result = append(result, separator...)
+	result = append(result, encoded[1:]...)
+
+	return result, nil
+}
+
+// Find the CIM data model of an Okta event type by its longest matching prefix
+func lookupModel(eventType string) string {
+	model := ""
+	longest := 0
+	for prefix, m := range eventTypePrefixModels {
+		if strings.HasPrefix(eventType, prefix) && len(prefix) > longest {
+			model = m
+			longest = len(prefix)
+		}
+	}
+
+	return model
+}
+
+// Map an Okta outcome result to a CIM status
+func status(result string) string {
+	switch result {
+	case "SUCCESS", "ALLOW":
+		return "success"
+	case "FAILURE", "DENY":
+		return "failure"
+	default:
+		return ""
+	}
+}
+
+// Map an Okta event type to a CIM change action
+func changeAction(eventType string, status string) string {
+	if status == "failure" {
+		return "failure"
+	}
+
+	segments := strings.Split(eventType, ".")
+	for i := len(segments) - 1; i >= 0; i-- {
+		for verb, action := range changeActions {
+			if strings.HasPrefix(segments[i], verb) {
+				return action
+			}
+		}
+	}
+
+	return "modified"
+}
+
+// Map an Okta target type to a CIM object category
+func objectCategory(targetType string) string {
+	switch targetType {
+	case "User":
+		return "user"
+	case "UserGroup":
+		return "group"
+	case "AppInstance", "AppUser":
+		return "application"
+	case "PolicyEntity", "PolicyRule":
+		return "policy"
+	default:
+		return strings.ToLower(targetType)
+	}
+}
@@ -0,0 +1,161 @@
+package cim
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFromEventAuthentication(t *testing.T) {
+	fields, err := FromEvent([]byte(`{
+		"eventType": "user.authentication.sso",
+		"displayMessage": "User single sign on to app",
+		"actor": {"id": "00u1abcd", "alternateId": "jane.doe@example.com"},
+		"client": {"ipAddress": "203.0.113.7"},
+		"outcome": {"result": "SUCCESS"},
+		"target": [{"id": "0oa1abcd", "type": "AppInstance", "displayName": "Salesforce"}]
+	}`))
+	if err != nil {
+		t.Fatalf("unable to map event: %v", err)
+	}
+
+	expected := &Fields{
+		Action:        "success",
+		App:           "okta",
+		Dest:          "Salesforce",
+		Src:           "203.0.113.7",
+		User:          "jane.doe@example.com",
+		Signature:     "User single sign on to app",
+		SignatureId:   "user.authentication.sso",
+		Status:        "success",
+		VendorProduct: "Okta",
+		DataModel:     AuthenticationModel,
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %+v, got %+v", expected, fields)
+	}
+}
+
+func TestFromEventFailedLogin(t *testing.T) {
+	fields, err := FromEvent([]byte(`{"eventType": "user.session.start", "actor": {"alternateId": "jane.doe@example.com"}, "outcome": {"result": "FAILURE", "reason": "INVALID_CREDENTIALS"}}`))
+	if err != nil {
+		t.Fatalf("unable to map event: %v", err)
+	}
+
+	if fields.DataModel != AuthenticationModel || fields.Action != "failure" || fields.Dest != "okta" || fields.Reason != "INVALID_CREDENTIALS" {
+		t.Errorf("unexpected fields %+v", fields)
+	}
+}
+
+func TestFromEventChange(t *testing.T) {
+	fields, err := FromEvent([]byte(`{
+		"eventType": "group.user_membership.add",
+		"displayMessage": "Add user to group membership",
+		"actor": {"id": "00u1abcd", "alternateId": "admin@example.com"},
+		"client": {"ipAddress": "203.0.113.7"},
+		"outcome": {"result": "SUCCESS"},
+		"target": [{"id": "00u2efgh", "type": "User", "alternateId": "jane.doe@example.com", "displayName": "Jane Doe"}, {"id": "00g3", "type": "UserGroup", "displayName": "Engineering"}]
+	}`))
+	if err != nil {
+		t.Fatalf("unable to map event: %v", err)
+	}
+
+	expected := &Fields{
+		Action:         "created",
+		App:            "okta",
+		Src:            "203.0.113.7",
+		SrcUser:        "admin@example.com",
+		User:           "jane.doe@example.com",
+		Signature:      "Add user to group membership",
+		SignatureId:    "group.user_membership.add",
+		Status:         "success",
+		ChangeType:     "AAA",
+		Object:         "Jane Doe",
+		ObjectId:       "00u2efgh",
+		ObjectCategory: "user",
+		VendorProduct:  "Okta",
+		DataModel:      ChangeModel,
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %+v, got %+v", expected, fields)
+	}
+}
+
+func TestLookupModel(t *testing.T) {
+	tests := map[string]string{
+		"user.session.start":               AuthenticationModel,
+		"user.session.end":                 AuthenticationModel,
+		"user.session.clear":               ChangeModel,
+		"user.authentication.auth_via_mfa": AuthenticationModel,
+		"app.oauth2.token.grant":           AuthenticationModel,
+		"policy.evaluate_sign_on":          AuthenticationModel,
+		"policy.rule.update":               ChangeModel,
+		"user.lifecycle.create":            ChangeModel,
+		"system.api_token.create":          ChangeModel,
+		"user.session.access_admin_app":    "",
+		"security.threat.detected":         "",
+	}
+
+	for eventType, expected := range tests {
+		if model := lookupModel(eventType); model != expected {
+			t.Errorf("expected %s to map to %q, got %q", eventType, expected, model)
+		}
+	}
+}
+
+func TestChangeAction(t *testing.T) {
+	tests := []struct {
+		eventType string
+		status    string
+		action    string
+	}{
+		{"user.lifecycle.create", "success", "created"},
+		{"group.user_membership.add", "success", "created"},
+		{"user.lifecycle.delete.initiated", "success", "deleted"},
+		{"app.user_membership.remove", "success", "deleted"},
+		{"policy.rule.update", "success", "modified"},
+		{"user.account.update_password", "success", "modified"},
+		{"user.account.lock", "success", "modified"},
+		{"user.account.unlock", "success", "modified"},
+		{"user.lifecycle.activate", "success", "modified"},
+		{"user.lifecycle.create", "failure", "failure"},
+	}
+
+	for _, test := range tests {
+		if action := changeAction(test.eventType, test.status); action != test.action {
+			t.Errorf("expected %s with status %s to be %s, got %s", test.eventType, test.status, test.action, action)
+		}
+	}
+}
+
+func TestAddFields(t *testing.T) {
+	raw := `{"uuid": "1", "eventType": "user.session.start", "outcome": {"result": "SUCCESS"}, "asNumber": 12345678901234567890}`
+	added, err := AddFields([]byte(raw + "\n"))
+	if err != nil {
+		t.Fatalf("unable to add fields: %v", err)
+	}
+
+	expected := `{"uuid": "1", "eventType": "user.session.start", "outcome": {"result": "SUCCESS"}, "asNumber": 12345678901234567890,"action":"success","app":"okta","dest":"okta","signature_id":"user.session.start","status":"success","vendor_product":"Okta","cim_data_model":"Authentication"}`
+	if string(added) != expected {
+		t.Errorf("expected %s, got %s", expected, added)
+	}
+}
+
+func TestAddFieldsEmptyEvent(t *testing.T) {
+	added, err := AddFields([]byte(`{ }`))
+	if err != nil {
+		t.Fatalf("unable to add fields: %v", err)
+	}
+
+	if !json.Valid(added) {
+		t.Errorf("expected valid json, got %s", added)
+	}
+}
+
+func TestAddFieldsInvalid(t *testing.T) {
+	for _, raw := range []string{`[]`, `"event"`, `{"uuid": `} {
+		if _, err := AddFields([]byte(raw)); err == nil {
+			t.Errorf("expected %s to be invalid", raw)
+		}
+	}
+}
@@ -3,47 +3,50 @@ package main
 import (
 	"errors"
 	"fmt"
-	"github.com/rfizzle/collector-helpers/outputs"
-	"github.com/rfizzle/collector-helpers/state"
+	"github.com/rfizzle/okta-collector/collector"
 	flag "github.com/spf13/pflag"
 	"github.com/spf13/viper"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-func setupCliFlags() error {
+// Register the params of the collector and its packages on the global flag set, shared by every command
+func initCliFlags() {
 	viper.SetEnvPrefix("OC")
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 
-	flag.Int("schedule", 30, "time in seconds to collect")
-	flag.String("okta-domain", "", "okta domain for organization")
-	flag.String("okta-api-key", "", "okta api key for authentication")
-	flag.BoolP("verbose", "v", false, "verbose logging")
 	flag.BoolP("config", "c", false, "enable config file")
 	flag.String("config-path", "", "config file path")
-	state.InitCLIParams()
-	outputs.InitCLIParams()
-	flag.Parse()
-	err := viper.BindPFlags(flag.CommandLine)
+	collector.InitParams()
+}
 
-	if err != nil {
-		log.Fatalf("Failed parsing flags: %v", err.Error())
+// Load the params parsed by the command, from flags, env and the config file
+func loadCliFlags() error {
+	if err := viper.BindPFlags(flag.CommandLine); err != nil {
+		return errors.New(fmt.Sprintf("Failed parsing flags: %v", err))
 	}
 
 	// Check config
-	if err := checkConfigParams(); err != nil {
-		return err
-	}
+	return checkConfigParams()
+}
 
-	// Check parameters
-	if err := checkRequiredParams(); err != nil {
+// Load, resolve and validate the params
+func setupCliFlags() error {
+	if err := loadCliFlags(); err != nil {
 		return err
 	}
 
-	return nil
+	return collector.LoadParams(false)
+}
+
+// Supported config file types, by extension
+var supportedConfigTypes = []string{"json", "toml", "yaml", "yml", "properties", "props", "prop", "env", "dotenv"}
+
+// Type of a config file from its extension
+func configFileType(path string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
 }
 
 func checkConfigParams() error {
@@ -53,44 +56,34 @@ func checkConfigParams() error {
 		}
 
 		dir, file := filepath.Split(viper.GetString("config-path"))
-		ext := strings.ToLower(filepath.Ext(viper.GetString("config-path")))
+		ext := filepath.Ext(viper.GetString("config-path"))
+		configType := configFileType(viper.GetString("config-path"))
 
-		supportedTypes := []string{"json", "toml", "yaml", "yml", "properties", "props", "prop", "env", "dotenv"}
-		if !contains(supportedTypes, ext) {
-			e := fmt.Sprintf("invalid config file type (supported: %s )", strings.Join(supportedTypes[:], ", "))
+		if !contains(supportedConfigTypes, configType) {
+			e := fmt.Sprintf("invalid config file type (supported: %s )", strings.Join(supportedConfigTypes[:], ", "))
 			return errors.New(e)
 		}
 
 		fileName := strings.TrimSuffix(file, ext)
 
 		viper.SetConfigName(fileName)
-		viper.SetConfigType(ext)
+		viper.SetConfigType(configType)
 		viper.AddConfigPath(dir)
 
 		err := viper.ReadInConfig() // Find and read the config file
 		if err != nil { // Handle errors reading the config file
 			return fmt.Errorf("Fatal error config file: %s \n", err)
 		}
-	}
 
-	return nil
-}
-
-func checkRequiredParams() error {
-	if viper.GetString("okta-domain") == "" {
-		return errors.New("missing okta domain param (--okta-domain)")
-	}
-
-	if viper.GetString("okta-api-key") == "" {
-		return errors.New("missing okta api key param (--okta-api-key)")
-	}
-
-	if err := state.ValidateCLIParams(); err != nil {
-		return err
-	}
+		// Replace the values with their decrypted values and references to environment variables expanded
+		config, _, err := readConfigFile(viper.GetString("config-path"))
+		if err != nil {
+			return err
+		}
 
-	if err := outputs.ValidateCLIParams(); err != nil {
-		return err
+		if err := viper.MergeConfigMap(config.AllSettings()); err != nil {
+			return err
+		}
 	}
 
 	return nil
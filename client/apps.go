@@ -0,0 +1,31 @@
+package client
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// An app instance of the Okta org
+type OktaApp struct {
+	Id         string                 `json:"id"`
+	Name       string                 `json:"name"`
+	Label      string                 `json:"label"`
+	Status     string                 `json:"status"`
+	SignOnMode string                 `json:"signOnMode"`
+	Profile    map[string]interface{} `json:"profile"`
+}
+
+// Get the app instances of the org
+func (oktaClient *OktaClient) GetApps() ([]OktaApp, error) {
+	var apps []OktaApp
+	err := oktaClient.listAll("/api/v1/apps", url.Values{}, func(body []byte) error {
+		var page []OktaApp
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		apps = append(apps, page...)
+		return nil
+	})
+
+	return apps, err
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Largest error body of a rejected request read for its error code
+const maxErrorBodyBytes = 64 * 1024
+
+// Error of a request rejected because the API token is invalid or expired (401), or lacks the permission or scope the
+// request needs (403)
+// Requests fail the same way until the token is rotated, so retrying them is pointless.
+type CredentialsError struct {
+	StatusCode   int
+	ErrorCode    string
+	ErrorSummary string
+}
+
+// Error response of the Okta API
+type oktaErrorResponse struct {
+	ErrorCode    string `json:"errorCode"`
+	ErrorSummary string `json:"errorSummary"`
+}
+
+func (e *CredentialsError) Error() string {
+	reason := "the okta api token is invalid or expired"
+	if e.StatusCode == http.StatusForbidden {
+		reason = "the okta api token lacks a permission or scope the request needs"
+	}
+
+	if e.ErrorCode == "" {
+		return fmt.Sprintf("credentials invalid: %s (HTTP %d)", reason, e.StatusCode)
+	}
+
+	return fmt.Sprintf("credentials invalid: %s (HTTP %d, %s: %s)", reason, e.StatusCode, e.ErrorCode, e.ErrorSummary)
+}
+
+// Check if a request failed because of its credentials
+func IsCredentialsError(err error) bool {
+	var credentialsErr *CredentialsError
+	return errors.As(err, &credentialsErr)
+}
+
+// Check if a response rejected the credentials of its request
+func rejectsCredentials(response *http.Response) bool {
+	return response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden
+}
+
+// Read the credentials error of a response rejecting the credentials of its request
+func credentialsErrorFromResponse(response *http.Response) *CredentialsError {
+	credentialsErr := &CredentialsError{StatusCode: response.StatusCode}
+
+	var body oktaErrorResponse
+	content, err := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorBodyBytes))
+	if err == nil && json.Unmarshal(content, &body) == nil {
+		credentialsErr.ErrorCode = body.ErrorCode
+		credentialsErr.ErrorSummary = body.ErrorSummary
+	}
+
+	return credentialsErr
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/match"
+	"github.com/rfizzle/okta-collector/scrub"
+	"github.com/spf13/viper"
+)
+
+// Prefix of capture files, so rotation only removes captures
+const capturePrefix = "okta-response-"
+
+// Value of secret headers in captures
+const capturedRedacted = "[REDACTED]"
+
+// Headers whose values are replaced in captures
+var capturedSecretHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+var (
+	// Serializes writing and rotating captures
+	captureMu sync.Mutex
+
+	// Sequence of captures, ordering captures written in the same nanosecond
+	captureSequence uint64
+)
+
+// A captured request and its raw response
+type capture struct {
+	Time            string              `json:"time"`
+	Method          string              `json:"method"`
+	Url             string              `json:"url"`
+	RequestHeaders  map[string][]string `json:"requestHeaders"`
+	Status          int                 `json:"status"`
+	ResponseHeaders map[string][]string `json:"responseHeaders"`
+	Body            string              `json:"body"`
+}
+
+// Record the raw response to a request in the capture directory, when set
+// The body of the response is replaced with a copy, since recording consumes it.
+func captureResponse(request *http.Request, response *http.Response) {
+	dir := viper.GetString("capture-dir")
+	if dir == "" {
+		return
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	_ = response.Body.Close()
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Unable to capture response: %v\n", err)
+		return
+	}
+
+	c := capture{
+		Time:            time.Now().UTC().Format(time.RFC3339Nano),
+		Method:          request.Method,
+		Url:             scrub.String(request.URL.String()),
+		RequestHeaders:  scrubHeaders(request.Header),
+		Status:          response.StatusCode,
+		ResponseHeaders: scrubHeaders(response.Header),
+		Body:            scrub.String(string(body)),
+	}
+
+	if err := writeCapture(dir, c); err != nil {
+		log.Printf("Unable to capture response: %v\n", err)
+	}
+}
+
+// Copy headers, replacing the values of secret headers and scrubbing the others
+func scrubHeaders(headers http.Header) map[string][]string {
+	scrubbed := make(map[string][]string, len(headers))
+	for name, values := range headers {
+		for _, value := range values {
+			if match.ContainsFold(capturedSecretHeaders, name) {
+				value = capturedRedacted
+			}
+			scrubbed[name] = append(scrubbed[name], scrub.String(value))
+		}
+	}
+
+	return scrubbed
+}
+
+// Write a capture to the directory and remove the oldest captures over the max files
+func writeCapture(dir string, c capture) error {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	captureSequence++
+	name := fmt.Sprintf("%s%d-%06d.json", capturePrefix, time.Now().UnixNano(), captureSequence%1000000)
+	if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+		return err
+	}
+
+	maxFiles := viper.GetInt("capture-max-files")
+	if maxFiles <= 0 {
+		return nil
+	}
+
+	// Captures are named after when they were written, so they sort chronologically
+	paths, err := filepath.Glob(filepath.Join(dir, capturePrefix+"*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	for len(paths) > maxFiles {
+		if err := os.Remove(paths[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		paths = paths[1:]
+	}
+
+	return nil
+}
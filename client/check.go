@@ -0,0 +1,14 @@
+package client
+
+import (
+	"net/url"
+)
+
+// Check that the token can read the resources at uri, with a single item request
+func (oktaClient *OktaClient) CheckAccess(uri string) error {
+	params := url.Values{}
+	params.Set("limit", "1")
+
+	_, _, err := oktaClient.conductRequest("GET", uri, params)
+	return err
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rfizzle/okta-collector/pool"
+	"github.com/rfizzle/okta-collector/quarantine"
+)
+
+// Decode the events of a JSON array one at a time as they are read, each into a compact pooled buffer
+// The events are only returned once the whole array was read, so a truncated page returns no events. Elements that
+// aren't objects are quarantined, and the rest of the page is returned.
+func decodeEvents(reader io.Reader) ([][]byte, error) {
+	decoder := json.NewDecoder(reader)
+
+	// Opening bracket of the array
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New(fmt.Sprintf("expected an array of events, got %v", token))
+	}
+
+	// The raw event is reused for every event, and copied to its buffer
+	var events [][]byte
+	var event json.RawMessage
+	for decoder.More() {
+		if err := decoder.Decode(&event); err != nil {
+			releaseEvents(events)
+			return nil, err
+		}
+
+		// Set aside elements that can't be events
+		if trimmed := bytes.TrimSpace(event); len(trimmed) == 0 || trimmed[0] != '{' {
+			quarantine.Event(event, "okta api page", errors.New("event is not a json object"))
+			continue
+		}
+
+		// Compact the json into a single line
+		buffer := bytes.NewBuffer(pool.Get())
+		if err := json.Compact(buffer, event); err != nil {
+			releaseEvents(events)
+			return nil, err
+		}
+
+		events = append(events, buffer.Bytes())
+	}
+
+	// Closing bracket of the array, so truncated pages are errors
+	if _, err := decoder.Token(); err != nil {
+		releaseEvents(events)
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Return the buffers of events that won't be sent to the pool
+func releaseEvents(events [][]byte) {
+	for _, event := range events {
+		pool.Put(event)
+	}
+}
+
+// Published time of an event, empty if it can't be read
+func publishedOf(event []byte) string {
+	var fields struct {
+		Published string `json:"published"`
+	}
+	_ = json.Unmarshal(event, &fields)
+
+	return fields.Published
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/metrics"
+)
+
+// Endpoints already warned about as deprecated, so each is only warned about once
+var deprecationWarned = struct {
+	mu        sync.Mutex
+	endpoints map[string]bool
+}{endpoints: make(map[string]bool)}
+
+// Warn when a response announces that its endpoint is deprecated, with the Deprecation header, or will be removed,
+// with the Sunset header, counting deprecated responses by endpoint
+func recordDeprecation(request *http.Request, response *http.Response) {
+	deprecation := response.Header.Get("Deprecation")
+	sunset := response.Header.Get("Sunset")
+	if (deprecation == "" || deprecation == "false") && sunset == "" {
+		return
+	}
+
+	endpoint := endpointOf(request.URL)
+	metrics.DeprecatedResponses.Add(endpoint, 1)
+
+	deprecationWarned.mu.Lock()
+	defer deprecationWarned.mu.Unlock()
+
+	if deprecationWarned.endpoints[endpoint] {
+		return
+	}
+	deprecationWarned.endpoints[endpoint] = true
+
+	var details []string
+	if since := deprecationTime(deprecation); since != "" {
+		details = append(details, "deprecated since "+since)
+	}
+	if sunset != "" {
+		if at, err := http.ParseTime(sunset); err == nil {
+			details = append(details, "removed after "+at.UTC().Format(time.RFC3339))
+		} else {
+			details = append(details, "removed after "+sunset)
+		}
+	}
+	for _, link := range deprecationLinks(response) {
+		details = append(details, "see "+link)
+	}
+
+	message := fmt.Sprintf("WARNING: Okta API endpoint %s used by the collector is deprecated", endpoint)
+	if len(details) > 0 {
+		message += " (" + strings.Join(details, ", ") + ")"
+	}
+	log.Printf("%s, upgrade the collector before it is removed\n", message)
+}
+
+// Time of a Deprecation header, an HTTP date or an @ prefixed unix time, empty when it is only true
+func deprecationTime(deprecation string) string {
+	if strings.HasPrefix(deprecation, "@") {
+		if seconds, err := strconv.ParseInt(deprecation[1:], 10, 64); err == nil {
+			return time.Unix(seconds, 0).UTC().Format(time.RFC3339)
+		}
+	}
+
+	if at, err := http.ParseTime(deprecation); err == nil {
+		return at.UTC().Format(time.RFC3339)
+	}
+
+	return ""
+}
+
+// Documentation links of the deprecation or sunset of a response, from its Link headers
+func deprecationLinks(response *http.Response) []string {
+	var links []string
+	for _, header := range response.Header["Link"] {
+		for _, link := range strings.Split(header, ",") {
+			if !strings.Contains(link, `rel="deprecation"`) && !strings.Contains(link, `rel="sunset"`) {
+				continue
+			}
+
+			if start, end := strings.Index(link, "<"), strings.Index(link, ">"); start >= 0 && end > start {
+				links = append(links, link[start+1:end])
+			}
+		}
+	}
+
+	return links
+}
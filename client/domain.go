@@ -0,0 +1,96 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Domains of Okta orgs, the suffixes of org subdomains such as acme.okta.com
+var oktaDomainSuffixes = []string{".okta.com", ".oktapreview.com", ".okta-emea.com", ".okta-gov.com", ".okta.mil", ".trexcloud.com"}
+
+// A host name of letters, digits and dashes separated by dots
+var hostPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+
+// Normalize an Okta domain given as acme.okta.com or https://acme.okta.com to the bare host name of the org
+// Mistakes that would otherwise only show as errors on the first request, such as the admin console domain or a path,
+// are rejected with the domain to use instead.
+func NormalizeDomain(domain string) (string, error) {
+	host := strings.ToLower(strings.TrimSpace(domain))
+	if host == "" {
+		return "", errors.New("empty okta domain")
+	}
+
+	if strings.Contains(host, "://") {
+		u, err := url.Parse(host)
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("invalid okta domain %s: %v", domain, err))
+		}
+
+		if u.Scheme != "https" {
+			return "", errors.New(fmt.Sprintf("invalid okta domain %s: okta is only served over https", domain))
+		}
+
+		if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+			return "", errors.New(fmt.Sprintf("invalid okta domain %s: remove the path, such as https://%s", domain, u.Host))
+		}
+
+		host = u.Host
+	} else if i := strings.Index(host, "/"); i >= 0 {
+		if strings.Trim(host[i:], "/") != "" {
+			return "", errors.New(fmt.Sprintf("invalid okta domain %s: remove the path, such as %s", domain, host[:i]))
+		}
+
+		host = host[:i]
+	}
+
+	if strings.Contains(host, ":") {
+		return "", errors.New(fmt.Sprintf("invalid okta domain %s: remove the port", domain))
+	}
+
+	if !hostPattern.MatchString(host) {
+		return "", errors.New(fmt.Sprintf("invalid okta domain %s: not a host name such as acme.okta.com", domain))
+	}
+
+	// The admin console is served from {org}-admin, while the API is only served from the org domain
+	for _, suffix := range oktaDomainSuffixes {
+		org := strings.TrimSuffix(host, suffix)
+		if org == host {
+			continue
+		}
+
+		if strings.HasSuffix(org, "-admin") {
+			return "", errors.New(fmt.Sprintf("invalid okta domain %s: use the org domain %s instead of the admin console domain", domain, strings.TrimSuffix(org, "-admin")+suffix))
+		}
+
+		if strings.Contains(org, ".") {
+			return "", errors.New(fmt.Sprintf("invalid okta domain %s: use the org domain, such as acme%s", domain, suffix))
+		}
+	}
+
+	return host, nil
+}
+
+// Validate a base URL of the API, such as https://acme.okta-gov.com or http://localhost:8080 for a mock server
+func ValidateBaseUrl(baseUrl string) error {
+	u, err := url.Parse(baseUrl)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return errors.New(fmt.Sprintf("unsupported scheme of %s, must be https or http", baseUrl))
+	}
+
+	if u.Host == "" {
+		return errors.New(fmt.Sprintf("missing host in %s", baseUrl))
+	}
+
+	if u.RawQuery != "" || u.Fragment != "" {
+		return errors.New(fmt.Sprintf("unexpected query in %s", baseUrl))
+	}
+
+	return nil
+}
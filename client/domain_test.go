@@ -0,0 +1,91 @@
+package client
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct {
+		name     string
+		domain   string
+		expected string
+	}{
+		{"bare host", "acme.okta.com", "acme.okta.com"},
+		{"https scheme", "https://acme.okta.com", "acme.okta.com"},
+		{"trailing slash", "acme.okta.com/", "acme.okta.com"},
+		{"https scheme and trailing slash", "https://acme.okta.com/", "acme.okta.com"},
+		{"uppercase and spaces", "  ACME.Okta.com ", "acme.okta.com"},
+		{"preview org", "acme-test.oktapreview.com", "acme-test.oktapreview.com"},
+		{"emea org", "https://acme.okta-emea.com", "acme.okta-emea.com"},
+		{"gov org", "acme.okta-gov.com", "acme.okta-gov.com"},
+		{"custom domain", "login.acme.com", "login.acme.com"},
+		{"custom domain ending in admin", "sso-admin.acme.com", "sso-admin.acme.com"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host, err := NormalizeDomain(test.domain)
+			if err != nil {
+				t.Fatalf("unable to normalize %s: %v", test.domain, err)
+			}
+
+			if host != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, host)
+			}
+		})
+	}
+}
+
+func TestNormalizeDomainInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		domain string
+		reason string
+	}{
+		{"empty", "", "empty okta domain"},
+		{"spaces", "   ", "empty okta domain"},
+		{"http scheme", "http://acme.okta.com", "only served over https"},
+		{"other scheme", "ftp://acme.okta.com", "only served over https"},
+		{"path with scheme", "https://acme.okta.com/admin/dashboard", "remove the path, such as https://acme.okta.com"},
+		{"path without scheme", "acme.okta.com/api/v1/logs", "remove the path, such as acme.okta.com"},
+		{"query", "https://acme.okta.com?fromURI=/app", "remove the path"},
+		{"port", "acme.okta.com:443", "remove the port"},
+		{"port with scheme", "https://acme.okta.com:8443", "remove the port"},
+		{"admin console", "acme-admin.okta.com", "use the org domain acme.okta.com"},
+		{"admin console with scheme", "https://acme-admin.oktapreview.com/", "use the org domain acme.oktapreview.com"},
+		{"subdomain of an org", "login.acme.okta.com", "use the org domain, such as acme.okta.com"},
+		{"not a host name", "acme_corp.okta.com", "not a host name"},
+		{"no top level domain", "acme", "not a host name"},
+		{"leading dash", "-acme.okta.com", "not a host name"},
+		{"empty label", "acme..okta.com", "not a host name"},
+		{"invalid url", "https://acme okta.com", "invalid okta domain"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host, err := NormalizeDomain(test.domain)
+			if err == nil {
+				t.Fatalf("expected %q to be invalid, got %s", test.domain, host)
+			}
+
+			if !strings.Contains(err.Error(), test.reason) {
+				t.Errorf("expected an error about %q, got %v", test.reason, err)
+			}
+		})
+	}
+}
+
+func TestValidateBaseUrl(t *testing.T) {
+	for _, baseUrl := range []string{"https://acme.okta-gov.com", "http://localhost:8080", "http://127.0.0.1:8080/"} {
+		if err := ValidateBaseUrl(baseUrl); err != nil {
+			t.Errorf("expected %s to be valid: %v", baseUrl, err)
+		}
+	}
+
+	for _, baseUrl := range []string{"acme.okta.com", "ftp://acme.okta.com", "https://", "https://acme.okta.com?debug=1", "https://acme.okta.com#logs"} {
+		if err := ValidateBaseUrl(baseUrl); err == nil {
+			t.Errorf("expected %s to be invalid", baseUrl)
+		}
+	}
+}
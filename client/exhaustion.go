@@ -0,0 +1,60 @@
+package client
+
+import (
+	"log"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// Rate limit of an endpoint that dropped below the rate limit warning percent param
+type RateLimitWarning struct {
+	// Host and path of the endpoint, with the ids of objects replaced with {id}
+	Endpoint string
+	// Rate limit reported by the response that dropped below the percent
+	RateLimit OktaRateLimit
+	// Rate limit warning percent param the rate limit dropped below
+	Percent int
+}
+
+// Endpoints below the warning percent, warned about once until their rate limit recovers, and warnings not taken yet
+var rateLimitWarnings = struct {
+	mu       sync.Mutex
+	below    map[string]bool
+	warnings []RateLimitWarning
+}{below: make(map[string]bool)}
+
+// Warn once when the remaining rate limit of an endpoint drops below the rate limit warning percent param
+func checkRateLimitExhaustion(endpoint string, rateLimit OktaRateLimit) {
+	percent := viper.GetInt("rate-limit-warning-percent")
+	if percent <= 0 || rateLimit.Limit == 0 {
+		return
+	}
+
+	rateLimitWarnings.mu.Lock()
+	defer rateLimitWarnings.mu.Unlock()
+
+	if !rateLimit.Below(float64(percent) / 100) {
+		delete(rateLimitWarnings.below, endpoint)
+		return
+	}
+
+	if rateLimitWarnings.below[endpoint] {
+		return
+	}
+	rateLimitWarnings.below[endpoint] = true
+
+	log.Printf("WARNING: %d of %d requests of the rate limit of Okta API endpoint %s remain until %s, below %d%%\n",
+		rateLimit.Remaining, rateLimit.Limit, endpoint, rateLimit.Reset.UTC().Format("15:04:05 MST"), percent)
+	rateLimitWarnings.warnings = append(rateLimitWarnings.warnings, RateLimitWarning{Endpoint: endpoint, RateLimit: rateLimit, Percent: percent})
+}
+
+// Take the warnings of rate limits that dropped below the rate limit warning percent param since they were last taken
+func TakeRateLimitWarnings() []RateLimitWarning {
+	rateLimitWarnings.mu.Lock()
+	defer rateLimitWarnings.mu.Unlock()
+
+	warnings := rateLimitWarnings.warnings
+	rateLimitWarnings.warnings = nil
+	return warnings
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// A group of the Okta org
+type OktaGroup struct {
+	Id      string `json:"id"`
+	Type    string `json:"type"`
+	Profile struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	} `json:"profile"`
+}
+
+// Get the groups of the org
+func (oktaClient *OktaClient) GetGroups() ([]OktaGroup, error) {
+	var groups []OktaGroup
+	err := oktaClient.listAll("/api/v1/groups", url.Values{}, func(body []byte) error {
+		var page []OktaGroup
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		groups = append(groups, page...)
+		return nil
+	})
+
+	return groups, err
+}
+
+// Get the IDs of the members of a group
+func (oktaClient *OktaClient) GetGroupMemberIds(groupId string) ([]string, error) {
+	var ids []string
+	err := oktaClient.listAll("/api/v1/groups/"+url.PathEscape(groupId)+"/users", url.Values{}, func(body []byte) error {
+		var page []OktaUser
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		for _, user := range page {
+			ids = append(ids, user.Id)
+		}
+		return nil
+	})
+
+	return ids, err
+}
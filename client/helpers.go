@@ -1,7 +1,6 @@
 package client
 
 import (
-	"encoding/json"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -35,21 +34,3 @@ func getResultsOffset(resp *http.Response) string {
 
 	return ""
 }
-
-func convertLogsToString(items []interface{}) ([]string, error) {
-	var data []string
-	for _, val := range items {
-		// Convert item to json byte array
-		plain, err := json.Marshal(val)
-
-		// Handle error
-		if err != nil {
-			return nil, err
-		}
-
-		// Add string to array
-		data = append(data, string(plain))
-	}
-
-	return data, nil
-}
\ No newline at end of file
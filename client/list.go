@@ -0,0 +1,36 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// Limit of list requests other than the System Log
+const listLimit = "200"
+
+// Get every page of a list endpoint, passing the body of each page to handle
+func (oktaClient *OktaClient) listAll(uri string, params url.Values, handle func(body []byte) error) error {
+	params.Set("limit", listLimit)
+
+	for {
+		// Call request
+		response, body, err := oktaClient.conductRequest("GET", uri, params)
+
+		// Handle error
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error conducting request: %v\n", err))
+		}
+
+		if err := handle(body); err != nil {
+			return errors.New(fmt.Sprintf("Error unmarshalling response body: %v\n", err))
+		}
+
+		// Get next page of results
+		afterLink := getResultsOffset(response)
+		if afterLink == "" {
+			return nil
+		}
+		params.Set("after", afterLink)
+	}
+}
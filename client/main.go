@@ -4,10 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/rfizzle/okta-collector/scrub"
 	"github.com/spf13/viper"
-	"github.com/tidwall/pretty"
 	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/url"
 	"strings"
@@ -23,18 +24,32 @@ const (
 	limit             = "1000"
 )
 
+// User-Agent sent with every request, set by the collector to include its version
+var UserAgent = "okta-collector"
+
 // Okta client struct
+// TokenSource, when set, is read for every request so rotated tokens are used without a restart.
+// BaseUrl, when set, replaces https://{Domain} as the base of request URLs, such as for a mock server.
+// Deadline, when set, stops paging through logs once it passed.
+// Tokens, when set, rotates requests across several tokens instead of the token of the client.
 type OktaClient struct {
 	Domain      string
-	Token       string
+	BaseUrl     string
+	token       scrub.Secret
+	Filter      string
+	Deadline    time.Time
+	TokenSource func() string
+	Tokens      *TokenRotation
 	httpClient  *http.Client
 }
 
 // Create a new client with the okta domain and token and a http client with a 10 seconds timeout
+// The base URL is set from the API base URL param.
 func NewClient(domain, token string) *OktaClient {
 	return &OktaClient{
-		Domain: domain,
-		Token:  token,
+		Domain:  domain,
+		BaseUrl: viper.GetString("api-base-url"),
+		token:   scrub.Secret(token),
 		httpClient: &http.Client{
 			Timeout: time.Second * 10,
 		},
@@ -43,84 +58,118 @@ func NewClient(domain, token string) *OktaClient {
 
 // Get logs method with paged results logic
 // Events are streamed into the results channel
-func (oktaClient *OktaClient) GetLogs(startTime string, endTime string, resultsChannel chan<- string) (int, error) {
+// When the deadline passed before the last page, returns the published time of the last event to resume from.
+// At least the first page is always read, so collection progresses however short the deadline.
+func (oktaClient *OktaClient) GetLogs(startTime string, endTime string, resultsChannel chan<- []byte) (int, string, error) {
 	// Setup variables
 	count := 0
 	afterLink := ""
 	hasNext := true
+	lastPublished := ""
 
 	// Setup request
 	params := url.Values{}
 	params.Set("limit", limit)
 	params.Set("since", startTime)
 	params.Set("until", endTime)
+	if oktaClient.Filter != "" {
+		params.Set("filter", oktaClient.Filter)
+	}
 
 	// Handle paged responses
 	for hasNext {
 		// Get logs
-		events, newAfterLink, err := oktaClient.getLogsRequest(params, afterLink)
+		pageCount, pagePublished, newAfterLink, err := oktaClient.getLogsRequest(params, afterLink, resultsChannel)
 
 		// Handle error
 		if err != nil {
-			return -1, err
-		}
-
-		// Send events to channel
-		for _, event := range events {
-			// Ugly print the json into a single lined string
-			resultsChannel <- string(pretty.Ugly([]byte(event)))
+			return -1, "", err
 		}
 
 		// Increment count
-		count += len(events)
+		count += pageCount
+		if pagePublished != "" {
+			lastPublished = pagePublished
+		}
 
 		// Set afterLink
 		hasNext = newAfterLink != ""
 		afterLink = newAfterLink
+
+		// Stop at the deadline, resuming from the last event
+		if hasNext && lastPublished != "" && !oktaClient.Deadline.IsZero() && time.Now().After(oktaClient.Deadline) {
+			return count, lastPublished, nil
+		}
 	}
 
-	return count, nil
+	return count, "", nil
 }
 
 // Individual get logs request method
-func (oktaClient *OktaClient) getLogsRequest(params url.Values, afterLink string) ([]string, string, error) {
-	// Set variables
-	var events []string
-	var tmpEventsRaw []interface{}
-
+// Events are decoded as the response body is read, rather than holding the whole body in memory, and sent to the
+// results channel once the response is closed, so outputs holding back collection never stall the request.
+// Pages that can't be decoded, such as when the connection dropped, sent no events and are requested again.
+// Returns the number of events of the page and the published time of its last event. Errors other than rejected
+// credentials are *TransientError, since the page can be collected again later.
+func (oktaClient *OktaClient) getLogsRequest(params url.Values, afterLink string, resultsChannel chan<- []byte) (int, string, string, error) {
 	// Set next link
 	if afterLink != "" {
 		params.Set("after", afterLink)
 	}
 
-	// Call request
-	response, body, err := oktaClient.conductRequest("GET", "/api/v1/logs", params)
+	backoffMs := initialBackoffMS
+	var response *http.Response
+	var events [][]byte
+	for {
+		// Call request
+		var err error
+		response, err = oktaClient.conductStreamingRequest("GET", "/api/v1/logs", params)
+
+		// Handle error, keeping credentials errors as they are so collection can tell them apart
+		if IsCredentialsError(err) {
+			return 0, "", "", err
+		} else if err != nil {
+			return 0, "", "", &TransientError{Err: errors.New(fmt.Sprintf("Error conducting request: %v\n", err))}
+		}
 
-	// Handle error
-	if err != nil {
-		return nil, "", errors.New(fmt.Sprintf("Error conducting request: %v\n", err))
-	}
+		// Rate limited after every retry
+		if response.StatusCode != 200 {
+			_ = response.Body.Close()
+			return 0, "", "", &TransientError{Err: errors.New(fmt.Sprintf("Error conducting request: HTTP response code: %v\n", response.Status))}
+		}
 
-	// Convert from JSON
-	err = json.Unmarshal(body, &tmpEventsRaw)
+		// Decode events from JSON
+		events, err = decodeEvents(response.Body)
+		_ = response.Body.Close()
+		if err == nil {
+			break
+		}
 
-	// Handle error
-	if err != nil {
-		return nil, "", errors.New(fmt.Sprintf("Error unmarshalling response body: %v\n", err))
+		// Handle error, requesting the page again until the backoff is exhausted
+		if backoffMs > maxBackoffMS {
+			return 0, "", "", &TransientError{Err: errors.New(fmt.Sprintf("Error unmarshalling response body: %v\n", err))}
+		}
+
+		log.Printf("Unable to read page of events, requesting it again in %v: %v\n", time.Duration(backoffMs)*time.Millisecond, err)
+		time.Sleep(time.Millisecond * time.Duration(backoffMs))
+		backoffMs *= backoffFactor
 	}
 
-	// Convert to strings
-	events, err = convertLogsToString(tmpEventsRaw)
+	// Published time of the last event, read before the receiver owns it
+	lastPublished := ""
+	if len(events) > 0 {
+		lastPublished = publishedOf(events[len(events)-1])
+	}
 
-	// Handle error
-	if err != nil {
-		return nil, "", errors.New(fmt.Sprintf("Error converting logs to strings: %v\n", err))
+	// Send events to channel, blocking while it is full
+	for _, event := range events {
+		resultsChannel <- event
 	}
 
 	// Get next page of results
 	newAfterLink := getResultsOffset(response)
 
-	return events, newAfterLink, nil
+	return len(events), lastPublished, newAfterLink, nil
 }
 
 // Make an Okta API call.
@@ -130,6 +179,28 @@ func (oktaClient *OktaClient) getLogsRequest(params url.Values, afterLink string
 //
 // Example: oktaClient.CallRequest("GET", "/auth/v2/check", nil)
 func (oktaClient *OktaClient) conductRequest(method string, uri string, params url.Values) (*http.Response, []byte, error) {
+	// Make the call
+	response, err := oktaClient.conductStreamingRequest(method, uri, params)
+
+	// Handle error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Read the body
+	body, err := ioutil.ReadAll(response.Body)
+	_ = response.Body.Close()
+
+	// Handle error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return response, body, nil
+}
+
+// Make an Okta API call, returning the response with its body unread for the caller to read and close
+func (oktaClient *OktaClient) conductStreamingRequest(method string, uri string, params url.Values) (*http.Response, error) {
 	// Build the URL
 	urlObj := url.URL{
 		Scheme: "https",
@@ -137,6 +208,16 @@ func (oktaClient *OktaClient) conductRequest(method string, uri string, params u
 		Path:   uri,
 	}
 
+	if oktaClient.BaseUrl != "" {
+		base, err := url.Parse(oktaClient.BaseUrl)
+		if err != nil {
+			return nil, err
+		}
+
+		urlObj = *base
+		urlObj.Path = strings.TrimRight(base.Path, "/") + uri
+	}
+
 	// Convert method to uppercase
 	method = strings.ToUpper(method)
 
@@ -147,14 +228,15 @@ func (oktaClient *OktaClient) conductRequest(method string, uri string, params u
 
 	// Log for debugging
 	if viper.GetBool("verbose") {
-		fmt.Printf("Calling URL: %s\n", urlObj.String())
+		fmt.Print(scrub.String(fmt.Sprintf("Calling URL: %s\n", urlObj.String())))
 	}
 
 	// Setup headers
 	headers := make(map[string]string)
 	headers["Accept"] = "application/json"
-	headers["Authorization"] = fmt.Sprintf("SSWS %s", oktaClient.Token)
+	headers["Authorization"] = fmt.Sprintf("SSWS %s", oktaClient.currentToken())
 	headers["Content-Type"] = "application/json"
+	headers["User-Agent"] = UserAgent
 
 	// JSON marshal body if POST or PUT
 	var requestBody io.ReadCloser = nil
@@ -165,23 +247,26 @@ func (oktaClient *OktaClient) conductRequest(method string, uri string, params u
 	}
 
 	// Make a retryable HTTP call
-	response, body, err := oktaClient.makeRetryableHttpCall(method, urlObj, headers, requestBody)
+	return oktaClient.makeRetryableHttpCall(method, urlObj, headers, requestBody)
+}
 
-	// Handle error
-	if err != nil {
-		return nil, nil, err
+// Current API token of the client
+func (oktaClient *OktaClient) currentToken() string {
+	if oktaClient.TokenSource != nil {
+		return oktaClient.TokenSource()
 	}
 
-	return response, body, nil
+	return string(oktaClient.token)
 }
 
 // Make a retryable HTTP call. Supports APIs that return a 429 for too many requests
+// The body of the returned response is unread, and must be closed by the caller.
 func (oktaClient *OktaClient) makeRetryableHttpCall(
 	method string,
 	url url.URL,
 	headers map[string]string,
 	body io.ReadCloser,
-) (*http.Response, []byte, error) {
+) (*http.Response, error) {
 	backoffMs := initialBackoffMS
 	for {
 		// Setup new request
@@ -189,7 +274,7 @@ func (oktaClient *OktaClient) makeRetryableHttpCall(
 
 		// Handle error
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		// Setup headers
@@ -204,24 +289,53 @@ func (oktaClient *OktaClient) makeRetryableHttpCall(
 			request.Body = body
 		}
 
-		// Conduct request
+		// Rotate across tokens per request, so retries of rate limited requests use another token
+		endpoint := endpointOf(request.URL)
+		token := -1
+		if oktaClient.Tokens != nil {
+			var value string
+			value, token = oktaClient.Tokens.pick(endpoint)
+			request.Header.Set("Authorization", fmt.Sprintf("SSWS %s", value))
+		}
+
+		// Conduct request, tracing its timings when debugging HTTP
+		request, trace := traceRequest(request)
 		resp, err := oktaClient.httpClient.Do(request)
-		var body []byte
+		received := time.Now()
+		trace.log(request, resp, err)
+
+		// Record the raw response for debugging, the rate limit it used, the clock of Okta and deprecations
+		if err == nil {
+			captureResponse(request, resp)
+			recordRateLimit(request, resp)
+			recordClockSkew(resp, received)
+			recordDeprecation(request, resp)
+			if token >= 0 {
+				oktaClient.Tokens.record(token, endpoint, rateLimitFromResponse(resp))
+			}
+		}
+
+		// Rejected credentials fail every retry until the token is rotated
+		if err == nil && rejectsCredentials(resp) {
+			credentialsErr := credentialsErrorFromResponse(resp)
+			_ = resp.Body.Close()
+			return resp, credentialsErr
+		}
 
 		// Handle error or failed response status code
 		if err != nil || (resp.StatusCode != 200 && resp.StatusCode != rateLimitHttpCode) {
 			if err == nil {
-				return resp, body, errors.New(fmt.Sprintf("HTTP response code: %v\n", resp.Status))
+				_ = resp.Body.Close()
+				return resp, errors.New(fmt.Sprintf("HTTP response code: %v\n", resp.Status))
 			}
-			return resp, body, err
+			return resp, err
 		}
 
 		// Handle rate limit code
 		if backoffMs > maxBackoffMS || resp.StatusCode != rateLimitHttpCode {
-			body, err = ioutil.ReadAll(resp.Body)
-			_ = resp.Body.Close()
-			return resp, body, err
+			return resp, nil
 		}
+		_ = resp.Body.Close()
 
 		time.Sleep(time.Millisecond * time.Duration(backoffMs))
 		backoffMs *= backoffFactor
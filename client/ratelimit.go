@@ -0,0 +1,133 @@
+package client
+
+import (
+	"expvar"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/metrics"
+)
+
+// Rate limit of an Okta API endpoint, as reported by the last response
+type OktaRateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// Parse the rate limit headers of a response
+// Returns a zero rate limit when the headers are missing.
+func rateLimitFromResponse(response *http.Response) OktaRateLimit {
+	var rateLimit OktaRateLimit
+	if response == nil {
+		return rateLimit
+	}
+
+	rateLimit.Limit, _ = strconv.Atoi(response.Header.Get("X-Rate-Limit-Limit"))
+	rateLimit.Remaining, _ = strconv.Atoi(response.Header.Get("X-Rate-Limit-Remaining"))
+	if reset, err := strconv.ParseInt(response.Header.Get("X-Rate-Limit-Reset"), 10, 64); err == nil {
+		rateLimit.Reset = time.Unix(reset, 0)
+	}
+
+	return rateLimit
+}
+
+// Check if less than a fraction of the rate limit remains until it resets
+// Lookups that enrich events back off below it so they never starve log collection.
+func (rateLimit OktaRateLimit) Below(fraction float64) bool {
+	if rateLimit.Limit == 0 || time.Now().After(rateLimit.Reset) {
+		return false
+	}
+
+	return float64(rateLimit.Remaining) < float64(rateLimit.Limit)*fraction
+}
+
+// Usage of the rate limit of an Okta API endpoint by the collector since the usage was last taken
+type RateLimitUsage struct {
+	// Host and path of the endpoint, with the ids of objects replaced with {id}
+	Endpoint string
+	// Rate limit reported by the last response of the endpoint
+	RateLimit OktaRateLimit
+	// Requests of the collector to the endpoint
+	Requests int
+	// Requests of the collector answered with a 429
+	RateLimited int
+}
+
+// Path segments of Okta object ids, such as 00u1a2b3c4d5e6f7g8h9
+var objectIdPattern = regexp.MustCompile(`^[0-9A-Za-z]{20}$`)
+
+// Rate limit usage of every endpoint requested since the usage was last taken
+var rateLimitUsage = struct {
+	mu        sync.Mutex
+	endpoints map[string]*RateLimitUsage
+}{endpoints: make(map[string]*RateLimitUsage)}
+
+// Record the rate limit of the response of a request to an endpoint
+func recordRateLimit(request *http.Request, response *http.Response) {
+	endpoint := endpointOf(request.URL)
+
+	rateLimitUsage.mu.Lock()
+	defer rateLimitUsage.mu.Unlock()
+
+	usage, ok := rateLimitUsage.endpoints[endpoint]
+	if !ok {
+		usage = &RateLimitUsage{Endpoint: endpoint}
+		rateLimitUsage.endpoints[endpoint] = usage
+	}
+
+	usage.Requests++
+	if response.StatusCode == rateLimitHttpCode {
+		usage.RateLimited++
+	}
+
+	// Keep the last known rate limit when a response has no rate limit headers
+	if rateLimit := rateLimitFromResponse(response); rateLimit.Limit > 0 {
+		usage.RateLimit = rateLimit
+		setRateLimitGauges(endpoint, rateLimit)
+		checkRateLimitExhaustion(endpoint, rateLimit)
+	}
+}
+
+// Publish the latest rate limit of an endpoint as gauges
+func setRateLimitGauges(endpoint string, rateLimit OktaRateLimit) {
+	limit, remaining := new(expvar.Int), new(expvar.Int)
+	limit.Set(int64(rateLimit.Limit))
+	remaining.Set(int64(rateLimit.Remaining))
+
+	metrics.RateLimitLimit.Set(endpoint, limit)
+	metrics.RateLimitRemaining.Set(endpoint, remaining)
+}
+
+// Take the rate limit usage of every endpoint requested since the usage was last taken, ordered by endpoint
+func TakeRateLimitUsage() []RateLimitUsage {
+	rateLimitUsage.mu.Lock()
+	defer rateLimitUsage.mu.Unlock()
+
+	var usages []RateLimitUsage
+	for _, usage := range rateLimitUsage.endpoints {
+		usages = append(usages, *usage)
+	}
+	rateLimitUsage.endpoints = make(map[string]*RateLimitUsage)
+
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Endpoint < usages[j].Endpoint })
+	return usages
+}
+
+// Endpoint of a request URL, which Okta rate limits share, such as acme.okta.com/api/v1/users/{id}
+func endpointOf(u *url.URL) string {
+	segments := strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		if objectIdPattern.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+
+	return u.Host + strings.Join(segments, "/")
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// Rotates requests across several API tokens, tracking the rate limit of every token by endpoint, to raise the
+// effective rate limit of high-volume orgs
+// Tokens are used in turn, skipping tokens whose rate limit of the endpoint is used up until it resets.
+type TokenRotation struct {
+	mu         sync.Mutex
+	sources    []func() string
+	next       int
+	rateLimits []map[string]OktaRateLimit
+}
+
+// Create a rotation of tokens, read from their sources for every request so rotated tokens are used without a restart
+func NewTokenRotation(sources ...func() string) *TokenRotation {
+	rotation := &TokenRotation{sources: sources}
+	for range sources {
+		rotation.rateLimits = append(rotation.rateLimits, make(map[string]OktaRateLimit))
+	}
+
+	return rotation
+}
+
+// Pick the token of the next request to an endpoint, returning it and its index
+// When every token is used up, the token whose rate limit resets first is picked.
+func (rotation *TokenRotation) pick(endpoint string) (string, int) {
+	rotation.mu.Lock()
+	defer rotation.mu.Unlock()
+
+	picked := -1
+	var earliestReset time.Time
+	for i := 0; i < len(rotation.sources); i++ {
+		index := (rotation.next + i) % len(rotation.sources)
+		rateLimit := rotation.rateLimits[index][endpoint]
+		if !rateLimit.exhausted() {
+			picked = index
+			break
+		}
+
+		if picked == -1 || rateLimit.Reset.Before(earliestReset) {
+			picked, earliestReset = index, rateLimit.Reset
+		}
+	}
+
+	rotation.next = (picked + 1) % len(rotation.sources)
+	return rotation.sources[picked](), picked
+}
+
+// Record the rate limit of the response to a request to an endpoint with a token
+func (rotation *TokenRotation) record(index int, endpoint string, rateLimit OktaRateLimit) {
+	if rateLimit.Limit == 0 {
+		return
+	}
+
+	rotation.mu.Lock()
+	defer rotation.mu.Unlock()
+
+	rotation.rateLimits[index][endpoint] = rateLimit
+}
+
+// Check if none of the rate limit remains until it resets
+func (rateLimit OktaRateLimit) exhausted() bool {
+	return rateLimit.Limit > 0 && rateLimit.Remaining <= 0 && time.Now().Before(rateLimit.Reset)
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Clock skew of the collector against Okta, measured from the Date header of the latest response
+var clockSkew struct {
+	mu       sync.Mutex
+	skew     time.Duration
+	measured bool
+}
+
+// Measure the clock skew from the Date header of a response received at a local time
+// The Date header is truncated to the second, so its middle is compared and skews under a second aren't measured.
+func recordClockSkew(response *http.Response, received time.Time) {
+	date, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+
+	clockSkew.mu.Lock()
+	defer clockSkew.mu.Unlock()
+
+	clockSkew.skew = date.Add(500 * time.Millisecond).Sub(received).Round(time.Second)
+	clockSkew.measured = true
+}
+
+// Clock skew of the collector against Okta, the time of Okta minus the local time, positive when the local clock is
+// behind
+// Returns false until a response with a Date header was received.
+func ClockSkew() (time.Duration, bool) {
+	clockSkew.mu.Lock()
+	defer clockSkew.mu.Unlock()
+
+	return clockSkew.skew, clockSkew.measured
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// An API token of the Okta org
+type OktaApiToken struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	UserId string `json:"userId"`
+}
+
+// Get the API tokens of the org
+func (oktaClient *OktaClient) GetApiTokens() ([]OktaApiToken, error) {
+	var tokens []OktaApiToken
+
+	// Call request
+	_, body, err := oktaClient.conductRequest("GET", "/api/v1/api-tokens", url.Values{})
+
+	// Handle error
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error conducting request: %v\n", err))
+	}
+
+	// Convert from JSON
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, errors.New(fmt.Sprintf("Error unmarshalling response body: %v\n", err))
+	}
+
+	return tokens, nil
+}
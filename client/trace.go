@@ -0,0 +1,146 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/scrub"
+	"github.com/spf13/viper"
+)
+
+// Timings of the phases of a request, traced when debugging HTTP
+type requestTrace struct {
+	mu sync.Mutex
+
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	reused       bool
+	remoteAddr   string
+	tlsVersion   uint16
+}
+
+// Trace the request when debugging HTTP, returning the request to send and its trace, or nil when not debugging
+func traceRequest(request *http.Request) (*http.Request, *requestTrace) {
+	if !viper.GetBool("debug-http") {
+		return request, nil
+	}
+
+	t := &requestTrace{start: time.Now()}
+	clientTrace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone) },
+		ConnectStart: func(network, addr string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			// Only the first dial is timed when several addresses are tried
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone:       func(network, addr string, err error) { t.mark(&t.connectDone) },
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			t.mark(&t.tlsDone)
+
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsVersion = state.Version
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			t.reused = info.Reused
+			if info.Conn != nil {
+				t.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.mark(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+	}
+
+	return request.WithContext(httptrace.WithClientTrace(request.Context(), clientTrace)), t
+}
+
+// Record the time of a phase
+func (t *requestTrace) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	*at = time.Now()
+}
+
+// Log the timings of a traced request once its response headers were read
+func (t *requestTrace) log(request *http.Request, response *http.Response, err error) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	phases := []string{fmt.Sprintf("conn=%s", t.connection())}
+	if t.remoteAddr != "" {
+		phases = append(phases, "addr="+t.remoteAddr)
+	}
+	phases = append(phases, phase("dns", t.dnsStart, t.dnsDone), phase("connect", t.connectStart, t.connectDone), phase("tls", t.tlsStart, t.tlsDone))
+	if t.tlsVersion != 0 {
+		phases = append(phases, "tls-version="+tlsVersionName(t.tlsVersion))
+	}
+	phases = append(phases, phase("ttfb", t.wroteRequest, t.firstByte), phase("total", t.start, time.Now()))
+
+	var status string
+	if err != nil {
+		status = fmt.Sprintf("error: %v", err)
+	} else {
+		status = response.Status
+	}
+
+	log.Print(scrub.String(fmt.Sprintf("HTTP %s %s %s: %s\n", request.Method, request.URL.String(), strings.Join(phases, " "), status)))
+}
+
+// Whether the connection of the request was new or reused
+func (t *requestTrace) connection() string {
+	if t.reused {
+		return "reused"
+	}
+	return "new"
+}
+
+// Format the duration of a phase, - for phases that didn't happen, such as dns and connect on reused connections
+func phase(name string, start time.Time, end time.Time) string {
+	if start.IsZero() || end.IsZero() {
+		return name + "=-"
+	}
+	return fmt.Sprintf("%s=%v", name, end.Sub(start).Round(time.Microsecond))
+}
+
+// Name of a TLS version
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "1.0"
+	case tls.VersionTLS11:
+		return "1.1"
+	case tls.VersionTLS12:
+		return "1.2"
+	case tls.VersionTLS13:
+		return "1.3"
+	default:
+		return fmt.Sprintf("0x%04x", version)
+	}
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"errors"
+)
+
+// Error of a page of events that couldn't be read, such as after a server error, exhausted rate limit retries or a body
+// cut short
+// The page sent no events, so collecting its window again from the same time loses no events.
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// Check if a request failed in a way that collecting again later may not
+func IsTransientError(err error) bool {
+	var transientErr *TransientError
+	return errors.As(err, &transientErr)
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// A user of the Okta org
+type OktaUser struct {
+	Id      string                 `json:"id"`
+	Status  string                 `json:"status"`
+	Profile map[string]interface{} `json:"profile"`
+}
+
+// Get a user by ID
+// Returns a nil user when the user doesn't exist, such as after it was deleted.
+func (oktaClient *OktaClient) GetUser(id string) (*OktaUser, OktaRateLimit, error) {
+	var user OktaUser
+
+	// Call request
+	response, body, err := oktaClient.conductRequest("GET", "/api/v1/users/"+url.PathEscape(id), url.Values{})
+	rateLimit := rateLimitFromResponse(response)
+
+	// Handle missing users
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return nil, rateLimit, nil
+	}
+
+	// Handle error
+	if err != nil {
+		return nil, rateLimit, errors.New(fmt.Sprintf("Error conducting request: %v\n", err))
+	}
+
+	// Convert from JSON
+	if err := json.Unmarshal(body, &user); err != nil {
+		return nil, rateLimit, errors.New(fmt.Sprintf("Error unmarshalling response body: %v\n", err))
+	}
+
+	return &user, rateLimit, nil
+}
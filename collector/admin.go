@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/admin"
+	"github.com/rfizzle/okta-collector/metrics"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Replacement of secrets in the effective config
+const maskedSecret = "[REDACTED]"
+
+// Status of a running collector, read by the management API while Run updates it
+type runStatus struct {
+	mu      sync.Mutex
+	status  admin.Status
+	paused  bool
+	resumed chan struct{}
+}
+
+// Current status of the collector
+func (c *Collector) Status() admin.Status {
+	c.run.mu.Lock()
+	defer c.run.mu.Unlock()
+
+	status := c.run.status
+	status.Health = metrics.Health.Value()
+	if c.run.paused {
+		status.State = admin.StatePaused
+	}
+
+	return status
+}
+
+// Pause collection once the current poll ended, until resumed
+func (c *Collector) Pause() {
+	c.run.mu.Lock()
+	defer c.run.mu.Unlock()
+
+	if c.run.paused {
+		return
+	}
+
+	log.Println("Collection paused")
+	c.run.paused = true
+	c.run.status.PausedSince = time.Now()
+	c.run.resumed = make(chan struct{})
+}
+
+// Resume paused collection
+func (c *Collector) Resume() {
+	c.run.mu.Lock()
+	defer c.run.mu.Unlock()
+
+	if !c.run.paused {
+		return
+	}
+
+	log.Println("Collection resumed")
+	c.run.paused = false
+	c.run.status.PausedSince = time.Time{}
+	close(c.run.resumed)
+}
+
+// Effective config of every param, with secrets masked
+func (c *Collector) Config() map[string]interface{} {
+	return EffectiveConfig()
+}
+
+// Effective config of every param, with secrets masked
+func EffectiveConfig() map[string]interface{} {
+	effective := make(map[string]interface{})
+	flag.VisitAll(func(f *flag.Flag) {
+		value := viper.Get(f.Name)
+		if contains(SecretParams, f.Name) || contains(DsnParams, f.Name) {
+			if s, ok := value.(string); ok && s != "" {
+				value = maskedSecret
+			}
+		}
+		if contains(SecretListParams, f.Name) {
+			if values := viper.GetStringSlice(f.Name); len(values) > 0 {
+				masked := make([]string, len(values))
+				for i := range masked {
+					masked[i] = maskedSecret
+				}
+				value = masked
+			}
+		}
+		effective[f.Name] = value
+	})
+
+	return effective
+}
+
+// Update the status of the collector
+func (c *Collector) setStatus(update func(status *admin.Status)) {
+	c.run.mu.Lock()
+	defer c.run.mu.Unlock()
+
+	update(&c.run.status)
+}
+
+// Wait while collection is paused, or until the context is done
+func (c *Collector) awaitResume(ctx context.Context) error {
+	c.run.mu.Lock()
+	paused, resumed := c.run.paused, c.run.resumed
+	c.run.mu.Unlock()
+
+	if !paused {
+		return nil
+	}
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
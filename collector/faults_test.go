@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rfizzle/okta-collector/oktamock"
+)
+
+// Check if a receiver received every event at least once
+func receivedAll(receiver *oktamock.Receiver, uuids []string) bool {
+	received := receiver.Uuids()
+	for _, uuid := range uuids {
+		if received[uuid] == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestRunLosesNoEventsToFaults(t *testing.T) {
+	server := oktamock.NewServer(mockApiKey)
+	defer server.Close()
+
+	// Two pages of events each poll, with faults injected into a good part of the requests
+	uuids := addMockEvents(t, server, 1500)
+	server.SetFaults(oktamock.Faults{RateLimited: 0.1, ServerErrors: 0.25, Truncated: 0.25, Seed: 42})
+
+	// The http output retries 429s and exits on other errors, so the receiver only rate limits
+	receiver := oktamock.NewReceiver()
+	defer receiver.Close()
+	receiver.SetFaults(oktamock.Faults{RateLimited: 0.2, Seed: 42})
+
+	c := newMockCollector(t, server,
+		WithSchedule(time.Second),
+		WithParam("http", true),
+		WithParam("http-url", receiver.URL),
+		WithParam("http-max-items", 500),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Run(ctx)
+	}()
+
+	// Collect until every event was received, failing if a fault stopped collection
+	deadline := time.Now().Add(3 * time.Minute)
+	for !receivedAll(receiver, uuids) {
+		if time.Now().After(deadline) {
+			break
+		}
+
+		select {
+		case err := <-done:
+			t.Fatalf("collection stopped: %v", err)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected collection to stop with the context, got %v", err)
+	}
+
+	received := receiver.Uuids()
+	for _, uuid := range uuids {
+		if received[uuid] == 0 {
+			t.Errorf("event %s was lost", uuid)
+		}
+	}
+
+	// The faults must have been hit for the test to mean anything
+	serverFaults := server.InjectedFaults()
+	if serverFaults[oktamock.FaultServerError] == 0 || serverFaults[oktamock.FaultTruncated] == 0 {
+		t.Errorf("expected server errors and truncated pages to be injected, got %v", serverFaults)
+	}
+
+	t.Logf("injected faults: server %v, receiver %v", serverFaults, receiver.InjectedFaults())
+}
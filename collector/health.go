@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/rfizzle/okta-collector/metrics"
+	"github.com/spf13/viper"
+)
+
+// Actions when Okta rejects the credentials of the collector
+const (
+	credentialsInvalidFatal = "fatal"
+	credentialsInvalidWait  = "wait"
+)
+
+// Validate the on credentials invalid param
+func validateHealthParams() error {
+	switch viper.GetString("on-credentials-invalid") {
+	case credentialsInvalidFatal, credentialsInvalidWait:
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("invalid on credentials invalid param (--on-credentials-invalid): must be %s or %s", credentialsInvalidFatal, credentialsInvalidWait))
+	}
+}
+
+// Whether collection keeps running in a degraded state while credentials are rejected, awaiting rotated credentials
+func awaitCredentials() bool {
+	return viper.GetString("on-credentials-invalid") == credentialsInvalidWait
+}
+
+// Record whether Okta rejected the credentials of the latest poll in the health of the collector
+func (c *Collector) setCredentialsInvalid(invalid bool) {
+	if invalid {
+		metrics.SetHealth(metrics.HealthCredentialsInvalid)
+	} else if c.credentialsInvalid {
+		log.Println("Credentials accepted again, collection resumed")
+		metrics.SetHealth(metrics.HealthOk)
+	}
+
+	c.credentialsInvalid = invalid
+}
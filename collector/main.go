@@ -0,0 +1,510 @@
+// Package collector collects Okta System Log events and delivers them to the outputs, so Go services can embed
+// collection rather than running the okta-collector binary.
+//
+// The collector is configured by the same params as the binary, set with options or loaded from the environment:
+//
+//	c, err := collector.New(
+//		collector.WithOktaDomain("example.okta.com"),
+//		collector.WithOktaApiKey(apiKey),
+//		collector.WithStatePath("okta-state.json"),
+//		collector.WithParam("file", true),
+//		collector.WithParam("file-path", "okta-events.log"),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	return c.Run(ctx)
+//
+// Params are global, so a process runs a single collector.
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rfizzle/okta-collector/admin"
+	"github.com/rfizzle/okta-collector/client"
+	"github.com/rfizzle/okta-collector/detect"
+	"github.com/rfizzle/okta-collector/enrich"
+	"github.com/rfizzle/okta-collector/filter"
+	"github.com/rfizzle/okta-collector/fips"
+	"github.com/rfizzle/okta-collector/metrics"
+	"github.com/rfizzle/okta-collector/output"
+	"github.com/rfizzle/okta-collector/pool"
+	"github.com/rfizzle/okta-collector/quarantine"
+	"github.com/rfizzle/okta-collector/schema"
+	"github.com/rfizzle/okta-collector/secrets"
+	"github.com/rfizzle/okta-collector/tenants"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Collects events of the selected input, or of every Okta tenant, and delivers them to the outputs
+// Run, PollOnce and Backfill must not be called concurrently.
+type Collector struct {
+	offline    bool
+	ackTimeout time.Duration
+	handler    func(event []byte)
+
+	// Inputs collected by Run and PollOnce, restored from their state on first use
+	targets []*target
+	// Number of the latest poll ended
+	polls uint64
+	// Events delivered since the latest poll ended
+	delivered int64
+	// When rate limit usage was last written to the outputs
+	rateLimitUsageWritten time.Time
+	// Whether the clock skew against Okta exceeded the threshold when last checked
+	skewed bool
+	// Whether Okta rejected the credentials of an input in the latest poll
+	credentialsInvalid bool
+	// Status of Run, read and paused by the management API
+	run runStatus
+}
+
+// Option of a collector
+type Option func(c *Collector)
+
+// Set a param, such as an output param, like the flag of the same name
+func WithParam(name string, value interface{}) Option {
+	return func(c *Collector) {
+		viper.Set(name, value)
+	}
+}
+
+// Set the Okta domain param
+func WithOktaDomain(domain string) Option {
+	return WithParam("okta-domain", domain)
+}
+
+// Set the Okta API key param
+func WithOktaApiKey(key string) Option {
+	return WithParam("okta-api-key", key)
+}
+
+// Set the state path param
+func WithStatePath(path string) Option {
+	return WithParam("state-path", path)
+}
+
+// Set the schedule param, the time between polls of Run
+func WithSchedule(schedule time.Duration) Option {
+	return WithParam("schedule", int(schedule/time.Second))
+}
+
+// Call a handler with every event after filters and enrichments, before it is written to the outputs
+// The event is only valid during the call, so handlers keeping it must copy it.
+func WithEventHandler(handler func(event []byte)) Option {
+	return func(c *Collector) {
+		c.handler = handler
+	}
+}
+
+// Set how long PollOnce, Backfill and Flush wait for the outputs to acknowledge events (default 5m)
+func WithAckTimeout(timeout time.Duration) Option {
+	return func(c *Collector) {
+		c.ackTimeout = timeout
+	}
+}
+
+// Only deliver events passed to Deliver, without the Okta params being required
+func WithOffline() Option {
+	return func(c *Collector) {
+		c.offline = true
+	}
+}
+
+// Create a collector, validating its params and setting up its metrics, filters, enrichments, outputs, tenants and
+// detections
+func New(options ...Option) (*Collector, error) {
+	InitParams()
+	if err := viper.BindPFlags(flag.CommandLine); err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed parsing flags: %v", err))
+	}
+
+	c := &Collector{ackTimeout: 5 * time.Minute}
+	for _, option := range options {
+		option(c)
+	}
+
+	if err := LoadParams(c.offline); err != nil {
+		return nil, errors.New(fmt.Sprintf("initialization failed: %v", err))
+	}
+
+	if err := setup(); err != nil {
+		output.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Stop the output plugins of the collector, once it doesn't deliver events anymore
+func (c *Collector) Close() {
+	output.Close()
+}
+
+// Setup the packages of the collector
+func setup() error {
+	// Restrict TLS before any clients are created
+	if err := fips.Setup(); err != nil {
+		return err
+	}
+
+	// Keep secrets referenced by params up to date
+	if err := secrets.Setup(); err != nil {
+		return err
+	}
+
+	if err := metrics.Setup(); err != nil {
+		return err
+	}
+
+	if err := filter.Setup(); err != nil {
+		return err
+	}
+
+	if err := schema.Setup(); err != nil {
+		return err
+	}
+
+	if err := enrich.Setup(); err != nil {
+		return err
+	}
+
+	if err := output.Setup(); err != nil {
+		return err
+	}
+
+	if err := tenants.Setup(); err != nil {
+		return err
+	}
+
+	return detect.Setup()
+}
+
+// Collect events every schedule until the context is done
+// Returns the error of the context once it is done, or the error that stopped collection.
+func (c *Collector) Run(ctx context.Context) error {
+	if err := c.restoreTargets(); err != nil {
+		return err
+	}
+
+	// Serve the management API while collecting
+	stopAdmin, err := admin.Serve(c)
+	if err != nil {
+		return err
+	}
+	defer stopAdmin()
+
+	// Setup the pipeline for handling async messages
+	events := newPipeline()
+	go events.run(c.handle)
+	defer events.close()
+
+	// Stagger the first poll of collectors started together
+	c.setStatus(func(status *admin.Status) { status.State = admin.StateWaiting })
+	if err := sleep(ctx, scheduleJitter()); err != nil {
+		return err
+	}
+
+	for {
+		// Polls only start while collection isn't paused
+		if err := c.awaitResume(ctx); err != nil {
+			return err
+		}
+
+		c.setStatus(func(status *admin.Status) {
+			status.State = admin.StatePolling
+			status.LastPollStarted = time.Now()
+		})
+
+		// Windows that failed for a transient reason aren't recorded, so the next poll collects them again
+		if err := c.collect(ctx, events, awaitCredentials()); client.IsTransientError(err) {
+			log.Printf("Unable to collect, collecting again next poll: %v\n", err)
+		} else if err != nil {
+			return err
+		}
+
+		if err := c.endPoll(); err != nil {
+			return err
+		}
+
+		// Update state to the latest acknowledged window
+		for _, t := range c.targets {
+			saveAckedState(t)
+		}
+
+		acked := output.AckedPoll()
+		c.setStatus(func(status *admin.Status) {
+			status.State = admin.StateWaiting
+			status.Polls = c.polls
+			status.AckedPoll = acked
+			status.LastPollEnded = time.Now()
+		})
+
+		// Wait for x seconds until next poll, plus jitter
+		if err := sleep(ctx, time.Duration(viper.GetInt("schedule"))*time.Second+scheduleJitter()); err != nil {
+			return err
+		}
+	}
+}
+
+// Collect the events since the saved state once, saving the state once every output acknowledged them
+func (c *Collector) PollOnce(ctx context.Context) error {
+	if err := c.restoreTargets(); err != nil {
+		return err
+	}
+
+	events := newPipeline()
+	go events.run(c.handle)
+
+	err := c.collect(ctx, events, false)
+	events.close()
+	if err != nil {
+		return err
+	}
+
+	if err := c.Flush(ctx); err != nil {
+		return err
+	}
+
+	for _, t := range c.targets {
+		saveAckedState(t)
+	}
+
+	return nil
+}
+
+// Collect the events of a time range, of every tenant when tenants are configured, leaving the saved state as is
+func (c *Collector) Backfill(ctx context.Context, since time.Time, until time.Time) error {
+	targets, err := inputTargets()
+	if err != nil {
+		return err
+	}
+
+	events := newPipeline()
+	go events.run(c.handle)
+
+	log.Println("Getting data...")
+	for _, t := range targets {
+		if _, err := t.input.Poll(ctx, since, until, events.events); err != nil {
+			events.close()
+			return err
+		}
+	}
+	events.close()
+
+	return c.Flush(ctx)
+}
+
+// Filter, enrich and write an event to the outputs, without running detections
+// Takes ownership of the event, which must not be used after the call. Flush once the events were delivered.
+func (c *Collector) Deliver(event []byte) {
+	c.deliver(event)
+}
+
+// Flush the events delivered since the latest poll to the outputs and wait until every output acknowledged them
+func (c *Collector) Flush(ctx context.Context) error {
+	if err := c.endPoll(); err != nil {
+		return err
+	}
+
+	// Wait for batches still being delivered
+	deadline := time.Now().Add(c.ackTimeout)
+	for output.AckedPoll() < c.polls {
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for outputs to acknowledge events")
+		}
+
+		if err := sleep(ctx, time.Second); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Restore the state of the inputs to collect, once
+func (c *Collector) restoreTargets() error {
+	if c.targets != nil {
+		return nil
+	}
+
+	targets, err := collectionTargets()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error getting state: %v", err))
+	}
+
+	c.targets = targets
+	return nil
+}
+
+// Collect the next window of every input into the pipeline and wait until its events were handled
+// Windows are recorded under the next poll, so the state only advances once the outputs acknowledged it. Inputs whose
+// credentials are rejected are skipped when awaiting credentials, so they are collected from the same time once the
+// credentials were rotated.
+func (c *Collector) collect(ctx context.Context, events *pipeline, await bool) error {
+	log.Println("Getting data...")
+
+	// Get events of every input in turn, until the poll deadline
+	pollCtx, cancel := pollContext(ctx)
+	defer cancel()
+
+	credentialsInvalid := false
+	for _, t := range c.targets {
+		lastPollTime := c.now()
+		resume, err := t.input.Poll(pollCtx, t.since, lastPollTime, events.events)
+		if client.IsCredentialsError(err) {
+			credentialsInvalid = true
+			if await {
+				log.Printf("%v, awaiting rotated credentials\n", err)
+				continue
+			}
+		}
+		if err != nil {
+			c.setCredentialsInvalid(credentialsInvalid)
+			events.wait()
+			return err
+		}
+
+		// Windows cut short by the deadline end at the last collected event, so the next poll resumes from it
+		windowEnd := lastPollTime
+		if !resume.IsZero() {
+			log.Printf("Poll deadline of the %s input reached, resuming from %s next poll\n", t.input.Name(), resume.Format(time.RFC3339Nano))
+			windowEnd = resume
+		}
+		t.since = windowEnd
+		t.windows[c.polls+1] = windowEnd
+	}
+
+	// Warn about the clock skew measured by the requests of the poll
+	c.checkClockSkew()
+	c.setCredentialsInvalid(credentialsInvalid)
+
+	// Wait until every collected event was handled
+	events.wait()
+	return nil
+}
+
+// End the poll of the events delivered since the previous poll, flushing batches to the outputs
+func (c *Collector) endPoll() error {
+	c.polls++
+
+	// Record the rate limit usage of the poll with its events
+	c.writeRateLimitUsage()
+	c.writeRateLimitWarnings()
+
+	// Flush batches to outputs
+	if err := output.EndPoll(c.polls); err != nil {
+		return errors.New(fmt.Sprintf("Unable to write to output: %v", err))
+	}
+
+	// Persist detection baselines
+	if !output.DryRun() {
+		if err := detect.EndPoll(); err != nil {
+			log.Printf("Unable to save detection baselines: %v\n", err)
+		}
+	}
+
+	// Let know that event has been processes
+	log.Printf("%v events processed...\n", atomic.SwapInt64(&c.delivered, 0))
+
+	// Show what would have been shipped so far
+	if output.DryRun() {
+		output.PrintDryRunSummary(os.Stdout)
+	}
+
+	return nil
+}
+
+// Handle a collected event
+func (c *Collector) handle(message []byte) {
+	// Run detections on every collected event, before filters drop any
+	if err := detect.Event(message); err != nil {
+		log.Printf("Unable to run detections on event: %v\n", err)
+	}
+
+	c.deliver(message)
+}
+
+// Filter, enrich and write an event to the outputs
+// Takes ownership of the message, returning it to the pool once it is dropped or written.
+func (c *Collector) deliver(message []byte) {
+	atomic.AddInt64(&c.delivered, 1)
+
+	// Drop filtered events, shipping events that can't be parsed rather than losing them
+	keep, err := filter.Keep(message)
+	if err != nil {
+		log.Printf("Unable to filter event: %v\n", err)
+	} else if !keep {
+		pool.Put(message)
+		return
+	}
+
+	// Drop events filtered by the tenant they were collected from
+	tenant, err := tenants.Of(message)
+	if err != nil {
+		log.Printf("Unable to find tenant of event: %v\n", err)
+	} else if tenant != nil {
+		if keep, err := tenant.Keep(message); err != nil {
+			log.Printf("Unable to filter event of tenant %s: %v\n", tenant.Name, err)
+		} else if !keep {
+			pool.Put(message)
+			return
+		}
+	}
+
+	// Set aside events of unexpected shapes, keeping them out of strict-schema stores
+	if schema.Quarantines() {
+		if violations := schema.Validate(message); len(violations) > 0 {
+			quarantine.Event(message, "schema validation", errors.New(strings.Join(violations, "; ")))
+			pool.Put(message)
+			return
+		}
+	}
+
+	// Add collector derived fields, shipping the event as is if it can't be enriched
+	event, err := enrich.Event(message)
+	if err != nil {
+		log.Printf("Unable to enrich event: %v\n", err)
+		event = message
+	}
+
+	// The outputs own the event from here, so the collected event is released when enrichment replaced it
+	if !pool.Same(event, message) {
+		pool.Put(message)
+	}
+
+	if c.handler != nil {
+		c.handler(event)
+	}
+
+	// Route events of tenants to their outputs
+	if tenant != nil {
+		output.WriteEventTo(event, tenant.Outputs)
+		return
+	}
+
+	output.WriteEvent(event)
+}
+
+// Sleep for a duration, or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,154 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rfizzle/okta-collector/oktamock"
+	"github.com/spf13/viper"
+)
+
+// API key the mock Okta orgs of the tests accept
+const mockApiKey = "test-api-key"
+
+// Create a collector of a mock Okta org, with its state in a temp directory and the params of earlier tests cleared
+func newMockCollector(t *testing.T, server *oktamock.Server, options ...Option) *Collector {
+	t.Helper()
+	viper.Reset()
+
+	dir := t.TempDir()
+	options = append([]Option{
+		WithOktaApiKey(mockApiKey),
+		WithParam("api-base-url", server.URL),
+		WithStatePath(filepath.Join(dir, "collector.state")),
+		WithParam("dead-letter-dir", filepath.Join(dir, "dead-letter")),
+		WithParam("quarantine-path", filepath.Join(dir, "quarantine.ndjson")),
+	}, options...)
+
+	c, err := New(options...)
+	if err != nil {
+		t.Fatalf("unable to create collector: %v", err)
+	}
+
+	return c
+}
+
+// Add events published a second apart over the last hour to a mock Okta org
+// Returns the uuids of the events.
+func addMockEvents(t *testing.T, server *oktamock.Server, count int) []string {
+	t.Helper()
+
+	start := time.Now().Add(-time.Hour)
+	var uuids []string
+	var events []string
+	for i := 0; i < count; i++ {
+		uuid := fmt.Sprintf("evt-%05d", i)
+		uuids = append(uuids, uuid)
+		events = append(events, oktamock.NewEvent(uuid, "user.session.start", start.Add(time.Duration(i)*time.Second)))
+	}
+
+	if err := server.AddEvents(events...); err != nil {
+		t.Fatalf("unable to add events: %v", err)
+	}
+
+	return uuids
+}
+
+// Uuid of an event
+func uuidOf(event []byte) string {
+	var fields struct {
+		Uuid string `json:"uuid"`
+	}
+	_ = json.Unmarshal(event, &fields)
+
+	return fields.Uuid
+}
+
+func TestPollOnceFollowsNextLinksAndBacksOff(t *testing.T) {
+	server := oktamock.NewServer(mockApiKey)
+	defer server.Close()
+
+	// Three pages of 1000 events
+	uuids := addMockEvents(t, server, 2500)
+
+	// Answer the first requests with 429s, retried after backing off 1 and then 2 seconds
+	server.Throttle(2)
+
+	var mu sync.Mutex
+	received := make(map[string]int)
+	c := newMockCollector(t, server, WithEventHandler(func(event []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[uuidOf(event)]++
+	}))
+
+	started := time.Now()
+	if err := c.PollOnce(context.Background()); err != nil {
+		t.Fatalf("unable to poll: %v", err)
+	}
+
+	if elapsed := time.Since(started); elapsed < 3*time.Second {
+		t.Errorf("expected backing off at least 3s from 429s, polled in %v", elapsed)
+	}
+
+	// The last page has no next link, so paging stops there
+	if requests := server.Requests(); requests != 5 {
+		t.Errorf("expected 2 rate limited requests and 3 pages, got %d requests", requests)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(received) != len(uuids) {
+		t.Errorf("expected %d events, got %d", len(uuids), len(received))
+	}
+
+	for _, uuid := range uuids {
+		if received[uuid] != 1 {
+			t.Errorf("expected event %s once, got it %d times", uuid, received[uuid])
+		}
+	}
+}
+
+func TestPollOnceCollectsOnlyNewEvents(t *testing.T) {
+	server := oktamock.NewServer(mockApiKey)
+	defer server.Close()
+
+	uuids := addMockEvents(t, server, 10)
+
+	var mu sync.Mutex
+	var received []string
+	c := newMockCollector(t, server, WithEventHandler(func(event []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, uuidOf(event))
+	}))
+
+	if err := c.PollOnce(context.Background()); err != nil {
+		t.Fatalf("unable to poll: %v", err)
+	}
+
+	// Events published after the first poll are collected by the next one, from the saved state
+	time.Sleep(time.Second)
+	if err := server.AddEvents(oktamock.NewEvent("evt-new", "user.session.end", time.Now())); err != nil {
+		t.Fatalf("unable to add event: %v", err)
+	}
+	time.Sleep(time.Second)
+
+	if err := c.PollOnce(context.Background()); err != nil {
+		t.Fatalf("unable to poll: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := append(uuids, "evt-new")
+	if fmt.Sprint(received) != fmt.Sprint(expected) {
+		t.Errorf("expected events %v, got %v", expected, received)
+	}
+}
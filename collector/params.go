@@ -0,0 +1,297 @@
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rfizzle/collector-helpers/outputs"
+	"github.com/rfizzle/collector-helpers/state"
+	"github.com/rfizzle/okta-collector/admin"
+	"github.com/rfizzle/okta-collector/client"
+	"github.com/rfizzle/okta-collector/detect"
+	"github.com/rfizzle/okta-collector/enrich"
+	"github.com/rfizzle/okta-collector/filter"
+	"github.com/rfizzle/okta-collector/fips"
+	"github.com/rfizzle/okta-collector/input"
+	"github.com/rfizzle/okta-collector/metrics"
+	"github.com/rfizzle/okta-collector/output"
+	"github.com/rfizzle/okta-collector/quarantine"
+	"github.com/rfizzle/okta-collector/schema"
+	"github.com/rfizzle/okta-collector/scrub"
+	"github.com/rfizzle/okta-collector/secrets"
+	"github.com/rfizzle/okta-collector/tenants"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Register the params of the collector and its packages on the global flag set, once
+func InitParams() {
+	if flag.Lookup("schedule") != nil {
+		return
+	}
+
+	flag.Int("schedule", 30, "time in seconds to collect")
+	flag.Int("schedule-jitter", 0, "maximum random delay in seconds added before each poll")
+	flag.Int("poll-timeout", 0, "maximum time in seconds a poll pages through events before resuming next poll (0 disables)")
+	flag.String("okta-domain", "", "okta domain for organization")
+	flag.String("okta-api-key", "", "okta api key for authentication")
+	flag.String("okta-api-key-file", "", "file to read the okta api key from, or - for stdin")
+	flag.StringSlice("okta-api-keys", []string{}, "additional okta api keys of the org to rotate requests across with the okta api key")
+	flag.String("api-base-url", "", "base url of the okta api, such as http://localhost:8080 for a mock server (default https://{okta-domain})")
+	flag.Int("event-buffer-size", 5000, "collected events buffered before collection waits for them to be handled")
+	flag.Bool("sort-events", false, "handle the events of every poll in order of their published time")
+	flag.String("on-credentials-invalid", credentialsInvalidFatal, "action when okta rejects the api key, as invalid, expired or missing a scope (fatal, wait)")
+	flag.Int("clock-skew-threshold", 30, "time in seconds the local clock may be off the clock of okta before warning (0 disables)")
+	flag.Bool("clock-skew-compensate", false, "end collection windows at the time of okta rather than the local time")
+	flag.Int("rate-limit-usage-interval", 0, "time in seconds between records of okta api rate limit usage written to the outputs (0 disables)")
+	flag.Int("rate-limit-warning-percent", 0, "percent of an okta api rate limit remaining below which a warning event is written to the outputs (0 disables)")
+	flag.BoolP("verbose", "v", false, "verbose logging")
+	flag.Bool("debug-http", false, "log dns, connect, tls and time to first byte timings of okta api requests")
+	flag.String("capture-dir", "", "debug directory to write raw okta api responses to")
+	flag.Int("capture-max-files", 100, "most recent okta api responses kept in the capture directory (0 keeps all)")
+	input.InitCLIParams()
+	quarantine.InitCLIParams()
+	schema.InitCLIParams()
+	fips.InitCLIParams()
+	state.InitCLIParams()
+	outputs.InitCLIParams()
+	output.InitCLIParams()
+	filter.InitCLIParams()
+	enrich.InitCLIParams()
+	detect.InitCLIParams()
+	metrics.InitCLIParams()
+	secrets.InitCLIParams()
+	admin.InitCLIParams()
+}
+
+// Resolve and validate the params loaded into viper, such as from flags, env and the config file
+// Offline collectors, such as those only delivering saved events, don't require the Okta params.
+func LoadParams(offline bool) error {
+	// Read the API key from its file
+	if err := readApiKeyFile(); err != nil {
+		return err
+	}
+
+	// Replace params referencing secrets in external stores with the secrets
+	if err := secrets.Resolve(append(SecretParams, DsnParams...)); err != nil {
+		return err
+	}
+
+	if err := resolveApiKeys(); err != nil {
+		return err
+	}
+
+	// Scrub secrets from logs before anything can log them
+	registerSecrets()
+
+	// Check parameters
+	return validateParams(offline)
+}
+
+// Validate the params of the collector and its packages
+// Offline collectors, such as those only delivering saved events, don't require the Okta params.
+func validateParams(offline bool) error {
+	if err := input.ValidateCLIParams(); err != nil {
+		return err
+	}
+
+	// Tenants are Okta orgs collected instead of the org of the params
+	okta := input.Selected() == input.Okta
+	if !okta && tenants.Enabled() {
+		return errors.New(fmt.Sprintf("tenants can't be combined with the %s input (--input)", input.Selected()))
+	}
+
+	// Tenants have their own domain and API key, and requests go to the base URL when set
+	if !offline && okta && !tenants.Enabled() && viper.GetString("okta-domain") == "" && viper.GetString("api-base-url") == "" {
+		return errors.New("missing okta domain param (--okta-domain)")
+	}
+
+	if !offline && okta && !tenants.Enabled() && viper.GetString("okta-api-key") == "" {
+		return errors.New("missing okta api key param (--okta-api-key, --okta-api-key-file)")
+	}
+
+	// Additional API keys rotate with the API key of the org of the params
+	if keys := viper.GetStringSlice("okta-api-keys"); len(keys) > 0 {
+		if !okta || tenants.Enabled() {
+			return errors.New("okta api keys param (--okta-api-keys) only applies to the okta org of the params, without tenants")
+		}
+
+		for _, key := range keys {
+			if strings.TrimSpace(key) == "" {
+				return errors.New("invalid okta api keys param (--okta-api-keys): empty api key")
+			}
+		}
+	}
+
+	// Catch domain mistakes on startup rather than as failed requests
+	if domain := viper.GetString("okta-domain"); domain != "" {
+		normalized, err := client.NormalizeDomain(domain)
+		if err != nil {
+			return errors.New(fmt.Sprintf("invalid okta domain param (--okta-domain): %v", err))
+		}
+		viper.Set("okta-domain", normalized)
+	}
+
+	if baseUrl := viper.GetString("api-base-url"); baseUrl != "" {
+		if err := client.ValidateBaseUrl(baseUrl); err != nil {
+			return errors.New(fmt.Sprintf("invalid api base url param (--api-base-url): %v", err))
+		}
+	}
+
+	if viper.GetInt("poll-timeout") < 0 {
+		return errors.New("invalid poll timeout param (--poll-timeout): must not be negative")
+	}
+
+	if viper.GetInt("schedule-jitter") < 0 {
+		return errors.New("invalid schedule jitter param (--schedule-jitter): must not be negative")
+	}
+
+	if err := validatePipelineParams(); err != nil {
+		return err
+	}
+
+	if err := validateHealthParams(); err != nil {
+		return err
+	}
+
+	if viper.GetInt("clock-skew-threshold") < 0 {
+		return errors.New("invalid clock skew threshold param (--clock-skew-threshold): must not be negative")
+	}
+
+	if viper.GetInt("rate-limit-usage-interval") < 0 {
+		return errors.New("invalid rate limit usage interval param (--rate-limit-usage-interval): must not be negative")
+	}
+
+	if percent := viper.GetInt("rate-limit-warning-percent"); percent < 0 || percent > 100 {
+		return errors.New("invalid rate limit warning percent param (--rate-limit-warning-percent): must be between 0 and 100")
+	}
+
+	if viper.GetInt("capture-max-files") < 0 {
+		return errors.New("invalid capture max files param (--capture-max-files): must not be negative")
+	}
+
+	if err := state.ValidateCLIParams(); err != nil {
+		return err
+	}
+
+	if err := outputs.ValidateCLIParams(); err != nil {
+		return err
+	}
+
+	if err := output.ValidateCLIParams(); err != nil {
+		return err
+	}
+
+	if err := filter.ValidateCLIParams(); err != nil {
+		return err
+	}
+
+	if err := enrich.ValidateCLIParams(); err != nil {
+		return err
+	}
+
+	if err := schema.ValidateCLIParams(); err != nil {
+		return err
+	}
+
+	if err := detect.ValidateCLIParams(); err != nil {
+		return err
+	}
+
+	if err := metrics.ValidateCLIParams(); err != nil {
+		return err
+	}
+
+	if err := admin.ValidateCLIParams(); err != nil {
+		return err
+	}
+
+	if err := tenants.ValidateCLIParams(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Read the API key from the file or stdin set by the API key file param, keeping it out of the environment and process list
+// Files are read as file references, so they are read again when secrets are refreshed.
+func readApiKeyFile() error {
+	path := viper.GetString("okta-api-key-file")
+	if path == "" {
+		return nil
+	}
+
+	if viper.GetString("okta-api-key") != "" {
+		return errors.New("okta api key param (--okta-api-key) can't be combined with the okta api key file param (--okta-api-key-file)")
+	}
+
+	if path != "-" {
+		viper.Set("okta-api-key", "file:"+path)
+		return nil
+	}
+
+	key, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return errors.New(fmt.Sprintf("invalid okta api key file param (--okta-api-key-file): %v", err))
+	}
+
+	viper.Set("okta-api-key", strings.TrimSpace(key))
+	return nil
+}
+
+// Params holding secrets, such as API keys and tokens
+var SecretParams = []string{
+	"okta-api-key",
+	"s3-secret-key",
+	"http-auth",
+	"adx-client-secret",
+	"security-lake-secret-key",
+	"threat-intel-api-key",
+	"vault-token",
+	"admin-grpc-token",
+}
+
+// Params holding DSNs with passwords
+var DsnParams = []string{"postgres-dsn", "snowflake-dsn"}
+
+// Params holding lists of secrets
+var SecretListParams = []string{"okta-api-keys"}
+
+// Resolve the additional API keys that reference secrets, each under its index such as okta-api-keys[0]
+func resolveApiKeys() error {
+	keys := viper.GetStringSlice("okta-api-keys")
+	for i, key := range keys {
+		value, err := secrets.ResolveValue(fmt.Sprintf("okta-api-keys[%d]", i), key)
+		if err != nil {
+			return err
+		}
+		keys[i] = value
+	}
+
+	if len(keys) > 0 {
+		viper.Set("okta-api-keys", keys)
+	}
+
+	return nil
+}
+
+// Register the secrets in params to be scrubbed from logs and debug output
+func registerSecrets() {
+	for _, param := range SecretParams {
+		scrub.Register(viper.GetString(param))
+	}
+
+	for _, param := range DsnParams {
+		scrub.RegisterDsn(viper.GetString(param))
+	}
+
+	for _, param := range SecretListParams {
+		for _, value := range viper.GetStringSlice(param) {
+			scrub.Register(value)
+		}
+	}
+}
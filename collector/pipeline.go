@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Collected events on their way to be handled by a single consumer
+// Sending blocks while the buffer is full, so slow outputs hold back collection rather than events piling up, and
+// a poll waits for the events it collected to be handled rather than for the buffer to look empty.
+// With the sort events param, events are held until the wait and handled in order of their published time.
+type pipeline struct {
+	events  chan []byte
+	drained chan chan struct{}
+	sorted  bool
+	held    []heldEvent
+}
+
+// An event held until it's handled in order
+type heldEvent struct {
+	event     []byte
+	published time.Time
+}
+
+// Published time of an event, read to order held events
+type publishedField struct {
+	Published time.Time `json:"published"`
+}
+
+// Create a pipeline buffering the event buffer size param of events
+func newPipeline() *pipeline {
+	return &pipeline{
+		events:  make(chan []byte, viper.GetInt("event-buffer-size")),
+		drained: make(chan chan struct{}),
+		sorted:  viper.GetBool("sort-events"),
+	}
+}
+
+// Validate the event buffer size param
+func validatePipelineParams() error {
+	if viper.GetInt("event-buffer-size") < 0 {
+		return errors.New("invalid event buffer size param (--event-buffer-size): must not be negative")
+	}
+
+	return nil
+}
+
+// Handle events until the pipeline is closed, answering waits once the events sent before them were handled
+func (p *pipeline) run(handle func([]byte)) {
+	for {
+		select {
+		case event, ok := <-p.events:
+			if !ok {
+				return
+			}
+			p.receive(event, handle)
+		case done := <-p.drained:
+			p.drain(handle)
+			p.release(handle)
+			close(done)
+		}
+	}
+}
+
+// Handle the events left in the buffer
+func (p *pipeline) drain(handle func([]byte)) {
+	for {
+		select {
+		case event, ok := <-p.events:
+			if !ok {
+				return
+			}
+			p.receive(event, handle)
+		default:
+			return
+		}
+	}
+}
+
+// Handle an event, or hold it when events are sorted
+func (p *pipeline) receive(event []byte, handle func([]byte)) {
+	if !p.sorted {
+		handle(event)
+		return
+	}
+
+	// Events without a valid published time sort first, in the order they were collected
+	var fields publishedField
+	_ = json.Unmarshal(event, &fields)
+	p.held = append(p.held, heldEvent{event: event, published: fields.Published})
+}
+
+// Handle the held events in order of their published time
+func (p *pipeline) release(handle func([]byte)) {
+	sort.SliceStable(p.held, func(i, j int) bool {
+		return p.held[i].published.Before(p.held[j].published)
+	})
+
+	for _, h := range p.held {
+		handle(h.event)
+	}
+	p.held = nil
+}
+
+// Wait until every event sent so far was handled
+// Must be called by the sender once it is done sending, so no event can be sent after the wait started.
+func (p *pipeline) wait() {
+	done := make(chan struct{})
+	p.drained <- done
+	<-done
+}
+
+// Stop handling events once the events sent were handled
+func (p *pipeline) close() {
+	p.wait()
+	close(p.events)
+}
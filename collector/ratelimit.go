@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rfizzle/okta-collector/alert"
+	"github.com/rfizzle/okta-collector/client"
+	"github.com/rfizzle/okta-collector/output"
+	"github.com/spf13/viper"
+)
+
+// Event types of rate limit usage records and warnings
+const (
+	rateLimitUsageEventType   = "collector.rate_limit_usage"
+	rateLimitWarningEventType = "collector.rate_limit_warning"
+)
+
+// A record of the usage of the rate limit of an Okta API endpoint
+// Records share the uuid, published and eventType fields of System Log events so outputs can batch and route them.
+type rateLimitUsageRecord struct {
+	Uuid      string `json:"uuid"`
+	Published string `json:"published"`
+	EventType string `json:"eventType"`
+	Endpoint  string `json:"endpoint"`
+	Limit     int    `json:"limit"`
+	Remaining int    `json:"remaining"`
+	// Requests to the endpoint counted against the limit until it resets, by the collector and other integrations
+	Used  int    `json:"used"`
+	Reset string `json:"reset,omitempty"`
+	// Requests of the collector since the previous record
+	CollectorRequests    int `json:"collectorRequests"`
+	CollectorRateLimited int `json:"collectorRateLimited"`
+}
+
+// Write a record of the rate limit usage of every endpoint requested to the outputs, once the rate limit usage
+// interval param passed since the previous records
+func (c *Collector) writeRateLimitUsage() {
+	interval := time.Duration(viper.GetInt("rate-limit-usage-interval")) * time.Second
+	if interval <= 0 || time.Since(c.rateLimitUsageWritten) < interval {
+		return
+	}
+	c.rateLimitUsageWritten = time.Now()
+
+	published := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, usage := range client.TakeRateLimitUsage() {
+		record := rateLimitUsageRecord{
+			Uuid:                 alert.NewUuid(),
+			Published:            published,
+			EventType:            rateLimitUsageEventType,
+			Endpoint:             usage.Endpoint,
+			Limit:                usage.RateLimit.Limit,
+			Remaining:            usage.RateLimit.Remaining,
+			Used:                 usage.RateLimit.Limit - usage.RateLimit.Remaining,
+			CollectorRequests:    usage.Requests,
+			CollectorRateLimited: usage.RateLimited,
+		}
+		if !usage.RateLimit.Reset.IsZero() {
+			record.Reset = usage.RateLimit.Reset.UTC().Format(time.RFC3339)
+		}
+
+		encoded, err := json.Marshal(record)
+		if err != nil {
+			log.Printf("Unable to encode rate limit usage of %s: %v\n", usage.Endpoint, err)
+			continue
+		}
+
+		output.WriteEvent(encoded)
+	}
+}
+
+// A warning that the remaining rate limit of an Okta API endpoint dropped below the rate limit warning percent param
+// Warnings have the WARN severity, so outputs can route them with severity filters.
+type rateLimitWarningRecord struct {
+	Uuid           string `json:"uuid"`
+	Published      string `json:"published"`
+	EventType      string `json:"eventType"`
+	Severity       string `json:"severity"`
+	DisplayMessage string `json:"displayMessage"`
+	Endpoint       string `json:"endpoint"`
+	Limit          int    `json:"limit"`
+	Remaining      int    `json:"remaining"`
+	Reset          string `json:"reset,omitempty"`
+	Percent        int    `json:"percent"`
+}
+
+// Write a warning to the outputs for every endpoint whose rate limit dropped below the rate limit warning percent param
+func (c *Collector) writeRateLimitWarnings() {
+	published := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, warning := range client.TakeRateLimitWarnings() {
+		record := rateLimitWarningRecord{
+			Uuid:           alert.NewUuid(),
+			Published:      published,
+			EventType:      rateLimitWarningEventType,
+			Severity:       "WARN",
+			DisplayMessage: fmt.Sprintf("Okta API rate limit of %s below %d%%", warning.Endpoint, warning.Percent),
+			Endpoint:       warning.Endpoint,
+			Limit:          warning.RateLimit.Limit,
+			Remaining:      warning.RateLimit.Remaining,
+			Percent:        warning.Percent,
+		}
+		if !warning.RateLimit.Reset.IsZero() {
+			record.Reset = warning.RateLimit.Reset.UTC().Format(time.RFC3339)
+		}
+
+		encoded, err := json.Marshal(record)
+		if err != nil {
+			log.Printf("Unable to encode rate limit warning of %s: %v\n", warning.Endpoint, err)
+			continue
+		}
+
+		output.WriteEvent(encoded)
+	}
+}
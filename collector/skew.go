@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"log"
+	"time"
+
+	"github.com/rfizzle/okta-collector/client"
+	"github.com/spf13/viper"
+)
+
+// Current time of the collector, shifted to the clock of Okta when the clock skew compensate param is set
+// Windows end at the time of Okta, so events published between the clocks are neither skipped nor collected late.
+func (c *Collector) now() time.Time {
+	now := time.Now()
+	if !viper.GetBool("clock-skew-compensate") {
+		return now
+	}
+
+	if skew, ok := client.ClockSkew(); ok {
+		return now.Add(skew)
+	}
+
+	return now
+}
+
+// Warn when the clock skew against Okta exceeds the clock skew threshold param, once until it is back within it
+func (c *Collector) checkClockSkew() {
+	threshold := time.Duration(viper.GetInt("clock-skew-threshold")) * time.Second
+	skew, ok := client.ClockSkew()
+	if threshold <= 0 || !ok {
+		return
+	}
+
+	offset, direction := skew, "behind"
+	if skew < 0 {
+		offset, direction = -skew, "ahead of"
+	}
+
+	exceeded := offset > threshold
+	if exceeded && !c.skewed {
+		if viper.GetBool("clock-skew-compensate") {
+			log.Printf("Warning: local clock is %v %s the clock of Okta, compensating collection windows\n", offset, direction)
+		} else {
+			log.Printf("Warning: local clock is %v %s the clock of Okta, which shifts collection windows, sync the clock or set --clock-skew-compensate\n", offset, direction)
+		}
+	} else if !exceeded && c.skewed {
+		log.Printf("Local clock is back within %v of the clock of Okta\n", threshold)
+	}
+
+	c.skewed = exceeded
+}
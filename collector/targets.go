@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/rfizzle/collector-helpers/state"
+	"github.com/rfizzle/okta-collector/input"
+	"github.com/rfizzle/okta-collector/output"
+	"github.com/rfizzle/okta-collector/tenants"
+	"github.com/spf13/viper"
+)
+
+// An input collected on every poll, such as the Okta org of the params or a tenant
+type target struct {
+	input     input.Input
+	statePath string
+	state     *state.State
+	since     time.Time
+	windows   map[uint64]time.Time
+}
+
+// Inputs to collect, the Okta input of every tenant when tenants are configured
+func inputTargets() ([]*target, error) {
+	if all := tenants.All(); len(all) > 0 {
+		var targets []*target
+		for _, t := range all {
+			targets = append(targets, &target{input: input.NewOkta(t), statePath: t.StatePath()})
+		}
+		return targets, nil
+	}
+
+	i, err := input.New()
+	if err != nil {
+		return nil, err
+	}
+
+	return []*target{{input: i, statePath: viper.GetString("state-path")}}, nil
+}
+
+// Restore the state of the inputs to collect
+func collectionTargets() ([]*target, error) {
+	targets, err := inputTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range targets {
+		currentState, err := RestoreState(t.statePath)
+		if err != nil {
+			return nil, err
+		}
+
+		since, err := time.Parse(time.RFC3339Nano, currentState.LastPollTimestamp)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid last poll timestamp of state %s: %v", t.statePath, err))
+		}
+
+		t.state = currentState
+		t.since = since
+		t.windows = make(map[uint64]time.Time)
+	}
+
+	return targets, nil
+}
+
+// Restore the state, or create a new one if there is no state yet
+func RestoreState(path string) (*state.State, error) {
+	if !state.Exists(path) {
+		return state.New(), nil
+	}
+
+	return state.Restore(path)
+}
+
+// Save the end of the latest window of the org acknowledged by every output as its state
+func saveAckedState(t *target) {
+	acked := output.AckedPoll()
+
+	windowEnd, ok := t.windows[acked]
+	if !ok {
+		return
+	}
+
+	// Collection continues from the window in dry-run mode, without saving it
+	if !output.DryRun() {
+		t.state.LastPollTimestamp = windowEnd.Format(time.RFC3339Nano)
+		state.Save(t.state, t.statePath)
+	}
+
+	// Forget acknowledged windows
+	for poll := range t.windows {
+		if poll <= acked {
+			delete(t.windows, poll)
+		}
+	}
+}
+
+// Random delay of up to the schedule jitter param, so collectors on the same schedule don't all poll at once
+func scheduleJitter() time.Duration {
+	jitter := viper.GetInt("schedule-jitter")
+	if jitter <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(jitter) * int64(time.Second)))
+}
+
+// Context of a poll started now, with the deadline of the poll timeout param when set
+func pollContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := viper.GetInt("poll-timeout")
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+}
@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rfizzle/collector-helpers/state"
+	"github.com/rfizzle/okta-collector/output"
+	"github.com/spf13/viper"
+)
+
+func TestSaveAckedStateKeepsFractionalSeconds(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	// Window of a poll cut short by its deadline, ending at the published time of its last event
+	windowEnd := time.Date(2023, 6, 1, 12, 0, 0, 123000000, time.UTC)
+	path := filepath.Join(t.TempDir(), "collector.state")
+	tg := &target{statePath: path, state: state.New(), windows: map[uint64]time.Time{output.AckedPoll(): windowEnd}}
+
+	saveAckedState(tg)
+
+	saved, err := RestoreState(path)
+	if err != nil {
+		t.Fatalf("unable to restore state: %v", err)
+	}
+
+	since, err := time.Parse(time.RFC3339Nano, saved.LastPollTimestamp)
+	if err != nil {
+		t.Fatalf("invalid saved timestamp %s: %v", saved.LastPollTimestamp, err)
+	}
+
+	if !since.Equal(windowEnd) {
+		t.Errorf("expected collection to continue from %v, got %v", windowEnd, since)
+	}
+
+	if len(tg.windows) != 0 {
+		t.Errorf("expected the acknowledged window to be forgotten, got %v", tg.windows)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/rfizzle/collector-helpers/state"
+	"github.com/rfizzle/okta-collector/collector"
+	"github.com/rfizzle/okta-collector/tenants"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Create the root command, which collects like the run command when no command is given
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:          "okta-collector",
+		Short:        "Collect Okta System Log events and deliver them to outputs",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			run()
+		},
+	}
+
+	// Params of the collector and its packages are shared by every command
+	root.PersistentFlags().AddFlagSet(flag.CommandLine)
+
+	root.AddCommand(
+		newInitCommand(),
+		newRunCommand(),
+		newOnceCommand(),
+		newBackfillCommand(),
+		newReplayCommand(),
+		newGenerateCommand(),
+		newValidateCommand(),
+		newStateCommand(),
+		newDoctorCommand(),
+		newVersionCommand(),
+		newCompletionCommand(),
+	)
+
+	return root
+}
+
+func newInitCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Interactively write a config file, checking access to Okta",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, _ := cmd.Flags().GetString("path")
+			force, _ := cmd.Flags().GetBool("force")
+			return initConfig(path, force)
+		},
+	}
+	cmd.Flags().String("path", "config.json", "path to write the config file to")
+	cmd.Flags().Bool("force", false, "overwrite an existing config file")
+
+	return cmd
+}
+
+func newRunCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "run",
+		Short: "Collect events every schedule until stopped",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			run()
+		},
+	}
+}
+
+func newOnceCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "once",
+		Short: "Collect events since the saved state once, then exit",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			timeout, _ := cmd.Flags().GetInt("timeout")
+			return collectOnce(time.Duration(timeout) * time.Second)
+		},
+	}
+	cmd.Flags().Int("timeout", 300, "time in seconds to wait for outputs to acknowledge the events")
+
+	return cmd
+}
+
+func newBackfillCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backfill",
+		Short: "Collect the events of a past time range without changing the saved state",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			since, _ := cmd.Flags().GetString("since")
+			until, _ := cmd.Flags().GetString("until")
+			timeout, _ := cmd.Flags().GetInt("timeout")
+			return backfill(since, until, time.Duration(timeout)*time.Second)
+		},
+	}
+	cmd.Flags().String("since", "", "start of the time range (RFC 3339)")
+	cmd.Flags().String("until", "", "end of the time range (RFC 3339) (default now)")
+	cmd.Flags().Int("timeout", 300, "time in seconds to wait for outputs to acknowledge the events")
+	_ = cmd.MarkFlagRequired("since")
+
+	return cmd
+}
+
+func newReplayCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Deliver the events of saved files to the outputs again, without querying Okta",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, _ := cmd.Flags().GetString("from")
+			timeout, _ := cmd.Flags().GetInt("timeout")
+			return replay(from, time.Duration(timeout)*time.Second)
+		},
+	}
+	cmd.Flags().String("from", "", "newline delimited or json array file of events, or a directory of them")
+	cmd.Flags().Int("timeout", 300, "time in seconds to wait for outputs to acknowledge the events")
+	_ = cmd.MarkFlagRequired("from")
+
+	return cmd
+}
+
+func newGenerateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Deliver realistic fake events to the outputs, to size outputs and test parsing",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			events, _ := cmd.Flags().GetInt("events")
+			rate, _ := cmd.Flags().GetFloat64("rate")
+			eventTypes, _ := cmd.Flags().GetStringSlice("event-types")
+			seed, _ := cmd.Flags().GetInt64("seed")
+			timeout, _ := cmd.Flags().GetInt("timeout")
+			if !cmd.Flags().Changed("seed") {
+				seed = time.Now().UnixNano()
+			}
+			return generate(events, rate, eventTypes, seed, time.Duration(timeout)*time.Second)
+		},
+	}
+	cmd.Flags().Int("events", 1000, "number of events to generate")
+	cmd.Flags().Float64("rate", 0, "events a second to generate, 0 for as fast as possible")
+	cmd.Flags().StringSlice("event-types", []string{}, "event types to generate (default every generated event type)")
+	cmd.Flags().Int64("seed", 0, "seed of the generated events, to generate the same events again (default random)")
+	cmd.Flags().Int("timeout", 300, "time in seconds to wait for outputs to acknowledge the events")
+
+	return cmd
+}
+
+func newValidateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "validate",
+		Aliases: []string{"validate-config"},
+		Short:   "Validate the config strictly and print the effective config",
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(validateConfig())
+		},
+	}
+}
+
+func newDoctorCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the config, Okta access, state and outputs",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(doctor())
+		},
+	}
+}
+
+func newVersionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the version and build metadata",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Printf("okta-collector %s\n", version)
+			fmt.Printf("commit: %s\n", commit)
+			fmt.Printf("built: %s\n", buildDate)
+			fmt.Printf("go: %s\n", runtime.Version())
+			fmt.Printf("platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+		},
+	}
+}
+
+func newCompletionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh|fish|powershell",
+		Short: "Generate the shell completion script of the commands and options",
+		Long: `Generate the shell completion script of the commands and options.
+
+Load completions in the current shell:
+
+  bash:       source <(okta-collector completion bash)
+  zsh:        source <(okta-collector completion zsh)
+  fish:       okta-collector completion fish | source
+  powershell: okta-collector completion powershell | Out-String | Invoke-Expression
+`,
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		Args:                  cobra.ExactValidArgs(1),
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletion(os.Stdout)
+			case "zsh":
+				return root.GenZshCompletion(os.Stdout)
+			case "fish":
+				return root.GenFishCompletion(os.Stdout, true)
+			default:
+				return root.GenPowerShellCompletion(os.Stdout)
+			}
+		},
+	}
+}
+
+func newStateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "state",
+		Short: "Show or change the saved state",
+	}
+	cmd.PersistentFlags().String("tenant", "", "name of the tenant whose state to show or change")
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "show",
+			Short: "Print the end of the last collected window",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				currentState, _, err := loadState(cmd)
+				if err != nil {
+					return err
+				}
+
+				fmt.Println(currentState.LastPollTimestamp)
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "set TIMESTAMP",
+			Short: "Set the end of the last collected window (RFC 3339), so collection continues from it",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				timestamp, err := time.Parse(time.RFC3339, args[0])
+				if err != nil {
+					return errors.New(fmt.Sprintf("invalid timestamp %s: %v", args[0], err))
+				}
+
+				currentState, path, err := loadState(cmd)
+				if err != nil {
+					return err
+				}
+
+				currentState.LastPollTimestamp = timestamp.Format(time.RFC3339Nano)
+				state.Save(currentState, path)
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "reset",
+			Short: "Remove the saved state, so collection starts over",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				_, path, err := loadState(cmd)
+				if err != nil {
+					return err
+				}
+
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+
+				return nil
+			},
+		},
+	)
+
+	return cmd
+}
+
+// Load the params and restore the state, or the state of the tenant of the command, without validating the params
+// unrelated to the state
+// Returns the state and its path.
+func loadState(cmd *cobra.Command) (*state.State, string, error) {
+	if err := loadCliFlags(); err != nil {
+		return nil, "", err
+	}
+
+	if viper.GetString("state-path") == "" {
+		return nil, "", errors.New("missing state path param (--state-path)")
+	}
+
+	path := viper.GetString("state-path")
+	if name, _ := cmd.Flags().GetString("tenant"); name != "" {
+		tenant, err := tenants.Lookup(name)
+		if err != nil {
+			return nil, "", err
+		}
+		path = tenant.StatePath()
+	}
+
+	currentState, err := collector.RestoreState(path)
+	return currentState, path, err
+}
+
+// Collect the events since the saved state once, saving the state once every output acknowledged them
+func collectOnce(timeout time.Duration) error {
+	c, err := setupCollector(collector.WithAckTimeout(timeout))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.PollOnce(context.Background())
+}
+
+// Collect the events of a time range, leaving the saved state as is
+func backfill(since string, until string, timeout time.Duration) error {
+	start, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid since param (--since): %v", err))
+	}
+
+	end := time.Now()
+	if until != "" {
+		if end, err = time.Parse(time.RFC3339, until); err != nil {
+			return errors.New(fmt.Sprintf("invalid until param (--until): %v", err))
+		}
+	}
+
+	if !start.Before(end) {
+		return errors.New("since param (--since) must be before until param (--until)")
+	}
+
+	c, err := setupCollector(collector.WithAckTimeout(timeout))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.Backfill(context.Background(), start, end)
+}
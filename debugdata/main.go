@@ -0,0 +1,56 @@
+// Package debugdata parses the string values of debugContext.debugData in Okta System Log events.
+package debugdata
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Parse a debug data string into a boolean or object where possible
+// Handles "true" and "false", JSON objects and maps such as "{reasons=New Device, level=LOW}".
+func ParseValue(value string) interface{} {
+	trimmed := strings.TrimSpace(value)
+	switch {
+	case trimmed == "true":
+		return true
+	case trimmed == "false":
+		return false
+	case strings.HasPrefix(trimmed, "{") && strings.HasSuffix(trimmed, "}"):
+		var object map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(trimmed))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err == nil {
+			return object
+		}
+
+		if object := parseMap(trimmed[1 : len(trimmed)-1]); object != nil {
+			return object
+		}
+	}
+
+	return value
+}
+
+// Parse the key=value pairs of a debug data map
+// Values may contain ", " themselves, such as reasons=New Geo-Location, New Device, so parts without an = are
+// appended to the previous value. Returns nil if the string isn't a map.
+func parseMap(value string) map[string]interface{} {
+	object := make(map[string]interface{})
+	lastKey := ""
+	for _, part := range strings.Split(value, ", ") {
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) == 2 && pair[0] != "" {
+			lastKey = pair[0]
+			object[lastKey] = ParseValue(pair[1])
+			continue
+		}
+
+		previous, ok := object[lastKey].(string)
+		if !ok {
+			return nil
+		}
+		object[lastKey] = previous + ", " + part
+	}
+
+	return object
+}
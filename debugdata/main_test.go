@@ -0,0 +1,59 @@
+package debugdata
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected interface{}
+	}{
+		{"true", "true", true},
+		{"false with spaces", " false ", false},
+		{"plain string", "LOW", "LOW"},
+		{"uppercase boolean", "TRUE", "TRUE"},
+		{"url with query", "/api/v1/authn?fromURI=a=b", "/api/v1/authn?fromURI=a=b"},
+		{"empty", "", ""},
+		{
+			"risk map",
+			"{reasons=Anomalous Device, New Geo-Location, level=HIGH}",
+			map[string]interface{}{"reasons": "Anomalous Device, New Geo-Location", "level": "HIGH"},
+		},
+		{
+			"behaviors map",
+			"{New Geo-Location=NEGATIVE, New Device=POSITIVE, New IP=NEGATIVE, New State=NEGATIVE, New Country=NEGATIVE, Velocity=NEGATIVE, New City=NEGATIVE}",
+			map[string]interface{}{
+				"New Geo-Location": "NEGATIVE",
+				"New Device":       "POSITIVE",
+				"New IP":           "NEGATIVE",
+				"New State":        "NEGATIVE",
+				"New Country":      "NEGATIVE",
+				"Velocity":         "NEGATIVE",
+				"New City":         "NEGATIVE",
+			},
+		},
+		{"map with booleans", "{suspected=true, reported=false}", map[string]interface{}{"suspected": true, "reported": false}},
+		{"map with empty value", "{reasons=, level=LOW}", map[string]interface{}{"reasons": "", "level": "LOW"}},
+		{
+			"json object",
+			`{"level": "MEDIUM", "score": 12345678901234567890, "reasons": ["New Device"]}`,
+			map[string]interface{}{"level": "MEDIUM", "score": json.Number("12345678901234567890"), "reasons": []interface{}{"New Device"}},
+		},
+		{"empty json object", "{}", map[string]interface{}{}},
+		{"braces without pairs", "{New Device}", "{New Device}"},
+		{"pair without key", "{=LOW}", "{=LOW}"},
+		{"unclosed brace", "{level=LOW", "{level=LOW"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := ParseValue(test.value); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package detect
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/alert"
+	"github.com/spf13/viper"
+)
+
+// Mean radius of the Earth in kilometers
+const earthRadiusKm = 6371.0
+
+// Where a user last signed in from
+type userLocation struct {
+	Time    time.Time `json:"time"`
+	Ip      string    `json:"ip"`
+	City    string    `json:"city,omitempty"`
+	Country string    `json:"country,omitempty"`
+	Lat     float64   `json:"lat"`
+	Lon     float64   `json:"lon"`
+	Located bool      `json:"located"`
+}
+
+// The sign in baseline of a user
+type userBaseline struct {
+	Last      userLocation `json:"last"`
+	Countries []string     `json:"countries"`
+}
+
+// Sign in baselines of users, persisted alongside the collector state so they survive restarts
+type baselines struct {
+	mu            sync.Mutex
+	path          string
+	maxSpeedKmh   float64
+	minDistanceKm float64
+	newCountry    bool
+	users         map[string]*userBaseline
+	changed       bool
+}
+
+// Baselines loaded from the params by Setup
+var userBaselines *baselines
+
+// Path of the baselines, next to the collector state unless set
+func baselinePath() string {
+	if p := viper.GetString("baseline-path"); p != "" {
+		return p
+	}
+
+	return viper.GetString("state-path") + ".baseline"
+}
+
+// Load the baselines of users
+// Returns nil when anomaly detection is disabled.
+func loadBaselines() (*baselines, error) {
+	if !viper.GetBool("detect-anomalies") {
+		return nil, nil
+	}
+
+	b := &baselines{
+		path:          baselinePath(),
+		maxSpeedKmh:   viper.GetFloat64("impossible-travel-speed"),
+		minDistanceKm: viper.GetFloat64("impossible-travel-min-distance"),
+		newCountry:    viper.GetBool("new-country-alerts"),
+		users:         make(map[string]*userBaseline),
+	}
+
+	content, err := ioutil.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return b, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, &b.users); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Save the baselines if they changed, replacing the file atomically
+func (b *baselines) save() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.changed {
+		return nil
+	}
+
+	content, err := json.Marshal(b.users)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(b.path+".tmp", content, 0600); err != nil {
+		return err
+	}
+
+	if err := os.Rename(b.path+".tmp", b.path); err != nil {
+		return err
+	}
+
+	b.changed = false
+	return nil
+}
+
+// Add a sign in to the baseline of its user, returning the anomaly alerts it raises
+func (b *baselines) add(event map[string]interface{}) []*alert.Alert {
+	if fieldValue(event, "eventType") != "user.session.start" || !strings.EqualFold(fieldValue(event, "outcome.result"), "SUCCESS") {
+		return nil
+	}
+
+	user := fieldValue(event, "actor.alternateId")
+	if user == "" {
+		return nil
+	}
+
+	current := userLocation{
+		Ip:      fieldValue(event, "client.ipAddress"),
+		City:    fieldValue(event, "client.geographicalContext.city"),
+		Country: fieldValue(event, "client.geographicalContext.country"),
+	}
+
+	lat, latErr := strconv.ParseFloat(fieldValue(event, "client.geographicalContext.geolocation.lat"), 64)
+	lon, lonErr := strconv.ParseFloat(fieldValue(event, "client.geographicalContext.geolocation.lon"), 64)
+	if latErr == nil && lonErr == nil {
+		current.Lat, current.Lon, current.Located = lat, lon, true
+	}
+
+	var err error
+	if current.Time, err = time.Parse(time.RFC3339Nano, fieldValue(event, "published")); err != nil {
+		current.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	baseline, known := b.users[user]
+	if !known {
+		baseline = &userBaseline{}
+		b.users[user] = baseline
+	}
+
+	var alerts []*alert.Alert
+	uuid := fieldValue(event, "uuid")
+
+	// Impossible travel between consecutive sign ins
+	if known && current.Located && baseline.Last.Located && current.Time.After(baseline.Last.Time) {
+		distance := distanceKm(baseline.Last.Lat, baseline.Last.Lon, current.Lat, current.Lon)
+		hours := current.Time.Sub(baseline.Last.Time).Hours()
+		if distance >= b.minDistanceKm && distance/hours > b.maxSpeedKmh {
+			a := anomalyAlert("impossible-travel", "Impossible travel between sign ins", user, uuid, baseline.Last, current)
+			a.Details["distanceKm"] = math.Round(distance)
+			a.Details["speedKmh"] = math.Round(distance / hours)
+			alerts = append(alerts, a)
+		}
+	}
+
+	// Country never seen for the user, once the user has a baseline
+	if current.Country != "" && !containsString(baseline.Countries, current.Country) {
+		if known && b.newCountry && len(baseline.Countries) > 0 {
+			alerts = append(alerts, anomalyAlert("new-country", "Sign in from a new country", user, uuid, baseline.Last, current))
+		}
+		baseline.Countries = append(baseline.Countries, current.Country)
+	}
+
+	if current.Time.After(baseline.Last.Time) {
+		baseline.Last = current
+	}
+	b.changed = true
+
+	return alerts
+}
+
+// Create an anomaly alert for a sign in of a user
+func anomalyAlert(id, title, user, uuid string, previous, current userLocation) *alert.Alert {
+	a := alert.New("anomaly", alert.Rule{Id: id, Title: title, Level: "medium", Tags: []string{"attack.t1078.004"}})
+	a.Details = map[string]interface{}{
+		"user":      user,
+		"previous":  previous,
+		"current":   current,
+		"eventUuid": uuid,
+	}
+	return a
+}
+
+// Great-circle distance between two coordinates, using the haversine formula
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
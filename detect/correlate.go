@@ -0,0 +1,183 @@
+package detect
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/alert"
+	"github.com/rfizzle/okta-collector/match"
+	"github.com/spf13/viper"
+)
+
+// Event types of authentications, whose failures are correlated
+var authEventTypes = []string{"user.session.start", "user.authentication.*"}
+
+// Correlation of authentication failures, per user for brute force and per client IP for password spraying
+type authCorrelation struct {
+	mu               sync.Mutex
+	window           time.Duration
+	failureThreshold int
+	sprayThreshold   int
+	failuresByUser   map[string][]authFailure
+	failuresBySource map[string][]authFailure
+	prunedAt         time.Time
+}
+
+// An authentication failure in a correlation window
+type authFailure struct {
+	published time.Time
+	uuid      string
+	user      string
+	ip        string
+}
+
+// Authentication failure correlation created from the params by Setup
+var correlation *authCorrelation
+
+// Create the authentication failure correlation
+// Returns nil when correlation is disabled.
+func newAuthCorrelation() *authCorrelation {
+	if !viper.GetBool("correlate-auth-failures") {
+		return nil
+	}
+
+	return &authCorrelation{
+		window:           time.Duration(viper.GetInt("auth-failure-window")) * time.Minute,
+		failureThreshold: viper.GetInt("auth-failure-threshold"),
+		sprayThreshold:   viper.GetInt("spray-user-threshold"),
+		failuresByUser:   make(map[string][]authFailure),
+		failuresBySource: make(map[string][]authFailure),
+	}
+}
+
+// Add an event to the correlation, returning the correlation alerts it raises
+func (c *authCorrelation) add(event map[string]interface{}) []*alert.Alert {
+	if !strings.EqualFold(fieldValue(event, "outcome.result"), "FAILURE") || !isAuthEvent(fieldValue(event, "eventType")) {
+		return nil
+	}
+
+	failure := authFailure{
+		uuid: fieldValue(event, "uuid"),
+		user: fieldValue(event, "actor.alternateId"),
+		ip:   fieldValue(event, "client.ipAddress"),
+	}
+
+	var err error
+	if failure.published, err = time.Parse(time.RFC3339Nano, fieldValue(event, "published")); err != nil {
+		failure.published = time.Now()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prune(failure.published)
+
+	var alerts []*alert.Alert
+
+	// Brute force of a user
+	if failure.user != "" {
+		failures := c.slide(c.failuresByUser[failure.user], failure)
+		if len(failures) >= c.failureThreshold {
+			alerts = append(alerts, c.alert("brute-force", "Brute force of a user", "user", failure.user, failures))
+			delete(c.failuresByUser, failure.user)
+		} else {
+			c.failuresByUser[failure.user] = failures
+		}
+	}
+
+	// Password spraying from a client IP
+	if failure.ip != "" {
+		failures := c.slide(c.failuresBySource[failure.ip], failure)
+		if distinctUsers(failures) >= c.sprayThreshold {
+			alerts = append(alerts, c.alert("password-spray", "Password spraying from a client IP", "ip", failure.ip, failures))
+			delete(c.failuresBySource, failure.ip)
+		} else {
+			c.failuresBySource[failure.ip] = failures
+		}
+	}
+
+	return alerts
+}
+
+// Add a failure to the failures of a window, dropping the failures that slid out of it
+func (c *authCorrelation) slide(failures []authFailure, failure authFailure) []authFailure {
+	start := 0
+	for start < len(failures) && failure.published.Sub(failures[start].published) > c.window {
+		start++
+	}
+
+	return append(failures[start:], failure)
+}
+
+// Forget the users and IPs whose last failure slid out of the window, at most once per window
+func (c *authCorrelation) prune(now time.Time) {
+	if now.Sub(c.prunedAt) < c.window {
+		return
+	}
+	c.prunedAt = now
+
+	for _, failures := range []map[string][]authFailure{c.failuresByUser, c.failuresBySource} {
+		for key, f := range failures {
+			if now.Sub(f[len(f)-1].published) > c.window {
+				delete(failures, key)
+			}
+		}
+	}
+}
+
+// Create a correlation alert from the failures of a window
+func (c *authCorrelation) alert(id, title, keyField, key string, failures []authFailure) *alert.Alert {
+	var uuids, users []string
+	for _, f := range failures {
+		uuids = append(uuids, f.uuid)
+		if !containsString(users, f.user) {
+			users = append(users, f.user)
+		}
+	}
+
+	a := alert.New("correlation", alert.Rule{Id: id, Title: title, Level: "high", Tags: []string{"attack.t1110"}})
+	a.Details = map[string]interface{}{
+		keyField:     key,
+		"count":      len(failures),
+		"users":      users,
+		"window":     c.window.String(),
+		"firstSeen":  failures[0].published.Format(time.RFC3339Nano),
+		"lastSeen":   failures[len(failures)-1].published.Format(time.RFC3339Nano),
+		"eventUuids": uuids,
+	}
+	return a
+}
+
+// Check if an event type is an authentication
+func isAuthEvent(eventType string) bool {
+	for _, pattern := range authEventTypes {
+		if match.Pattern(pattern, eventType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Number of distinct users of failures
+func distinctUsers(failures []authFailure) int {
+	users := make(map[string]bool)
+	for _, f := range failures {
+		if f.user != "" {
+			users[f.user] = true
+		}
+	}
+
+	return len(users)
+}
+
+// Check if a slice contains a value
+func containsString(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
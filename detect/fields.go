@@ -0,0 +1,56 @@
+package detect
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Values of a dotted field path in a decoded event, descending into every element of arrays
+func fieldValues(value interface{}, path string) []string {
+	return lookup(value, strings.Split(path, "."))
+}
+
+func lookup(value interface{}, path []string) []string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(path) == 0 {
+			return nil
+		}
+		return lookup(v[path[0]], path[1:])
+	case []interface{}:
+		var values []string
+		for _, element := range v {
+			values = append(values, lookup(element, path)...)
+		}
+		return values
+	case nil:
+		return nil
+	default:
+		if len(path) > 0 {
+			return nil
+		}
+		return []string{scalarString(v)}
+	}
+}
+
+// Text of a scalar value
+func scalarString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// First value of a dotted field path in a decoded event
+func fieldValue(event map[string]interface{}, path string) string {
+	if values := fieldValues(event, path); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,156 @@
+// Package detect runs detections on collected events and emits alerts for their matches.
+package detect
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/rfizzle/okta-collector/alert"
+	"github.com/rfizzle/okta-collector/sigma"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Sigma rules loaded from the params by Setup
+var sigmaRules []*sigma.Rule
+
+// Local detection rules loaded from the params by Setup
+var rules []*rule
+
+// Register the detection params
+func InitCLIParams() {
+	flag.Bool("correlate-auth-failures", false, "raise alerts for brute force of users and password spraying from client ips")
+	flag.Int("auth-failure-threshold", 10, "authentication failures of a user within the window that raise a brute force alert")
+	flag.Int("spray-user-threshold", 5, "users with authentication failures from a client ip within the window that raise a password spray alert")
+	flag.Int("auth-failure-window", 10, "minutes of the authentication failure correlation window")
+	flag.Bool("detect-anomalies", false, "raise alerts for impossible travel and sign ins from new countries, from per user baselines")
+	flag.String("baseline-path", "", "file the per user sign in baselines are kept in (default the state path with a .baseline extension)")
+	flag.Float64("impossible-travel-speed", 1000, "speed in km/h between consecutive sign ins above which travel is impossible")
+	flag.Float64("impossible-travel-min-distance", 500, "distance in km between consecutive sign ins below which travel is never impossible")
+	flag.Bool("new-country-alerts", true, "raise alerts for sign ins from countries never seen for the user")
+	flag.StringSlice("detection-rules", []string{}, "yaml files of local detection rules matching events by fields and thresholds")
+	flag.StringSlice("sigma-rules", []string{}, "sigma rule files or directories of them, rules of the okta logsource are evaluated on every event")
+}
+
+// Validate the detection params
+func ValidateCLIParams() error {
+	if viper.GetInt("auth-failure-threshold") < 1 {
+		return errors.New("invalid auth failure threshold param (--auth-failure-threshold)")
+	}
+
+	if viper.GetInt("spray-user-threshold") < 1 {
+		return errors.New("invalid spray user threshold param (--spray-user-threshold)")
+	}
+
+	if viper.GetInt("auth-failure-window") < 1 {
+		return errors.New("invalid auth failure window param (--auth-failure-window)")
+	}
+
+	if viper.GetFloat64("impossible-travel-speed") <= 0 {
+		return errors.New("invalid impossible travel speed param (--impossible-travel-speed)")
+	}
+
+	if viper.GetFloat64("impossible-travel-min-distance") < 0 {
+		return errors.New("invalid impossible travel min distance param (--impossible-travel-min-distance)")
+	}
+
+	if _, err := loadBaselines(); err != nil {
+		return errors.New(fmt.Sprintf("invalid baseline path param (--baseline-path): %v", err))
+	}
+
+	if _, err := loadRules(viper.GetStringSlice("detection-rules")); err != nil {
+		return errors.New(fmt.Sprintf("invalid detection rules param (--detection-rules): %v", err))
+	}
+
+	if _, err := sigma.Load(viper.GetStringSlice("sigma-rules")); err != nil {
+		return errors.New(fmt.Sprintf("invalid sigma rules param (--sigma-rules): %v", err))
+	}
+
+	return nil
+}
+
+// Setup the detections from the validated params
+func Setup() error {
+	correlation = newAuthCorrelation()
+
+	var err error
+	if userBaselines, err = loadBaselines(); err != nil {
+		return err
+	}
+
+	if rules, err = loadRules(viper.GetStringSlice("detection-rules")); err != nil {
+		return err
+	}
+
+	if sigmaRules, err = sigma.Load(viper.GetStringSlice("sigma-rules")); err != nil {
+		return err
+	}
+
+	if len(sigmaRules) > 0 {
+		log.Printf("Loaded %d sigma rules\n", len(sigmaRules))
+	}
+
+	return nil
+}
+
+// Persist the state of detections at the end of a poll
+func EndPoll() error {
+	if userBaselines == nil {
+		return nil
+	}
+
+	return userBaselines.save()
+}
+
+// Run the detections on an event, emitting an alert for every match
+func Event(event []byte) error {
+	if correlation == nil && userBaselines == nil && len(rules) == 0 && len(sigmaRules) == 0 {
+		return nil
+	}
+
+	var decoded map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(event))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return err
+	}
+
+	// Authentication failure correlation
+	if correlation != nil {
+		for _, a := range correlation.add(decoded) {
+			alert.Emit(a)
+		}
+	}
+
+	// Sign in anomalies
+	if userBaselines != nil {
+		for _, a := range userBaselines.add(decoded) {
+			alert.Emit(a)
+		}
+	}
+
+	// Local rules
+	for _, r := range rules {
+		if a := r.evaluate(decoded, event); a != nil {
+			alert.Emit(a)
+		}
+	}
+
+	// Sigma rules
+	for _, rule := range sigmaRules {
+		if rule.Matches(decoded) {
+			alert.Emit(alert.New("sigma", alert.Rule{
+				Id:          rule.Id,
+				Title:       rule.Title,
+				Description: rule.Description,
+				Level:       rule.Level,
+				Tags:        rule.Tags,
+			}, event))
+		}
+	}
+
+	return nil
+}
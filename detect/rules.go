@@ -0,0 +1,236 @@
+package detect
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/alert"
+	"github.com/rfizzle/okta-collector/match"
+	"gopkg.in/yaml.v3"
+)
+
+// A file of local detection rules
+type ruleFile struct {
+	Rules []*rule `yaml:"rules"`
+}
+
+// A local detection rule, matching events by field patterns and optionally a threshold of them over a window
+type rule struct {
+	Id          string              `yaml:"id"`
+	Title       string              `yaml:"title"`
+	Description string              `yaml:"description"`
+	Level       string              `yaml:"level"`
+	Tags        []string            `yaml:"tags"`
+	Match       map[string]patterns `yaml:"match"`
+	Exclude     map[string]patterns `yaml:"exclude"`
+	Threshold   *struct {
+		Count   int      `yaml:"count"`
+		Window  string   `yaml:"window"`
+		GroupBy []string `yaml:"groupBy"`
+	} `yaml:"threshold"`
+
+	window  time.Duration
+	windows *slidingWindows
+}
+
+// Wildcard patterns of a field, written as a single pattern or a list of them
+type patterns []string
+
+func (p *patterns) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var pattern string
+		if err := value.Decode(&pattern); err != nil {
+			return err
+		}
+		*p = patterns{pattern}
+		return nil
+	}
+
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*p = list
+	return nil
+}
+
+// Load the rules of rule files
+func loadRules(paths []string) ([]*rule, error) {
+	var rules []*rule
+	for _, p := range paths {
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		var file ruleFile
+		if err := yaml.Unmarshal(content, &file); err != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %v", p, err))
+		}
+
+		for _, r := range file.Rules {
+			if err := r.validate(); err != nil {
+				return nil, errors.New(fmt.Sprintf("%s: rule %s: %v", p, r.Title, err))
+			}
+			rules = append(rules, r)
+		}
+	}
+
+	return rules, nil
+}
+
+// Validate a rule, setting up its threshold window
+func (r *rule) validate() error {
+	if r.Title == "" {
+		return errors.New("missing title")
+	}
+
+	if len(r.Match) == 0 {
+		return errors.New("missing match")
+	}
+
+	for _, fields := range []map[string]patterns{r.Match, r.Exclude} {
+		for field, patterns := range fields {
+			for _, pattern := range patterns {
+				if !match.ValidPattern(pattern) {
+					return errors.New(fmt.Sprintf("invalid %s pattern %s", field, pattern))
+				}
+			}
+		}
+	}
+
+	if r.Threshold == nil {
+		return nil
+	}
+
+	if r.Threshold.Count < 1 {
+		return errors.New("invalid threshold count")
+	}
+
+	window, err := time.ParseDuration(r.Threshold.Window)
+	if err != nil || window <= 0 {
+		return errors.New(fmt.Sprintf("invalid threshold window %s", r.Threshold.Window))
+	}
+
+	r.window = window
+	r.windows = newSlidingWindows()
+	return nil
+}
+
+// Check if an event matches the field patterns of a rule
+// Every match field must have a value matching one of its patterns and no exclude field may.
+func (r *rule) matches(event map[string]interface{}) bool {
+	for field, patterns := range r.Match {
+		if !anyValueMatches(fieldValues(event, field), patterns) {
+			return false
+		}
+	}
+
+	for field, patterns := range r.Exclude {
+		if anyValueMatches(fieldValues(event, field), patterns) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Evaluate a rule on an event, returning the alert it raises, if any
+func (r *rule) evaluate(event map[string]interface{}, raw []byte) *alert.Alert {
+	if !r.matches(event) {
+		return nil
+	}
+
+	metadata := alert.Rule{Id: r.Id, Title: r.Title, Description: r.Description, Level: r.Level, Tags: r.Tags}
+	if r.Threshold == nil {
+		return alert.New("rule", metadata, raw)
+	}
+
+	// Group events by the values of the group by fields
+	group := make(map[string]interface{})
+	var key []string
+	for _, field := range r.Threshold.GroupBy {
+		value := fieldValue(event, field)
+		group[field] = value
+		key = append(key, value)
+	}
+
+	published, err := time.Parse(time.RFC3339Nano, fieldValue(event, "published"))
+	if err != nil {
+		published = time.Now()
+	}
+
+	events := r.windows.add(strings.Join(key, "\x00"), published, raw, r.window, r.Threshold.Count)
+	if events == nil {
+		return nil
+	}
+
+	a := alert.New("rule", metadata, events...)
+	a.Details = map[string]interface{}{
+		"count":   len(events),
+		"window":  r.Threshold.Window,
+		"groupBy": group,
+	}
+	return a
+}
+
+// Check if any value matches any wildcard pattern, ignoring case
+func anyValueMatches(values []string, fieldPatterns patterns) bool {
+	for _, value := range values {
+		for _, pattern := range fieldPatterns {
+			if match.PatternFold(pattern, value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Events of groups within a sliding window, kept across polls
+type slidingWindows struct {
+	mu     sync.Mutex
+	groups map[string][]windowEvent
+}
+
+// An event in a sliding window
+type windowEvent struct {
+	published time.Time
+	raw       []byte
+}
+
+func newSlidingWindows() *slidingWindows {
+	return &slidingWindows{groups: make(map[string][]windowEvent)}
+}
+
+// Add an event to the window of a group
+// Returns the events of the window once it has count events, starting the window of the group over. The event is
+// copied, since collected events return to the pool once handled while windows keep them across events.
+func (w *slidingWindows) add(key string, published time.Time, raw []byte, window time.Duration, count int) [][]byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Drop events that slid out of the window
+	events := w.groups[key]
+	start := 0
+	for start < len(events) && published.Sub(events[start].published) > window {
+		start++
+	}
+	events = append(events[start:], windowEvent{published: published, raw: append([]byte(nil), raw...)})
+
+	if len(events) < count {
+		w.groups[key] = events
+		return nil
+	}
+
+	delete(w.groups, key)
+	raws := make([][]byte, len(events))
+	for i, e := range events {
+		raws[i] = e.raw
+	}
+	return raws
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rfizzle/collector-helpers/state"
+	"github.com/rfizzle/okta-collector/alert"
+	"github.com/rfizzle/okta-collector/client"
+	"github.com/rfizzle/okta-collector/fips"
+	"github.com/rfizzle/okta-collector/output"
+	"github.com/rfizzle/okta-collector/scrub"
+	"github.com/rfizzle/okta-collector/secrets"
+	"github.com/rfizzle/okta-collector/tenants"
+	"github.com/spf13/viper"
+)
+
+// An Okta API the collector reads, with the scope it requires
+type oktaAccess struct {
+	scope   string
+	uri     string
+	enabled func() bool
+}
+
+// Okta APIs the collector reads when the features using them are enabled
+var oktaAccesses = []oktaAccess{
+	{"okta.logs.read", "/api/v1/logs", func() bool { return true }},
+	{"okta.users.read", "/api/v1/users", func() bool { return viper.GetBool("enrich-users") }},
+	{"okta.groups.read", "/api/v1/groups", func() bool { return viper.GetBool("enrich-groups") }},
+	{"okta.apps.read", "/api/v1/apps", func() bool { return viper.GetBool("enrich-apps") }},
+	{"okta.apiTokens.read", "/api/v1/api-tokens", func() bool { return len(viper.GetStringSlice("suppress-api-tokens")) > 0 }},
+}
+
+// Result of a doctor check
+type doctorResult struct {
+	name string
+	err  error
+}
+
+// Run the doctor command, checking the config, credentials, Okta access, state and outputs
+// Returns the exit code, 1 if any check failed.
+func doctor() int {
+	var results []doctorResult
+	check := func(name string, err error) {
+		results = append(results, doctorResult{name: name, err: err})
+	}
+
+	// Parse and validate the config, resolving credentials
+	err := setupCliFlags()
+	if err == nil {
+		err = fips.Setup()
+	}
+	check("config", err)
+	if err != nil {
+		return report(results)
+	}
+
+	// Confirm the API key and its scopes with single item requests
+	setupVersion()
+	if tenants.Enabled() {
+		if err := tenants.Setup(); err != nil {
+			check("tenants", err)
+			return report(results)
+		}
+
+		// Tenants are checked for the System Log only, since lookups can't be combined with tenants
+		for _, t := range tenants.All() {
+			oktaClient := t.NewClient()
+			check("okta "+t.Name+" "+oktaAccesses[0].scope, oktaClient.CheckAccess(oktaAccesses[0].uri))
+			check("state "+t.Name, checkState(t.StatePath()))
+		}
+	} else {
+		oktaClient := client.NewClient(viper.GetString("okta-domain"), secrets.Value("okta-api-key"))
+		for _, access := range oktaAccesses {
+			if access.enabled() {
+				check("okta "+access.scope, oktaClient.CheckAccess(access.uri))
+			}
+		}
+
+		check("state", checkState(viper.GetString("state-path")))
+	}
+
+	for _, result := range output.TestWrite(doctorEvent()) {
+		check("output "+result.Name, result.Err)
+	}
+
+	return report(results)
+}
+
+// Check that the state can be restored and saved
+func checkState(path string) error {
+	if state.Exists(path) {
+		if _, err := state.Restore(path); err != nil {
+			return err
+		}
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), ".okta-doctor-*")
+	if err != nil {
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(file.Name())
+}
+
+// Test event written to outputs, with a collector.doctor event type so it can be told apart from System Log events
+func doctorEvent() []byte {
+	event, _ := json.Marshal(map[string]interface{}{
+		"uuid":           alert.NewUuid(),
+		"published":      time.Now().UTC().Format(time.RFC3339Nano),
+		"eventType":      "collector.doctor",
+		"severity":       "INFO",
+		"displayMessage": "okta-collector doctor test event",
+		"outcome":        map[string]string{"result": "SUCCESS"},
+	})
+
+	return event
+}
+
+// Print the results as a table
+// Returns the exit code, 1 if any check failed.
+func report(results []doctorResult) int {
+	code := 0
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "CHECK\tRESULT\tDETAILS")
+
+	for _, result := range results {
+		if result.err == nil {
+			_, _ = fmt.Fprintf(writer, "%s\tPASS\t\n", result.name)
+			continue
+		}
+
+		code = 1
+		_, _ = fmt.Fprintf(writer, "%s\tFAIL\t%s\n", result.name, strings.Join(strings.Fields(scrub.String(result.err.Error())), " "))
+	}
+
+	_ = writer.Flush()
+	return code
+}
@@ -0,0 +1,222 @@
+// Package ecs converts Okta System Log events to Elastic Common Schema (ECS) events.
+package ecs
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ECS version the events conform to
+const Version = "8.0.0"
+
+// ECS categorization of an Okta event type
+type categorization struct {
+	category []string
+	types    []string
+}
+
+// Okta event types mapped to ECS categorization
+var eventTypeCategorizations = map[string]categorization{
+	"user.session.start":              {[]string{"authentication", "session"}, []string{"start"}},
+	"user.session.end":                {[]string{"authentication", "session"}, []string{"end"}},
+	"user.lifecycle.create":           {[]string{"iam"}, []string{"user", "creation"}},
+	"user.lifecycle.delete.initiated": {[]string{"iam"}, []string{"user", "deletion"}},
+	"group.user_membership.add":       {[]string{"iam"}, []string{"group", "change"}},
+	"group.user_membership.remove":    {[]string{"iam"}, []string{"group", "change"}},
+	"group.lifecycle.create":          {[]string{"iam"}, []string{"group", "creation"}},
+	"group.lifecycle.delete":          {[]string{"iam"}, []string{"group", "deletion"}},
+}
+
+// Okta event type prefixes mapped to ECS categorization
+var eventTypePrefixCategorizations = map[string]categorization{
+	"user.authentication.": {[]string{"authentication"}, []string{"info"}},
+	"user.session.":        {[]string{"session"}, []string{"info"}},
+	"user.mfa.":            {[]string{"authentication", "iam"}, []string{"user", "change"}},
+	"user.account.":        {[]string{"iam"}, []string{"user", "change"}},
+	"user.lifecycle.":      {[]string{"iam"}, []string{"user", "change"}},
+	"group.":               {[]string{"iam"}, []string{"group", "change"}},
+	"policy.":              {[]string{"configuration"}, []string{"change"}},
+	"system.":              {[]string{"configuration"}, []string{"info"}},
+}
+
+// Fields of an Okta event used for conversion
+type oktaEvent struct {
+	Uuid           string `json:"uuid"`
+	Published      string `json:"published"`
+	EventType      string `json:"eventType"`
+	Severity       string `json:"severity"`
+	DisplayMessage string `json:"displayMessage"`
+	Actor          struct {
+		Id          string `json:"id"`
+		Type        string `json:"type"`
+		AlternateId string `json:"alternateId"`
+		DisplayName string `json:"displayName"`
+	} `json:"actor"`
+	Client struct {
+		IpAddress string `json:"ipAddress"`
+		UserAgent struct {
+			RawUserAgent string `json:"rawUserAgent"`
+		} `json:"userAgent"`
+		GeographicalContext *struct {
+			City        string `json:"city"`
+			State       string `json:"state"`
+			Country     string `json:"country"`
+			PostalCode  string `json:"postalCode"`
+			Geolocation *struct {
+				Lat float64 `json:"lat"`
+				Lon float64 `json:"lon"`
+			} `json:"geolocation"`
+		} `json:"geographicalContext"`
+	} `json:"client"`
+	Outcome struct {
+		Result string `json:"result"`
+		Reason string `json:"reason"`
+	} `json:"outcome"`
+	Target []struct {
+		Id          string `json:"id"`
+		Type        string `json:"type"`
+		AlternateId string `json:"alternateId"`
+		DisplayName string `json:"displayName"`
+	} `json:"target"`
+}
+
+// Convert a raw Okta event to an ECS event, keeping the raw event under okta
+func FromEvent(raw []byte) (*Event, error) {
+	var okta oktaEvent
+	if err := json.Unmarshal(raw, &okta); err != nil {
+		return nil, err
+	}
+
+	c := lookupCategorization(okta.EventType)
+	event := &Event{
+		Timestamp: okta.Published,
+		Ecs:       Ecs{Version: Version},
+		Message:   okta.DisplayMessage,
+		Event: EventFields{
+			Id:       okta.Uuid,
+			Kind:     "event",
+			Category: c.category,
+			Type:     c.types,
+			Action:   okta.EventType,
+			Outcome:  outcome(okta.Outcome.Result),
+			Reason:   okta.Outcome.Reason,
+			Severity: severity(okta.Severity),
+			Dataset:  "okta.system",
+			Provider: "okta",
+		},
+		Okta: json.RawMessage(raw),
+	}
+
+	related := &Related{}
+
+	// Actor user
+	if okta.Actor.Type == "User" {
+		event.User = &User{
+			Id:       okta.Actor.Id,
+			Name:     okta.Actor.AlternateId,
+			FullName: okta.Actor.DisplayName,
+		}
+		if strings.Contains(okta.Actor.AlternateId, "@") {
+			event.User.Email = okta.Actor.AlternateId
+		}
+		related.User = append(related.User, okta.Actor.AlternateId)
+	}
+
+	// Target user
+	for _, target := range okta.Target {
+		if target.Type != "User" {
+			continue
+		}
+
+		if event.User == nil {
+			event.User = &User{}
+		}
+		event.User.Target = &TargetUser{
+			Id:       target.Id,
+			Name:     target.AlternateId,
+			FullName: target.DisplayName,
+		}
+		if strings.Contains(target.AlternateId, "@") {
+			event.User.Target.Email = target.AlternateId
+		}
+		related.User = append(related.User, target.AlternateId)
+		break
+	}
+
+	// Source
+	if okta.Client.IpAddress != "" {
+		event.Source = &Source{Ip: okta.Client.IpAddress}
+		related.Ip = append(related.Ip, okta.Client.IpAddress)
+
+		if geo := okta.Client.GeographicalContext; geo != nil {
+			event.Source.Geo = &Geo{
+				CityName:    geo.City,
+				RegionName:  geo.State,
+				CountryName: geo.Country,
+				PostalCode:  geo.PostalCode,
+			}
+			if geo.Geolocation != nil {
+				event.Source.Geo.Location = &GeoLocation{Lat: geo.Geolocation.Lat, Lon: geo.Geolocation.Lon}
+			}
+		}
+	}
+
+	if okta.Client.UserAgent.RawUserAgent != "" {
+		event.UserAgent = &UserAgent{Original: okta.Client.UserAgent.RawUserAgent}
+	}
+
+	if len(related.Ip) > 0 || len(related.User) > 0 {
+		event.Related = related
+	}
+
+	return event, nil
+}
+
+// Find the ECS categorization of an Okta event type
+func lookupCategorization(eventType string) categorization {
+	if c, ok := eventTypeCategorizations[eventType]; ok {
+		return c
+	}
+
+	// Longest matching prefix
+	var match categorization
+	longest := 0
+	for prefix, c := range eventTypePrefixCategorizations {
+		if strings.HasPrefix(eventType, prefix) && len(prefix) > longest {
+			match = c
+			longest = len(prefix)
+		}
+	}
+
+	return match
+}
+
+// Map an Okta outcome result to an ECS event outcome
+func outcome(result string) string {
+	switch result {
+	case "SUCCESS", "ALLOW":
+		return "success"
+	case "FAILURE", "DENY":
+		return "failure"
+	case "":
+		return ""
+	default:
+		return "unknown"
+	}
+}
+
+// Map an Okta severity to a numeric ECS severity, using the syslog scale
+func severity(oktaSeverity string) int {
+	switch oktaSeverity {
+	case "DEBUG":
+		return 7
+	case "INFO":
+		return 6
+	case "WARN":
+		return 4
+	case "ERROR":
+		return 3
+	default:
+		return 0
+	}
+}
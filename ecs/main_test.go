@@ -0,0 +1,155 @@
+package ecs
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Okta event of a user signing in to an app
+const testEvent = `{
+	"uuid": "b8d6cb1e-0a38-11ee-9a3c-4f1f0a1b2c3d",
+	"published": "2023-06-12T14:03:21.000Z",
+	"eventType": "user.authentication.sso",
+	"severity": "INFO",
+	"displayMessage": "User single sign on to app",
+	"actor": {"id": "00u1abcd", "type": "User", "alternateId": "jane.doe@example.com", "displayName": "Jane Doe"},
+	"client": {
+		"ipAddress": "203.0.113.7",
+		"userAgent": {"rawUserAgent": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7)"},
+		"geographicalContext": {"city": "Portland", "state": "Oregon", "country": "United States", "postalCode": "97201", "geolocation": {"lat": 45.5, "lon": -122.6}}
+	},
+	"outcome": {"result": "SUCCESS"},
+	"target": [
+		{"id": "0oa1abcd", "type": "AppInstance", "alternateId": "Salesforce", "displayName": "Salesforce"},
+		{"id": "00u2efgh", "type": "User", "alternateId": "john.roe@example.com", "displayName": "John Roe"}
+	]
+}`
+
+func TestFromEvent(t *testing.T) {
+	event, err := FromEvent([]byte(testEvent))
+	if err != nil {
+		t.Fatalf("unable to convert event: %v", err)
+	}
+
+	if event.Timestamp != "2023-06-12T14:03:21.000Z" || event.Ecs.Version != Version || event.Message != "User single sign on to app" {
+		t.Errorf("unexpected timestamp, version or message: %+v", event)
+	}
+
+	expected := EventFields{
+		Id:       "b8d6cb1e-0a38-11ee-9a3c-4f1f0a1b2c3d",
+		Kind:     "event",
+		Category: []string{"authentication"},
+		Type:     []string{"info"},
+		Action:   "user.authentication.sso",
+		Outcome:  "success",
+		Severity: 6,
+		Dataset:  "okta.system",
+		Provider: "okta",
+	}
+	if !reflect.DeepEqual(event.Event, expected) {
+		t.Errorf("expected event fields %+v, got %+v", expected, event.Event)
+	}
+
+	expectedUser := &User{
+		Id:       "00u1abcd",
+		Name:     "jane.doe@example.com",
+		FullName: "Jane Doe",
+		Email:    "jane.doe@example.com",
+		Target:   &TargetUser{Id: "00u2efgh", Name: "john.roe@example.com", FullName: "John Roe", Email: "john.roe@example.com"},
+	}
+	if !reflect.DeepEqual(event.User, expectedUser) {
+		t.Errorf("expected user %+v, got %+v", expectedUser, event.User)
+	}
+
+	expectedSource := &Source{Ip: "203.0.113.7", Geo: &Geo{CityName: "Portland", RegionName: "Oregon", CountryName: "United States", PostalCode: "97201", Location: &GeoLocation{Lat: 45.5, Lon: -122.6}}}
+	if !reflect.DeepEqual(event.Source, expectedSource) {
+		t.Errorf("expected source %+v, got %+v", expectedSource, event.Source)
+	}
+
+	if event.UserAgent == nil || event.UserAgent.Original != "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7)" {
+		t.Errorf("unexpected user agent %+v", event.UserAgent)
+	}
+
+	expectedRelated := &Related{Ip: []string{"203.0.113.7"}, User: []string{"jane.doe@example.com", "john.roe@example.com"}}
+	if !reflect.DeepEqual(event.Related, expectedRelated) {
+		t.Errorf("expected related %+v, got %+v", expectedRelated, event.Related)
+	}
+
+	if string(event.Okta) != testEvent {
+		t.Error("expected the raw event to be kept under okta")
+	}
+}
+
+func TestFromEventOmitsMissingFields(t *testing.T) {
+	event, err := FromEvent([]byte(`{"uuid": "1", "eventType": "system.org.rate_limit.warning", "actor": {"type": "PublicClientApp", "alternateId": "app"}}`))
+	if err != nil {
+		t.Fatalf("unable to convert event: %v", err)
+	}
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("unable to encode event: %v", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		t.Fatalf("unable to decode event: %v", err)
+	}
+
+	for _, field := range []string{"user", "source", "user_agent", "related", "message"} {
+		if _, ok := fields[field]; ok {
+			t.Errorf("expected %s to be omitted from %s", field, encoded)
+		}
+	}
+
+	if event.Event.Outcome != "" || event.Event.Severity != 0 {
+		t.Errorf("expected no outcome and severity, got %+v", event.Event)
+	}
+}
+
+func TestFromEventInvalid(t *testing.T) {
+	if _, err := FromEvent([]byte(`{"uuid": `)); err == nil {
+		t.Error("expected a truncated event to be invalid")
+	}
+}
+
+func TestLookupCategorization(t *testing.T) {
+	tests := []struct {
+		eventType string
+		category  []string
+		types     []string
+	}{
+		{"user.session.start", []string{"authentication", "session"}, []string{"start"}},
+		{"user.session.access_admin_app", []string{"session"}, []string{"info"}},
+		{"user.authentication.auth_via_mfa", []string{"authentication"}, []string{"info"}},
+		{"user.lifecycle.create", []string{"iam"}, []string{"user", "creation"}},
+		{"user.lifecycle.suspend", []string{"iam"}, []string{"user", "change"}},
+		{"group.user_membership.add", []string{"iam"}, []string{"group", "change"}},
+		{"policy.lifecycle.update", []string{"configuration"}, []string{"change"}},
+		{"application.lifecycle.create", nil, nil},
+	}
+
+	for _, test := range tests {
+		c := lookupCategorization(test.eventType)
+		if !reflect.DeepEqual(c.category, test.category) || !reflect.DeepEqual(c.types, test.types) {
+			t.Errorf("expected %s to be %v %v, got %v %v", test.eventType, test.category, test.types, c.category, c.types)
+		}
+	}
+}
+
+func TestOutcomeAndSeverity(t *testing.T) {
+	outcomes := map[string]string{"SUCCESS": "success", "ALLOW": "success", "FAILURE": "failure", "DENY": "failure", "CHALLENGE": "unknown", "": ""}
+	for result, expected := range outcomes {
+		if actual := outcome(result); actual != expected {
+			t.Errorf("expected outcome %s to be %q, got %q", result, expected, actual)
+		}
+	}
+
+	severities := map[string]int{"DEBUG": 7, "INFO": 6, "WARN": 4, "ERROR": 3, "": 0}
+	for oktaSeverity, expected := range severities {
+		if actual := severity(oktaSeverity); actual != expected {
+			t.Errorf("expected severity %s to be %d, got %d", oktaSeverity, expected, actual)
+		}
+	}
+}
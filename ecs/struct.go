@@ -0,0 +1,78 @@
+package ecs
+
+import (
+	"encoding/json"
+)
+
+// ECS event with the original Okta event under okta
+type Event struct {
+	Timestamp string          `json:"@timestamp"`
+	Ecs       Ecs             `json:"ecs"`
+	Message   string          `json:"message,omitempty"`
+	Event     EventFields     `json:"event"`
+	User      *User           `json:"user,omitempty"`
+	Source    *Source         `json:"source,omitempty"`
+	UserAgent *UserAgent      `json:"user_agent,omitempty"`
+	Related   *Related        `json:"related,omitempty"`
+	Okta      json.RawMessage `json:"okta"`
+}
+
+type Ecs struct {
+	Version string `json:"version"`
+}
+
+type EventFields struct {
+	Id       string   `json:"id"`
+	Kind     string   `json:"kind"`
+	Category []string `json:"category,omitempty"`
+	Type     []string `json:"type,omitempty"`
+	Action   string   `json:"action"`
+	Outcome  string   `json:"outcome,omitempty"`
+	Reason   string   `json:"reason,omitempty"`
+	Severity int      `json:"severity,omitempty"`
+	Dataset  string   `json:"dataset"`
+	Provider string   `json:"provider"`
+	Created  string   `json:"created,omitempty"`
+}
+
+type User struct {
+	Id       string      `json:"id,omitempty"`
+	Name     string      `json:"name,omitempty"`
+	FullName string      `json:"full_name,omitempty"`
+	Email    string      `json:"email,omitempty"`
+	Target   *TargetUser `json:"target,omitempty"`
+}
+
+type TargetUser struct {
+	Id       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	FullName string `json:"full_name,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+type Source struct {
+	Ip  string `json:"ip,omitempty"`
+	Geo *Geo   `json:"geo,omitempty"`
+}
+
+type Geo struct {
+	CityName    string       `json:"city_name,omitempty"`
+	RegionName  string       `json:"region_name,omitempty"`
+	CountryName string       `json:"country_name,omitempty"`
+	PostalCode  string       `json:"postal_code,omitempty"`
+	Location    *GeoLocation `json:"location,omitempty"`
+}
+
+type GeoLocation struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type UserAgent struct {
+	Original string `json:"original"`
+}
+
+type Related struct {
+	Ip   []string `json:"ip,omitempty"`
+	User []string `json:"user,omitempty"`
+}
@@ -0,0 +1,99 @@
+package enrich
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/client"
+	"github.com/spf13/viper"
+)
+
+// Metadata of an app instance added to events
+type app struct {
+	Id         string      `json:"id"`
+	Name       string      `json:"name"`
+	Label      string      `json:"label"`
+	Status     string      `json:"status"`
+	SignOnMode string      `json:"signOnMode"`
+	Owner      interface{} `json:"owner,omitempty"`
+}
+
+// Cache of the metadata of app instances by ID, refreshed in the background
+type appCache struct {
+	mu             sync.RWMutex
+	client         *client.OktaClient
+	ownerAttribute string
+	apps           map[string]*app
+}
+
+// App cache created from the params by Setup
+var apps *appCache
+
+// Create the app cache, loading apps and refreshing them in the background every interval
+// Returns nil when app enrichment is disabled.
+func newAppCache(interval time.Duration) *appCache {
+	if !viper.GetBool("enrich-apps") {
+		return nil
+	}
+
+	c := &appCache{
+		client:         newOktaClient(),
+		ownerAttribute: viper.GetString("app-owner-attribute"),
+		apps:           make(map[string]*app),
+	}
+
+	// Events are enriched without apps until they can be loaded
+	if err := c.refresh(); err != nil {
+		log.Printf("Unable to load apps: %v\n", err)
+	}
+
+	if interval > 0 {
+		go func() {
+			for {
+				<-time.After(interval)
+				if err := c.refresh(); err != nil {
+					log.Printf("Unable to refresh apps: %v\n", err)
+				}
+			}
+		}()
+	}
+
+	return c
+}
+
+// Reload the apps of the org
+// The previous apps are kept when they can't be loaded.
+func (c *appCache) refresh() error {
+	orgApps, err := c.client.GetApps()
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]*app)
+	for _, a := range orgApps {
+		loaded[a.Id] = &app{
+			Id:         a.Id,
+			Name:       a.Name,
+			Label:      a.Label,
+			Status:     a.Status,
+			SignOnMode: a.SignOnMode,
+			Owner:      a.Profile[c.ownerAttribute],
+		}
+	}
+
+	c.mu.Lock()
+	c.apps = loaded
+	c.mu.Unlock()
+
+	log.Printf("Loaded %d apps\n", len(loaded))
+	return nil
+}
+
+// Get the cached metadata of an app instance
+func (c *appCache) get(id string) *app {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.apps[id]
+}
@@ -0,0 +1,145 @@
+package enrich
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// How often database files are checked for updates, such as by geoipupdate
+const geoipReloadInterval = time.Minute
+
+// Geo fields of a client IP
+type geo struct {
+	City           string  `json:"city,omitempty"`
+	Subdivision    string  `json:"subdivision,omitempty"`
+	Country        string  `json:"country,omitempty"`
+	CountryIsoCode string  `json:"countryIsoCode,omitempty"`
+	PostalCode     string  `json:"postalCode,omitempty"`
+	Lat            float64 `json:"lat"`
+	Lon            float64 `json:"lon"`
+	TimeZone       string  `json:"timeZone,omitempty"`
+}
+
+// Autonomous system of a client IP
+type asn struct {
+	Number       uint   `json:"number"`
+	Organization string `json:"organization,omitempty"`
+}
+
+// A MaxMind database reopened when its file changes
+type geoipDatabase struct {
+	mu        sync.Mutex
+	path      string
+	reader    *geoip2.Reader
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// City and ASN databases opened from the params by Setup
+var cityDatabase, asnDatabase *geoipDatabase
+
+// Open a MaxMind database
+// Returns nil if no path is set.
+func openGeoipDatabase(path string) (*geoipDatabase, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	db := &geoipDatabase{path: path}
+	if err := db.open(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Check that a MaxMind database can be opened
+func validateGeoipDatabase(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return err
+	}
+
+	return reader.Close()
+}
+
+func (db *geoipDatabase) open() error {
+	info, err := os.Stat(db.path)
+	if err != nil {
+		return err
+	}
+
+	reader, err := geoip2.Open(db.path)
+	if err != nil {
+		return err
+	}
+
+	if db.reader != nil {
+		_ = db.reader.Close()
+	}
+
+	db.reader = reader
+	db.modTime = info.ModTime()
+	db.checkedAt = time.Now()
+	return nil
+}
+
+// Get the reader of the database, reopening the database if its file changed
+// A database that fails to reopen keeps being read from the previous file.
+func (db *geoipDatabase) get() *geoip2.Reader {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if time.Since(db.checkedAt) >= geoipReloadInterval {
+		db.checkedAt = time.Now()
+		if info, err := os.Stat(db.path); err == nil && !info.ModTime().Equal(db.modTime) {
+			_ = db.open()
+		}
+	}
+
+	return db.reader
+}
+
+// Look up the geo fields of an IP
+// Returns nil if the IP isn't in the database.
+func lookupGeo(ip net.IP) *geo {
+	record, err := cityDatabase.get().City(ip)
+	if err != nil || record.Country.IsoCode == "" {
+		return nil
+	}
+
+	result := &geo{
+		City:           record.City.Names["en"],
+		Country:        record.Country.Names["en"],
+		CountryIsoCode: record.Country.IsoCode,
+		PostalCode:     record.Postal.Code,
+		Lat:            record.Location.Latitude,
+		Lon:            record.Location.Longitude,
+		TimeZone:       record.Location.TimeZone,
+	}
+
+	if len(record.Subdivisions) > 0 {
+		result.Subdivision = record.Subdivisions[0].Names["en"]
+	}
+
+	return result
+}
+
+// Look up the autonomous system of an IP
+// Returns nil if the IP isn't in the database.
+func lookupAsn(ip net.IP) *asn {
+	record, err := asnDatabase.get().ASN(ip)
+	if err != nil || record.AutonomousSystemNumber == 0 {
+		return nil
+	}
+
+	return &asn{Number: record.AutonomousSystemNumber, Organization: record.AutonomousSystemOrganization}
+}
@@ -0,0 +1,114 @@
+package enrich
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/client"
+	"github.com/rfizzle/okta-collector/match"
+	"github.com/spf13/viper"
+)
+
+// Cache of the names of the groups of users, refreshed in the background
+type groupCache struct {
+	mu     sync.RWMutex
+	client *client.OktaClient
+	names  []string
+	groups map[string][]string
+}
+
+// Group cache created from the params by Setup
+var groups *groupCache
+
+// Create the group cache, loading memberships and refreshing them in the background every interval
+// Returns nil when group enrichment is disabled.
+func newGroupCache(interval time.Duration) *groupCache {
+	if !viper.GetBool("enrich-groups") {
+		return nil
+	}
+
+	c := &groupCache{
+		client: newOktaClient(),
+		names:  viper.GetStringSlice("group-names"),
+		groups: make(map[string][]string),
+	}
+
+	// Events are enriched without groups until memberships can be loaded
+	if err := c.refresh(); err != nil {
+		log.Printf("Unable to load group memberships: %v\n", err)
+	}
+
+	if interval > 0 {
+		go func() {
+			for {
+				<-time.After(interval)
+				if err := c.refresh(); err != nil {
+					log.Printf("Unable to refresh group memberships: %v\n", err)
+				}
+			}
+		}()
+	}
+
+	return c
+}
+
+// Reload the memberships of the groups matching the group names
+// The previous memberships are kept when they can't be loaded.
+func (c *groupCache) refresh() error {
+	orgGroups, err := c.client.GetGroups()
+	if err != nil {
+		return err
+	}
+
+	memberships := make(map[string][]string)
+	for _, group := range orgGroups {
+		if !c.matches(group.Profile.Name) {
+			continue
+		}
+
+		ids, err := c.client.GetGroupMemberIds(group.Id)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			memberships[id] = append(memberships[id], group.Profile.Name)
+		}
+	}
+
+	for _, names := range memberships {
+		sort.Strings(names)
+	}
+
+	c.mu.Lock()
+	c.groups = memberships
+	c.mu.Unlock()
+
+	log.Printf("Loaded group memberships of %d users\n", len(memberships))
+	return nil
+}
+
+// Check if a group is cached
+func (c *groupCache) matches(name string) bool {
+	if len(c.names) == 0 {
+		return true
+	}
+
+	for _, pattern := range c.names {
+		if match.Pattern(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Names of the cached groups of a user
+func (c *groupCache) userGroups(id string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.groups[id]
+}
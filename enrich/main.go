@@ -0,0 +1,395 @@
+// Package enrich adds collector derived fields to events before they reach the outputs.
+package enrich
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/rfizzle/okta-collector/attack"
+	"github.com/rfizzle/okta-collector/expression"
+	"github.com/rfizzle/okta-collector/filter"
+	"github.com/rfizzle/okta-collector/iplist"
+	"github.com/rfizzle/okta-collector/match"
+	"github.com/rfizzle/okta-collector/schema"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Field of events that collector derived fields are added under
+const collectorField = "collector"
+
+// Fields of an event used for enrichment
+type eventFields struct {
+	EventType string `json:"eventType"`
+	Actor     struct {
+		Id   string `json:"id"`
+		Type string `json:"type"`
+	} `json:"actor"`
+	Target []struct {
+		Id   string `json:"id"`
+		Type string `json:"type"`
+	} `json:"target"`
+	Client struct {
+		IpAddress string `json:"ipAddress"`
+		UserAgent struct {
+			RawUserAgent string `json:"rawUserAgent"`
+		} `json:"userAgent"`
+	} `json:"client"`
+	Outcome struct {
+		Result string `json:"result"`
+	} `json:"outcome"`
+	DebugContext struct {
+		DebugData riskDebugData `json:"debugData"`
+	} `json:"debugContext"`
+}
+
+// A CIDR list tagging the events of client IPs inside it
+type cidrTag struct {
+	tag  string
+	list *iplist.List
+}
+
+// A field computed from an expression over the event
+type computedField struct {
+	name    string
+	program *expression.Program
+}
+
+// CIDR tags parsed from the params by Setup
+var cidrTags []cidrTag
+
+// Computed fields compiled from the params by Setup
+var computedFields []computedField
+
+// ATT&CK mapper created from the params by Setup
+var attackMapper *attack.Mapper
+
+// Register the enrichment params
+func InitCLIParams() {
+	flag.StringSlice("cidr-tags", []string{}, "tags added to events with a client ip in a cidr range, as tag=cidr or tag=@file")
+	flag.Bool("parse-user-agents", false, "add the browser, os and device parsed from the client user agent")
+	flag.String("geoip-city-database", "", "path of a maxmind city database to add the geo fields of client ips")
+	flag.String("geoip-asn-database", "", "path of a maxmind asn database to add the autonomous system of client ips")
+	flag.StringSlice("threat-intel-lists", []string{}, "threat intel lists tagging events of matching client ips, as name=path or name=url of a cidr list or misp json export")
+	flag.String("threat-intel-api-key", "", "api key sent in the authorization header when fetching threat intel lists, such as a misp auth key")
+	flag.Int("threat-intel-refresh", 3600, "seconds between reloads of threat intel lists (0 to disable)")
+	flag.Bool("enrich-users", false, "add profile attributes of the actor, looked up from the users api")
+	flag.StringSlice("user-attributes", []string{"department", "title", "manager", "employeeType"}, "profile attributes of the actor added by user enrichment")
+	flag.Int("user-cache-ttl", 3600, "seconds user profiles are cached for")
+	flag.Bool("enrich-groups", false, "add the names of the groups of the actor, from a cache of group memberships")
+	flag.StringSlice("group-names", []string{}, "names of the groups added by group enrichment, supports wildcards (default all)")
+	flag.Int("group-refresh", 3600, "seconds between refreshes of group memberships (0 to disable)")
+	flag.Bool("enrich-apps", false, "add the metadata of app instance targets, from a cache of the apps api")
+	flag.String("app-owner-attribute", "owner", "app profile attribute holding the owner of app instances")
+	flag.Int("app-refresh", 3600, "seconds between refreshes of app metadata (0 to disable)")
+	flag.Bool("attack-tagging", false, "add the mitre att&ck techniques of events")
+	flag.String("attack-mapping-file", "", "path of a json file extending the bundled att&ck mappings")
+	flag.Bool("normalize-risk", false, "add the risk level, reasons, behaviors and threat suspected of events, parsed from debug data")
+	flag.Bool("received-time", false, "add the time events are received by the collector")
+	flag.StringArray("computed-fields", []string{}, "fields added to events from cel expressions over the event, as name=expression")
+}
+
+// Validate the enrichment params
+func ValidateCLIParams() error {
+	if _, err := parseCidrTags(viper.GetStringSlice("cidr-tags")); err != nil {
+		return errors.New(fmt.Sprintf("invalid cidr tags param (--cidr-tags): %v", err))
+	}
+
+	if _, err := parseComputedFields(viper.GetStringSlice("computed-fields")); err != nil {
+		return errors.New(fmt.Sprintf("invalid computed fields param (--computed-fields): %v", err))
+	}
+
+	if viper.GetInt("user-cache-ttl") <= 0 {
+		return errors.New("invalid user cache ttl param (--user-cache-ttl)")
+	}
+
+	if viper.GetInt("group-refresh") < 0 {
+		return errors.New("invalid group refresh param (--group-refresh)")
+	}
+
+	for _, pattern := range viper.GetStringSlice("group-names") {
+		if !match.ValidPattern(pattern) {
+			return errors.New(fmt.Sprintf("invalid group names param (--group-names): invalid pattern %s", pattern))
+		}
+	}
+
+	if viper.GetInt("app-refresh") < 0 {
+		return errors.New("invalid app refresh param (--app-refresh)")
+	}
+
+	if _, err := attack.NewMapper(viper.GetString("attack-mapping-file")); err != nil {
+		return errors.New(fmt.Sprintf("invalid attack mapping file param (--attack-mapping-file): %v", err))
+	}
+
+	if _, err := parseThreatIntelLists(viper.GetStringSlice("threat-intel-lists")); err != nil {
+		return errors.New(fmt.Sprintf("invalid threat intel lists param (--threat-intel-lists): %v", err))
+	}
+
+	if viper.GetInt("threat-intel-refresh") < 0 {
+		return errors.New("invalid threat intel refresh param (--threat-intel-refresh)")
+	}
+
+	if err := validateGeoipDatabase(viper.GetString("geoip-city-database")); err != nil {
+		return errors.New(fmt.Sprintf("invalid geoip city database param (--geoip-city-database): %v", err))
+	}
+
+	if err := validateGeoipDatabase(viper.GetString("geoip-asn-database")); err != nil {
+		return errors.New(fmt.Sprintf("invalid geoip asn database param (--geoip-asn-database): %v", err))
+	}
+
+	return nil
+}
+
+// Setup the enrichments from the validated params
+func Setup() error {
+	var err error
+	if cidrTags, err = parseCidrTags(viper.GetStringSlice("cidr-tags")); err != nil {
+		return err
+	}
+
+	if computedFields, err = parseComputedFields(viper.GetStringSlice("computed-fields")); err != nil {
+		return err
+	}
+
+	if cityDatabase, err = openGeoipDatabase(viper.GetString("geoip-city-database")); err != nil {
+		return err
+	}
+
+	if asnDatabase, err = openGeoipDatabase(viper.GetString("geoip-asn-database")); err != nil {
+		return err
+	}
+
+	if viper.GetBool("attack-tagging") {
+		if attackMapper, err = attack.NewMapper(viper.GetString("attack-mapping-file")); err != nil {
+			return err
+		}
+	}
+
+	users = newUserCache()
+	groups = newGroupCache(time.Duration(viper.GetInt("group-refresh")) * time.Second)
+	apps = newAppCache(time.Duration(viper.GetInt("app-refresh")) * time.Second)
+
+	refresh := time.Duration(viper.GetInt("threat-intel-refresh")) * time.Second
+	return setupThreatIntel(viper.GetStringSlice("threat-intel-lists"), refresh)
+}
+
+// Enrich an event
+// Returns the event unchanged when no enrichment applies.
+func Event(event []byte) ([]byte, error) {
+	if !enabled() {
+		return event, nil
+	}
+
+	var fields eventFields
+	if err := json.Unmarshal(event, &fields); err != nil {
+		return nil, err
+	}
+
+	added := make(map[string]interface{})
+
+	// Receive time
+	if viper.GetBool("received-time") {
+		added["receivedAt"] = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+
+	// User agent
+	if viper.GetBool("parse-user-agents") {
+		if parsed := parseUserAgent(fields.Client.UserAgent.RawUserAgent); parsed != nil {
+			added["userAgent"] = parsed
+		}
+	}
+
+	// GeoIP
+	if ip := net.ParseIP(fields.Client.IpAddress); ip != nil {
+		if cityDatabase != nil {
+			if g := lookupGeo(ip); g != nil {
+				added["geo"] = g
+			}
+		}
+
+		if asnDatabase != nil {
+			if a := lookupAsn(ip); a != nil {
+				added["asn"] = a
+			}
+		}
+	}
+
+	// Actor profile
+	if users != nil && fields.Actor.Type == "User" && fields.Actor.Id != "" {
+		if attributes := users.attributes(fields.Actor.Id); len(attributes) > 0 {
+			added["actor"] = attributes
+		}
+	}
+
+	// Actor groups
+	if groups != nil && fields.Actor.Type == "User" && fields.Actor.Id != "" {
+		if names := groups.userGroups(fields.Actor.Id); len(names) > 0 {
+			added["actorGroups"] = names
+		}
+	}
+
+	// Target apps
+	if apps != nil {
+		var targetApps []*app
+		for _, target := range fields.Target {
+			if target.Type != "AppInstance" {
+				continue
+			}
+			if a := apps.get(target.Id); a != nil {
+				targetApps = append(targetApps, a)
+			}
+		}
+
+		if len(targetApps) > 0 {
+			added["apps"] = targetApps
+		}
+	}
+
+	// Risk signals
+	if viper.GetBool("normalize-risk") {
+		if r := normalizeRisk(fields.DebugContext.DebugData); r != nil {
+			added["risk"] = r
+		}
+	}
+
+	// ATT&CK techniques
+	if attackMapper != nil {
+		if techniques := attackMapper.Techniques(fields.EventType, fields.Outcome.Result); len(techniques) > 0 {
+			added["attack"] = techniques
+		}
+	}
+
+	// Threat intel
+	if matches := threatIntelMatches(fields.Client.IpAddress); len(matches) > 0 {
+		added["threatIntel"] = matches
+	}
+
+	// Tags
+	var tags []string
+	for _, t := range cidrTags {
+		if t.list.Contains(fields.Client.IpAddress) && !contains(tags, t.tag) {
+			tags = append(tags, t.tag)
+		}
+	}
+
+	if len(tags) > 0 {
+		added["tags"] = tags
+	}
+
+	// Schema violations, so downstream can tell events of unexpected shapes apart
+	if schema.Tags() {
+		if violations := schema.Validate(event); len(violations) > 0 {
+			added["schemaViolations"] = violations
+		}
+	}
+
+	// Sample rate, so downstream can weight sampled events
+	if rate := filter.SampleRate(fields.EventType, fields.Outcome.Result); rate < 1 {
+		added["sampleRate"] = rate
+	}
+
+	// Computed fields
+	if len(computedFields) > 0 {
+		computed, err := computeFields(event)
+		if err != nil {
+			return nil, err
+		}
+		added["fields"] = computed
+	}
+
+	if len(added) == 0 {
+		return event, nil
+	}
+
+	return AddCollectorFields(event, added)
+}
+
+// Check if any enrichment is enabled
+func enabled() bool {
+	return len(cidrTags) > 0 || len(computedFields) > 0 || filter.Sampling() || viper.GetBool("received-time") ||
+		viper.GetBool("parse-user-agents") || cityDatabase != nil || asnDatabase != nil ||
+		threatIntelEnabled() || users != nil || groups != nil ||
+		apps != nil || attackMapper != nil ||
+		viper.GetBool("normalize-risk") || schema.Tags()
+}
+
+// Parse tag=cidr pairs, grouping the ranges of each tag
+func parseCidrTags(pairs []string) ([]cidrTag, error) {
+	values := make(map[string][]string)
+	var order []string
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New(fmt.Sprintf("invalid cidr tag %s, expected tag=cidr", pair))
+		}
+
+		if _, ok := values[parts[0]]; !ok {
+			order = append(order, parts[0])
+		}
+		values[parts[0]] = append(values[parts[0]], parts[1])
+	}
+
+	var tags []cidrTag
+	for _, tag := range order {
+		list, err := iplist.Parse(values[tag])
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, cidrTag{tag: tag, list: list})
+	}
+
+	return tags, nil
+}
+
+// Parse name=expression pairs, compiling the expressions
+func parseComputedFields(pairs []string) ([]computedField, error) {
+	var fields []computedField
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, errors.New(fmt.Sprintf("invalid computed field %s, expected name=expression", pair))
+		}
+
+		program, err := expression.CompileValue(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, computedField{name: name, program: program})
+	}
+
+	return fields, nil
+}
+
+// Evaluate the computed fields of an event
+func computeFields(event []byte) (map[string]interface{}, error) {
+	parsed, err := expression.ParseEvent(event)
+	if err != nil {
+		return nil, err
+	}
+
+	computed := make(map[string]interface{})
+	for _, field := range computedFields {
+		value, err := field.program.EvalValue(parsed)
+		if err != nil {
+			return nil, err
+		}
+		computed[field.name] = value
+	}
+
+	return computed, nil
+}
+
+// Check if a slice contains a value
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
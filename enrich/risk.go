@@ -0,0 +1,81 @@
+package enrich
+
+import (
+	"strings"
+
+	"github.com/rfizzle/okta-collector/debugdata"
+)
+
+// Okta Identity Engine risk signals of an event, normalized from debug data strings
+type risk struct {
+	Level           string          `json:"level,omitempty"`
+	Reasons         []string        `json:"reasons,omitempty"`
+	Behaviors       map[string]bool `json:"behaviors,omitempty"`
+	ThreatSuspected *bool           `json:"threatSuspected,omitempty"`
+}
+
+// Debug data of an event used for risk normalization
+// Values are usually strings, but are parsed as is when they aren't.
+type riskDebugData struct {
+	Risk                interface{} `json:"risk"`
+	Behaviors           interface{} `json:"behaviors"`
+	ThreatSuspected     interface{} `json:"threatSuspected"`
+	LogOnlySecurityData interface{} `json:"logOnlySecurityData"`
+}
+
+// Normalize the risk signals of debug data
+// Reads the risk, behaviors and threatSuspected keys, falling back to the logOnlySecurityData JSON of newer events.
+// Returns nil when the event has no risk signals.
+func normalizeRisk(data riskDebugData) *risk {
+	r := &risk{}
+
+	security, _ := parseDebugData(data.LogOnlySecurityData).(map[string]interface{})
+
+	// Level and reasons
+	riskObject, _ := parseDebugData(data.Risk).(map[string]interface{})
+	if riskObject == nil {
+		riskObject, _ = security["risk"].(map[string]interface{})
+	}
+	if level, ok := riskObject["level"].(string); ok {
+		r.Level = strings.ToUpper(level)
+	}
+	if reasons, ok := riskObject["reasons"].(string); ok && reasons != "" {
+		for _, reason := range strings.Split(reasons, ",") {
+			r.Reasons = append(r.Reasons, strings.TrimSpace(reason))
+		}
+	}
+
+	// Behaviors, POSITIVE when the sign in shows the behavior
+	behaviors, _ := parseDebugData(data.Behaviors).(map[string]interface{})
+	if behaviors == nil {
+		behaviors, _ = security["behaviors"].(map[string]interface{})
+	}
+	for name, value := range behaviors {
+		if s, ok := value.(string); ok {
+			if r.Behaviors == nil {
+				r.Behaviors = make(map[string]bool)
+			}
+			r.Behaviors[name] = strings.EqualFold(s, "POSITIVE")
+		}
+	}
+
+	// Threat suspected
+	if suspected, ok := parseDebugData(data.ThreatSuspected).(bool); ok {
+		r.ThreatSuspected = &suspected
+	}
+
+	if r.Level == "" && len(r.Reasons) == 0 && len(r.Behaviors) == 0 && r.ThreatSuspected == nil {
+		return nil
+	}
+
+	return r
+}
+
+// Parse a debug data value that may already have been decoded as a boolean or object
+func parseDebugData(value interface{}) interface{} {
+	if s, ok := value.(string); ok {
+		return debugdata.ParseValue(s)
+	}
+
+	return value
+}
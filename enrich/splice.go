@@ -0,0 +1,131 @@
+package enrich
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+// Offsets of a value in a JSON document
+type span struct {
+	start int
+	end   int
+}
+
+// Merge fields into the collector field of an event
+// The fields are spliced in, leaving the rest of the event byte for byte as received.
+func AddCollectorFields(event []byte, fields map[string]interface{}) ([]byte, error) {
+	values := make(map[string][]byte)
+	for key, value := range fields {
+		raw, err := marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = raw
+	}
+
+	spans, _, err := objectSpans(event)
+	if err != nil {
+		return nil, err
+	}
+
+	collector := []byte("{}")
+	if s, ok := spans[collectorField]; ok && event[s.start] == '{' {
+		collector = event[s.start:s.end]
+	}
+
+	merged, err := setKeys(collector, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return setKeys(event, map[string][]byte{collectorField: merged})
+}
+
+// Marshal a value without escaping HTML characters, as Okta doesn't
+func marshal(value interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(b.Bytes(), "\n"), nil
+}
+
+// Find the offsets of the values of an object's keys and of its closing brace
+func objectSpans(object []byte) (map[string]span, int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(object))
+	if token, err := decoder.Token(); err != nil {
+		return nil, 0, err
+	} else if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, 0, errors.New("event is not a json object")
+	}
+
+	spans := make(map[string]span)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, 0, err
+		}
+
+		end := int(decoder.InputOffset())
+		spans[token.(string)] = span{start: end - len(value), end: end}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, 0, err
+	}
+
+	return spans, int(decoder.InputOffset()) - 1, nil
+}
+
+// Set keys of an object to raw values, replacing values in place and adding new keys before the closing brace
+func setKeys(object []byte, values map[string][]byte) ([]byte, error) {
+	spans, closing, err := objectSpans(object)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace existing values in the order they appear, then add new keys in name order
+	var replaced []string
+	var added []string
+	for key := range values {
+		if _, ok := spans[key]; ok {
+			replaced = append(replaced, key)
+		} else {
+			added = append(added, key)
+		}
+	}
+	sort.Slice(replaced, func(i, j int) bool { return spans[replaced[i]].start < spans[replaced[j]].start })
+	sort.Strings(added)
+
+	var b bytes.Buffer
+	offset := 0
+	for _, key := range replaced {
+		b.Write(object[offset:spans[key].start])
+		b.Write(values[key])
+		offset = spans[key].end
+	}
+	b.Write(object[offset:closing])
+
+	for i, key := range added {
+		if i > 0 || len(spans) > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := marshal(key)
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(values[key])
+	}
+	b.Write(object[closing:])
+
+	return b.Bytes(), nil
+}
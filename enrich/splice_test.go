@@ -0,0 +1,89 @@
+package enrich
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAddCollectorFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    string
+		fields   map[string]interface{}
+		expected string
+	}{
+		{
+			"added after the last field",
+			`{"uuid":"1","eventType":"user.session.start"}`,
+			map[string]interface{}{"tenant": "acme"},
+			`{"uuid":"1","eventType":"user.session.start","collector":{"tenant":"acme"}}`,
+		},
+		{
+			"keeps order, spacing and escapes of the event",
+			`{"zeta": 1, "alpha": "<b>&</b>", "published": "2023-06-01T12:00:00.000Z"}`,
+			map[string]interface{}{"tenant": "acme"},
+			`{"zeta": 1, "alpha": "<b>&</b>", "published": "2023-06-01T12:00:00.000Z","collector":{"tenant":"acme"}}`,
+		},
+		{
+			"keeps number precision",
+			`{"asNumber":12345678901234567890,"score":0.10000000000000001}`,
+			map[string]interface{}{"tenant": "acme"},
+			`{"asNumber":12345678901234567890,"score":0.10000000000000001,"collector":{"tenant":"acme"}}`,
+		},
+		{
+			"merged into the existing collector field",
+			`{"collector":{"receivedAt":"2023-06-01T12:00:01Z"},"uuid":"1"}`,
+			map[string]interface{}{"tenant": "acme"},
+			`{"collector":{"receivedAt":"2023-06-01T12:00:01Z","tenant":"acme"},"uuid":"1"}`,
+		},
+		{
+			"replaces existing collector fields in place",
+			`{"collector":{"tenant":"old","tags":["vpn"]}}`,
+			map[string]interface{}{"tenant": "acme"},
+			`{"collector":{"tenant":"acme","tags":["vpn"]}}`,
+		},
+		{
+			"replaces a collector field that isn't an object",
+			`{"collector":null,"uuid":"1"}`,
+			map[string]interface{}{"tenant": "acme"},
+			`{"collector":{"tenant":"acme"},"uuid":"1"}`,
+		},
+		{
+			"empty event",
+			`{}`,
+			map[string]interface{}{"tenant": "acme"},
+			`{"collector":{"tenant":"acme"}}`,
+		},
+		{
+			"new fields in name order without html escapes",
+			`{"uuid":"1","collector":{}}`,
+			map[string]interface{}{"tags": []string{"a&b"}, "asn": map[string]interface{}{"number": 64512}},
+			`{"uuid":"1","collector":{"asn":{"number":64512},"tags":["a&b"]}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tagged, err := AddCollectorFields([]byte(test.event), test.fields)
+			if err != nil {
+				t.Fatalf("unable to add fields: %v", err)
+			}
+
+			if string(tagged) != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, tagged)
+			}
+
+			if !json.Valid(tagged) {
+				t.Errorf("expected valid json, got %s", tagged)
+			}
+		})
+	}
+}
+
+func TestAddCollectorFieldsInvalid(t *testing.T) {
+	for _, event := range []string{``, `[]`, `{"uuid":`, `"event"`} {
+		if _, err := AddCollectorFields([]byte(event), map[string]interface{}{"tenant": "acme"}); err == nil {
+			t.Errorf("expected adding fields to %q to fail", event)
+		}
+	}
+}
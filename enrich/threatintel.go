@@ -0,0 +1,261 @@
+package enrich
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/iplist"
+	"github.com/spf13/viper"
+)
+
+// MISP attribute types holding IP addresses
+var mispIpTypes = map[string]bool{
+	"ip-src":      true,
+	"ip-dst":      true,
+	"ip-src|port": true,
+	"ip-dst|port": true,
+}
+
+// A threat intel list loaded from a file or URL
+type threatIntelList struct {
+	name   string
+	source string
+	list   *iplist.List
+}
+
+// Threat intel lists loaded from the params by Setup and refreshed in the background
+var threatIntel = struct {
+	sync.RWMutex
+	lists []*threatIntelList
+}{}
+
+// HTTP client fetching remote threat intel lists
+var threatIntelClient = &http.Client{Timeout: 30 * time.Second}
+
+// Parse name=source pairs of threat intel lists, without loading them
+func parseThreatIntelLists(pairs []string) ([]*threatIntelList, error) {
+	var lists []*threatIntelList
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New(fmt.Sprintf("invalid threat intel list %s, expected name=path or name=url", pair))
+		}
+		lists = append(lists, &threatIntelList{name: parts[0], source: parts[1]})
+	}
+
+	return lists, nil
+}
+
+// Load threat intel lists and refresh them in the background every interval
+func setupThreatIntel(pairs []string, interval time.Duration) error {
+	lists, err := parseThreatIntelLists(pairs)
+	if err != nil || len(lists) == 0 {
+		return err
+	}
+
+	for _, l := range lists {
+		if l.list, err = loadThreatIntel(l.source); err != nil {
+			return errors.New(fmt.Sprintf("unable to load %s threat intel list: %v", l.name, err))
+		}
+		log.Printf("Loaded %d ranges from %s threat intel list\n", l.list.Len(), l.name)
+	}
+
+	threatIntel.Lock()
+	threatIntel.lists = lists
+	threatIntel.Unlock()
+
+	if interval > 0 {
+		go refreshThreatIntel(interval)
+	}
+
+	return nil
+}
+
+// Reload threat intel lists every interval, keeping the previous ranges of lists that fail to load
+func refreshThreatIntel(interval time.Duration) {
+	for {
+		<-time.After(interval)
+
+		threatIntel.RLock()
+		lists := threatIntel.lists
+		threatIntel.RUnlock()
+
+		refreshed := make([]*threatIntelList, len(lists))
+		for i, l := range lists {
+			refreshed[i] = l
+			list, err := loadThreatIntel(l.source)
+			if err != nil {
+				log.Printf("Unable to refresh %s threat intel list: %v\n", l.name, err)
+				continue
+			}
+			refreshed[i] = &threatIntelList{name: l.name, source: l.source, list: list}
+		}
+
+		threatIntel.Lock()
+		threatIntel.lists = refreshed
+		threatIntel.Unlock()
+	}
+}
+
+// Names of the threat intel lists an IP address is in
+func threatIntelMatches(address string) []string {
+	threatIntel.RLock()
+	defer threatIntel.RUnlock()
+
+	var names []string
+	for _, l := range threatIntel.lists {
+		if l.list.Contains(address) && !contains(names, l.name) {
+			names = append(names, l.name)
+		}
+	}
+
+	return names
+}
+
+// Check if any threat intel list is loaded
+func threatIntelEnabled() bool {
+	threatIntel.RLock()
+	defer threatIntel.RUnlock()
+
+	return len(threatIntel.lists) > 0
+}
+
+// Load a threat intel list from a file or http(s) URL
+// Lists are either MISP JSON exports or plain lists of CIDR ranges and IP addresses, one per line.
+func loadThreatIntel(source string) (*iplist.List, error) {
+	var content []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		content, err = fetchThreatIntel(source)
+	} else {
+		content, err = ioutil.ReadFile(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return parseMispExport(source, trimmed)
+	}
+
+	return iplist.ParseReader(source, bytes.NewReader(content))
+}
+
+// Fetch a remote threat intel list
+// MISP instances expect the API key in the Authorization header.
+func fetchThreatIntel(url string) ([]byte, error) {
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if key := viper.GetString("threat-intel-api-key"); key != "" {
+		request.Header.Set("Authorization", key)
+		request.Header.Set("Accept", "application/json")
+	}
+
+	response, err := threatIntelClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		return nil, errors.New(fmt.Sprintf("HTTP response code: %v", response.Status))
+	}
+
+	return ioutil.ReadAll(response.Body)
+}
+
+// An attribute of a MISP event
+type mispAttribute struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// A MISP event with its attributes and the attributes of its objects
+type mispEvent struct {
+	Attribute []mispAttribute `json:"Attribute"`
+	Object    []struct {
+		Attribute []mispAttribute `json:"Attribute"`
+	} `json:"Object"`
+}
+
+// Parse the IP attributes of a MISP JSON export
+// Supports event exports ({"response": [{"Event": ...}]}, [{"Event": ...}] or {"Event": ...}) and attribute search
+// exports ({"response": {"Attribute": [...]}}).
+func parseMispExport(source string, content []byte) (*iplist.List, error) {
+	type wrapped struct {
+		Event mispEvent `json:"Event"`
+	}
+
+	var attributes []mispAttribute
+	addEvent := func(e mispEvent) {
+		attributes = append(attributes, e.Attribute...)
+		for _, o := range e.Object {
+			attributes = append(attributes, o.Attribute...)
+		}
+	}
+
+	if content[0] == '[' {
+		var events []wrapped
+		if err := json.Unmarshal(content, &events); err != nil {
+			return nil, errors.New(fmt.Sprintf("%s: invalid misp export: %v", source, err))
+		}
+		for _, e := range events {
+			addEvent(e.Event)
+		}
+	} else {
+		var export struct {
+			Event    *mispEvent      `json:"Event"`
+			Response json.RawMessage `json:"response"`
+		}
+		if err := json.Unmarshal(content, &export); err != nil {
+			return nil, errors.New(fmt.Sprintf("%s: invalid misp export: %v", source, err))
+		}
+
+		if export.Event != nil {
+			addEvent(*export.Event)
+		}
+
+		var events []wrapped
+		var search struct {
+			Attribute []mispAttribute `json:"Attribute"`
+		}
+		if err := json.Unmarshal(export.Response, &events); err == nil {
+			for _, e := range events {
+				addEvent(e.Event)
+			}
+		} else if err := json.Unmarshal(export.Response, &search); err == nil {
+			attributes = append(attributes, search.Attribute...)
+		}
+	}
+
+	var values []string
+	for _, a := range attributes {
+		if !mispIpTypes[a.Type] {
+			continue
+		}
+
+		// Drop the port of ip|port attributes
+		values = append(values, strings.SplitN(a.Value, "|", 2)[0])
+	}
+
+	list, err := iplist.Parse(values)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %v", source, err))
+	}
+
+	return list, nil
+}
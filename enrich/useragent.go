@@ -0,0 +1,67 @@
+package enrich
+
+import (
+	"sync"
+
+	"github.com/mssola/user_agent"
+)
+
+// Max user agents kept parsed, as the same few user agents make up most events
+const maxParsedUserAgents = 10000
+
+// Normalized fields of a user agent
+type userAgent struct {
+	Browser        string `json:"browser,omitempty"`
+	BrowserVersion string `json:"browserVersion,omitempty"`
+	Os             string `json:"os,omitempty"`
+	OsVersion      string `json:"osVersion,omitempty"`
+	Device         string `json:"device"`
+}
+
+// Parsed user agents by raw user agent
+var parsedUserAgents = struct {
+	sync.Mutex
+	entries map[string]*userAgent
+}{entries: make(map[string]*userAgent)}
+
+// Parse a raw user agent into normalized fields
+// Returns nil for empty user agents.
+func parseUserAgent(raw string) *userAgent {
+	if raw == "" {
+		return nil
+	}
+
+	parsedUserAgents.Lock()
+	defer parsedUserAgents.Unlock()
+
+	if parsed, ok := parsedUserAgents.entries[raw]; ok {
+		return parsed
+	}
+
+	ua := user_agent.New(raw)
+	browser, browserVersion := ua.Browser()
+	os := ua.OSInfo()
+
+	parsed := &userAgent{
+		Browser:        browser,
+		BrowserVersion: browserVersion,
+		Os:             os.Name,
+		OsVersion:      os.Version,
+		Device:         "desktop",
+	}
+
+	switch {
+	case ua.Bot():
+		parsed.Device = "bot"
+	case ua.Mobile():
+		parsed.Device = "mobile"
+	}
+
+	// Start over rather than growing without bound
+	if len(parsedUserAgents.entries) >= maxParsedUserAgents {
+		parsedUserAgents.entries = make(map[string]*userAgent)
+	}
+	parsedUserAgents.entries[raw] = parsed
+
+	return parsed
+}
@@ -0,0 +1,91 @@
+package enrich
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/client"
+	"github.com/rfizzle/okta-collector/secrets"
+	"github.com/spf13/viper"
+)
+
+// Fraction of the users API rate limit left to other calls
+const usersRateLimitReserve = 0.2
+
+// A cached user profile
+type cachedUser struct {
+	attributes map[string]interface{}
+	expires    time.Time
+}
+
+// Cache of the profile attributes of users by ID, including users that don't exist
+type userCache struct {
+	mu        sync.Mutex
+	client    *client.OktaClient
+	ttl       time.Duration
+	keys      []string
+	users     map[string]cachedUser
+	rateLimit client.OktaRateLimit
+}
+
+// User cache created from the params by Setup
+var users *userCache
+
+// Create the user cache
+// Returns nil when user enrichment is disabled.
+func newUserCache() *userCache {
+	if !viper.GetBool("enrich-users") {
+		return nil
+	}
+
+	return &userCache{
+		client: newOktaClient(),
+		ttl:    time.Duration(viper.GetInt("user-cache-ttl")) * time.Second,
+		keys:   viper.GetStringSlice("user-attributes"),
+		users:  make(map[string]cachedUser),
+	}
+}
+
+// Get the selected profile attributes of a user
+// Returns nil when the user doesn't exist or can't be looked up, such as while the rate limit is low.
+func (c *userCache) attributes(id string) map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.users[id]; ok && time.Now().Before(cached.expires) {
+		return cached.attributes
+	}
+
+	// Back off until the rate limit resets, leaving calls for log collection
+	if c.rateLimit.Below(usersRateLimitReserve) {
+		return nil
+	}
+
+	user, rateLimit, err := c.client.GetUser(id)
+	c.rateLimit = rateLimit
+	if err != nil {
+		log.Printf("Unable to get user %s: %v\n", id, err)
+		return nil
+	}
+
+	var attributes map[string]interface{}
+	if user != nil {
+		attributes = make(map[string]interface{})
+		for _, key := range c.keys {
+			if value, ok := user.Profile[key]; ok && value != nil {
+				attributes[key] = value
+			}
+		}
+	}
+
+	c.users[id] = cachedUser{attributes: attributes, expires: time.Now().Add(c.ttl)}
+	return attributes
+}
+
+// Okta client that reads the latest API key for every request, so rotated keys are used without a restart
+func newOktaClient() *client.OktaClient {
+	c := client.NewClient(viper.GetString("okta-domain"), secrets.Value("okta-api-key"))
+	c.TokenSource = secrets.Source("okta-api-key")
+	return c
+}
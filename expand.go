@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/spf13/viper"
+)
+
+// Reference to an environment variable in a config value, as ${VAR} or ${VAR:-default}, or escaped as $${VAR}
+var envReferencePattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Read the config file at path, decrypting it if encrypted with SOPS and expanding references to environment variables
+// in its values
+// Returns the config and the keys whose values referenced environment variables.
+func readConfigFile(path string) (*viper.Viper, map[string]bool, error) {
+	config := viper.New()
+	config.SetConfigFile(path)
+	config.SetConfigType(configFileType(path))
+	if err := config.ReadInConfig(); err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("unable to read config file: %v", err))
+	}
+
+	if isSopsConfig(config) {
+		if err := decryptSopsConfig(path, config); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	expanded := make(map[string]bool)
+	for _, key := range config.AllKeys() {
+		value, changed, err := expandValue(key, config.Get(key))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if changed {
+			config.Set(key, value)
+			expanded[key] = true
+		}
+	}
+
+	return config, expanded, nil
+}
+
+// Expand the references to environment variables in a config value, including the values of lists and config blocks
+// Returns the expanded value and whether it changed.
+func expandValue(key string, value interface{}) (interface{}, bool, error) {
+	switch v := value.(type) {
+	case string:
+		s, err := expandEnv(key, v)
+		return s, s != v, err
+	case []interface{}:
+		changed := false
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			e, c, err := expandValue(key, item)
+			if err != nil {
+				return nil, false, err
+			}
+			values[i] = e
+			changed = changed || c
+		}
+		return values, changed, nil
+	case map[string]interface{}:
+		changed := false
+		values := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			e, c, err := expandValue(key+"."+k, item)
+			if err != nil {
+				return nil, false, err
+			}
+			values[k] = e
+			changed = changed || c
+		}
+		return values, changed, nil
+	}
+
+	return value, false, nil
+}
+
+// Expand the references to environment variables in the value of a config key
+// Unset variables without a default are an error rather than an empty value, which could pass validation unnoticed.
+func expandEnv(key string, value string) (string, error) {
+	var err error
+	expanded := envReferencePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		if reference[1] == '$' {
+			return reference[1:]
+		}
+
+		match := envReferencePattern.FindStringSubmatch(reference)
+		if v, ok := os.LookupEnv(match[1]); ok {
+			return v
+		}
+
+		if match[2] != "" {
+			return match[3]
+		}
+
+		if err == nil {
+			err = errors.New(fmt.Sprintf("config key %s references unset environment variable %s", key, match[1]))
+		}
+		return reference
+	})
+
+	return expanded, err
+}
@@ -0,0 +1,110 @@
+// Package expression compiles CEL expressions over the typed System Log event.
+package expression
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"github.com/rfizzle/okta-collector/client"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Name of the event variable in expressions
+const eventVariable = "event"
+
+// Type of the event variable, named after its Go package and struct
+const eventType = "client.OktaResponse"
+
+// A compiled expression
+type Program struct {
+	source  string
+	program cel.Program
+}
+
+// Create the environment expressions are checked against
+// Event fields are named after their JSON keys, such as event.actor.alternateId.
+func newEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		ext.NativeTypes(reflect.TypeOf(&client.OktaResponse{}), ext.ParseStructTags(true)),
+		ext.Strings(),
+		cel.Variable(eventVariable, cel.ObjectType(eventType)),
+	)
+}
+
+// Compile and type check an expression that returns a boolean
+func CompileBool(source string) (*Program, error) {
+	return compile(source, cel.BoolType)
+}
+
+// Compile and type check an expression that returns any value
+func CompileValue(source string) (*Program, error) {
+	return compile(source, nil)
+}
+
+func compile(source string, outputType *cel.Type) (*Program, error) {
+	env, err := newEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	ast, issues := env.Compile(source)
+	if issues != nil && issues.Err() != nil {
+		return nil, errors.New(fmt.Sprintf("invalid expression %s: %v", source, issues.Err()))
+	}
+
+	if outputType != nil && !ast.OutputType().IsExactType(outputType) {
+		return nil, errors.New(fmt.Sprintf("invalid expression %s: returns %v instead of %v", source, ast.OutputType(), outputType))
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid expression %s: %v", source, err))
+	}
+
+	return &Program{source: source, program: program}, nil
+}
+
+// Parse an event for evaluation
+func ParseEvent(event []byte) (*client.OktaResponse, error) {
+	var parsed client.OktaResponse
+	if err := json.Unmarshal(event, &parsed); err != nil {
+		return nil, err
+	}
+
+	return &parsed, nil
+}
+
+// Evaluate a boolean expression on an event
+func (p *Program) EvalBool(event *client.OktaResponse) (bool, error) {
+	result, _, err := p.program.Eval(map[string]interface{}{eventVariable: event})
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("Error evaluating expression %s: %v", p.source, err))
+	}
+
+	value, ok := result.Value().(bool)
+	if !ok {
+		return false, errors.New(fmt.Sprintf("Error evaluating expression %s: not a boolean", p.source))
+	}
+
+	return value, nil
+}
+
+// Evaluate an expression on an event
+// Returns the result as a JSON value.
+func (p *Program) EvalValue(event *client.OktaResponse) (interface{}, error) {
+	result, _, err := p.program.Eval(map[string]interface{}{eventVariable: event})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error evaluating expression %s: %v", p.source, err))
+	}
+
+	value, err := result.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error evaluating expression %s: %v", p.source, err))
+	}
+
+	return value.(*structpb.Value).AsInterface(), nil
+}
@@ -0,0 +1,308 @@
+// Package filter drops events that shouldn't be shipped before they reach the outputs.
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rfizzle/okta-collector/expression"
+	"github.com/rfizzle/okta-collector/iplist"
+	"github.com/rfizzle/okta-collector/match"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Fields of an event used for filtering
+type eventFields struct {
+	EventType string `json:"eventType"`
+	Severity  string `json:"severity"`
+	Outcome   struct {
+		Result string `json:"result"`
+	} `json:"outcome"`
+	Actor  eventTarget   `json:"actor"`
+	Target []eventTarget `json:"target"`
+	Client struct {
+		IpAddress string `json:"ipAddress"`
+		UserAgent struct {
+			RawUserAgent string `json:"rawUserAgent"`
+		} `json:"userAgent"`
+	} `json:"client"`
+	Transaction struct {
+		Detail struct {
+			RequestApiTokenId string `json:"requestApiTokenId"`
+		} `json:"detail"`
+	} `json:"transaction"`
+}
+
+// An actor or target of an event
+type eventTarget struct {
+	Id          string `json:"id"`
+	Type        string `json:"type"`
+	AlternateId string `json:"alternateId"`
+	DisplayName string `json:"displayName"`
+}
+
+// Event type, severity and outcome filters
+type eventFilters struct {
+	includeEventTypes []string
+	excludeEventTypes []string
+	minSeverity       string
+	outcomes          []string
+}
+
+// Event type, severity and outcome filters loaded from the params by Setup
+var filters eventFilters
+
+// Rules parsed from the params by Setup
+var rules []*rule
+
+// Expressions compiled from the params by Setup
+var expressions []*expression.Program
+
+// Client IP ranges to drop, parsed from the params by Setup
+var excludeCidrs *iplist.List
+
+// Values of a field of every target
+func (f *eventFields) targetValues(value func(t eventTarget) string) []string {
+	var values []string
+	for _, target := range f.Target {
+		values = append(values, value(target))
+	}
+	return values
+}
+
+// Names of the apps an event targets
+func (f *eventFields) appNames() []string {
+	var names []string
+	for _, target := range f.Target {
+		if target.Type == "AppInstance" {
+			names = append(names, target.DisplayName, target.AlternateId)
+		}
+	}
+	return names
+}
+
+// Register the filter params
+func InitCLIParams() {
+	flag.StringSlice("include-event-types", []string{}, "event types to collect, supports wildcards (default all)")
+	flag.StringSlice("exclude-event-types", []string{}, "event types to drop, supports wildcards")
+	flag.String("min-severity", "", "minimum severity of events to collect (DEBUG, INFO, WARN, ERROR) (default all)")
+	flag.StringSlice("outcomes", []string{}, "outcome results of events to collect, such as FAILURE and DENY (default all)")
+	flag.StringSlice("exclude-cidrs", []string{}, "client ip cidr ranges to drop, or @file of ranges")
+	flag.StringSlice("suppress-actor-ids", []string{}, "actor ids of known automation to drop")
+	flag.StringSlice("suppress-user-agents", []string{}, "user agents of known automation to drop, supports wildcards")
+	flag.StringSlice("suppress-api-tokens", []string{}, "names or ids of api tokens of known automation to drop")
+	flag.StringSlice("sample-rates", []string{}, "sampling of event types as type=probability or type=1/N, supports wildcards, failures are always kept")
+	flag.StringSlice("filter-rules", []string{}, "rules including or excluding events by actor, target or app, such as 'exclude actor.alternateId = svc-*'")
+	flag.StringArray("filter-expressions", []string{}, "cel expressions over the event that must all be true to collect it")
+}
+
+// Validate the filter params
+func ValidateCLIParams() error {
+	if err := validatePatterns(viper.GetStringSlice("include-event-types")); err != nil {
+		return errors.New(fmt.Sprintf("invalid include event types param (--include-event-types): %v", err))
+	}
+
+	if err := validatePatterns(viper.GetStringSlice("exclude-event-types")); err != nil {
+		return errors.New(fmt.Sprintf("invalid exclude event types param (--exclude-event-types): %v", err))
+	}
+
+	if minSeverity := viper.GetString("min-severity"); minSeverity != "" && !ValidSeverity(minSeverity) {
+		return errors.New(fmt.Sprintf("invalid min severity param (--min-severity): unknown severity %s", minSeverity))
+	}
+
+	if _, err := iplist.Parse(viper.GetStringSlice("exclude-cidrs")); err != nil {
+		return errors.New(fmt.Sprintf("invalid exclude cidrs param (--exclude-cidrs): %v", err))
+	}
+
+	if _, err := parseRules(viper.GetStringSlice("filter-rules")); err != nil {
+		return errors.New(fmt.Sprintf("invalid filter rules param (--filter-rules): %v", err))
+	}
+
+	if _, err := compileExpressions(viper.GetStringSlice("filter-expressions")); err != nil {
+		return errors.New(fmt.Sprintf("invalid filter expressions param (--filter-expressions): %v", err))
+	}
+
+	if _, err := parseSamplers(viper.GetStringSlice("sample-rates")); err != nil {
+		return errors.New(fmt.Sprintf("invalid sample rates param (--sample-rates): %v", err))
+	}
+
+	if _, err := match.CompileGlobs(viper.GetStringSlice("suppress-user-agents")); err != nil {
+		return errors.New(fmt.Sprintf("invalid suppress user agents param (--suppress-user-agents): %v", err))
+	}
+
+	return nil
+}
+
+// Setup the filters from the validated params
+func Setup() error {
+	filters = eventFilters{
+		includeEventTypes: viper.GetStringSlice("include-event-types"),
+		excludeEventTypes: viper.GetStringSlice("exclude-event-types"),
+		minSeverity:       viper.GetString("min-severity"),
+		outcomes:          viper.GetStringSlice("outcomes"),
+	}
+
+	var err error
+	if excludeCidrs, err = iplist.Parse(viper.GetStringSlice("exclude-cidrs")); err != nil {
+		return err
+	}
+
+	if rules, err = parseRules(viper.GetStringSlice("filter-rules")); err != nil {
+		return err
+	}
+
+	if expressions, err = compileExpressions(viper.GetStringSlice("filter-expressions")); err != nil {
+		return err
+	}
+
+	if samplers, err = parseSamplers(viper.GetStringSlice("sample-rates")); err != nil {
+		return err
+	}
+
+	if suppressed, err = loadSuppressions(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Check if an event passes the filters
+func Keep(event []byte) (bool, error) {
+	var fields eventFields
+	if err := json.Unmarshal(event, &fields); err != nil {
+		return false, err
+	}
+
+	// Event types
+	if len(filters.includeEventTypes) > 0 && !match.Any(filters.includeEventTypes, fields.EventType) {
+		return false, nil
+	}
+
+	if match.Any(filters.excludeEventTypes, fields.EventType) {
+		return false, nil
+	}
+
+	// Severity
+	if filters.minSeverity != "" && !AtLeastSeverity(fields.Severity, filters.minSeverity) {
+		return false, nil
+	}
+
+	// Outcome
+	if len(filters.outcomes) > 0 && !match.ContainsFold(filters.outcomes, fields.Outcome.Result) {
+		return false, nil
+	}
+
+	// Client IP
+	if excludeCidrs != nil && excludeCidrs.Contains(fields.Client.IpAddress) {
+		return false, nil
+	}
+
+	// Rules
+	if len(rules) > 0 && !evaluateRules(rules, &fields) {
+		return false, nil
+	}
+
+	// Expressions
+	if len(expressions) > 0 {
+		if keep, err := evaluateExpressions(event); err != nil || !keep {
+			return false, err
+		}
+	}
+
+	// Noise suppression, after the other filters so only events it drops are counted
+	if suppressed.suppresses(&fields) {
+		return false, nil
+	}
+
+	// Sampling, last so only events that would be shipped count towards 1 in N rates
+	if sampler := findSampler(fields.EventType, fields.Outcome.Result); sampler != nil && !sampler.keep() {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Build a System Log API filter expression that narrows collection to the included event types
+// Returns an empty string when the filters can't be expressed server side, leaving them to Keep.
+func ServerFilter() string {
+	return ServerFilterOf(viper.GetStringSlice("include-event-types"))
+}
+
+// Build a System Log API filter expression that narrows collection to the included event type patterns
+// Returns an empty string when the patterns can't all be expressed server side.
+func ServerFilterOf(include []string) string {
+	if len(include) == 0 {
+		return ""
+	}
+
+	var expressions []string
+	for _, pattern := range include {
+		expression, ok := eventTypeExpression(pattern)
+		if !ok {
+			return ""
+		}
+		expressions = append(expressions, expression)
+	}
+
+	return strings.Join(expressions, " or ")
+}
+
+// Build the filter expression of an event type pattern
+// Only exact event types and patterns with a single trailing wildcard can be expressed.
+func eventTypeExpression(pattern string) (string, bool) {
+	prefix := strings.TrimSuffix(pattern, "*")
+	if strings.ContainsAny(prefix, "*?[]\\\"") {
+		return "", false
+	}
+
+	if prefix != pattern {
+		return fmt.Sprintf("eventType sw \"%s\"", prefix), true
+	}
+
+	return fmt.Sprintf("eventType eq \"%s\"", pattern), true
+}
+
+// Compile boolean expressions
+func compileExpressions(sources []string) ([]*expression.Program, error) {
+	var programs []*expression.Program
+	for _, source := range sources {
+		program, err := expression.CompileBool(source)
+		if err != nil {
+			return nil, err
+		}
+		programs = append(programs, program)
+	}
+
+	return programs, nil
+}
+
+// Check if an event passes every expression
+func evaluateExpressions(event []byte) (bool, error) {
+	parsed, err := expression.ParseEvent(event)
+	if err != nil {
+		return false, err
+	}
+
+	for _, program := range expressions {
+		keep, err := program.EvalBool(parsed)
+		if err != nil || !keep {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// Validate wildcard patterns
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if !match.ValidPattern(pattern) {
+			return errors.New(fmt.Sprintf("invalid pattern %s", pattern))
+		}
+	}
+
+	return nil
+}
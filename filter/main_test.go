@@ -0,0 +1,90 @@
+package filter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// Event from a client ip address
+func clientEvent(ipAddress string) []byte {
+	return []byte(fmt.Sprintf(`{"eventType": "user.session.start", "client": {"ipAddress": %q}}`, ipAddress))
+}
+
+func TestKeepExcludesCidrs(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("exclude-cidrs", []string{"10.0.0.0/8", "2001:db8::/32", "203.0.113.7"})
+
+	if err := Setup(); err != nil {
+		t.Fatalf("unable to setup filters: %v", err)
+	}
+
+	tests := []struct {
+		ipAddress string
+		keep      bool
+	}{
+		{"10.20.30.40", false},
+		{"11.0.0.1", true},
+		{"2001:db8::1", false},
+		{"2001:db9::1", true},
+		{"203.0.113.7", false},
+		{"203.0.113.8", true},
+		{"", true},
+	}
+
+	for _, test := range tests {
+		keep, err := Keep(clientEvent(test.ipAddress))
+		if err != nil {
+			t.Fatalf("unable to filter event: %v", err)
+		}
+
+		if keep != test.keep {
+			t.Errorf("expected keeping an event from %q to be %v", test.ipAddress, test.keep)
+		}
+	}
+}
+
+func TestKeepFiltersEventTypesSeverityAndOutcomes(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("include-event-types", []string{"user.session.*", "user.authentication.*"})
+	viper.Set("exclude-event-types", []string{"user.session.end"})
+	viper.Set("min-severity", "INFO")
+	viper.Set("outcomes", []string{"success", "FAILURE"})
+
+	if err := Setup(); err != nil {
+		t.Fatalf("unable to setup filters: %v", err)
+	}
+
+	// Params are read once by Setup
+	viper.Set("include-event-types", []string{"system.*"})
+
+	tests := []struct {
+		eventType string
+		severity  string
+		outcome   string
+		keep      bool
+	}{
+		{"user.session.start", "INFO", "SUCCESS", true},
+		{"user.authentication.sso", "WARN", "FAILURE", true},
+		{"user.session.end", "INFO", "SUCCESS", false},
+		{"system.org.rate_limit.warning", "WARN", "SUCCESS", false},
+		{"user.session.start", "DEBUG", "SUCCESS", false},
+		{"user.session.start", "INFO", "DENY", false},
+		{"user.session.start", "INFO", "", false},
+	}
+
+	for _, test := range tests {
+		event := fmt.Sprintf(`{"eventType": %q, "severity": %q, "outcome": {"result": %q}}`, test.eventType, test.severity, test.outcome)
+		keep, err := Keep([]byte(event))
+		if err != nil {
+			t.Fatalf("unable to filter event: %v", err)
+		}
+
+		if keep != test.keep {
+			t.Errorf("expected keeping %s at %s with outcome %q to be %v", test.eventType, test.severity, test.outcome, test.keep)
+		}
+	}
+}
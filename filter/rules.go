@@ -0,0 +1,145 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rfizzle/okta-collector/match"
+)
+
+// Actions of filter rules
+const (
+	ruleInclude = "include"
+	ruleExclude = "exclude"
+)
+
+// A filter rule such as `exclude actor.alternateId = svc-*@acme.com`
+type rule struct {
+	include bool
+	field   string
+	op      string
+	value   string
+	re      *regexp.Regexp
+}
+
+// Fields rules can match, each extracting every value of the field from an event
+var ruleFields = map[string]func(fields *eventFields) []string{
+	"actor.id":          func(f *eventFields) []string { return []string{f.Actor.Id} },
+	"actor.type":        func(f *eventFields) []string { return []string{f.Actor.Type} },
+	"actor.alternateId": func(f *eventFields) []string { return []string{f.Actor.AlternateId} },
+	"actor.displayName": func(f *eventFields) []string { return []string{f.Actor.DisplayName} },
+	"target.id":         func(f *eventFields) []string { return f.targetValues(func(t eventTarget) string { return t.Id }) },
+	"target.type":       func(f *eventFields) []string { return f.targetValues(func(t eventTarget) string { return t.Type }) },
+	"target.alternateId": func(f *eventFields) []string {
+		return f.targetValues(func(t eventTarget) string { return t.AlternateId })
+	},
+	"target.displayName": func(f *eventFields) []string {
+		return f.targetValues(func(t eventTarget) string { return t.DisplayName })
+	},
+	"app": func(f *eventFields) []string { return f.appNames() },
+}
+
+// Parse a rule of the form `<include|exclude> <field> <=|!=|~> <value>`
+// `=` and `!=` match wildcard patterns and `~` matches a regular expression.
+func parseRule(expression string) (*rule, error) {
+	parts := strings.Fields(expression)
+	if len(parts) < 4 {
+		return nil, errors.New(fmt.Sprintf("invalid rule %q, expected <include|exclude> <field> <=|!=|~> <value>", expression))
+	}
+
+	r := &rule{
+		field: parts[1],
+		op:    parts[2],
+		value: strings.Join(parts[3:], " "),
+	}
+
+	switch parts[0] {
+	case ruleInclude:
+		r.include = true
+	case ruleExclude:
+		r.include = false
+	default:
+		return nil, errors.New(fmt.Sprintf("invalid rule %q, unknown action %s", expression, parts[0]))
+	}
+
+	if _, ok := ruleFields[r.field]; !ok {
+		return nil, errors.New(fmt.Sprintf("invalid rule %q, unknown field %s", expression, r.field))
+	}
+
+	switch r.op {
+	case "=", "!=":
+		if !match.ValidPattern(r.value) {
+			return nil, errors.New(fmt.Sprintf("invalid rule %q, invalid pattern %s", expression, r.value))
+		}
+	case "~":
+		re, err := regexp.Compile(r.value)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid rule %q: %v", expression, err))
+		}
+		r.re = re
+	default:
+		return nil, errors.New(fmt.Sprintf("invalid rule %q, unknown operator %s", expression, r.op))
+	}
+
+	return r, nil
+}
+
+// Parse every rule
+func parseRules(expressions []string) ([]*rule, error) {
+	var rules []*rule
+	for _, expression := range expressions {
+		r, err := parseRule(expression)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+// Check if the rule matches an event
+// Fields with several values, such as targets, match if any value does.
+func (r *rule) matches(fields *eventFields) bool {
+	values := ruleFields[r.field](fields)
+
+	// Every value must differ for a negated match
+	if r.op == "!=" {
+		for _, value := range values {
+			if match.Pattern(r.value, value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, value := range values {
+		if r.re != nil && r.re.MatchString(value) {
+			return true
+		}
+
+		if r.re == nil {
+			if match.Pattern(r.value, value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Evaluate rules in order, the first matching rule deciding if the event is kept
+// Events matching no rule are kept, unless there are include rules.
+func evaluateRules(rules []*rule, fields *eventFields) bool {
+	hasInclude := false
+	for _, r := range rules {
+		if r.matches(fields) {
+			return r.include
+		}
+		hasInclude = hasInclude || r.include
+	}
+
+	return !hasInclude
+}
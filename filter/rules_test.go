@@ -0,0 +1,141 @@
+package filter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Event of a service account signing in to an app, as the fields rules match
+const ruleTestEvent = `{
+	"eventType": "user.authentication.sso",
+	"actor": {"id": "00u1abcd", "type": "User", "alternateId": "svc-backup@acme.com", "displayName": "Backup Service"},
+	"target": [
+		{"id": "0oa1abcd", "type": "AppInstance", "alternateId": "Salesforce", "displayName": "Salesforce.com"},
+		{"id": "0ua2efgh", "type": "AppUser", "alternateId": "svc-backup@acme.com", "displayName": "Backup Service"}
+	]
+}`
+
+// Decode the fields rules match of an event
+func ruleTestFields(t *testing.T, event string) *eventFields {
+	var fields eventFields
+	if err := json.Unmarshal([]byte(event), &fields); err != nil {
+		t.Fatalf("unable to decode event: %v", err)
+	}
+	return &fields
+}
+
+func TestRuleMatches(t *testing.T) {
+	fields := ruleTestFields(t, ruleTestEvent)
+
+	tests := []struct {
+		rule    string
+		matches bool
+	}{
+		{"exclude actor.alternateId = svc-*@acme.com", true},
+		{"exclude actor.alternateId = svc-*@example.com", false},
+		{"exclude actor.id = 00u1abcd", true},
+		{"exclude actor.type = User", true},
+		{"exclude actor.displayName = Backup Service", true},
+		{"exclude actor.displayName = Backup  Service", true},
+		{"exclude actor.alternateId != svc-*", false},
+		{"exclude actor.alternateId != jane.*", true},
+		{"exclude actor.alternateId ~ ^svc-[a-z]+@acme\\.com$", true},
+		{"exclude actor.alternateId ~ ^admin", false},
+		{"exclude target.type = AppInstance", true},
+		{"exclude target.type = UserGroup", false},
+		{"exclude target.type != AppInstance", false},
+		{"exclude target.type != UserGroup", true},
+		{"exclude target.id ~ ^0ua", true},
+		{"exclude target.alternateId = Sales*", true},
+		{"exclude target.displayName = Salesforce.com", true},
+		{"exclude app = Salesforce", true},
+		{"exclude app = Salesforce.com", true},
+		{"exclude app = Workday", false},
+		{"exclude app ~ (?i)^salesforce", true},
+	}
+
+	for _, test := range tests {
+		r, err := parseRule(test.rule)
+		if err != nil {
+			t.Fatalf("unable to parse %s: %v", test.rule, err)
+		}
+
+		if matches := r.matches(fields); matches != test.matches {
+			t.Errorf("expected %q matching to be %v", test.rule, test.matches)
+		}
+	}
+}
+
+func TestRuleMatchesEventWithoutTargets(t *testing.T) {
+	fields := ruleTestFields(t, `{"actor": {"alternateId": "jane.doe@acme.com"}}`)
+
+	for rule, matches := range map[string]bool{"exclude target.type = *": false, "exclude target.type != User": true, "exclude app = *": false} {
+		r, err := parseRule(rule)
+		if err != nil {
+			t.Fatalf("unable to parse %s: %v", rule, err)
+		}
+
+		if r.matches(fields) != matches {
+			t.Errorf("expected %q matching an event without targets to be %v", rule, matches)
+		}
+	}
+}
+
+func TestEvaluateRules(t *testing.T) {
+	service := ruleTestFields(t, ruleTestEvent)
+	user := ruleTestFields(t, `{"actor": {"alternateId": "jane.doe@acme.com"}, "target": [{"type": "AppInstance", "displayName": "Workday"}]}`)
+
+	tests := []struct {
+		name    string
+		rules   []string
+		service bool
+		user    bool
+	}{
+		{"no rules", nil, true, true},
+		{"exclude only", []string{"exclude actor.alternateId = svc-*"}, false, true},
+		{"include only keeps matches", []string{"include app = Workday"}, false, true},
+		{"first matching rule wins", []string{"include actor.alternateId = svc-backup@*", "exclude actor.alternateId = svc-*"}, true, false},
+		{"exclude before include", []string{"exclude actor.alternateId = svc-*", "include target.type = AppInstance"}, false, true},
+		{"include rules drop the rest", []string{"exclude app = Workday", "include actor.type = User"}, true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := parseRules(test.rules)
+			if err != nil {
+				t.Fatalf("unable to parse rules: %v", err)
+			}
+
+			if keep := evaluateRules(rules, service); keep != test.service {
+				t.Errorf("expected keeping the service account event to be %v", test.service)
+			}
+
+			if keep := evaluateRules(rules, user); keep != test.user {
+				t.Errorf("expected keeping the user event to be %v", test.user)
+			}
+		})
+	}
+}
+
+func TestParseRuleInvalid(t *testing.T) {
+	tests := []struct {
+		rule   string
+		reason string
+	}{
+		{"exclude actor.alternateId =", "expected <include|exclude> <field> <=|!=|~> <value>"},
+		{"drop actor.alternateId = svc-*", "unknown action drop"},
+		{"exclude actor.email = svc-*", "unknown field actor.email"},
+		{"exclude actor.alternateId == svc-*", "unknown operator =="},
+		{"exclude actor.alternateId = svc-[", "invalid pattern svc-["},
+		{"exclude actor.alternateId ~ svc-(", "error parsing regexp"},
+	}
+
+	for _, test := range tests {
+		if _, err := parseRule(test.rule); err == nil {
+			t.Errorf("expected %q to be invalid", test.rule)
+		} else if !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("expected an error about %q for %q, got %v", test.reason, test.rule, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rfizzle/okta-collector/match"
+)
+
+// Outcome results that are never sampled out
+var unsampledOutcomes = []string{"FAILURE", "DENY"}
+
+// Samples the events of the event types matching its pattern, either with a probability or 1 in every N
+type sampler struct {
+	pattern     string
+	probability float64
+	every       uint64
+	count       uint64
+}
+
+// Samplers parsed from the params by Setup
+var samplers []*sampler
+
+// Parse pattern=rate pairs, where the rate is a probability such as 0.1 or 1 in N such as 1/10
+func parseSamplers(pairs []string) ([]*sampler, error) {
+	var parsed []*sampler
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.New(fmt.Sprintf("invalid sample rate %s, expected event-type=rate", pair))
+		}
+
+		if !match.ValidPattern(parts[0]) {
+			return nil, errors.New(fmt.Sprintf("invalid sample rate %s, invalid pattern %s", pair, parts[0]))
+		}
+
+		s := &sampler{pattern: parts[0]}
+		if strings.HasPrefix(parts[1], "1/") {
+			every, err := strconv.ParseUint(strings.TrimPrefix(parts[1], "1/"), 10, 64)
+			if err != nil || every == 0 {
+				return nil, errors.New(fmt.Sprintf("invalid sample rate %s, expected 1/N with N at least 1", pair))
+			}
+			s.every = every
+		} else {
+			probability, err := strconv.ParseFloat(parts[1], 64)
+			if err != nil || probability < 0 || probability > 1 {
+				return nil, errors.New(fmt.Sprintf("invalid sample rate %s, expected a probability from 0 to 1", pair))
+			}
+			s.probability = probability
+		}
+
+		parsed = append(parsed, s)
+	}
+
+	return parsed, nil
+}
+
+// Find the sampler of an event, the first whose pattern matches its type
+// Returns nil for events that are always kept, such as failures.
+func findSampler(eventType string, outcome string) *sampler {
+	if match.ContainsFold(unsampledOutcomes, outcome) {
+		return nil
+	}
+
+	for _, s := range samplers {
+		if match.Pattern(s.pattern, eventType) {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// Fraction of the events of the sampler that are kept
+func (s *sampler) rate() float64 {
+	if s.every > 0 {
+		return 1 / float64(s.every)
+	}
+
+	return s.probability
+}
+
+// Check if the next event of the sampler is kept
+func (s *sampler) keep() bool {
+	if s.every > 0 {
+		return (atomic.AddUint64(&s.count, 1)-1)%s.every == 0
+	}
+
+	return rand.Float64() < s.probability
+}
+
+// Check if any event type is sampled
+func Sampling() bool {
+	return len(samplers) > 0
+}
+
+// Fraction of events of a type and outcome that are kept by sampling, 1 when they aren't sampled
+func SampleRate(eventType string, outcome string) float64 {
+	s := findSampler(eventType, outcome)
+	if s == nil {
+		return 1
+	}
+
+	return s.rate()
+}
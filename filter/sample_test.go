@@ -0,0 +1,132 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSamplers(t *testing.T) {
+	parsed, err := parseSamplers([]string{"user.session.*=0.25", "policy.evaluate_sign_on=1/10", "system.*=0", "app.*=1"})
+	if err != nil {
+		t.Fatalf("unable to parse sample rates: %v", err)
+	}
+
+	expected := []struct {
+		pattern string
+		rate    float64
+	}{
+		{"user.session.*", 0.25},
+		{"policy.evaluate_sign_on", 0.1},
+		{"system.*", 0},
+		{"app.*", 1},
+	}
+
+	if len(parsed) != len(expected) {
+		t.Fatalf("expected %d samplers, got %d", len(expected), len(parsed))
+	}
+
+	for i, s := range parsed {
+		if s.pattern != expected[i].pattern || s.rate() != expected[i].rate {
+			t.Errorf("expected sampler %s at %v, got %s at %v", expected[i].pattern, expected[i].rate, s.pattern, s.rate())
+		}
+	}
+}
+
+func TestParseSamplersInvalid(t *testing.T) {
+	tests := []struct {
+		pair   string
+		reason string
+	}{
+		{"user.session.start", "expected event-type=rate"},
+		{"=0.5", "expected event-type=rate"},
+		{"user.[session=0.5", "invalid pattern user.[session"},
+		{"user.session.*=1/0", "expected 1/N with N at least 1"},
+		{"user.session.*=1/x", "expected 1/N with N at least 1"},
+		{"user.session.*=2/10", "expected a probability from 0 to 1"},
+		{"user.session.*=1.5", "expected a probability from 0 to 1"},
+		{"user.session.*=-0.1", "expected a probability from 0 to 1"},
+		{"user.session.*=half", "expected a probability from 0 to 1"},
+	}
+
+	for _, test := range tests {
+		if _, err := parseSamplers([]string{test.pair}); err == nil {
+			t.Errorf("expected %q to be invalid", test.pair)
+		} else if !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("expected an error about %q for %q, got %v", test.reason, test.pair, err)
+		}
+	}
+}
+
+func TestSamplerKeepsOneInN(t *testing.T) {
+	s := &sampler{pattern: "*", every: 4}
+
+	var kept []int
+	for i := 0; i < 12; i++ {
+		if s.keep() {
+			kept = append(kept, i)
+		}
+	}
+
+	if len(kept) != 3 || kept[0] != 0 || kept[1] != 4 || kept[2] != 8 {
+		t.Errorf("expected the 1st, 5th and 9th events to be kept, got %v", kept)
+	}
+}
+
+func TestSamplerProbability(t *testing.T) {
+	never := &sampler{pattern: "*", probability: 0}
+	always := &sampler{pattern: "*", probability: 1}
+	for i := 0; i < 1000; i++ {
+		if never.keep() {
+			t.Fatal("expected a probability of 0 to keep no event")
+		}
+		if !always.keep() {
+			t.Fatal("expected a probability of 1 to keep every event")
+		}
+	}
+
+	quarter := &sampler{pattern: "*", probability: 0.25}
+	kept := 0
+	for i := 0; i < 10000; i++ {
+		if quarter.keep() {
+			kept++
+		}
+	}
+
+	// Well outside of the binomial spread of 10000 events
+	if kept < 2000 || kept > 3000 {
+		t.Errorf("expected about 2500 of 10000 events to be kept, got %d", kept)
+	}
+}
+
+func TestFindSampler(t *testing.T) {
+	parsed, err := parseSamplers([]string{"user.session.start=1/10", "user.session.*=0.5"})
+	if err != nil {
+		t.Fatalf("unable to parse sample rates: %v", err)
+	}
+
+	previous := samplers
+	samplers = parsed
+	defer func() { samplers = previous }()
+
+	tests := []struct {
+		eventType string
+		outcome   string
+		rate      float64
+	}{
+		{"user.session.start", "SUCCESS", 0.1},
+		{"user.session.end", "SUCCESS", 0.5},
+		{"user.session.start", "FAILURE", 1},
+		{"user.session.start", "deny", 1},
+		{"policy.evaluate_sign_on", "ALLOW", 1},
+	}
+
+	for _, test := range tests {
+		if rate := SampleRate(test.eventType, test.outcome); rate != test.rate {
+			t.Errorf("expected %s with outcome %s sampled at %v, got %v", test.eventType, test.outcome, test.rate, rate)
+		}
+	}
+
+	if !Sampling() {
+		t.Error("expected sampling to be enabled")
+	}
+}
@@ -0,0 +1,31 @@
+package filter
+
+import (
+	"strings"
+)
+
+// System Log severities in increasing order
+var severities = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+// Rank of a severity, or -1 if it isn't a System Log severity
+func severityRank(severity string) int {
+	for rank, s := range severities {
+		if strings.EqualFold(s, severity) {
+			return rank
+		}
+	}
+
+	return -1
+}
+
+// Check if a severity is a System Log severity
+func ValidSeverity(severity string) bool {
+	return severityRank(severity) >= 0
+}
+
+// Check if a severity is at least the minimum severity
+// Unknown severities never are.
+func AtLeastSeverity(severity string, minimum string) bool {
+	rank := severityRank(severity)
+	return rank >= 0 && rank >= severityRank(minimum)
+}
@@ -0,0 +1,102 @@
+package filter
+
+import (
+	"log"
+
+	"github.com/rfizzle/okta-collector/client"
+	"github.com/rfizzle/okta-collector/match"
+	"github.com/rfizzle/okta-collector/metrics"
+	"github.com/rfizzle/okta-collector/secrets"
+	"github.com/spf13/viper"
+)
+
+// Kinds of noise suppression entries, used as metric keys
+const (
+	suppressedActor     = "actor"
+	suppressedUserAgent = "user_agent"
+	suppressedApiToken  = "api_token"
+)
+
+// Noise suppression list of known automation
+type suppressions struct {
+	actorIds    []string
+	userAgents  match.Globs
+	apiTokenIds []string
+}
+
+// Suppressions loaded from the params by Setup
+var suppressed suppressions
+
+// Load the suppression list, resolving API token names to the IDs found in events
+func loadSuppressions() (suppressions, error) {
+	userAgents, err := match.CompileGlobs(viper.GetStringSlice("suppress-user-agents"))
+	if err != nil {
+		return suppressions{}, err
+	}
+
+	s := suppressions{
+		actorIds:    viper.GetStringSlice("suppress-actor-ids"),
+		userAgents:  userAgents,
+		apiTokenIds: viper.GetStringSlice("suppress-api-tokens"),
+	}
+
+	if len(s.apiTokenIds) == 0 {
+		return s, nil
+	}
+
+	// Events only carry the ID of the API token, so look up tokens listed by name
+	oktaClient := client.NewClient(viper.GetString("okta-domain"), secrets.Value("okta-api-key"))
+	tokens, err := oktaClient.GetApiTokens()
+	if err != nil {
+		log.Printf("Unable to resolve suppressed api token names, matching api token ids only: %v\n", err)
+		return s, nil
+	}
+
+	names := s.apiTokenIds
+	for _, token := range tokens {
+		if match.ContainsFold(names, token.Name) {
+			s.apiTokenIds = append(s.apiTokenIds, token.Id)
+		}
+	}
+
+	return s, nil
+}
+
+// Find the kind of suppression entry an event matches
+// Returns an empty string when the event isn't suppressed.
+func (s suppressions) match(fields *eventFields) string {
+	if fields.Actor.Id != "" && contains(s.actorIds, fields.Actor.Id) {
+		return suppressedActor
+	}
+
+	if fields.Client.UserAgent.RawUserAgent != "" && s.userAgents.Any(fields.Client.UserAgent.RawUserAgent) {
+		return suppressedUserAgent
+	}
+
+	if fields.Transaction.Detail.RequestApiTokenId != "" && contains(s.apiTokenIds, fields.Transaction.Detail.RequestApiTokenId) {
+		return suppressedApiToken
+	}
+
+	return ""
+}
+
+// Check if an event is suppressed, counting it in the suppressed events metric
+func (s suppressions) suppresses(fields *eventFields) bool {
+	kind := s.match(fields)
+	if kind == "" {
+		return false
+	}
+
+	metrics.SuppressedEvents.Add(kind, 1)
+	return true
+}
+
+// Check if a slice contains a value
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package filter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// Event from a client with a user agent
+func userAgentEvent(userAgent string) []byte {
+	return []byte(fmt.Sprintf(`{"eventType": "user.session.start", "client": {"userAgent": {"rawUserAgent": %q}}}`, userAgent))
+}
+
+func TestKeepSuppressesUserAgents(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("suppress-user-agents", []string{"python-requests*", "Terraform/*"})
+
+	if err := Setup(); err != nil {
+		t.Fatalf("unable to setup filters: %v", err)
+	}
+
+	tests := []struct {
+		userAgent string
+		keep      bool
+	}{
+		{"python-requests/2.25.1", false},
+		{"python-requests/2.31.0 (okta-sync)", false},
+		{"Terraform/1.5.0 (+https://www.terraform.io) terraform-provider-okta/4.0", false},
+		{"Terraform/0.12.31 (+https://www.terraform.io) Terraform-Plugin-SDK/2.10.1 terraform-provider-okta/3.20.2", false},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36", true},
+		{"okta-sdk-golang/2.20.0 golang/go1.20.5 linux/amd64 terraform-provider-okta/4.0", true},
+		{"", true},
+	}
+
+	for _, test := range tests {
+		keep, err := Keep(userAgentEvent(test.userAgent))
+		if err != nil {
+			t.Fatalf("unable to filter event: %v", err)
+		}
+
+		if keep != test.keep {
+			t.Errorf("expected keeping an event from %q to be %v", test.userAgent, test.keep)
+		}
+	}
+}
+
+func TestValidateSuppressUserAgents(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.Set("suppress-user-agents", []string{"Terraform/*", "[internal] scanner/*"})
+	if err := ValidateCLIParams(); err != nil {
+		t.Errorf("expected user agents with brackets to be valid: %v", err)
+	}
+
+	viper.Set("suppress-user-agents", []string{`acme-scanner\`})
+	if err := ValidateCLIParams(); err == nil {
+		t.Error("expected a trailing escape to be invalid")
+	}
+}
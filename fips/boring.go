@@ -0,0 +1,10 @@
+//go:build boringcrypto
+// +build boringcrypto
+
+package fips
+
+// Restrict every TLS client and server of the process to FIPS-approved settings
+import _ "crypto/tls/fipsonly"
+
+// Built with the BoringCrypto FIPS validated crypto module
+const boringCrypto = true
@@ -0,0 +1,65 @@
+// Package fips restricts the TLS settings of the collector to FIPS-approved algorithms.
+package fips
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"net/http"
+
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// FIPS-approved TLS 1.2 cipher suites (NIST SP 800-52 Rev. 2)
+var cipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// FIPS-approved key exchange curves
+var curves = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+
+// Register the FIPS params
+func InitCLIParams() {
+	flag.Bool("fips-mode", false, "restrict tls to fips-approved versions, cipher suites and curves")
+}
+
+// Check if FIPS mode is enabled, which it always is in BoringCrypto builds
+func Enabled() bool {
+	return boringCrypto || viper.GetBool("fips-mode")
+}
+
+// TLS config restricted to FIPS-approved settings
+// TLS 1.3 is disabled since its cipher suites can't be restricted.
+func Config() *tls.Config {
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		MaxVersion:       tls.VersionTLS12,
+		CipherSuites:     cipherSuites,
+		CurvePreferences: curves,
+	}
+}
+
+// Restrict the default HTTP transport, used by the Okta client and most outputs, to FIPS-approved TLS settings
+func Setup() error {
+	if !Enabled() {
+		return nil
+	}
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return errors.New("unable to enable fips mode: unsupported default http transport")
+	}
+	transport.TLSClientConfig = Config()
+
+	if !boringCrypto {
+		log.Println("FIPS mode restricts TLS settings only, build with BoringCrypto for a FIPS validated crypto module")
+	}
+
+	return nil
+}
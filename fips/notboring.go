@@ -0,0 +1,7 @@
+//go:build !boringcrypto
+// +build !boringcrypto
+
+package fips
+
+// Built with the standard Go crypto
+const boringCrypto = false
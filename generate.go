@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/rfizzle/okta-collector/collector"
+)
+
+// A kind of generated event, picked in proportion to its weight
+type generatedEventType struct {
+	eventType      string
+	displayMessage string
+	weight         int
+	// Fraction of events of the type that fail
+	failureRate float64
+	// Type of the target of the event, none if the event has no target
+	targetType string
+}
+
+// Event types of generated events, weighted like the System Log of a typical org
+var generatedEventTypes = []generatedEventType{
+	{"user.session.start", "User login to Okta", 30, 0.08, ""},
+	{"user.authentication.sso", "User single sign on to app", 25, 0.02, "AppInstance"},
+	{"user.authentication.auth_via_mfa", "Authentication of user via MFA", 15, 0.05, ""},
+	{"policy.evaluate_sign_on", "Evaluation of sign-on policy", 15, 0, "PolicyEntity"},
+	{"user.session.end", "User logout from Okta", 8, 0, ""},
+	{"app.oauth2.as.token.grant.access_token", "OAuth2 access token is granted", 4, 0.01, "AppInstance"},
+	{"group.user_membership.add", "Add user to group membership", 1, 0, "UserGroup"},
+	{"user.account.lock", "Max sign in attempts exceeded", 1, 0, ""},
+	{"user.lifecycle.create", "Create okta user", 1, 0, "User"},
+}
+
+// Names, apps, groups, places and browsers generated events are made up from
+var (
+	generatedFirstNames = []string{"Ada", "Alan", "Grace", "Linus", "Margaret", "Dennis", "Barbara", "Ken", "Radia", "Tim"}
+	generatedLastNames  = []string{"Lovelace", "Turing", "Hopper", "Torvalds", "Hamilton", "Ritchie", "Liskov", "Thompson", "Perlman", "Berners-Lee"}
+	generatedApps       = []string{"Salesforce", "Slack", "GitHub", "Workday", "Zoom", "AWS Console", "Google Workspace", "Jira"}
+	generatedGroups     = []string{"Engineering", "Finance", "Sales", "Support", "Admins", "Contractors"}
+	generatedPlaces     = []generatedPlace{
+		{"San Francisco", "California", "United States", "94107", 37.7749, -122.4194},
+		{"New York", "New York", "United States", "10001", 40.7128, -74.0060},
+		{"London", "England", "United Kingdom", "EC1A", 51.5074, -0.1278},
+		{"Berlin", "Berlin", "Germany", "10115", 52.5200, 13.4050},
+		{"Sydney", "New South Wales", "Australia", "2000", -33.8688, 151.2093},
+	}
+	generatedBrowsers = [][3]string{
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/89.0.4389.90 Safari/537.36", "Mac OS X", "CHROME"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:86.0) Gecko/20100101 Firefox/86.0", "Windows 10", "FIREFOX"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 14_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.0.3 Mobile/15E148 Safari/604.1", "iOS", "SAFARI"},
+	}
+	// Documentation address ranges, so generated addresses never belong to anyone
+	generatedNetworks = []string{"192.0.2", "198.51.100", "203.0.113"}
+)
+
+// A place generated events are made from
+type generatedPlace struct {
+	city, state, country, postalCode string
+	lat, lon                         float64
+}
+
+// Generator of realistic fake System Log events
+type eventGenerator struct {
+	rand        *rand.Rand
+	totalWeight int
+	eventTypes  []generatedEventType
+	users       int
+}
+
+// Create a generator of events of the event types, or of every generated event type when empty
+// Events are generated from the seed, so the same seed generates the same events apart from their times.
+func newEventGenerator(seed int64, eventTypes []string) (*eventGenerator, error) {
+	g := &eventGenerator{rand: rand.New(rand.NewSource(seed)), users: 50}
+
+	for _, t := range generatedEventTypes {
+		if len(eventTypes) == 0 || contains(eventTypes, t.eventType) {
+			g.eventTypes = append(g.eventTypes, t)
+			g.totalWeight += t.weight
+		}
+	}
+
+	for _, eventType := range eventTypes {
+		if !g.generates(eventType) {
+			var known []string
+			for _, t := range generatedEventTypes {
+				known = append(known, t.eventType)
+			}
+			return nil, errors.New(fmt.Sprintf("unable to generate %s events, event types are %s", eventType, strings.Join(known, ", ")))
+		}
+	}
+
+	return g, nil
+}
+
+// Check if the generator generates events of an event type
+func (g *eventGenerator) generates(eventType string) bool {
+	for _, t := range g.eventTypes {
+		if t.eventType == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Pick a generated event type by weight
+func (g *eventGenerator) pickType() generatedEventType {
+	n := g.rand.Intn(g.totalWeight)
+	for _, t := range g.eventTypes {
+		if n < t.weight {
+			return t
+		}
+		n -= t.weight
+	}
+	return g.eventTypes[len(g.eventTypes)-1]
+}
+
+// Random hex string of n bytes
+func (g *eventGenerator) hex(n int) string {
+	b := make([]byte, n)
+	g.rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+// Random 20 character Okta object id with a prefix, such as 00u for users
+func (g *eventGenerator) id(prefix string) string {
+	return prefix + g.hex(9)[:17]
+}
+
+// Pick a random string
+func (g *eventGenerator) pick(values []string) string {
+	return values[g.rand.Intn(len(values))]
+}
+
+// Generate an event published at a time
+func (g *eventGenerator) event(published time.Time) []byte {
+	t := g.pickType()
+
+	// Users are numbered so the same user has the same id, name and address across events
+	user := g.rand.Intn(g.users)
+	first, last := generatedFirstNames[user%len(generatedFirstNames)], generatedLastNames[user/len(generatedFirstNames)%len(generatedLastNames)]
+	place := generatedPlaces[user%len(generatedPlaces)]
+	browser := generatedBrowsers[user%len(generatedBrowsers)]
+	address := fmt.Sprintf("%s.%d", generatedNetworks[user%len(generatedNetworks)], 10+user)
+	requestId := g.hex(12)
+	uuid := g.hex(16)
+
+	outcome := map[string]interface{}{"result": "SUCCESS", "reason": nil}
+	severity := "INFO"
+	if g.rand.Float64() < t.failureRate {
+		outcome = map[string]interface{}{"result": "FAILURE", "reason": "INVALID_CREDENTIALS"}
+		severity = "WARN"
+	}
+	if t.eventType == "user.account.lock" {
+		severity = "WARN"
+	}
+
+	var targets []interface{}
+	switch t.targetType {
+	case "AppInstance":
+		targets = append(targets, map[string]interface{}{"id": g.id("0oa"), "type": "AppInstance", "alternateId": g.pick(generatedApps), "displayName": g.pick(generatedApps)})
+	case "UserGroup":
+		group := g.pick(generatedGroups)
+		targets = append(targets, map[string]interface{}{"id": g.id("00g"), "type": "UserGroup", "alternateId": group, "displayName": group})
+	case "PolicyEntity":
+		targets = append(targets, map[string]interface{}{"id": g.id("00p"), "type": "PolicyEntity", "alternateId": "unknown", "displayName": "Default Policy"})
+	case "User":
+		targets = append(targets, map[string]interface{}{"id": g.id("00u"), "type": "User", "alternateId": fmt.Sprintf("new.user%d@example.com", g.rand.Intn(10000)), "displayName": "New User"})
+	}
+
+	content, _ := json.Marshal(map[string]interface{}{
+		"uuid":            fmt.Sprintf("%s-%s-%s-%s-%s", uuid[:8], uuid[8:12], uuid[12:16], uuid[16:20], uuid[20:]),
+		"published":       published.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"eventType":       t.eventType,
+		"version":         "0",
+		"severity":        severity,
+		"legacyEventType": nil,
+		"displayMessage":  t.displayMessage,
+		"actor": map[string]interface{}{
+			"id":          fmt.Sprintf("00u%014d", user),
+			"type":        "User",
+			"alternateId": strings.ToLower(first+"."+last) + "@example.com",
+			"displayName": first + " " + last,
+			"detailEntry": nil,
+		},
+		"client": map[string]interface{}{
+			"userAgent": map[string]interface{}{"rawUserAgent": browser[0], "os": browser[1], "browser": browser[2]},
+			"zone":      "null",
+			"device":    "Computer",
+			"id":        nil,
+			"ipAddress": address,
+			"geographicalContext": map[string]interface{}{
+				"city": place.city, "state": place.state, "country": place.country, "postalCode": place.postalCode,
+				"geolocation": map[string]interface{}{"lat": place.lat, "lon": place.lon},
+			},
+		},
+		"authenticationContext": map[string]interface{}{
+			"authenticationProvider": nil,
+			"credentialProvider":     nil,
+			"credentialType":         nil,
+			"issuer":                 nil,
+			"interface":              nil,
+			"authenticationStep":     0,
+			"externalSessionId":      g.hex(12),
+		},
+		"outcome": outcome,
+		"target":  targets,
+		"transaction": map[string]interface{}{
+			"type":   "WEB",
+			"id":     requestId,
+			"detail": map[string]interface{}{},
+		},
+		"debugContext": map[string]interface{}{
+			"debugData": map[string]interface{}{"requestId": requestId, "requestUri": "/api/v1/authn", "url": "/api/v1/authn?"},
+		},
+		"request": map[string]interface{}{
+			"ipChain": []interface{}{map[string]interface{}{"ip": address, "version": "V4", "source": nil}},
+		},
+		"securityContext": map[string]interface{}{"asNumber": nil, "asOrg": nil, "isp": nil, "domain": nil, "isProxy": nil},
+	})
+
+	return content
+}
+
+// Feed generated events through the filters, enrichments and outputs at a rate of events a second, as fast as
+// possible for a zero rate, without querying Okta or changing the saved state
+// Detections aren't run, so generated events never alert.
+func generate(events int, rate float64, eventTypes []string, seed int64, timeout time.Duration) error {
+	if events < 1 {
+		return errors.New("events must be at least 1")
+	}
+
+	if rate < 0 {
+		return errors.New("rate must not be negative")
+	}
+
+	generator, err := newEventGenerator(seed, eventTypes)
+	if err != nil {
+		return err
+	}
+
+	c, err := setupCollector(collector.WithOffline(), collector.WithAckTimeout(timeout))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	log.Printf("Generating %d events...\n", events)
+
+	start := time.Now()
+	for i := 0; i < events; i++ {
+		if rate > 0 {
+			if wait := time.Until(start.Add(time.Duration(float64(i) / rate * float64(time.Second)))); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		c.Deliver(generator.event(time.Now()))
+	}
+
+	elapsed := time.Since(start)
+	log.Printf("Generated %d events in %v (%.0f events a second)\n", events, elapsed.Round(time.Millisecond), float64(events)/elapsed.Seconds())
+
+	return c.Flush(context.Background())
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rfizzle/okta-collector/client"
+	"github.com/rfizzle/okta-collector/secrets"
+	"github.com/spf13/viper"
+)
+
+// Sources of the Okta API key offered by the setup wizard
+const (
+	credentialKey       = "key"
+	credentialFile      = "file"
+	credentialReference = "reference"
+)
+
+// Outputs offered by the setup wizard, the other outputs are set up by editing the config
+var initOutputs = []string{"file", "s3", "gcs", "http"}
+
+// Prompts of the setup wizard, reading answers line by line
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// Ask a question, returning the default for an empty answer
+func (w *wizard) ask(question string, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(w.out, "%s: ", question)
+	}
+
+	answer, err := w.in.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", errors.New(fmt.Sprintf("no answer to %s", strings.ToLower(question)))
+	}
+
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def, nil
+	}
+
+	return answer, nil
+}
+
+// Ask a question until it is answered
+func (w *wizard) require(question string, def string) (string, error) {
+	for {
+		answer, err := w.ask(question, def)
+		if err != nil || answer != "" {
+			return answer, err
+		}
+
+		fmt.Fprintln(w.out, "An answer is required.")
+	}
+}
+
+// Ask to pick one of the choices until a choice is picked
+func (w *wizard) choose(question string, choices []string, def string) (string, error) {
+	for {
+		answer, err := w.ask(fmt.Sprintf("%s (%s)", question, strings.Join(choices, ", ")), def)
+		if err != nil || contains(choices, answer) {
+			return answer, err
+		}
+
+		fmt.Fprintf(w.out, "Pick one of %s.\n", strings.Join(choices, ", "))
+	}
+}
+
+// Ask a yes or no question
+func (w *wizard) confirm(question string) (bool, error) {
+	answer, err := w.ask(question+" (y/N)", "")
+	return strings.HasPrefix(strings.ToLower(answer), "y"), err
+}
+
+// Ask for the settings of the collector, check access to Okta and write them as a JSON config file at path
+func initConfig(path string, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return errors.New(fmt.Sprintf("config file %s already exists, use --force to overwrite it", path))
+	}
+
+	w := &wizard{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	config := make(map[string]interface{})
+
+	fmt.Fprintf(w.out, "This will write an okta-collector config to %s.\n\n", path)
+
+	// Okta
+	for {
+		answer, err := w.require("Okta domain, such as acme.okta.com", "")
+		if err != nil {
+			return err
+		}
+
+		domain, err := client.NormalizeDomain(answer)
+		if err == nil {
+			config["okta-domain"] = domain
+			break
+		}
+
+		fmt.Fprintf(w.out, "%v\n", err)
+	}
+
+	if err := askCredential(w, config); err != nil {
+		return err
+	}
+
+	// Collection
+	for {
+		answer, err := w.ask("Seconds between collections", "30")
+		if err != nil {
+			return err
+		}
+
+		if schedule, err := strconv.Atoi(answer); err == nil && schedule > 0 {
+			config["schedule"] = schedule
+			break
+		}
+
+		fmt.Fprintln(w.out, "The schedule must be a positive number of seconds.")
+	}
+
+	statePath, err := w.require("State file path", "/var/lib/okta-collector/state.json")
+	if err != nil {
+		return err
+	}
+	config["state-path"] = statePath
+
+	if err := askOutput(w, config); err != nil {
+		return err
+	}
+
+	// Check access to the System Log before writing the config
+	fmt.Fprintln(w.out, "\nChecking access to the Okta System Log...")
+	if err := checkInitAccess(config); err != nil {
+		fmt.Fprintf(w.out, "Unable to read the Okta System Log: %v\n", err)
+		if ok, err := w.confirm("Write the config anyway?"); err != nil || !ok {
+			return errors.New("config not written")
+		}
+	} else {
+		fmt.Fprintln(w.out, "Okta access ok.")
+	}
+
+	content, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// The config may hold the API key
+	if err := ioutil.WriteFile(path, append(content, '\n'), 0600); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w.out, "\nWrote %s. Check it with:\n\n  okta-collector doctor -c --config-path %s\n\n", path, path)
+	fmt.Fprintf(w.out, "and start collecting with:\n\n  okta-collector run -c --config-path %s\n", path)
+	return nil
+}
+
+// Ask where the Okta API key is read from
+func askCredential(w *wizard, config map[string]interface{}) error {
+	fmt.Fprintln(w.out, "\nThe Okta API key can be stored in the config (key), read from a file (file), or fetched from")
+	fmt.Fprintln(w.out, "AWS Secrets Manager, SSM Parameter Store, Vault or Azure Key Vault by reference (reference).")
+
+	source, err := w.choose("API key source", []string{credentialKey, credentialFile, credentialReference}, credentialFile)
+	if err != nil {
+		return err
+	}
+
+	switch source {
+	case credentialKey:
+		config["okta-api-key"], err = w.require("Okta API key", "")
+	case credentialFile:
+		config["okta-api-key-file"], err = w.require("Okta API key file path", "")
+	default:
+		var reference string
+		if reference, err = w.require("Secret reference, such as arn:aws:secretsmanager:... or vault:secret/data/okta#api-key", ""); err != nil {
+			return err
+		}
+		config["okta-api-key"] = reference
+
+		if strings.HasPrefix(reference, "vault:") {
+			config["vault-address"], err = w.require("Vault address, such as https://vault:8200", "")
+		}
+	}
+
+	return err
+}
+
+// Ask for the output to deliver events to and its settings
+func askOutput(w *wizard, config map[string]interface{}) error {
+	fmt.Fprintln(w.out, "\nOther outputs can be enabled later by editing the config.")
+
+	name, err := w.choose("Output", initOutputs, "file")
+	if err != nil {
+		return err
+	}
+	config[name] = true
+
+	// Settings of the output, as param and question pairs
+	var settings [][2]string
+	switch name {
+	case "file":
+		settings = [][2]string{{"file-path", "Log file path"}}
+	case "s3":
+		settings = [][2]string{{"s3-region", "S3 region"}, {"s3-bucket", "S3 bucket"}, {"s3-path", "S3 path"}}
+	case "gcs":
+		settings = [][2]string{{"gcs-bucket", "GCS bucket"}, {"gcs-path", "GCS path"}, {"gcs-credentials", "GCS credentials file path"}}
+	case "http":
+		settings = [][2]string{{"http-url", "HTTP endpoint URL"}}
+	}
+
+	for _, setting := range settings {
+		if config[setting[0]], err = w.require(setting[1], ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Check that the API key of the config can read the System Log
+func checkInitAccess(config map[string]interface{}) error {
+	reference, _ := config["okta-api-key"].(string)
+	if path, ok := config["okta-api-key-file"].(string); ok {
+		reference = "file:" + path
+	}
+
+	viper.Set("okta-api-key", reference)
+	if address, ok := config["vault-address"]; ok {
+		viper.Set("vault-address", address)
+	}
+
+	if err := secrets.Resolve([]string{"okta-api-key"}); err != nil {
+		return err
+	}
+
+	setupVersion()
+	oktaClient := client.NewClient(config["okta-domain"].(string), secrets.Value("okta-api-key"))
+	return oktaClient.CheckAccess("/api/v1/logs")
+}
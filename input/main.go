@@ -0,0 +1,118 @@
+// Package input defines the sources of identity events collected through the pipeline of the collector.
+package input
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Input is a source of identity events, such as the Okta System Log.
+type Input interface {
+	// Name of the input used in logs and errors
+	Name() string
+
+	// Send the events published from since until until to events, oldest first
+	// Paging stops once the deadline of the context passed, when set, returning the published time of the last event
+	// sent to resume from next poll, or the zero time when every event of the window was sent.
+	// Errors of rejected credentials wrap a *client.CredentialsError, so collection can await rotated credentials, and
+	// errors of windows that can be collected again next poll wrap a *client.TransientError.
+	Poll(ctx context.Context, since time.Time, until time.Time, events chan<- []byte) (time.Time, error)
+}
+
+// A kind of input that can be selected through the input param
+type Type struct {
+	// Name of the input type, the value of the input param selecting it
+	Name string
+
+	// Register the CLI params of the input type, nil for none
+	InitParams func()
+
+	// Validate the CLI params of the input type when it is selected, nil for none
+	ValidateParams func() error
+
+	// Create an input from the validated params
+	New func() (Input, error)
+}
+
+// Every registered input type
+var inputTypes = []Type{
+	{Name: Okta, New: newOktaInput},
+}
+
+// Register an input type, before the CLI params are registered
+func Register(t Type) {
+	inputTypes = append(inputTypes, t)
+}
+
+// Names of the registered input types
+func Names() []string {
+	var names []string
+	for _, t := range inputTypes {
+		names = append(names, t.Name)
+	}
+
+	return names
+}
+
+// Register the CLI params of every input type
+func InitCLIParams() {
+	flag.String("input", Okta, fmt.Sprintf("source of the collected events (%s)", strings.Join(Names(), ", ")))
+
+	for _, t := range inputTypes {
+		if t.InitParams != nil {
+			t.InitParams()
+		}
+	}
+}
+
+// Validate the input param and the CLI params of the selected input type
+func ValidateCLIParams() error {
+	t, err := selected()
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid input param (--input): %v", err))
+	}
+
+	if t.ValidateParams != nil {
+		return t.ValidateParams()
+	}
+
+	return nil
+}
+
+// Name of the selected input type
+func Selected() string {
+	return viper.GetString("input")
+}
+
+// Create an input of the selected input type
+func New() (Input, error) {
+	t, err := selected()
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := t.New()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to setup %s input: %v", t.Name, err))
+	}
+
+	return i, nil
+}
+
+// Input type selected by the input param
+func selected() (Type, error) {
+	name := Selected()
+	for _, t := range inputTypes {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+
+	return Type{}, errors.New(fmt.Sprintf("unknown input %s, inputs are %s", name, strings.Join(Names(), ", ")))
+}
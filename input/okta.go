@@ -0,0 +1,137 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/rfizzle/okta-collector/client"
+	"github.com/rfizzle/okta-collector/filter"
+	"github.com/rfizzle/okta-collector/pool"
+	"github.com/rfizzle/okta-collector/secrets"
+	"github.com/rfizzle/okta-collector/tenants"
+	"github.com/spf13/viper"
+)
+
+// Name of the Okta System Log input type
+const Okta = "okta"
+
+// Layout of the times of System Log requests, with the milliseconds of published times
+const oktaTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// Input of the System Log of the Okta org of the params, or of a tenant
+// Requests of the org of the params rotate across its API keys when several are set.
+type oktaInput struct {
+	tenant *tenants.Tenant
+	tokens *client.TokenRotation
+}
+
+func newOktaInput() (Input, error) {
+	input := &oktaInput{}
+
+	// Rotation keeps the rate limits of the tokens across polls, so it's created once
+	if keys := viper.GetStringSlice("okta-api-keys"); len(keys) > 0 {
+		sources := []func() string{secrets.Source("okta-api-key")}
+		for i := range keys {
+			sources = append(sources, secrets.Source(fmt.Sprintf("okta-api-keys[%d]", i)))
+		}
+		input.tokens = client.NewTokenRotation(sources...)
+	}
+
+	return input, nil
+}
+
+// Create an input of the System Log of a tenant, or of the Okta org of the params when nil
+// Events of tenants are tagged with the name of the tenant for its filters and outputs.
+func NewOkta(tenant *tenants.Tenant) Input {
+	return &oktaInput{tenant: tenant}
+}
+
+func (input *oktaInput) Name() string {
+	if input.tenant != nil {
+		return Okta + " tenant " + input.tenant.Name
+	}
+
+	return Okta
+}
+
+func (input *oktaInput) Poll(ctx context.Context, since time.Time, until time.Time, events chan<- []byte) (time.Time, error) {
+	if input.tenant != nil {
+		return input.pollTenant(ctx, since, until, events)
+	}
+
+	// Build an Okta client with the latest API key
+	oktaClient := client.NewClient(viper.GetString("okta-domain"), secrets.Value("okta-api-key"))
+	oktaClient.Filter = filter.ServerFilter()
+	oktaClient.Tokens = input.tokens
+
+	resume, err := getLogs(ctx, oktaClient, since, until, events)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Unable to retrieve okta logs: %w", err)
+	}
+
+	return resume, nil
+}
+
+// Get the events of a tenant, tagged with its name
+func (input *oktaInput) pollTenant(ctx context.Context, since time.Time, until time.Time, events chan<- []byte) (time.Time, error) {
+	oktaClient := input.tenant.NewClient()
+	oktaClient.Filter = combineServerFilters(filter.ServerFilter(), input.tenant.ServerFilter())
+
+	collected := make(chan []byte, viper.GetInt("event-buffer-size"))
+	tagged := make(chan struct{})
+	go func() {
+		for event := range collected {
+			if t, err := input.tenant.Tag(event); err != nil {
+				log.Printf("Unable to tag event of tenant %s: %v\n", input.tenant.Name, err)
+			} else {
+				pool.Put(event)
+				event = t
+			}
+			events <- event
+		}
+		close(tagged)
+	}()
+
+	resume, err := getLogs(ctx, oktaClient, since, until, collected)
+	close(collected)
+	<-tagged
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Unable to retrieve okta logs of tenant %s: %w", input.tenant.Name, err)
+	}
+
+	return resume, nil
+}
+
+// Get the System Log events of a window, stopping at the deadline of the context
+func getLogs(ctx context.Context, oktaClient *client.OktaClient, since time.Time, until time.Time, events chan<- []byte) (time.Time, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		oktaClient.Deadline = deadline
+	}
+
+	_, resume, err := oktaClient.GetLogs(since.Format(oktaTimeLayout), until.Format(oktaTimeLayout), events)
+	if err != nil || resume == "" {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339Nano, resume)
+}
+
+// Combine System Log API filter expressions that must all match
+func combineServerFilters(expressions ...string) string {
+	var combined []string
+	for _, expression := range expressions {
+		if expression != "" {
+			combined = append(combined, expression)
+		}
+	}
+
+	if len(combined) < 2 {
+		return strings.Join(combined, "")
+	}
+
+	return "(" + strings.Join(combined, ") and (") + ")"
+}
@@ -0,0 +1,127 @@
+// Package iplist matches IP addresses against lists of CIDR ranges.
+package iplist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// A list of CIDR ranges
+// Single addresses are kept apart so large lists of them, such as threat intel lists, are matched in constant time.
+type List struct {
+	networks  []*net.IPNet
+	addresses map[string]bool
+}
+
+// Parse a list from CIDR ranges, IP addresses and @path references to files of them, one per line
+// Blank lines and lines starting with # are skipped in files.
+func Parse(values []string) (*List, error) {
+	list := &List{addresses: make(map[string]bool)}
+	for _, value := range values {
+		if strings.HasPrefix(value, "@") {
+			if err := list.load(strings.TrimPrefix(value, "@")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := list.add(value); err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+// Parse a list from a reader of CIDR ranges and IP addresses, one per line
+// Blank lines and lines starting with # are skipped.
+func ParseReader(name string, reader io.Reader) (*List, error) {
+	list := &List{addresses: make(map[string]bool)}
+	if err := list.read(name, reader); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Load the CIDR ranges in a file
+func (list *List) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return list.read(path, file)
+}
+
+// Read the CIDR ranges of a reader, naming it in errors
+func (list *List) read(name string, reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err := list.add(line); err != nil {
+			return errors.New(fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Add a CIDR range or IP address
+func (list *List) add(value string) error {
+	value = strings.TrimSpace(value)
+
+	// Single addresses
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return errors.New(fmt.Sprintf("invalid ip address %s", value))
+		}
+
+		list.addresses[ip.String()] = true
+		return nil
+	}
+
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid cidr range %s", value))
+	}
+
+	list.networks = append(list.networks, network)
+	return nil
+}
+
+// Number of ranges in the list
+func (list *List) Len() int {
+	return len(list.networks) + len(list.addresses)
+}
+
+// Check if an IP address is inside any range of the list
+func (list *List) Contains(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	if list.addresses[ip.String()] {
+		return true
+	}
+
+	for _, network := range list.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,122 @@
+package iplist
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContains(t *testing.T) {
+	list, err := Parse([]string{"10.0.0.0/8", "192.168.1.0/24", "203.0.113.7", "2001:db8::/32", "2001:0db8:ffff::1", " 198.51.100.0/31 "})
+	if err != nil {
+		t.Fatalf("unable to parse list: %v", err)
+	}
+
+	tests := []struct {
+		address  string
+		contains bool
+	}{
+		{"10.0.0.0", true},
+		{"10.255.255.255", true},
+		{"11.0.0.0", false},
+		{"9.255.255.255", false},
+		{"192.168.1.0", true},
+		{"192.168.1.255", true},
+		{"192.168.2.0", false},
+		{"192.168.0.255", false},
+		{"203.0.113.7", true},
+		{"203.0.113.8", false},
+		{"198.51.100.0", true},
+		{"198.51.100.1", true},
+		{"198.51.100.2", false},
+		{"::ffff:10.1.2.3", true},
+		{"::ffff:203.0.113.7", true},
+		{"2001:db8::1", true},
+		{"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", true},
+		{"2001:db9::", false},
+		{"2001:db7:ffff::", false},
+		{"2001:DB8:0:0:0:0:0:1", true},
+		{"fe80::1", false},
+		{"", false},
+		{"not-an-ip", false},
+		{"10.0.0.1/32", false},
+		{"10.0.0.256", false},
+	}
+
+	for _, test := range tests {
+		if contains := list.Contains(test.address); contains != test.contains {
+			t.Errorf("expected the list containing %q to be %v", test.address, test.contains)
+		}
+	}
+}
+
+func TestContainsIPv6Address(t *testing.T) {
+	list, err := Parse([]string{"2001:0db8:0000:0000:0000:0000:0000:0001"})
+	if err != nil {
+		t.Fatalf("unable to parse list: %v", err)
+	}
+
+	if !list.Contains("2001:db8::1") {
+		t.Error("expected addresses to match whatever their notation")
+	}
+
+	if list.Contains("2001:db8::2") {
+		t.Error("expected a different address not to match")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		value  string
+		reason string
+	}{
+		{"10.0.0.0/33", "invalid cidr range"},
+		{"10.0.0/8", "invalid cidr range"},
+		{"10.0.0.0/", "invalid cidr range"},
+		{"2001:db8::/129", "invalid cidr range"},
+		{"10.0.0.256", "invalid ip address"},
+		{"10.0.0", "invalid ip address"},
+		{"acme.example.com", "invalid ip address"},
+		{"", "invalid ip address"},
+		{"@/nonexistent/iplist.txt", "no such file"},
+	}
+
+	for _, test := range tests {
+		if _, err := Parse([]string{"10.0.0.0/8", test.value}); err == nil {
+			t.Errorf("expected %q to be invalid", test.value)
+		} else if !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("expected an error about %q for %q, got %v", test.reason, test.value, err)
+		}
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ranges.txt")
+	content := "# office ranges\n\n10.0.0.0/8\n  192.0.2.1  \n# vpn\n2001:db8::/32\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	list, err := Parse([]string{"@" + path, "203.0.113.0/24"})
+	if err != nil {
+		t.Fatalf("unable to parse list: %v", err)
+	}
+
+	if list.Len() != 4 {
+		t.Errorf("expected 4 ranges, got %d", list.Len())
+	}
+
+	for _, address := range []string{"10.1.1.1", "192.0.2.1", "2001:db8::5", "203.0.113.10"} {
+		if !list.Contains(address) {
+			t.Errorf("expected the list to contain %s", address)
+		}
+	}
+}
+
+func TestParseReaderNamesInvalidLines(t *testing.T) {
+	_, err := ParseReader("threat-intel", strings.NewReader("10.0.0.0/8\n10.0.0.0/40\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "threat-intel: invalid cidr range 10.0.0.0/40") {
+		t.Errorf("expected the invalid line to be named with its source, got %v", err)
+	}
+}
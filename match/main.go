@@ -0,0 +1,106 @@
+// Package match holds the wildcard and value matching shared by filters, routes, tenants, enrichments and detections,
+// so a pattern matches the same values wherever it is given.
+package match
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Check if a value matches a wildcard pattern
+// Wildcards don't match /, like shell file name patterns.
+func Pattern(pattern, value string) bool {
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+// Check if a value matches a wildcard pattern, ignoring case
+func PatternFold(pattern, value string) bool {
+	return Pattern(strings.ToLower(pattern), strings.ToLower(value))
+}
+
+// Check if a wildcard pattern is well formed
+func ValidPattern(pattern string) bool {
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// Check if a value matches any of the wildcard patterns
+func Any(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if Pattern(pattern, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if a slice contains a value, ignoring case
+func ContainsFold(s []string, e string) bool {
+	for _, a := range s {
+		if strings.EqualFold(a, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compiled glob patterns, where * matches any characters including /
+// Used for free form values such as user agents, which are full of slashes.
+type Globs []*regexp.Regexp
+
+// Compile glob patterns, where * matches any characters, ? matches one character and \ escapes the next one
+func CompileGlobs(patterns []string) (Globs, error) {
+	var globs Globs
+	for _, pattern := range patterns {
+		expression, err := globExpression(pattern)
+		if err != nil {
+			return nil, err
+		}
+		globs = append(globs, regexp.MustCompile(expression))
+	}
+
+	return globs, nil
+}
+
+// Check if a value matches any of the globs
+func (g Globs) Any(value string) bool {
+	for _, glob := range g {
+		if glob.MatchString(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Convert a glob pattern to an anchored regular expression
+func globExpression(pattern string) (string, error) {
+	var b strings.Builder
+	b.WriteString(`^(?s:`)
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		case '\\':
+			if i+1 == len(runes) {
+				return "", errors.New(fmt.Sprintf("invalid pattern %s: trailing escape", pattern))
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+	}
+
+	b.WriteString(`)$`)
+	return b.String(), nil
+}
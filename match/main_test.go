@@ -0,0 +1,117 @@
+package match
+
+import "testing"
+
+func TestGlobs(t *testing.T) {
+	tests := []struct {
+		name      string
+		pattern   string
+		userAgent string
+		matches   bool
+	}{
+		{"trailing wildcard crosses slashes", "python-requests*", "python-requests/2.25.1", true},
+		{"trailing wildcard after slash", "Terraform/*", "Terraform/1.5.0 (+https://www.terraform.io) terraform-provider-okta/4.0", true},
+		{"trailing wildcard after slash mismatch", "Terraform/*", "terraform-provider-okta/4.0 Terraform/1.5.0", false},
+		{"is case sensitive", "terraform/*", "Terraform/1.5.0 (+https://www.terraform.io) terraform-provider-okta/4.0", false},
+		{"leading and trailing wildcards", "*okta-sdk-golang*", "okta-sdk-golang/2.20.0 golang/go1.20.5 linux/amd64", true},
+		{"wildcard in the middle", "Mozilla/5.0 * Chrome/*", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36", true},
+		{"wildcard in the middle mismatch", "Mozilla/5.0 * Firefox/*", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36", false},
+		{"exact", "okta-aws-cli/1.2.1", "okta-aws-cli/1.2.1", true},
+		{"exact is anchored", "okta-aws-cli/1.2", "okta-aws-cli/1.2.1", false},
+		{"single character wildcard", "curl/7.?8.0", "curl/7.88.0", true},
+		{"single character wildcard needs a character", "curl/7.8?.0", "curl/7.8.0", false},
+		{"regexp characters are literal", "Go-http-client/1.1 (+*)", "Go-http-client/1.1 (+https://golang.org)", true},
+		{"brackets are literal", "scanner [*]", "scanner [internal]", true},
+		{"escaped wildcard", `acme\*scanner/*`, "acme*scanner/1.0", true},
+		{"escaped wildcard is literal", `acme\*scanner/*`, "acme-fast-scanner/1.0", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			globs, err := CompileGlobs([]string{test.pattern})
+			if err != nil {
+				t.Fatalf("unable to compile %s: %v", test.pattern, err)
+			}
+
+			if matches := globs.Any(test.userAgent); matches != test.matches {
+				t.Errorf("expected %s matching %s to be %v", test.pattern, test.userAgent, test.matches)
+			}
+		})
+	}
+}
+
+func TestGlobsAny(t *testing.T) {
+	globs, err := CompileGlobs([]string{"acme-scanner/*", "Terraform/*"})
+	if err != nil {
+		t.Fatalf("unable to compile globs: %v", err)
+	}
+
+	if !globs.Any("Terraform/1.5.0 (+https://www.terraform.io) terraform-provider-okta/4.0") {
+		t.Error("expected the second glob to match")
+	}
+
+	if globs.Any("okta-sdk-golang/2.20.0 golang/go1.20.5 linux/amd64") {
+		t.Error("expected no glob to match")
+	}
+
+	if Globs(nil).Any("Terraform/1.5.0") {
+		t.Error("expected no globs to match nothing")
+	}
+}
+
+func TestCompileGlobsInvalid(t *testing.T) {
+	if _, err := CompileGlobs([]string{"Terraform/*", `acme-scanner\`}); err == nil {
+		t.Error("expected a trailing escape to be invalid")
+	}
+}
+
+func TestPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		matches bool
+	}{
+		{"user.session.start", "user.session.start", true},
+		{"user.session.*", "user.session.start", true},
+		{"user.*", "user.session.start", true},
+		{"user.session.?tart", "user.session.start", true},
+		{"user.session.[se]*", "user.session.end", true},
+		{"user.session.*", "user.authentication.sso", false},
+		{"User.Session.*", "user.session.start", false},
+		{"app/*", "app/okta/sso", false},
+	}
+
+	for _, test := range tests {
+		if matches := Pattern(test.pattern, test.value); matches != test.matches {
+			t.Errorf("expected %s matching %s to be %v", test.pattern, test.value, test.matches)
+		}
+	}
+}
+
+func TestPatternFold(t *testing.T) {
+	if !PatternFold("User.Session.*", "user.SESSION.start") {
+		t.Error("expected matching to ignore case")
+	}
+
+	if PatternFold("user.session.*", "user.authentication.sso") {
+		t.Error("expected a different event type not to match")
+	}
+}
+
+func TestValidPattern(t *testing.T) {
+	for pattern, valid := range map[string]bool{"user.*": true, "user.[se]*": true, "user.[session": false, `user.\`: false} {
+		if ValidPattern(pattern) != valid {
+			t.Errorf("expected %s being valid to be %v", pattern, valid)
+		}
+	}
+}
+
+func TestAny(t *testing.T) {
+	if !Any([]string{"user.session.*", "policy.*"}, "policy.lifecycle.update") {
+		t.Error("expected the second pattern to match")
+	}
+
+	if Any(nil, "policy.lifecycle.update") {
+		t.Error("expected no patterns to match nothing")
+	}
+}
@@ -0,0 +1,111 @@
+// Package metrics publishes collector counters and gauges with expvar, optionally serving them over HTTP.
+package metrics
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Events dropped by the noise suppression list, by the kind of entry that matched
+var SuppressedEvents = expvar.NewMap("okta_collector_suppressed_events")
+
+// Events set aside as malformed, by the stage of processing they failed
+var QuarantinedEvents = expvar.NewMap("okta_collector_quarantined_events")
+
+// Okta API responses announcing that their endpoint is deprecated or will be removed, by endpoint
+var DeprecatedResponses = expvar.NewMap("okta_collector_deprecated_responses")
+
+// Latest rate limit of Okta API endpoints requested by the collector and the requests remaining until it resets, by
+// endpoint
+var (
+	RateLimitLimit     = expvar.NewMap("okta_collector_rate_limit_limit")
+	RateLimitRemaining = expvar.NewMap("okta_collector_rate_limit_remaining")
+)
+
+// Version, commit, build date and Go version of the running collector
+var BuildInfo = expvar.NewMap("okta_collector_build_info")
+
+// Health statuses of the collector
+const (
+	HealthOk                 = "ok"
+	HealthCredentialsInvalid = "credentials invalid"
+)
+
+// Health of the collector, ok or the reason it is degraded
+var Health = expvar.NewString("okta_collector_health")
+
+func init() {
+	Health.Set(HealthOk)
+}
+
+// Register the metrics params
+func InitCLIParams() {
+	flag.String("metrics-address", "", "address to serve metrics on as json, such as localhost:9090 (default disabled)")
+}
+
+// Validate the metrics params
+func ValidateCLIParams() error {
+	if address := viper.GetString("metrics-address"); address != "" {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return errors.New(fmt.Sprintf("invalid metrics address param (--metrics-address): %v", err))
+		}
+	}
+
+	return nil
+}
+
+// Publish the build metadata of the collector
+func SetBuildInfo(version, commit, buildDate, goVersion string) {
+	for key, value := range map[string]string{"version": version, "commit": commit, "build_date": buildDate, "go_version": goVersion} {
+		v := new(expvar.String)
+		v.Set(value)
+		BuildInfo.Set(key, v)
+	}
+}
+
+// Set the health of the collector
+func SetHealth(status string) {
+	Health.Set(status)
+}
+
+// Serve the health of the collector, with a 503 while it is degraded
+func serveHealth(w http.ResponseWriter, r *http.Request) {
+	status := Health.Value()
+	if status != HealthOk {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_, _ = fmt.Fprintln(w, status)
+}
+
+// Start serving metrics if enabled
+func Setup() error {
+	address := viper.GetString("metrics-address")
+	if address == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to serve metrics: %v", err))
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", expvar.Handler())
+	mux.HandleFunc("/health", serveHealth)
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf("Metrics server stopped: %v\n", err)
+		}
+	}()
+
+	return nil
+}
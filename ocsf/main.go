@@ -0,0 +1,270 @@
+// Package ocsf converts Okta System Log events to Open Cybersecurity Schema Framework (OCSF) events.
+package ocsf
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// OCSF schema version the events conform to
+const SchemaVersion = "1.0.0"
+
+// Identity & Access Management category
+const (
+	IAMCategoryUid  = 3
+	IAMCategoryName = "Identity & Access Management"
+)
+
+// OCSF classes events are converted to
+const (
+	AccountChangeClassUid   = 3001
+	AuthenticationClassUid  = 3002
+	GroupManagementClassUid = 3006
+)
+
+// Names of the OCSF classes
+var classNames = map[int32]string{
+	AccountChangeClassUid:   "Account Change",
+	AuthenticationClassUid:  "Authentication",
+	GroupManagementClassUid: "Group Management",
+}
+
+// Other activity of any class
+const otherActivityId = 99
+
+// An OCSF activity an Okta event type maps to
+type activity struct {
+	classUid int32
+	id       int32
+	name     string
+}
+
+// Okta event types mapped to OCSF activities
+var eventTypeActivities = map[string]activity{
+	// Authentication
+	"user.session.start":               {AuthenticationClassUid, 1, "Logon"},
+	"user.authentication.sso":          {AuthenticationClassUid, 1, "Logon"},
+	"user.authentication.auth_via_mfa": {AuthenticationClassUid, 1, "Logon"},
+	"user.authentication.verify":       {AuthenticationClassUid, 1, "Logon"},
+	"user.session.end":                 {AuthenticationClassUid, 2, "Logoff"},
+	"app.oauth2.token.grant":           {AuthenticationClassUid, 3, "Authentication Ticket"},
+
+	// Account change
+	"user.lifecycle.create":           {AccountChangeClassUid, 1, "Create"},
+	"user.lifecycle.activate":         {AccountChangeClassUid, 2, "Enable"},
+	"user.lifecycle.reactivate":       {AccountChangeClassUid, 2, "Enable"},
+	"user.lifecycle.unsuspend":        {AccountChangeClassUid, 2, "Enable"},
+	"user.account.unlock":             {AccountChangeClassUid, 2, "Enable"},
+	"user.account.update_password":    {AccountChangeClassUid, 3, "Password Change"},
+	"user.account.reset_password":     {AccountChangeClassUid, 4, "Password Reset"},
+	"user.lifecycle.deactivate":       {AccountChangeClassUid, 5, "Disable"},
+	"user.lifecycle.suspend":          {AccountChangeClassUid, 5, "Disable"},
+	"user.lifecycle.delete.initiated": {AccountChangeClassUid, 6, "Delete"},
+	"user.account.lock":               {AccountChangeClassUid, 9, "Lock"},
+	"user.mfa.factor.activate":        {AccountChangeClassUid, 10, "MFA Factor Enable"},
+	"user.mfa.factor.deactivate":      {AccountChangeClassUid, 11, "MFA Factor Disable"},
+	"user.mfa.factor.reset_all":       {AccountChangeClassUid, 11, "MFA Factor Disable"},
+
+	// Group management
+	"group.privilege.grant":        {GroupManagementClassUid, 1, "Assign Privileges"},
+	"group.privilege.revoke":       {GroupManagementClassUid, 2, "Revoke Privileges"},
+	"group.user_membership.add":    {GroupManagementClassUid, 3, "Add User"},
+	"group.user_membership.remove": {GroupManagementClassUid, 4, "Remove User"},
+}
+
+// Okta event type prefixes mapped to the other activity of a class
+var eventTypePrefixClasses = map[string]int32{
+	"user.authentication.": AuthenticationClassUid,
+	"user.session.":        AuthenticationClassUid,
+	"user.account.":        AccountChangeClassUid,
+	"user.lifecycle.":      AccountChangeClassUid,
+	"user.mfa.":            AccountChangeClassUid,
+	"group.":               GroupManagementClassUid,
+}
+
+// Fields of an Okta event used for conversion
+type oktaEvent struct {
+	Uuid           string `json:"uuid"`
+	Published      string `json:"published"`
+	EventType      string `json:"eventType"`
+	Severity       string `json:"severity"`
+	DisplayMessage string `json:"displayMessage"`
+	Actor          struct {
+		Id          string `json:"id"`
+		Type        string `json:"type"`
+		AlternateId string `json:"alternateId"`
+		DisplayName string `json:"displayName"`
+	} `json:"actor"`
+	Client struct {
+		IpAddress string `json:"ipAddress"`
+		UserAgent struct {
+			RawUserAgent string `json:"rawUserAgent"`
+		} `json:"userAgent"`
+		GeographicalContext struct {
+			City        string `json:"city"`
+			State       string `json:"state"`
+			Country     string `json:"country"`
+			PostalCode  string `json:"postalCode"`
+			Geolocation struct {
+				Lat float64 `json:"lat"`
+				Lon float64 `json:"lon"`
+			} `json:"geolocation"`
+		} `json:"geographicalContext"`
+	} `json:"client"`
+	Outcome struct {
+		Result string `json:"result"`
+		Reason string `json:"reason"`
+	} `json:"outcome"`
+	Target []struct {
+		Id          string `json:"id"`
+		Type        string `json:"type"`
+		AlternateId string `json:"alternateId"`
+		DisplayName string `json:"displayName"`
+	} `json:"target"`
+	AuthenticationContext struct {
+		ExternalSessionId string `json:"externalSessionId"`
+	} `json:"authenticationContext"`
+}
+
+// Convert a raw Okta event to an OCSF event
+// ok is false when the event type doesn't map to a supported class
+func FromEvent(raw []byte) (event *Event, ok bool, err error) {
+	var okta oktaEvent
+	if err := json.Unmarshal(raw, &okta); err != nil {
+		return nil, false, err
+	}
+
+	// Find activity
+	act, ok := lookupActivity(okta.EventType)
+	if !ok {
+		return nil, false, nil
+	}
+
+	// Parse published time
+	published, err := time.Parse(time.RFC3339Nano, okta.Published)
+	if err != nil {
+		return nil, false, err
+	}
+
+	event = &Event{
+		ActivityId:   act.id,
+		ActivityName: act.name,
+		CategoryUid:  IAMCategoryUid,
+		CategoryName: IAMCategoryName,
+		ClassUid:     act.classUid,
+		ClassName:    classNames[act.classUid],
+		TypeUid:      int64(act.classUid)*100 + int64(act.id),
+		Time:         published.UnixNano() / int64(time.Millisecond),
+		Message:      okta.DisplayMessage,
+		StatusDetail: okta.Outcome.Reason,
+		Metadata: Metadata{
+			Version:      SchemaVersion,
+			Uid:          okta.Uuid,
+			EventCode:    okta.EventType,
+			OriginalTime: okta.Published,
+			Product: Product{
+				Name:       "Okta System Log",
+				VendorName: "Okta",
+			},
+		},
+		Actor: Actor{
+			User: User{
+				Uid:  okta.Actor.Id,
+				Name: okta.Actor.AlternateId,
+				Type: okta.Actor.Type,
+			},
+		},
+		SrcEndpoint: Endpoint{
+			Ip: okta.Client.IpAddress,
+			Location: Location{
+				City:       okta.Client.GeographicalContext.City,
+				Region:     okta.Client.GeographicalContext.State,
+				Country:    okta.Client.GeographicalContext.Country,
+				PostalCode: okta.Client.GeographicalContext.PostalCode,
+				Lat:        okta.Client.GeographicalContext.Geolocation.Lat,
+				Long:       okta.Client.GeographicalContext.Geolocation.Lon,
+			},
+		},
+		HttpRequest: HttpRequest{
+			UserAgent: okta.Client.UserAgent.RawUserAgent,
+		},
+		Session: Session{
+			Uid: okta.AuthenticationContext.ExternalSessionId,
+		},
+		Unmapped: string(raw),
+	}
+
+	// Set severity and status
+	event.SeverityId, event.Severity = severity(okta.Severity)
+	event.StatusId, event.Status = status(okta.Outcome.Result)
+
+	// The affected user is the first user target, or the actor for authentication
+	event.User = event.Actor.User
+	for _, target := range okta.Target {
+		if target.Type == "User" {
+			event.User = User{
+				Uid:  target.Id,
+				Name: target.AlternateId,
+				Type: target.Type,
+			}
+			break
+		}
+	}
+
+	// The affected group is the first group target
+	for _, target := range okta.Target {
+		if target.Type == "UserGroup" {
+			event.Group = Group{
+				Uid:  target.Id,
+				Name: target.DisplayName,
+			}
+			break
+		}
+	}
+
+	return event, true, nil
+}
+
+// Find the OCSF activity of an Okta event type
+func lookupActivity(eventType string) (activity, bool) {
+	if act, ok := eventTypeActivities[eventType]; ok {
+		return act, true
+	}
+
+	for prefix, classUid := range eventTypePrefixClasses {
+		if strings.HasPrefix(eventType, prefix) {
+			return activity{classUid, otherActivityId, "Other"}, true
+		}
+	}
+
+	return activity{}, false
+}
+
+// Map an Okta severity to an OCSF severity
+func severity(oktaSeverity string) (int32, string) {
+	switch oktaSeverity {
+	case "DEBUG", "INFO":
+		return 1, "Informational"
+	case "WARN":
+		return 3, "Medium"
+	case "ERROR":
+		return 4, "High"
+	default:
+		return 0, "Unknown"
+	}
+}
+
+// Map an Okta outcome result to an OCSF status
+func status(result string) (int32, string) {
+	switch result {
+	case "SUCCESS", "ALLOW":
+		return 1, "Success"
+	case "FAILURE", "DENY":
+		return 2, "Failure"
+	case "":
+		return 0, "Unknown"
+	default:
+		return otherActivityId, "Other"
+	}
+}
@@ -0,0 +1,156 @@
+package ocsf
+
+import (
+	"testing"
+)
+
+// Okta event of an admin adding a user to a group
+const testEvent = `{
+	"uuid": "c1f2e3d4-0a38-11ee-9a3c-4f1f0a1b2c3d",
+	"published": "2023-06-12T14:03:21.123Z",
+	"eventType": "group.user_membership.add",
+	"severity": "INFO",
+	"displayMessage": "Add user to group membership",
+	"actor": {"id": "00u1abcd", "type": "User", "alternateId": "admin@example.com", "displayName": "Admin"},
+	"client": {
+		"ipAddress": "203.0.113.7",
+		"userAgent": {"rawUserAgent": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7)"},
+		"geographicalContext": {"city": "Portland", "state": "Oregon", "country": "United States", "postalCode": "97201", "geolocation": {"lat": 45.5, "lon": -122.6}}
+	},
+	"outcome": {"result": "SUCCESS"},
+	"authenticationContext": {"externalSessionId": "102abcdEFGH"},
+	"target": [
+		{"id": "00u2efgh", "type": "User", "alternateId": "jane.doe@example.com", "displayName": "Jane Doe"},
+		{"id": "00g3ijkl", "type": "UserGroup", "alternateId": "unknown", "displayName": "Engineering"}
+	]
+}`
+
+func TestFromEvent(t *testing.T) {
+	event, ok, err := FromEvent([]byte(testEvent))
+	if err != nil || !ok {
+		t.Fatalf("unable to convert event: %v", err)
+	}
+
+	if event.ClassUid != GroupManagementClassUid || event.ClassName != "Group Management" || event.CategoryUid != IAMCategoryUid {
+		t.Errorf("unexpected class %d %s in category %d", event.ClassUid, event.ClassName, event.CategoryUid)
+	}
+
+	if event.ActivityId != 3 || event.ActivityName != "Add User" || event.TypeUid != 300603 {
+		t.Errorf("unexpected activity %d %s of type %d", event.ActivityId, event.ActivityName, event.TypeUid)
+	}
+
+	if event.Time != 1686578601123 || event.Metadata.OriginalTime != "2023-06-12T14:03:21.123Z" {
+		t.Errorf("unexpected time %d, original time %s", event.Time, event.Metadata.OriginalTime)
+	}
+
+	if event.Metadata.Uid != "c1f2e3d4-0a38-11ee-9a3c-4f1f0a1b2c3d" || event.Metadata.EventCode != "group.user_membership.add" || event.Metadata.Version != SchemaVersion {
+		t.Errorf("unexpected metadata %+v", event.Metadata)
+	}
+
+	if event.SeverityId != 1 || event.Severity != "Informational" || event.StatusId != 1 || event.Status != "Success" {
+		t.Errorf("unexpected severity %d %s or status %d %s", event.SeverityId, event.Severity, event.StatusId, event.Status)
+	}
+
+	if event.Actor.User.Uid != "00u1abcd" || event.Actor.User.Name != "admin@example.com" {
+		t.Errorf("unexpected actor %+v", event.Actor.User)
+	}
+
+	if event.User.Uid != "00u2efgh" || event.User.Name != "jane.doe@example.com" {
+		t.Errorf("expected the user target as the affected user, got %+v", event.User)
+	}
+
+	if event.Group.Uid != "00g3ijkl" || event.Group.Name != "Engineering" {
+		t.Errorf("unexpected group %+v", event.Group)
+	}
+
+	location := event.SrcEndpoint.Location
+	if event.SrcEndpoint.Ip != "203.0.113.7" || location.City != "Portland" || location.Region != "Oregon" || location.Lat != 45.5 || location.Long != -122.6 {
+		t.Errorf("unexpected source endpoint %+v", event.SrcEndpoint)
+	}
+
+	if event.Session.Uid != "102abcdEFGH" || event.HttpRequest.UserAgent == "" {
+		t.Errorf("unexpected session %+v or user agent %s", event.Session, event.HttpRequest.UserAgent)
+	}
+
+	if event.Unmapped != testEvent {
+		t.Error("expected the raw event to be kept as unmapped")
+	}
+}
+
+func TestFromEventAuthenticationUser(t *testing.T) {
+	event, ok, err := FromEvent([]byte(`{"uuid": "1", "published": "2023-06-12T14:03:21Z", "eventType": "user.session.start", "severity": "WARN", "actor": {"id": "00u1", "type": "User", "alternateId": "jane.doe@example.com"}, "outcome": {"result": "FAILURE", "reason": "INVALID_CREDENTIALS"}}`))
+	if err != nil || !ok {
+		t.Fatalf("unable to convert event: %v", err)
+	}
+
+	if event.ClassUid != AuthenticationClassUid || event.ActivityId != 1 || event.ActivityName != "Logon" {
+		t.Errorf("unexpected class %d and activity %d %s", event.ClassUid, event.ActivityId, event.ActivityName)
+	}
+
+	if event.User.Uid != "00u1" {
+		t.Errorf("expected the actor as the affected user without user targets, got %+v", event.User)
+	}
+
+	if event.StatusId != 2 || event.StatusDetail != "INVALID_CREDENTIALS" || event.SeverityId != 3 {
+		t.Errorf("unexpected status %d %s or severity %d", event.StatusId, event.StatusDetail, event.SeverityId)
+	}
+}
+
+func TestFromEventUnsupported(t *testing.T) {
+	event, ok, err := FromEvent([]byte(`{"uuid": "1", "published": "2023-06-12T14:03:21Z", "eventType": "application.lifecycle.create"}`))
+	if err != nil || ok || event != nil {
+		t.Errorf("expected an unsupported event type to be skipped, got %v %v %v", event, ok, err)
+	}
+}
+
+func TestFromEventInvalid(t *testing.T) {
+	if _, _, err := FromEvent([]byte(`{"uuid": `)); err == nil {
+		t.Error("expected a truncated event to be invalid")
+	}
+
+	if _, _, err := FromEvent([]byte(`{"eventType": "user.session.start", "published": "yesterday"}`)); err == nil {
+		t.Error("expected an invalid published time to be an error")
+	}
+}
+
+func TestLookupActivity(t *testing.T) {
+	tests := []struct {
+		eventType string
+		classUid  int32
+		id        int32
+		ok        bool
+	}{
+		{"user.session.start", AuthenticationClassUid, 1, true},
+		{"user.session.end", AuthenticationClassUid, 2, true},
+		{"app.oauth2.token.grant", AuthenticationClassUid, 3, true},
+		{"user.session.access_admin_app", AuthenticationClassUid, otherActivityId, true},
+		{"user.account.reset_password", AccountChangeClassUid, 4, true},
+		{"user.mfa.factor.update", AccountChangeClassUid, otherActivityId, true},
+		{"group.privilege.revoke", GroupManagementClassUid, 2, true},
+		{"group.lifecycle.create", GroupManagementClassUid, otherActivityId, true},
+		{"policy.lifecycle.update", 0, 0, false},
+	}
+
+	for _, test := range tests {
+		act, ok := lookupActivity(test.eventType)
+		if ok != test.ok || act.classUid != test.classUid || act.id != test.id {
+			t.Errorf("expected %s to be class %d activity %d (%v), got %d %d (%v)", test.eventType, test.classUid, test.id, test.ok, act.classUid, act.id, ok)
+		}
+	}
+}
+
+func TestSeverityAndStatus(t *testing.T) {
+	severities := map[string]int32{"DEBUG": 1, "INFO": 1, "WARN": 3, "ERROR": 4, "": 0}
+	for oktaSeverity, expected := range severities {
+		if id, _ := severity(oktaSeverity); id != expected {
+			t.Errorf("expected severity %s to be %d, got %d", oktaSeverity, expected, id)
+		}
+	}
+
+	statuses := map[string]int32{"SUCCESS": 1, "ALLOW": 1, "FAILURE": 2, "DENY": 2, "": 0, "CHALLENGE": 99}
+	for result, expected := range statuses {
+		if id, _ := status(result); id != expected {
+			t.Errorf("expected status %s to be %d, got %d", result, expected, id)
+		}
+	}
+}
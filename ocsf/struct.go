@@ -0,0 +1,77 @@
+package ocsf
+
+// OCSF event of the Authentication, Account Change or Group Management class
+type Event struct {
+	ActivityId   int32       `json:"activity_id" parquet:"name=activity_id, type=INT32"`
+	ActivityName string      `json:"activity_name" parquet:"name=activity_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CategoryUid  int32       `json:"category_uid" parquet:"name=category_uid, type=INT32"`
+	CategoryName string      `json:"category_name" parquet:"name=category_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ClassUid     int32       `json:"class_uid" parquet:"name=class_uid, type=INT32"`
+	ClassName    string      `json:"class_name" parquet:"name=class_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TypeUid      int64       `json:"type_uid" parquet:"name=type_uid, type=INT64"`
+	Time         int64       `json:"time" parquet:"name=time, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	SeverityId   int32       `json:"severity_id" parquet:"name=severity_id, type=INT32"`
+	Severity     string      `json:"severity" parquet:"name=severity, type=BYTE_ARRAY, convertedtype=UTF8"`
+	StatusId     int32       `json:"status_id" parquet:"name=status_id, type=INT32"`
+	Status       string      `json:"status" parquet:"name=status, type=BYTE_ARRAY, convertedtype=UTF8"`
+	StatusDetail string      `json:"status_detail,omitempty" parquet:"name=status_detail, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Message      string      `json:"message,omitempty" parquet:"name=message, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Metadata     Metadata    `json:"metadata" parquet:"name=metadata"`
+	Actor        Actor       `json:"actor" parquet:"name=actor"`
+	User         User        `json:"user" parquet:"name=user"`
+	Group        Group       `json:"group" parquet:"name=group"`
+	SrcEndpoint  Endpoint    `json:"src_endpoint" parquet:"name=src_endpoint"`
+	HttpRequest  HttpRequest `json:"http_request" parquet:"name=http_request"`
+	Session      Session     `json:"session" parquet:"name=session"`
+	Unmapped     string      `json:"unmapped,omitempty" parquet:"name=unmapped, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type Metadata struct {
+	Version      string  `json:"version" parquet:"name=version, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Uid          string  `json:"uid" parquet:"name=uid, type=BYTE_ARRAY, convertedtype=UTF8"`
+	EventCode    string  `json:"event_code" parquet:"name=event_code, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OriginalTime string  `json:"original_time" parquet:"name=original_time, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Product      Product `json:"product" parquet:"name=product"`
+}
+
+type Product struct {
+	Name       string `json:"name" parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	VendorName string `json:"vendor_name" parquet:"name=vendor_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type Actor struct {
+	User User `json:"user" parquet:"name=user"`
+}
+
+type User struct {
+	Uid  string `json:"uid,omitempty" parquet:"name=uid, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Name string `json:"name,omitempty" parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Type string `json:"type,omitempty" parquet:"name=type, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type Group struct {
+	Uid  string `json:"uid,omitempty" parquet:"name=uid, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Name string `json:"name,omitempty" parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type Endpoint struct {
+	Ip       string   `json:"ip,omitempty" parquet:"name=ip, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Location Location `json:"location" parquet:"name=location"`
+}
+
+type Location struct {
+	City       string  `json:"city,omitempty" parquet:"name=city, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Region     string  `json:"region,omitempty" parquet:"name=region, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Country    string  `json:"country,omitempty" parquet:"name=country, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PostalCode string  `json:"postal_code,omitempty" parquet:"name=postal_code, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Lat        float64 `json:"lat,omitempty" parquet:"name=lat, type=DOUBLE"`
+	Long       float64 `json:"long,omitempty" parquet:"name=long, type=DOUBLE"`
+}
+
+type HttpRequest struct {
+	UserAgent string `json:"user_agent,omitempty" parquet:"name=user_agent, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type Session struct {
+	Uid string `json:"uid,omitempty" parquet:"name=uid, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
@@ -0,0 +1,93 @@
+package oktamock
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Kinds of injected faults
+const (
+	FaultRateLimited = "rate-limited"
+	FaultServerError = "server-error"
+	FaultTruncated   = "truncated"
+)
+
+// Status codes of injected server errors
+var serverErrorCodes = []int{500, 502, 503, 504}
+
+// Faults randomly injected into responses, as the fraction of requests answered with each fault
+// Use them to check that retries and checkpointing never lose events.
+type Faults struct {
+	// Requests answered with a 429, as if the rate limit was exhausted by another client
+	RateLimited float64
+	// Requests answered with a 500, 502, 503 or 504
+	ServerErrors float64
+	// Requests answered with a 200 and a body cut short, as if the connection dropped
+	Truncated float64
+	// Seed of the random faults, so a failing run can be repeated
+	Seed int64
+}
+
+// Picks the faults injected into responses and counts them
+type faultInjector struct {
+	mu       sync.Mutex
+	faults   Faults
+	rand     *rand.Rand
+	injected map[string]int
+}
+
+func newFaultInjector(faults Faults) *faultInjector {
+	return &faultInjector{faults: faults, rand: rand.New(rand.NewSource(faults.Seed)), injected: make(map[string]int)}
+}
+
+// Pick the fault to inject into a response, empty for none
+func (injector *faultInjector) pick() string {
+	if injector == nil {
+		return ""
+	}
+
+	injector.mu.Lock()
+	defer injector.mu.Unlock()
+
+	n := injector.rand.Float64()
+	fault := ""
+	switch {
+	case n < injector.faults.RateLimited:
+		fault = FaultRateLimited
+	case n < injector.faults.RateLimited+injector.faults.ServerErrors:
+		fault = FaultServerError
+	case n < injector.faults.RateLimited+injector.faults.ServerErrors+injector.faults.Truncated:
+		fault = FaultTruncated
+	}
+
+	if fault != "" {
+		injector.injected[fault]++
+	}
+
+	return fault
+}
+
+// Pick the status code of an injected server error
+func (injector *faultInjector) serverErrorCode() int {
+	injector.mu.Lock()
+	defer injector.mu.Unlock()
+
+	return serverErrorCodes[injector.rand.Intn(len(serverErrorCodes))]
+}
+
+// Number of injected faults by kind
+func (injector *faultInjector) counts() map[string]int {
+	counts := make(map[string]int)
+	if injector == nil {
+		return counts
+	}
+
+	injector.mu.Lock()
+	defer injector.mu.Unlock()
+
+	for fault, count := range injector.injected {
+		counts[fault] = count
+	}
+
+	return counts
+}
@@ -0,0 +1,130 @@
+package oktamock
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Tokens of filter expressions: parentheses, quoted strings and words
+var filterTokenPattern = regexp.MustCompile(`\(|\)|"(?:[^"\\]|\\.)*"|[^\s()"]+`)
+
+// Parse a System Log filter expression into a matcher of event types
+// Only the eventType eq and sw operators, combined with and, or and parentheses, are supported.
+func parseFilter(filter string) (func(eventType string) bool, error) {
+	if strings.TrimSpace(filter) == "" {
+		return func(string) bool { return true }, nil
+	}
+
+	p := &filterParser{tokens: filterTokenPattern.FindAllString(filter, -1)}
+	match, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos != len(p.tokens) {
+		return nil, errors.New(fmt.Sprintf("unexpected %s", p.tokens[p.pos]))
+	}
+
+	return match, nil
+}
+
+// Recursive descent parser of filter expressions
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+// Next token, empty at the end of the expression
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// Parse terms combined with or
+func (p *filterParser) or() (func(string) bool, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for strings.EqualFold(p.peek(), "or") {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(eventType string) bool { return l(eventType) || right(eventType) }
+	}
+
+	return left, nil
+}
+
+// Parse terms combined with and
+func (p *filterParser) and() (func(string) bool, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for strings.EqualFold(p.peek(), "and") {
+		p.pos++
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(eventType string) bool { return l(eventType) && right(eventType) }
+	}
+
+	return left, nil
+}
+
+// Parse a parenthesized expression or an eventType comparison
+func (p *filterParser) term() (func(string) bool, error) {
+	if p.peek() == "(" {
+		p.pos++
+		match, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.peek() != ")" {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+
+		return match, nil
+	}
+
+	if p.pos+3 > len(p.tokens) {
+		return nil, errors.New("incomplete comparison")
+	}
+
+	attribute, operator, value := p.tokens[p.pos], strings.ToLower(p.tokens[p.pos+1]), p.tokens[p.pos+2]
+	p.pos += 3
+
+	if attribute != "eventType" {
+		return nil, errors.New(fmt.Sprintf("unsupported attribute %s", attribute))
+	}
+
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return nil, errors.New(fmt.Sprintf("unquoted value %s", value))
+	}
+	value = strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`)
+
+	switch operator {
+	case "eq":
+		return func(eventType string) bool { return eventType == value }, nil
+	case "sw":
+		return func(eventType string) bool { return strings.HasPrefix(eventType, value) }, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("unsupported operator %s", operator))
+	}
+}
@@ -0,0 +1,349 @@
+// Package oktamock serves canned System Log pages like the Okta API does, so integration tests can collect events
+// without an Okta org. Point the collector at the server with the api-base-url param, or a client at Server.URL.
+package oktamock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Page sizes of the System Log API
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Rate limit window of the System Log API
+const rateLimitWindow = time.Minute
+
+// A mock Okta org serving the System Log API
+type Server struct {
+	*httptest.Server
+
+	apiKey string
+
+	mu        sync.Mutex
+	events    []event
+	rateLimit int
+	window    time.Time
+	used      int
+	throttled int
+	requests  int
+	faults    *faultInjector
+}
+
+// An event served by the mock, with the fields it is paged and filtered by
+type event struct {
+	raw       json.RawMessage
+	published time.Time
+	eventType string
+}
+
+// Fields of an added event
+type eventFields struct {
+	Uuid      string `json:"uuid"`
+	Published string `json:"published"`
+	EventType string `json:"eventType"`
+}
+
+// Error body of the Okta API
+type oktaError struct {
+	ErrorCode    string        `json:"errorCode"`
+	ErrorSummary string        `json:"errorSummary"`
+	ErrorLink    string        `json:"errorLink"`
+	ErrorId      string        `json:"errorId"`
+	ErrorCauses  []interface{} `json:"errorCauses"`
+}
+
+// Start a mock Okta org that accepts requests with the API key, with a rate limit of 120 requests a minute
+// Close the server when done.
+func NewServer(apiKey string) *Server {
+	server := &Server{apiKey: apiKey, rateLimit: 120}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/logs", server.handleLogs)
+	server.Server = httptest.NewServer(mux)
+
+	return server
+}
+
+// Add events to the System Log, as JSON objects with at least a uuid and an RFC3339 published time
+func (server *Server) AddEvents(events ...string) error {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	for _, raw := range events {
+		var fields eventFields
+		if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+			return errors.New(fmt.Sprintf("invalid event: %v", err))
+		}
+
+		if fields.Uuid == "" {
+			return errors.New("invalid event: missing uuid")
+		}
+
+		published, err := time.Parse(time.RFC3339Nano, fields.Published)
+		if err != nil {
+			return errors.New(fmt.Sprintf("invalid event %s: %v", fields.Uuid, err))
+		}
+
+		server.events = append(server.events, event{raw: json.RawMessage(raw), published: published, eventType: fields.EventType})
+	}
+
+	// The System Log is served oldest first
+	sort.SliceStable(server.events, func(i, j int) bool {
+		return server.events[i].published.Before(server.events[j].published)
+	})
+
+	return nil
+}
+
+// Build a minimal System Log event of the event type published at a time
+func NewEvent(uuid string, eventType string, published time.Time) string {
+	content, _ := json.Marshal(map[string]interface{}{
+		"uuid":      uuid,
+		"published": published.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"eventType": eventType,
+		"version":   "0",
+		"severity":  "INFO",
+		"actor": map[string]interface{}{
+			"id":          "00u1a2b3c4d5e6f7g8h9",
+			"type":        "User",
+			"alternateId": "jdoe@acme.com",
+			"displayName": "John Doe",
+		},
+		"outcome": map[string]interface{}{
+			"result": "SUCCESS",
+		},
+		"displayMessage": eventType,
+	})
+
+	return string(content)
+}
+
+// Set the number of requests a minute allowed before requests are answered with 429s until the window resets
+func (server *Server) SetRateLimit(limit int) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.rateLimit = limit
+}
+
+// Answer the next requests with 429s, as if the rate limit was exhausted by another client
+func (server *Server) Throttle(requests int) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.throttled = requests
+}
+
+// Randomly inject faults into the responses of later requests
+func (server *Server) SetFaults(faults Faults) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.faults = newFaultInjector(faults)
+}
+
+// Number of faults injected so far by kind, such as FaultServerError
+func (server *Server) InjectedFaults() map[string]int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.faults.counts()
+}
+
+// Number of requests served so far, including rejected ones
+func (server *Server) Requests() int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.requests
+}
+
+// Serve a page of the System Log
+func (server *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.requests++
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "E0000022", "The endpoint does not support the provided HTTP method")
+		return
+	}
+
+	if r.Header.Get("Authorization") != "SSWS "+server.apiKey {
+		writeError(w, http.StatusUnauthorized, "E0000011", "Invalid token provided")
+		return
+	}
+
+	// Rate limit
+	now := time.Now()
+	if now.Sub(server.window) >= rateLimitWindow {
+		server.window = now
+		server.used = 0
+	}
+	server.used++
+
+	remaining := server.rateLimit - server.used
+	if remaining < 0 || server.throttled > 0 {
+		remaining = 0
+	}
+
+	reset := server.window.Add(rateLimitWindow)
+	w.Header().Set("X-Rate-Limit-Limit", strconv.Itoa(server.rateLimit))
+	w.Header().Set("X-Rate-Limit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-Rate-Limit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+	if server.throttled > 0 || server.used > server.rateLimit {
+		if server.throttled > 0 {
+			server.throttled--
+		}
+		writeError(w, http.StatusTooManyRequests, "E0000047", "API call exceeded rate limit due to too many requests.")
+		return
+	}
+
+	// Injected faults
+	fault := server.faults.pick()
+	switch fault {
+	case FaultRateLimited:
+		w.Header().Set("X-Rate-Limit-Remaining", "0")
+		writeError(w, http.StatusTooManyRequests, "E0000047", "API call exceeded rate limit due to too many requests.")
+		return
+	case FaultServerError:
+		writeError(w, server.faults.serverErrorCode(), "E0000009", "Internal Server Error")
+		return
+	}
+
+	// Query
+	query := r.URL.Query()
+	limit, since, until, after, err := parseQuery(query, now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "E0000001", "Api validation failed: "+err.Error())
+		return
+	}
+
+	match, err := parseFilter(query.Get("filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "E0000031", "Invalid search criteria.")
+		return
+	}
+
+	page := make([]json.RawMessage, 0, limit)
+	next := after
+	more := false
+	for i := after; i < len(server.events); i++ {
+		// Events are sorted, so events from the until time on are left for later polls
+		e := server.events[i]
+		if !e.published.Before(until) {
+			break
+		}
+
+		if e.published.Before(since) || !match(e.eventType) {
+			next = i + 1
+			continue
+		}
+
+		if len(page) == limit {
+			more = true
+			break
+		}
+
+		page = append(page, e.raw)
+		next = i + 1
+	}
+
+	// Link headers
+	self := *r.URL
+	self.Scheme, self.Host = "http", r.Host
+	w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"self\"", self.String()))
+
+	// Bounded requests end on their last page, while polling requests always have a next page to poll
+	if more || query.Get("until") == "" {
+		nextQuery := url.Values{}
+		for key, values := range query {
+			nextQuery[key] = values
+		}
+		nextQuery.Set("after", strconv.Itoa(next))
+
+		nextUrl := self
+		nextUrl.RawQuery = nextQuery.Encode()
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextUrl.String()))
+	}
+
+	content, _ := json.Marshal(page)
+	if fault == FaultTruncated {
+		content = content[:len(content)/2]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(content)
+}
+
+// Parse the page size, time range and cursor of a System Log request
+func parseQuery(query url.Values, now time.Time) (int, time.Time, time.Time, int, error) {
+	limit := defaultLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return 0, time.Time{}, time.Time{}, 0, errors.New("limit: must be a positive number")
+		}
+
+		limit = parsed
+		if limit > maxLimit {
+			limit = maxLimit
+		}
+	}
+
+	// The System Log defaults to the last 7 days
+	since := now.Add(-7 * 24 * time.Hour)
+	if value := query.Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return 0, time.Time{}, time.Time{}, 0, errors.New("since: must be an RFC3339 time")
+		}
+		since = parsed
+	}
+
+	until := now
+	if value := query.Get("until"); value != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return 0, time.Time{}, time.Time{}, 0, errors.New("until: must be an RFC3339 time")
+		}
+		until = parsed
+	}
+
+	after := 0
+	if value := query.Get("after"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, time.Time{}, time.Time{}, 0, errors.New("after: unknown cursor")
+		}
+		after = parsed
+	}
+
+	return limit, since, until, after, nil
+}
+
+// Write an Okta API error
+func writeError(w http.ResponseWriter, status int, code string, summary string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(oktaError{
+		ErrorCode:    code,
+		ErrorSummary: summary,
+		ErrorLink:    code,
+		ErrorId:      "oaemock",
+		ErrorCauses:  []interface{}{},
+	})
+}
@@ -0,0 +1,163 @@
+package oktamock
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// An HTTP endpoint that receives events from the http output, failing requests with injected faults
+// Compare the events it received with the events added to the Server to check that no events were lost.
+type Receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	events   []json.RawMessage
+	requests int
+	faults   *faultInjector
+}
+
+// Start a receiver of events posted as newline delimited JSON, JSON arrays or the {"results": [...]} objects of the http
+// output, optionally gzip compressed
+// Close the receiver when done.
+func NewReceiver() *Receiver {
+	receiver := &Receiver{}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(receiver.handle))
+	return receiver
+}
+
+// Randomly fail later requests with the rate limited and server error faults
+func (receiver *Receiver) SetFaults(faults Faults) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	receiver.faults = newFaultInjector(faults)
+}
+
+// Number of faults injected so far by kind
+func (receiver *Receiver) InjectedFaults() map[string]int {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	return receiver.faults.counts()
+}
+
+// Events received so far, in the order they were received
+func (receiver *Receiver) Events() []json.RawMessage {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	return append([]json.RawMessage(nil), receiver.events...)
+}
+
+// Uuids of the events received so far, counting events received more than once
+func (receiver *Receiver) Uuids() map[string]int {
+	uuids := make(map[string]int)
+	for _, raw := range receiver.Events() {
+		var fields eventFields
+		if err := json.Unmarshal(raw, &fields); err == nil {
+			uuids[fields.Uuid]++
+		}
+	}
+
+	return uuids
+}
+
+// Number of requests received so far, including failed ones
+func (receiver *Receiver) Requests() int {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	return receiver.requests
+}
+
+// Receive a batch of events
+func (receiver *Receiver) handle(w http.ResponseWriter, r *http.Request) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	receiver.requests++
+
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Truncated bodies only apply to responses of the Server, so they fail requests like server errors
+	switch receiver.faults.pick() {
+	case FaultRateLimited:
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	case FaultServerError, FaultTruncated:
+		w.WriteHeader(receiver.faults.serverErrorCode())
+		return
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	events, err := parseEvents(content)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	receiver.events = append(receiver.events, events...)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Parse a batch of events, as a JSON array, a results object or newline delimited JSON
+func parseEvents(content []byte) ([]json.RawMessage, error) {
+	content = bytes.TrimSpace(content)
+	if len(content) > 0 && content[0] == '[' {
+		var events []json.RawMessage
+		err := json.Unmarshal(content, &events)
+		return events, err
+	}
+
+	// A single object with a results array, rather than a line per event
+	var results struct {
+		Results []json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal(content, &results); err == nil && results.Results != nil {
+		return results.Results, nil
+	}
+
+	var events []json.RawMessage
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var event json.RawMessage
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, scanner.Err()
+}
@@ -0,0 +1,129 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// A column of an ADX JSON ingestion mapping
+type adxColumnMapping struct {
+	Column     string            `json:"column"`
+	Datatype   string            `json:"datatype"`
+	Properties map[string]string `json:"Properties"`
+}
+
+// Default ingestion mapping of System Log fields to table columns
+var adxDefaultMapping = []adxColumnMapping{
+	{"Published", "datetime", map[string]string{"Path": "$.published"}},
+	{"Uuid", "string", map[string]string{"Path": "$.uuid"}},
+	{"EventType", "string", map[string]string{"Path": "$.eventType"}},
+	{"Severity", "string", map[string]string{"Path": "$.severity"}},
+	{"DisplayMessage", "string", map[string]string{"Path": "$.displayMessage"}},
+	{"Actor", "string", map[string]string{"Path": "$.actor.alternateId"}},
+	{"ClientIpAddress", "string", map[string]string{"Path": "$.client.ipAddress"}},
+	{"OutcomeResult", "string", map[string]string{"Path": "$.outcome.result"}},
+	{"Event", "dynamic", map[string]string{"Path": "$"}},
+}
+
+// Azure Data Explorer output using queued ingestion
+type adxOutput struct {
+	ingestor *ingest.Ingestion
+	options  []ingest.FileOption
+}
+
+func adxInitParams() {
+	flag.Bool("adx", false, "enable azure data explorer output")
+	flag.String("adx-endpoint", "", "azure data explorer ingestion cluster endpoint")
+	flag.String("adx-database", "", "azure data explorer database")
+	flag.String("adx-table", "OktaEvents", "azure data explorer table")
+	flag.String("adx-mapping", "", "name of a pre-created azure data explorer json ingestion mapping (default inline mapping)")
+	flag.String("adx-tenant-id", "", "azure ad tenant id for application authentication")
+	flag.String("adx-client-id", "", "azure ad application client id")
+	flag.String("adx-client-secret", "", "azure ad application client secret")
+}
+
+func adxEnabled() bool {
+	return viper.GetBool("adx")
+}
+
+func adxValidateParams() error {
+	if viper.GetString("adx-endpoint") == "" {
+		return errors.New("missing azure data explorer endpoint param (--adx-endpoint)")
+	}
+
+	if viper.GetString("adx-database") == "" {
+		return errors.New("missing azure data explorer database param (--adx-database)")
+	}
+
+	if viper.GetString("adx-table") == "" {
+		return errors.New("missing azure data explorer table param (--adx-table)")
+	}
+
+	// Application authentication requires all of its params
+	if viper.GetString("adx-client-secret") != "" {
+		if viper.GetString("adx-tenant-id") == "" {
+			return errors.New("missing azure data explorer tenant id param (--adx-tenant-id)")
+		}
+
+		if viper.GetString("adx-client-id") == "" {
+			return errors.New("missing azure data explorer client id param (--adx-client-id)")
+		}
+	}
+
+	return nil
+}
+
+// Create the azure data explorer output
+func newAdxOutput() (Output, error) {
+	// Setup authentication
+	kcsb := kusto.NewConnectionStringBuilder(viper.GetString("adx-endpoint"))
+	if viper.GetString("adx-client-secret") != "" {
+		kcsb = kcsb.WithAadAppKey(viper.GetString("adx-client-id"), viper.GetString("adx-client-secret"), viper.GetString("adx-tenant-id"))
+	} else {
+		kcsb = kcsb.WithDefaultAzureCredential()
+	}
+
+	// Setup client
+	client, err := kusto.New(kcsb)
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup queued ingestion
+	ingestor, err := ingest.New(client, viper.GetString("adx-database"), viper.GetString("adx-table"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup mapping
+	options := []ingest.FileOption{ingest.FileFormat(ingest.MultiJSON)}
+	if viper.GetString("adx-mapping") != "" {
+		options = append(options, ingest.IngestionMappingRef(viper.GetString("adx-mapping"), ingest.MultiJSON))
+	} else {
+		mapping, err := json.Marshal(adxDefaultMapping)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, ingest.IngestionMapping(string(mapping), ingest.MultiJSON))
+	}
+
+	return &adxOutput{
+		ingestor: ingestor,
+		options:  options,
+	}, nil
+}
+
+func (output *adxOutput) Name() string {
+	return "adx"
+}
+
+// Queue the batch at src for ingestion
+func (output *adxOutput) Write(src string, timestamp string) error {
+	_, err := output.ingestor.FromFile(context.Background(), src, output.options...)
+	return err
+}
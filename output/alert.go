@@ -0,0 +1,49 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Validate the names of alert outputs
+// Outputs that read System Log fields from events can't receive alerts.
+func validateAlertOutputs(names []string) error {
+	for _, name := range names {
+		found := false
+		for _, t := range outputTypes {
+			if t.name == name {
+				found = true
+				if !t.enabled() {
+					return errors.New(fmt.Sprintf("%s output is not enabled", name))
+				}
+			}
+		}
+
+		if !found {
+			return errors.New(fmt.Sprintf("unknown output %s", name))
+		}
+
+		if rawEventOutputTypes[name] {
+			return errors.New(fmt.Sprintf("%s output does not support alerts as it reads System Log fields from events", name))
+		}
+	}
+
+	return nil
+}
+
+// Queue an alert for every alert output, or log it when there are none
+// Blocks while the event queue of any alert output is full
+func WriteAlert(alert []byte) {
+	written := false
+	for _, s := range enabledSinks {
+		if s.alerts {
+			s.items <- sinkItem{event: alert}
+			written = true
+		}
+	}
+
+	if !written {
+		log.Printf("Alert: %s\n", alert)
+	}
+}
@@ -0,0 +1,439 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Default template of archived file and object names
+const defaultArchiveNameTemplate = "{{.Year}}/{{.Month}}/{{.Day}}/okta-{{.Timestamp}}-{{.Sequence}}{{.Ext}}"
+
+// Archive output that writes batches to files in a local directory or objects in S3, named from a template
+type archiveOutput struct {
+	dir      string
+	bucket   string
+	prefix   string
+	uploader *s3manager.Uploader
+	names    *template.Template
+	manifest *template.Template
+	chain    *hashChain
+	signing  string
+	signKey  string
+	org      string
+	encoding encoding
+}
+
+// Fields available to archive name templates
+type archiveName struct {
+	Org       string
+	EventType string
+	Date      string
+	Year      string
+	Month     string
+	Day       string
+	Hour      string
+	Sequence  uint64
+	Timestamp int64
+	Ext       string
+}
+
+func archiveInitParams() {
+	flag.Bool("archive", false, "enable archive output")
+	flag.String("archive-location", "", "archive directory or s3 location (s3://bucket/prefix)")
+	flag.String("archive-name-template", defaultArchiveNameTemplate, "template of archived file and object names")
+	flag.String("archive-s3-region", "", "region of the archive s3 bucket")
+	flag.Bool("archive-manifest", false, "write a manifest of the archived files of every batch")
+	flag.String("archive-manifest-name-template", defaultArchiveNameTemplate, "template of archived manifest names")
+	flag.String("archive-signing", signingNone, "algorithm of detached signatures of archived files (none, hmac-sha256, ed25519)")
+	flag.String("archive-signing-key-file", "", "file of the hmac secret or pem ed25519 private key to sign archived files with")
+	flag.Bool("archive-hash-chain", false, "record a rolling hash chain over archived events in manifests")
+	flag.String("archive-hash-chain-path", "", "file the hash chain head is kept in (default the state path with a .chain extension)")
+}
+
+func archiveEnabled() bool {
+	return viper.GetBool("archive")
+}
+
+func archiveValidateParams() error {
+	location := viper.GetString("archive-location")
+	if location == "" {
+		return errors.New("missing archive location param (--archive-location)")
+	}
+
+	if strings.HasPrefix(location, "s3://") {
+		if bucket, _ := parseS3Location(location); bucket == "" {
+			return errors.New(fmt.Sprintf("invalid archive location param (--archive-location): missing bucket in %s", location))
+		}
+
+		if viper.GetString("archive-s3-region") == "" {
+			return errors.New("missing archive s3 region param (--archive-s3-region)")
+		}
+	}
+
+	if err := validateArchiveNameTemplate("archive-name-template"); err != nil {
+		return err
+	}
+
+	if viper.GetBool("archive-manifest") {
+		if err := validateArchiveNameTemplate("archive-manifest-name-template"); err != nil {
+			return err
+		}
+	}
+
+	if signing := viper.GetString("archive-signing"); signing != signingNone {
+		if err := validateSigning(signing, viper.GetString("archive-signing-key-file")); err != nil {
+			return errors.New(fmt.Sprintf("invalid archive signing params (--archive-signing, --archive-signing-key-file): %v", err))
+		}
+	}
+
+	if viper.GetBool("archive-hash-chain") {
+		if !viper.GetBool("archive-manifest") {
+			return errors.New("archive hash chain param (--archive-hash-chain) requires the archive manifest param (--archive-manifest)")
+		}
+
+		// Batches must be chained in delivery order
+		if viper.GetInt("archive-workers") != 1 {
+			return errors.New("archive hash chain param (--archive-hash-chain) requires a single archive worker (--archive-workers)")
+		}
+
+		if _, err := loadHashChain(hashChainPath()); err != nil {
+			return errors.New(fmt.Sprintf("invalid archive hash chain path param (--archive-hash-chain-path): %v", err))
+		}
+	}
+
+	return nil
+}
+
+// Validate a name template param
+func validateArchiveNameTemplate(param string) error {
+	nameTemplate := viper.GetString(param)
+	if _, err := template.New(param).Option("missingkey=error").Parse(nameTemplate); err != nil {
+		return errors.New(fmt.Sprintf("invalid archive name template param (--%s): %v", param, err))
+	}
+
+	if !strings.Contains(nameTemplate, ".Sequence") && !strings.Contains(nameTemplate, ".Timestamp") {
+		return errors.New(fmt.Sprintf("invalid archive name template param (--%s): must contain {{.Sequence}} or {{.Timestamp}} so batches don't overwrite each other", param))
+	}
+
+	return nil
+}
+
+// The archive output encodes the files it splits batches into, so every encoding is supported
+func archiveValidateEncoding() error {
+	return nil
+}
+
+// Create the archive output
+func newArchiveOutput() (Output, error) {
+	names, err := template.New("archive").Option("missingkey=error").Parse(viper.GetString("archive-name-template"))
+	if err != nil {
+		return nil, err
+	}
+
+	output := &archiveOutput{
+		names:   names,
+		signing: viper.GetString("archive-signing"),
+		signKey: viper.GetString("archive-signing-key-file"),
+		org:     oktaOrg(viper.GetString("okta-domain")),
+	}
+
+	if viper.GetBool("archive-manifest") {
+		output.manifest, err = template.New("archive-manifest").Option("missingkey=error").Parse(viper.GetString("archive-manifest-name-template"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if viper.GetBool("archive-hash-chain") {
+		output.chain, err = loadHashChain(hashChainPath())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	location := viper.GetString("archive-location")
+	if !strings.HasPrefix(location, "s3://") {
+		output.dir = location
+		return output, os.MkdirAll(location, 0755)
+	}
+
+	// Setup S3 uploader
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(viper.GetString("archive-s3-region")),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	output.bucket, output.prefix = parseS3Location(location)
+	output.uploader = s3manager.NewUploader(sess)
+
+	return output, nil
+}
+
+func (output *archiveOutput) Name() string {
+	return "archive"
+}
+
+// Archived files are encoded by the output after splitting batches by name
+func (output *archiveOutput) setEncoding(e encoding) {
+	output.encoding = e
+}
+
+// Split the batch at src into a file per rendered name, then encode and store each file
+func (output *archiveOutput) Write(src string, timestamp string) error {
+	sequence, _, err := parsePendingBatchName(filepath.Base(src))
+	if err != nil {
+		return err
+	}
+
+	batchTime, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return err
+	}
+
+	// Setup temp dir for the split files
+	dir, err := createTempDir("okta-archive-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// Split batch by name
+	files := make(map[string]*os.File)
+	ranges := make(map[string]*eventRange)
+	defer func() {
+		for _, file := range files {
+			_ = file.Close()
+		}
+	}()
+
+	err = readEvents(src, func(event []byte) error {
+		fields, published, err := parseEventFields(event)
+		if err != nil {
+			return err
+		}
+
+		name, err := output.render(output.names, output.nameFields(fields.EventType, published, sequence, batchTime))
+		if err != nil {
+			return err
+		}
+
+		file, ok := files[name]
+		if !ok {
+			file, err = ioutil.TempFile(dir, "part-*"+pendingBatchExt)
+			if err != nil {
+				return err
+			}
+			files[name] = file
+			ranges[name] = &eventRange{}
+		}
+		ranges[name].add(published)
+
+		if _, err := file.Write(event); err != nil {
+			return err
+		}
+
+		_, err = file.Write([]byte("\n"))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Encode and store each file in name order
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Chain the files in name order from the head of the last stored batch
+	head := ""
+	if output.chain != nil {
+		output.chain.mu.Lock()
+		defer output.chain.mu.Unlock()
+		head = output.chain.head
+	}
+
+	batchManifest := &manifest{Output: output.Name(), Sequence: sequence, Timestamp: timestamp, Files: []manifestFile{}}
+	for _, name := range names {
+		if err := files[name].Close(); err != nil {
+			return err
+		}
+
+		if output.chain != nil {
+			if head, err = extendHashChain(head, files[name].Name()); err != nil {
+				return err
+			}
+		}
+
+		if err := output.store(name, files[name].Name(), *ranges[name], head, batchManifest); err != nil {
+			return err
+		}
+	}
+
+	if output.chain != nil {
+		batchManifest.HashChain = &manifestChain{Algorithm: hashChainAlgorithm, Previous: output.chain.head, Head: head}
+	}
+
+	if output.manifest == nil {
+		return nil
+	}
+
+	// Store the manifest after every file so its presence marks the batch complete
+	manifestFields := output.nameFields("", batchTime, sequence, batchTime)
+	manifestFields.Ext = ".manifest.json"
+	manifestName, err := output.render(output.manifest, manifestFields)
+	if err != nil {
+		return err
+	}
+
+	manifestPath, err := batchManifest.write()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(manifestPath)
+
+	if err := output.put(manifestName, manifestPath); err != nil {
+		return err
+	}
+
+	// Advance the chain only once the batch is complete, so retried batches chain from the same head
+	if output.chain != nil {
+		return output.chain.commit(head)
+	}
+
+	return nil
+}
+
+// Fields of the name of an archived file
+func (output *archiveOutput) nameFields(eventType string, published time.Time, sequence uint64, batchTime time.Time) archiveName {
+	published = published.UTC()
+
+	return archiveName{
+		Org:       output.org,
+		EventType: eventType,
+		Date:      published.Format("2006-01-02"),
+		Year:      published.Format("2006"),
+		Month:     published.Format("01"),
+		Day:       published.Format("02"),
+		Hour:      published.Format("15"),
+		Sequence:  sequence,
+		Timestamp: batchTime.UnixNano(),
+		Ext:       output.encoding.ext(),
+	}
+}
+
+// Render a name template
+func (output *archiveOutput) render(names *template.Template, fields archiveName) (string, error) {
+	var name bytes.Buffer
+	if err := names.Execute(&name, fields); err != nil {
+		return "", err
+	}
+
+	return name.String(), nil
+}
+
+// Encode the split file at src, add it to the manifest with its chain head and store it under name
+func (output *archiveOutput) store(name string, src string, r eventRange, chainHead string, batchManifest *manifest) error {
+	if output.encoding.enabled() {
+		encodedPath, err := output.encoding.encode(src)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error encoding batch: %v", err))
+		}
+		defer os.Remove(encodedPath)
+		src = encodedPath
+	}
+
+	if output.manifest != nil {
+		if err := batchManifest.add(name, src, r, chainHead); err != nil {
+			return err
+		}
+	}
+
+	return output.put(name, src)
+}
+
+// Put the file at src in the archive under name, after its detached signature if signing is enabled
+func (output *archiveOutput) put(name string, src string) error {
+	if output.signing != signingNone {
+		signaturePath, err := signFile(output.signing, output.signKey, src)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error signing batch: %v", err))
+		}
+		defer os.Remove(signaturePath)
+
+		if err := output.putFile(name+signatureExt, signaturePath); err != nil {
+			return err
+		}
+	}
+
+	return output.putFile(name, src)
+}
+
+// Put the file at src in the archive under name as is
+func (output *archiveOutput) putFile(name string, src string) error {
+	if output.uploader != nil {
+		return output.upload(path.Join(output.prefix, name), src)
+	}
+
+	// Write to a partial file first so readers never see incomplete files
+	dst := filepath.Join(output.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if err := copyFile(src, dst+".partial"); err != nil {
+		return err
+	}
+
+	return os.Rename(dst+".partial", dst)
+}
+
+// Upload the file at src to the archive bucket
+func (output *archiveOutput) upload(key string, src string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = output.uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(output.bucket),
+		Key:    aws.String(key),
+		Body:   file,
+	})
+
+	return err
+}
+
+// Parse the bucket and prefix of an s3://bucket/prefix location
+func parseS3Location(location string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(location, "s3://"), "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+
+	return parts[0], strings.Trim(parts[1], "/")
+}
+
+// Name of the Okta org from its domain, such as acme for acme.okta.com
+func oktaOrg(domain string) string {
+	domain = strings.TrimPrefix(strings.TrimPrefix(domain, "https://"), "http://")
+	return strings.SplitN(domain, ".", 2)[0]
+}
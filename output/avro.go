@@ -0,0 +1,92 @@
+package output
+
+import (
+	"io"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// Avro codecs by compression param
+// Avro has no gzip codec, and its deflate codec is a different file format, so gzip isn't supported.
+var avroCodecs = map[string]string{
+	compressionNone: "null",
+	compressionZstd: "zstandard",
+}
+
+// Avro schema of System Log events, matching the columns of event rows
+const avroSchema = `{
+  "type": "record",
+  "name": "OktaSystemLogEvent",
+  "namespace": "com.okta.systemlog",
+  "fields": [
+    {"name": "uuid", "type": "string"},
+    {"name": "published", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "event_type", "type": "string"},
+    {"name": "version", "type": "string"},
+    {"name": "severity", "type": "string"},
+    {"name": "display_message", "type": "string"},
+    {"name": "actor_id", "type": "string"},
+    {"name": "actor_type", "type": "string"},
+    {"name": "actor_alternate_id", "type": "string"},
+    {"name": "actor_display_name", "type": "string"},
+    {"name": "client_ip_address", "type": "string"},
+    {"name": "client_user_agent", "type": "string"},
+    {"name": "client_country", "type": "string"},
+    {"name": "client_city", "type": "string"},
+    {"name": "outcome_result", "type": "string"},
+    {"name": "outcome_reason", "type": "string"},
+    {"name": "transaction_id", "type": "string"},
+    {"name": "raw", "type": "string"}
+  ]
+}`
+
+// Write the batch at src as an Avro object container file compressed with the codec of the encoding
+func writeAvro(e encoding, src string, out io.Writer) error {
+	codecName := avroCodecs[compressionNone]
+	if e.compressed() {
+		codecName = avroCodecs[e.compression]
+	}
+
+	ocfWriter, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		W:               out,
+		Schema:          avroSchema,
+		CompressionName: codecName,
+	})
+	if err != nil {
+		return err
+	}
+
+	return readEvents(src, func(event []byte) error {
+		row, err := newEventRow(event)
+		if err != nil {
+			return err
+		}
+
+		return ocfWriter.Append([]interface{}{row.avroRecord()})
+	})
+}
+
+// Convert an event row to an Avro record
+func (r *eventRow) avroRecord() map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":               r.Uuid,
+		"published":          time.Unix(0, r.Published*int64(time.Millisecond)).UTC(),
+		"event_type":         r.EventType,
+		"version":            r.Version,
+		"severity":           r.Severity,
+		"display_message":    r.DisplayMessage,
+		"actor_id":           r.ActorId,
+		"actor_type":         r.ActorType,
+		"actor_alternate_id": r.ActorAlternateId,
+		"actor_display_name": r.ActorDisplayName,
+		"client_ip_address":  r.ClientIpAddress,
+		"client_user_agent":  r.ClientUserAgent,
+		"client_country":     r.ClientCountry,
+		"client_city":        r.ClientCity,
+		"outcome_result":     r.OutcomeResult,
+		"outcome_reason":     r.OutcomeReason,
+		"transaction_id":     r.TransactionId,
+		"raw":                r.Raw,
+	}
+}
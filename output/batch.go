@@ -0,0 +1,157 @@
+package output
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Limits that govern when an open batch is flushed to its output
+type batchLimits struct {
+	maxEvents int
+	maxBytes  int64
+	maxAge    time.Duration
+}
+
+// A batch that events are being appended to
+type openBatch struct {
+	file      *os.File
+	writer    *bufio.Writer
+	path      string
+	count     int
+	bytes     int64
+	opened    time.Time
+	firstPoll uint64
+}
+
+// Append an event to the open batch of the sink, flushing it when a limit is reached
+func (s *sink) append(event []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Open batch
+	if s.current == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	// Write event
+	if _, err := s.current.writer.Write(event); err != nil {
+		return err
+	}
+
+	if err := s.current.writer.WriteByte('\n'); err != nil {
+		return err
+	}
+
+	s.current.count++
+	s.current.bytes += int64(len(event)) + 1
+
+	// Flush full batch
+	if s.limits.maxEvents > 0 && s.current.count >= s.limits.maxEvents {
+		return s.flush()
+	}
+
+	if s.limits.maxBytes > 0 && s.current.bytes >= s.limits.maxBytes {
+		return s.flush()
+	}
+
+	return nil
+}
+
+// Open a new batch file in the spool directory of the sink
+func (s *sink) open() error {
+	file, err := ioutil.TempFile(s.dir, "batch-*"+openBatchExt)
+	if err != nil {
+		return err
+	}
+
+	s.current = &openBatch{
+		file:      file,
+		writer:    bufio.NewWriter(file),
+		path:      file.Name(),
+		opened:    time.Now(),
+		firstPoll: s.lastEndedPoll + 1,
+	}
+
+	return nil
+}
+
+// Close the open batch and make it pending delivery
+func (s *sink) flush() error {
+	if s.current == nil {
+		return nil
+	}
+
+	current := s.current
+	s.current = nil
+
+	// Close batch file
+	if err := current.writer.Flush(); err != nil {
+		_ = current.file.Close()
+		return err
+	}
+
+	// Sync so the batch survives a crash once pending
+	if err := current.file.Sync(); err != nil {
+		_ = current.file.Close()
+		return err
+	}
+
+	if err := current.file.Close(); err != nil {
+		return err
+	}
+
+	return s.enqueue(current.path, current.firstPoll)
+}
+
+// Flush the open batch once it reaches its max age
+func (s *sink) flushAged() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		if s.current != nil && time.Since(s.current.opened) >= s.limits.maxAge {
+			if err := s.flush(); err != nil {
+				s.handleError(err)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Mark the end of a poll, flushing the open batch unless batches are flushed by age
+func (s *sink) endPoll(poll uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastEndedPoll = poll
+
+	if s.limits.maxAge > 0 {
+		return nil
+	}
+
+	return s.flush()
+}
+
+// Latest poll whose events have all been delivered or handled by the failure mode of the sink
+func (s *sink) ackedPoll() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acked := s.lastEndedPoll
+	if s.current != nil && s.current.firstPoll-1 < acked {
+		acked = s.current.firstPoll - 1
+	}
+
+	for _, firstPoll := range s.inflight {
+		if firstPoll-1 < acked {
+			acked = firstPoll - 1
+		}
+	}
+
+	return acked
+}
@@ -0,0 +1,263 @@
+package output
+
+import (
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"net/http"
+	"time"
+)
+
+// Constants for the BigQuery output
+const (
+	bigqueryAppendRows     = 500
+	bigqueryInitialBackoff = time.Second
+	bigqueryMaxBackoff     = 32 * time.Second
+)
+
+// Managed schema of the System Log events table
+var bigquerySchema = bigquery.Schema{
+	{Name: "uuid", Type: bigquery.StringFieldType, Required: true},
+	{Name: "published", Type: bigquery.TimestampFieldType, Required: true},
+	{Name: "event_type", Type: bigquery.StringFieldType, Required: true},
+	{Name: "severity", Type: bigquery.StringFieldType},
+	{Name: "actor", Type: bigquery.StringFieldType},
+	{Name: "event", Type: bigquery.JSONFieldType, Required: true},
+}
+
+// BigQuery output that streams events with the Storage Write API
+type bigqueryOutput struct {
+	stream     *managedwriter.ManagedStream
+	descriptor protoreflect.MessageDescriptor
+}
+
+// A row of the events table in the form expected by protojson
+type bigqueryRow struct {
+	Uuid      string `json:"uuid"`
+	Published int64  `json:"published"`
+	EventType string `json:"event_type"`
+	Severity  string `json:"severity"`
+	Actor     string `json:"actor"`
+	Event     string `json:"event"`
+}
+
+func bigqueryInitParams() {
+	flag.Bool("bigquery", false, "enable bigquery output")
+	flag.String("bigquery-project", "", "bigquery project id")
+	flag.String("bigquery-dataset", "", "bigquery dataset")
+	flag.String("bigquery-table", "okta_events", "bigquery table")
+	flag.String("bigquery-credentials", "", "bigquery credentials file path")
+}
+
+func bigqueryEnabled() bool {
+	return viper.GetBool("bigquery")
+}
+
+func bigqueryValidateParams() error {
+	if viper.GetString("bigquery-project") == "" {
+		return errors.New("missing bigquery project param (--bigquery-project)")
+	}
+
+	if viper.GetString("bigquery-dataset") == "" {
+		return errors.New("missing bigquery dataset param (--bigquery-dataset)")
+	}
+
+	if viper.GetString("bigquery-table") == "" {
+		return errors.New("missing bigquery table param (--bigquery-table)")
+	}
+
+	return nil
+}
+
+// Create the bigquery output, ensuring the events table exists
+func newBigqueryOutput() (Output, error) {
+	ctx := context.Background()
+	project := viper.GetString("bigquery-project")
+	dataset := viper.GetString("bigquery-dataset")
+	table := viper.GetString("bigquery-table")
+
+	// Setup client options
+	var opts []option.ClientOption
+	if viper.GetString("bigquery-credentials") != "" {
+		opts = append(opts, option.WithCredentialsFile(viper.GetString("bigquery-credentials")))
+	}
+
+	// Create table
+	if err := bigqueryCreateTable(ctx, project, dataset, table, opts); err != nil {
+		return nil, err
+	}
+
+	// Build row descriptor from the managed schema
+	storageSchema, err := adapt.BQSchemaToStorageTableSchema(bigquerySchema)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor, err := adapt.StorageSchemaToProto2Descriptor(storageSchema, "root")
+	if err != nil {
+		return nil, err
+	}
+
+	messageDescriptor, ok := descriptor.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, errors.New("bigquery schema descriptor is not a message descriptor")
+	}
+
+	descriptorProto, err := adapt.NormalizeDescriptor(messageDescriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open a write stream to the default stream of the table
+	client, err := managedwriter.NewClient(ctx, project, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := client.NewManagedStream(ctx,
+		managedwriter.WithDestinationTable(managedwriter.TableParentFromParts(project, dataset, table)),
+		managedwriter.WithType(managedwriter.DefaultStream),
+		managedwriter.WithSchemaDescriptor(descriptorProto),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bigqueryOutput{
+		stream:     stream,
+		descriptor: messageDescriptor,
+	}, nil
+}
+
+// Create the events table partitioned by published if it doesn't exist
+func bigqueryCreateTable(ctx context.Context, project, dataset, table string, opts []option.ClientOption) error {
+	client, err := bigquery.NewClient(ctx, project, opts...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	tableRef := client.Dataset(dataset).Table(table)
+
+	// Check if table exists
+	_, err = tableRef.Metadata(ctx)
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+		return err
+	}
+
+	return tableRef.Create(ctx, &bigquery.TableMetadata{
+		Schema: bigquerySchema,
+		TimePartitioning: &bigquery.TimePartitioning{
+			Field: "published",
+		},
+	})
+}
+
+func (output *bigqueryOutput) Name() string {
+	return "bigquery"
+}
+
+// Write the batch at src to bigquery in appends of bigqueryAppendRows rows
+func (output *bigqueryOutput) Write(src string, timestamp string) error {
+	ctx := context.Background()
+	var rows [][]byte
+
+	err := readEvents(src, func(event []byte) error {
+		row, err := output.encodeRow(event)
+		if err != nil {
+			return err
+		}
+
+		rows = append(rows, row)
+
+		// Append full request
+		if len(rows) >= bigqueryAppendRows {
+			if err := output.appendRows(ctx, rows); err != nil {
+				return err
+			}
+			rows = nil
+		}
+
+		return nil
+	})
+
+	// Handle error
+	if err != nil {
+		return err
+	}
+
+	// Append remaining rows
+	if len(rows) > 0 {
+		return output.appendRows(ctx, rows)
+	}
+
+	return nil
+}
+
+// Encode an event as a serialized row message
+func (output *bigqueryOutput) encodeRow(event []byte) ([]byte, error) {
+	fields, published, err := parseEventFields(event)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error parsing event: %v", err))
+	}
+
+	// Build JSON row
+	rowJson, err := json.Marshal(bigqueryRow{
+		Uuid:      fields.Uuid,
+		Published: published.UnixNano() / int64(time.Microsecond),
+		EventType: fields.EventType,
+		Severity:  fields.Severity,
+		Actor:     fields.Actor.AlternateId,
+		Event:     string(event),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to row message
+	message := dynamicpb.NewMessage(output.descriptor)
+	if err := protojson.Unmarshal(rowJson, message); err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(message)
+}
+
+// Append rows to the stream, backing off while the write quota is exhausted
+func (output *bigqueryOutput) appendRows(ctx context.Context, rows [][]byte) error {
+	backoff := bigqueryInitialBackoff
+	for {
+		result, err := output.stream.AppendRows(ctx, rows)
+		if err == nil {
+			_, err = result.GetResult(ctx)
+		}
+
+		// Handle quota errors
+		if err != nil && status.Code(err) == codes.ResourceExhausted && backoff <= bigqueryMaxBackoff {
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,152 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/collector-helpers/outputs"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Flags that enable the collector helpers outputs
+var builtinFlags = []string{"file", "gcs", "stackdriver", "s3", "http"}
+
+// Collector helpers outputs that parse batches instead of copying them verbatim
+var builtinEventFlags = []string{"stackdriver", "http"}
+
+// Builtin output that writes to the file, GCS, Stackdriver, S3 and HTTP outputs of collector helpers.
+// Their params are registered and validated by the collector helpers package, except for the rotation params of the
+// file output which are handled here.
+type builtinOutput struct {
+	mu         sync.Mutex
+	fileOpened time.Time
+}
+
+func builtinInitParams() {
+	flag.Int64("file-max-bytes", 0, "rotate the log file when it reaches this size in bytes (0 for unlimited)")
+	flag.Int("file-max-age", 0, "rotate the log file when it is older than this many seconds (0 for unlimited)")
+}
+
+func builtinEnabled() bool {
+	for _, name := range builtinFlags {
+		if viper.GetBool(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func builtinValidateParams() error {
+	if viper.GetInt64("file-max-bytes") < 0 {
+		return errors.New("invalid file max bytes param (--file-max-bytes)")
+	}
+
+	if viper.GetInt("file-max-age") < 0 {
+		return errors.New("invalid file max age param (--file-max-age)")
+	}
+
+	if viper.GetBool("file-rotate") && (viper.GetInt64("file-max-bytes") > 0 || viper.GetInt("file-max-age") > 0) {
+		return errors.New("file max bytes and max age params (--file-max-bytes, --file-max-age) can't be combined with file rotate (--file-rotate), use the builtin batch params instead")
+	}
+
+	return nil
+}
+
+// Only the file, GCS and S3 outputs copy batches verbatim, so encoding can't be
+// combined with the Stackdriver and HTTP outputs
+func builtinValidateEncoding() error {
+	for _, name := range builtinEventFlags {
+		if viper.GetBool(name) {
+			return errors.New(fmt.Sprintf("builtin output encoding (--builtin-format, --builtin-compression, --builtin-encryption) is not supported with the %s output", name))
+		}
+	}
+
+	return nil
+}
+
+func newBuiltinOutput() (Output, error) {
+	return &builtinOutput{}, nil
+}
+
+func (output *builtinOutput) Name() string {
+	return "builtin"
+}
+
+func (output *builtinOutput) Write(src string, timestamp string) error {
+	// The log file is appended to, synced and rotated one batch at a time
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	if err := outputs.WriteToOutputs(src, timestamp); err != nil {
+		return err
+	}
+
+	// Only acknowledge the batch once it is on disk
+	if viper.GetBool("file") {
+		if err := syncFile(viper.GetString("file-path")); err != nil {
+			return err
+		}
+	}
+
+	return output.rotateFile(timestamp)
+}
+
+// Sync the log file appended to by the file output of collector helpers, which closes it without syncing
+func syncFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Rotate the log file of the file output to {path}.{timestamp} once it reaches the max size or age
+// Called by Write with the mutex held.
+func (output *builtinOutput) rotateFile(timestamp string) error {
+	maxBytes := viper.GetInt64("file-max-bytes")
+	maxAge := time.Duration(viper.GetInt("file-max-age")) * time.Second
+	if !viper.GetBool("file") || (maxBytes == 0 && maxAge == 0) {
+		return nil
+	}
+
+	path := viper.GetString("file-path")
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Age a file found on startup from the first write
+	if output.fileOpened.IsZero() {
+		output.fileOpened = time.Now()
+	}
+
+	if (maxBytes == 0 || info.Size() < maxBytes) && (maxAge == 0 || time.Since(output.fileOpened) < maxAge) {
+		return nil
+	}
+
+	// Format the batch timestamp without colons so the name is valid on every platform
+	rotatedAt, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(path, path+"."+rotatedAt.UTC().Format("20060102T150405.000000000Z")); err != nil {
+		return err
+	}
+
+	output.fileOpened = time.Time{}
+	return nil
+}
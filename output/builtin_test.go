@@ -0,0 +1,68 @@
+package output
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Write a batch file to deliver through the builtin output
+func writeBatch(t *testing.T, dir string, content string) string {
+	src := filepath.Join(dir, "batch")
+	if err := ioutil.WriteFile(src, []byte(content), 0600); err != nil {
+		t.Fatalf("unable to write batch: %v", err)
+	}
+	return src
+}
+
+func TestBuiltinWriteAppendsToFile(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "okta.log")
+	viper.Set("file", true)
+	viper.Set("file-path", path)
+
+	output := &builtinOutput{}
+	for _, batch := range []string{"{\"uuid\":\"1\"}\n", "{\"uuid\":\"2\"}\n"} {
+		if err := output.Write(writeBatch(t, dir, batch), time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+			t.Fatalf("unable to write batch: %v", err)
+		}
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read log file: %v", err)
+	}
+
+	if expected := "{\"uuid\":\"1\"}\n{\"uuid\":\"2\"}\n"; string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, content)
+	}
+}
+
+func TestBuiltinWriteRotatesBySize(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "okta.log")
+	viper.Set("file", true)
+	viper.Set("file-path", path)
+	viper.Set("file-max-bytes", 10)
+
+	output := &builtinOutput{}
+	if err := output.Write(writeBatch(t, dir, "{\"uuid\":\"1\"}\n"), "2023-06-01T12:00:00.5Z"); err != nil {
+		t.Fatalf("unable to write batch: %v", err)
+	}
+
+	rotated := path + ".20230601T120000.500000000Z"
+	if content, err := ioutil.ReadFile(rotated); err != nil {
+		t.Fatalf("expected the log file to be rotated to %s: %v", rotated, err)
+	} else if string(content) != "{\"uuid\":\"1\"}\n" {
+		t.Errorf("unexpected rotated content %q", content)
+	}
+}
@@ -0,0 +1,100 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// Algorithm of event hash chains
+const hashChainAlgorithm = "sha256"
+
+// Head of a new hash chain
+var hashChainGenesis = strings.Repeat("0", sha256.Size*2)
+
+// Rolling hash chain over archived events, where each link is the hash of the previous link and the event
+// The head is kept in a file so the chain continues across restarts.
+type hashChain struct {
+	mu   sync.Mutex
+	path string
+	head string
+}
+
+// Chain heads of a manifest
+type manifestChain struct {
+	Algorithm string `json:"algorithm"`
+	Previous  string `json:"previous"`
+	Head      string `json:"head"`
+}
+
+// Path of the hash chain head, next to the collector state unless set
+func hashChainPath() string {
+	if p := viper.GetString("archive-hash-chain-path"); p != "" {
+		return p
+	}
+
+	return viper.GetString("state-path") + ".chain"
+}
+
+// Load the hash chain head from the file at path, starting a new chain if it doesn't exist
+func loadHashChain(path string) (*hashChain, error) {
+	chain := &hashChain{path: path, head: hashChainGenesis}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return chain, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	head := strings.TrimSpace(string(content))
+	if decoded, err := hex.DecodeString(head); err != nil || len(decoded) != sha256.Size {
+		return nil, errors.New(fmt.Sprintf("invalid hash chain head in %s", path))
+	}
+
+	chain.head = head
+	return chain, nil
+}
+
+// Extend the chain from head with the events of the batch at src
+// Returns the new head.
+func extendHashChain(head string, src string) (string, error) {
+	link, err := hex.DecodeString(head)
+	if err != nil {
+		return "", err
+	}
+
+	err = readEvents(src, func(event []byte) error {
+		hash := sha256.New()
+		hash.Write(link)
+		hash.Write(event)
+		link = hash.Sum(nil)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(link), nil
+}
+
+// Save a new head once the batch it ends is stored, replacing the file atomically
+func (c *hashChain) commit(head string) error {
+	if err := ioutil.WriteFile(c.path+".tmp", []byte(head+"\n"), 0600); err != nil {
+		return err
+	}
+
+	if err := os.Rename(c.path+".tmp", c.path); err != nil {
+		return err
+	}
+
+	c.head = head
+	return nil
+}
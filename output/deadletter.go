@@ -0,0 +1,71 @@
+package output
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rfizzle/okta-collector/scrub"
+)
+
+// Metadata written alongside a dead-lettered batch
+type deadLetterMetadata struct {
+	Output    string `json:"output"`
+	Timestamp string `json:"timestamp"`
+	Error     string `json:"error"`
+	Attempts  int    `json:"attempts"`
+	Events    int    `json:"events"`
+	FailedAt  string `json:"failedAt"`
+}
+
+// Move a batch that failed delivery into the dead-letter directory of the output, with its metadata
+// Returns the path of the dead-lettered batch
+func deadLetter(dir string, output string, b batch, deliveryErr error, attempts int) (string, error) {
+	// Setup output directory
+	outputDir := filepath.Join(dir, output)
+	if err := os.MkdirAll(outputDir, 0750); err != nil {
+		return "", err
+	}
+
+	// Name the batch after when it failed so dead letters sort chronologically
+	failedAt := time.Now().UTC()
+	name := failedAt.Format("20060102T150405.000000000Z") + "-" + filepath.Base(b.path)
+	dst := filepath.Join(outputDir, name)
+
+	// Move the batch, copying if it is on another device
+	if err := os.Rename(b.path, dst); err != nil {
+		if err := copyFile(b.path, dst); err != nil {
+			return "", err
+		}
+	}
+
+	// Count events
+	events := 0
+	_ = readEvents(dst, func(event []byte) error {
+		events++
+		return nil
+	})
+
+	// Write metadata
+	metadata, err := json.MarshalIndent(deadLetterMetadata{
+		Output:    output,
+		Timestamp: b.timestamp,
+		Error:     scrub.String(deliveryErr.Error()),
+		Attempts:  attempts,
+		Events:    events,
+		FailedAt:  failedAt.Format(time.RFC3339Nano),
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	metadataPath := strings.TrimSuffix(dst, pendingBatchExt) + deadLetterMetaExt
+	if err := ioutil.WriteFile(metadataPath, metadata, 0640); err != nil {
+		return "", err
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,73 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Result of a test write to an output
+type TestResult struct {
+	Name string
+	Err  error
+}
+
+// Write a batch of a single test event to every enabled output
+// Batches bypass the sinks, so failures are reported instead of retried, spooled or dead-lettered.
+func TestWrite(event []byte) []TestResult {
+	var results []TestResult
+	for _, t := range outputTypes {
+		if !t.enabled() {
+			continue
+		}
+
+		results = append(results, TestResult{Name: t.name, Err: testWrite(t, event)})
+	}
+
+	return results
+}
+
+// Write a batch of a single event to an output, processed and encoded like its sink would
+func testWrite(t outputType, event []byte) error {
+	output, err := t.setup()
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to setup %s output: %v", t.name, err))
+	}
+
+	config := sinkConfigFromParams(t.name)
+	if encoder, ok := output.(encodingOutput); ok {
+		encoder.setEncoding(config.encoding)
+		config.encoding = encoding{format: formatNdjson, compression: compressionNone}
+	}
+
+	for _, process := range config.processors {
+		processed, err := process(event)
+		if err != nil {
+			return err
+		}
+
+		// Write the event as is when a processor drops it
+		if processed != nil {
+			event = processed
+		}
+	}
+
+	// Name the batch like a pending batch, which some outputs parse
+	dir, err := createTempDir("okta-doctor-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	path := filepath.Join(dir, pendingBatchName(0, now))
+	if err := ioutil.WriteFile(path, append(event, '\n'), 0600); err != nil {
+		return err
+	}
+
+	s := &sink{sinkConfig: config, output: output}
+	return s.writeBatch(batch{path: path, timestamp: now.UTC().Format(time.RFC3339Nano)})
+}
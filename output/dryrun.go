@@ -0,0 +1,143 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+)
+
+// Stand in for an output in dry-run mode, counting the batches that would have been written to it instead
+type dryRunOutput struct {
+	name     string
+	encoding encoding
+
+	mu           sync.Mutex
+	batches      int
+	events       int
+	bytes        int64
+	encodedBytes int64
+	eventTypes   map[string]int
+}
+
+// Fields of an event counted in dry-run summaries
+type dryRunFields struct {
+	EventType string `json:"eventType"`
+}
+
+// Check if outputs only count the batches that would have been written to them
+func DryRun() bool {
+	return viper.GetBool("dry-run")
+}
+
+func newDryRunOutput(name string) *dryRunOutput {
+	return &dryRunOutput{name: name, eventTypes: make(map[string]int)}
+}
+
+func (output *dryRunOutput) Name() string {
+	return output.name
+}
+
+// Keep the encoding of the output, so batches are encoded like they would have been for the summary
+func (output *dryRunOutput) setEncoding(e encoding) {
+	output.encoding = e
+}
+
+// Count the events of the batch at src and the size it would have been written with
+func (output *dryRunOutput) Write(src string, timestamp string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	encodedBytes := info.Size()
+	if output.encoding.enabled() {
+		encodedPath, err := output.encoding.encode(src)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(encodedPath)
+
+		encodedInfo, err := os.Stat(encodedPath)
+		if err != nil {
+			return err
+		}
+		encodedBytes = encodedInfo.Size()
+	}
+
+	eventTypes := make(map[string]int)
+	events := 0
+	err = readEvents(src, func(event []byte) error {
+		var fields dryRunFields
+		_ = json.Unmarshal(event, &fields)
+		eventTypes[fields.EventType]++
+		events++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	output.batches++
+	output.events += events
+	output.bytes += info.Size()
+	output.encodedBytes += encodedBytes
+	for eventType, count := range eventTypes {
+		output.eventTypes[eventType] += count
+	}
+
+	return nil
+}
+
+// Print what would have been written to every output so far in dry-run mode, with the event types of each output
+func PrintDryRunSummary(w io.Writer) {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "OUTPUT\tBATCHES\tEVENTS\tBYTES\tENCODED BYTES")
+
+	var outputs []*dryRunOutput
+	for _, s := range enabledSinks {
+		if output, ok := s.output.(*dryRunOutput); ok {
+			outputs = append(outputs, output)
+		}
+	}
+
+	for _, output := range outputs {
+		output.mu.Lock()
+		_, _ = fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%d\n", output.name, output.batches, output.events, output.bytes, output.encodedBytes)
+		output.mu.Unlock()
+	}
+	_ = writer.Flush()
+
+	for _, output := range outputs {
+		output.mu.Lock()
+		eventTypes := make([]string, 0, len(output.eventTypes))
+		for eventType := range output.eventTypes {
+			eventTypes = append(eventTypes, eventType)
+		}
+
+		sort.Slice(eventTypes, func(i, j int) bool {
+			if output.eventTypes[eventTypes[i]] != output.eventTypes[eventTypes[j]] {
+				return output.eventTypes[eventTypes[i]] > output.eventTypes[eventTypes[j]]
+			}
+			return eventTypes[i] < eventTypes[j]
+		})
+
+		if len(eventTypes) > 0 {
+			_, _ = fmt.Fprintf(w, "\n%s event types:\n", output.name)
+			writer = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			for _, eventType := range eventTypes {
+				_, _ = fmt.Fprintf(writer, "  %s\t%d\n", eventType, output.eventTypes[eventType])
+			}
+			_ = writer.Flush()
+		}
+		output.mu.Unlock()
+	}
+}
@@ -0,0 +1,271 @@
+package output
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression codecs of encoded batches
+const (
+	compressionNone = "none"
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+)
+
+// Formats of encoded batches
+const (
+	formatNdjson    = "ndjson"
+	formatJsonArray = "json-array"
+	formatParquet   = "parquet"
+	formatAvro      = "avro"
+)
+
+// Default compression level of every codec
+const defaultCompressionLevel = -1
+
+// A compression codec of encoded batches
+type codec struct {
+	ext       string
+	minLevel  int
+	maxLevel  int
+	newWriter func(out io.Writer, level int) (io.WriteCloser, error)
+}
+
+// Supported compression codecs
+var codecs = map[string]codec{
+	compressionGzip: {".gz", gzip.BestSpeed, gzip.BestCompression, newGzipWriter},
+	compressionZstd: {".zst", 1, 22, newZstdWriter},
+}
+
+// A file format of encoded batches
+// Formats with internal compression apply the codec themselves instead of compressing the whole file, at its default
+// level and only with the codecs they support, or every codec when nil.
+type format struct {
+	ext                 string
+	internalCompression bool
+	compressions        []string
+	write               func(e encoding, src string, out io.Writer) error
+}
+
+// Supported file formats
+var formats = map[string]format{
+	formatNdjson:    {".json", false, nil, writeNdjson},
+	formatJsonArray: {".json", false, nil, writeJsonArray},
+	formatParquet:   {".parquet", true, nil, writeParquet},
+	formatAvro:      {".avro", true, []string{compressionZstd}, writeAvro},
+}
+
+// Outputs that encode batches themselves, after reading the newline delimited events
+type encodingOutput interface {
+	Output
+	setEncoding(e encoding)
+}
+
+// Encoding applied to batches of outputs that copy batch files verbatim
+type encoding struct {
+	format            string
+	compression       string
+	compressionLevel  int
+	encryption        string
+	encryptionKeyFile string
+}
+
+// Check if the encoding changes batches
+func (e encoding) enabled() bool {
+	return e.format != formatNdjson || e.compressed() || e.encrypted()
+}
+
+// Check if the encoding compresses batches
+func (e encoding) compressed() bool {
+	return e.compression != "" && e.compression != compressionNone
+}
+
+// Check if the encoding encrypts batches
+func (e encoding) encrypted() bool {
+	return e.encryption != "" && e.encryption != encryptionNone
+}
+
+// Validate the encoding params
+func (e encoding) validate() error {
+	f, ok := formats[e.format]
+	if !ok {
+		return errors.New(fmt.Sprintf("unsupported format %s", e.format))
+	}
+
+	if e.compressed() {
+		c, ok := codecs[e.compression]
+		if !ok {
+			return errors.New(fmt.Sprintf("unsupported compression %s", e.compression))
+		}
+
+		if f.compressions != nil && !contains(f.compressions, e.compression) {
+			return errors.New(fmt.Sprintf("%s format does not support %s compression (%s)", e.format, e.compression, strings.Join(f.compressions, ", ")))
+		}
+
+		if e.compressionLevel != defaultCompressionLevel && f.internalCompression {
+			return errors.New(fmt.Sprintf("%s format does not support compression levels", e.format))
+		}
+
+		if e.compressionLevel != defaultCompressionLevel && (e.compressionLevel < c.minLevel || e.compressionLevel > c.maxLevel) {
+			return errors.New(fmt.Sprintf("invalid %s compression level %d (%d to %d)", e.compression, e.compressionLevel, c.minLevel, c.maxLevel))
+		}
+	}
+
+	if e.encrypted() {
+		c, ok := ciphers[e.encryption]
+		if !ok {
+			return errors.New(fmt.Sprintf("unsupported encryption %s", e.encryption))
+		}
+
+		if e.encryptionKeyFile == "" {
+			return errors.New(fmt.Sprintf("missing %s encryption key file", e.encryption))
+		}
+
+		// Load the public keys
+		if _, err := c.newWriter(ioutil.Discard, e.encryptionKeyFile); err != nil {
+			return errors.New(fmt.Sprintf("invalid %s encryption key file %s: %v", e.encryption, e.encryptionKeyFile, err))
+		}
+	}
+
+	return nil
+}
+
+// Extension of encoded batches
+func (e encoding) ext() string {
+	f := formats[e.format]
+	ext := f.ext
+	if e.compressed() && !f.internalCompression {
+		ext += codecs[e.compression].ext
+	}
+
+	if e.encrypted() {
+		ext += ciphers[e.encryption].ext
+	}
+
+	return ext
+}
+
+// Encode the batch at src into a new temp file
+// Returns the path of the encoded batch
+func (e encoding) encode(src string) (string, error) {
+	out, err := createTempFile("okta-batch-*" + e.ext())
+	if err != nil {
+		return "", err
+	}
+	dst := out.Name()
+
+	// Convert, compress and encrypt
+	if err := e.write(src, out); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return "", err
+	}
+
+	if err := out.Close(); err != nil {
+		_ = os.Remove(dst)
+		return "", err
+	}
+
+	return dst, nil
+}
+
+// Write the batch at src to out in the format, compression and encryption of the encoding
+func (e encoding) write(src string, out io.Writer) error {
+	if !e.encrypted() {
+		return e.writeCompressed(src, out)
+	}
+
+	// Public keys are loaded for every batch so key files can be rotated without a restart
+	writer, err := ciphers[e.encryption].newWriter(out, e.encryptionKeyFile)
+	if err != nil {
+		return err
+	}
+
+	if err := e.writeCompressed(src, writer); err != nil {
+		_ = writer.Close()
+		return err
+	}
+
+	return writer.Close()
+}
+
+// Write the batch at src to out in the format and compression of the encoding
+func (e encoding) writeCompressed(src string, out io.Writer) error {
+	f := formats[e.format]
+	if !e.compressed() || f.internalCompression {
+		return f.write(e, src, out)
+	}
+
+	writer, err := codecs[e.compression].newWriter(out, e.compressionLevel)
+	if err != nil {
+		return err
+	}
+
+	if err := f.write(e, src, writer); err != nil {
+		_ = writer.Close()
+		return err
+	}
+
+	return writer.Close()
+}
+
+// Write the newline delimited batch at src as is
+func writeNdjson(e encoding, src string, out io.Writer) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// Write the newline delimited batch at src as a JSON array with an event per line
+func writeJsonArray(e encoding, src string, out io.Writer) error {
+	writer := bufio.NewWriter(out)
+	if _, err := writer.WriteString("["); err != nil {
+		return err
+	}
+
+	separator := "\n"
+	err := readEvents(src, func(event []byte) error {
+		if _, err := writer.WriteString(separator); err != nil {
+			return err
+		}
+		separator = ",\n"
+
+		_, err := writer.Write(event)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.WriteString("\n]\n"); err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
+
+func newGzipWriter(out io.Writer, level int) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(out, level)
+}
+
+func newZstdWriter(out io.Writer, level int) (io.WriteCloser, error) {
+	encoderLevel := zstd.SpeedDefault
+	if level != defaultCompressionLevel {
+		encoderLevel = zstd.EncoderLevelFromZstd(level)
+	}
+
+	return zstd.NewWriter(out, zstd.WithEncoderLevel(encoderLevel))
+}
@@ -0,0 +1,66 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/openpgp"
+)
+
+// Encryption ciphers of encoded batches
+const (
+	encryptionNone = "none"
+	encryptionAge  = "age"
+	encryptionPgp  = "pgp"
+)
+
+// An encryption cipher of encoded batches, encrypting to the public keys in a key file
+type cipher struct {
+	ext       string
+	newWriter func(out io.Writer, keyFile string) (io.WriteCloser, error)
+}
+
+// Supported encryption ciphers
+var ciphers = map[string]cipher{
+	encryptionAge: {".age", newAgeWriter},
+	encryptionPgp: {".gpg", newPgpWriter},
+}
+
+// Encrypt to the age recipients listed in the key file, one per line
+func newAgeWriter(out io.Writer, keyFile string) (io.WriteCloser, error) {
+	file, err := os.Open(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	recipients, err := age.ParseRecipients(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return age.Encrypt(out, recipients...)
+}
+
+// Encrypt to the PGP public keys in the armored key file
+func newPgpWriter(out io.Writer, keyFile string) (io.WriteCloser, error) {
+	file, err := os.Open(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	keyRing, err := openpgp.ReadArmoredKeyRing(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keyRing) == 0 {
+		return nil, errors.New(fmt.Sprintf("no public keys in %s", keyFile))
+	}
+
+	return openpgp.Encrypt(out, keyRing, nil, &openpgp.FileHints{IsBinary: true}, nil)
+}
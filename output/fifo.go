@@ -0,0 +1,134 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"os"
+	"time"
+)
+
+// Time between attempts to open a named pipe without a reader
+const fifoOpenInterval = 500 * time.Millisecond
+
+// Named pipe output that writes newline delimited events for on-host log shippers
+type fifoOutput struct {
+	path    string
+	timeout time.Duration
+	file    *os.File
+}
+
+func fifoInitParams() {
+	flag.Bool("fifo", false, "enable named pipe (fifo) output")
+	flag.String("fifo-path", "", "path of the named pipe to write to")
+	flag.Bool("fifo-create", true, "create the named pipe if it doesn't exist")
+	flag.Int("fifo-timeout", 60, "time in seconds to wait for a named pipe reader to connect or read a line")
+}
+
+func fifoEnabled() bool {
+	return viper.GetBool("fifo")
+}
+
+func fifoValidateParams() error {
+	if viper.GetString("fifo-path") == "" {
+		return errors.New("missing fifo path param (--fifo-path)")
+	}
+
+	if viper.GetInt("fifo-timeout") < 1 {
+		return errors.New("invalid fifo timeout param (--fifo-timeout)")
+	}
+
+	return nil
+}
+
+// Create the named pipe output, creating the pipe if enabled
+func newFifoOutput() (Output, error) {
+	path := viper.GetString("fifo-path")
+
+	// Create pipe
+	if _, err := os.Stat(path); os.IsNotExist(err) && viper.GetBool("fifo-create") {
+		if err := makeFifo(path); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Check path is a pipe
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		return nil, errors.New(fmt.Sprintf("%s is not a named pipe", path))
+	}
+
+	return &fifoOutput{
+		path:    path,
+		timeout: time.Duration(viper.GetInt("fifo-timeout")) * time.Second,
+	}, nil
+}
+
+func (output *fifoOutput) Name() string {
+	return "fifo"
+}
+
+// Write each event of the batch at src to the pipe as a line
+func (output *fifoOutput) Write(src string, timestamp string) error {
+	return readEvents(src, func(event []byte) error {
+		line := append(event, '\n')
+
+		// Reopen once if the reader went away
+		err := output.writeLine(line)
+		if err != nil && !os.IsTimeout(err) {
+			output.close()
+			err = output.writeLine(line)
+		}
+
+		// Close after a stalled write so the reader sees the end of any partial line
+		if os.IsTimeout(err) {
+			output.close()
+		}
+
+		return err
+	})
+}
+
+// Write a line to the pipe, waiting for a reader if needed
+// Fails once the reader stops reading for longer than the timeout
+func (output *fifoOutput) writeLine(line []byte) error {
+	if output.file == nil {
+		deadline := time.Now().Add(output.timeout)
+		for {
+			file, err := openFifo(output.path)
+			if err == nil {
+				output.file = file
+				break
+			}
+
+			// Wait for a reader
+			if !isFifoNoReader(err) || time.Now().After(deadline) {
+				return err
+			}
+
+			time.Sleep(fifoOpenInterval)
+		}
+	}
+
+	if err := output.file.SetWriteDeadline(time.Now().Add(output.timeout)); err != nil {
+		return err
+	}
+
+	_, err := output.file.Write(line)
+	return err
+}
+
+// Close the pipe
+func (output *fifoOutput) close() {
+	if output.file != nil {
+		_ = output.file.Close()
+		output.file = nil
+	}
+}
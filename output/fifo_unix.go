@@ -0,0 +1,25 @@
+// +build !windows
+
+package output
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Create a named pipe
+func makeFifo(path string) error {
+	return syscall.Mkfifo(path, 0600)
+}
+
+// Open a named pipe for writing without blocking when there is no reader
+// The pipe is left non-blocking so writes wait in the runtime poller and honor write deadlines.
+func openFifo(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+}
+
+// Check if opening a named pipe failed because there is no reader
+func isFifoNoReader(err error) bool {
+	return errors.Is(err, syscall.ENXIO)
+}
@@ -0,0 +1,23 @@
+// +build windows
+
+package output
+
+import (
+	"errors"
+	"os"
+)
+
+// Named pipes are not supported on windows
+var errFifoUnsupported = errors.New("fifo output is not supported on windows")
+
+func makeFifo(path string) error {
+	return errFifoUnsupported
+}
+
+func openFifo(path string) (*os.File, error) {
+	return nil, errFifoUnsupported
+}
+
+func isFifoNoReader(err error) bool {
+	return false
+}
@@ -0,0 +1,53 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// Flatten nested objects and arrays of an event into dotted keys, indexing array elements, such as target.0.id
+func flattenEvent(event []byte) ([]byte, error) {
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(event))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	flattened := make(map[string]interface{})
+	flatten(flattened, "", decoded)
+
+	return json.Marshal(flattened)
+}
+
+// Add the leaf values of a value to flattened under the key prefix
+// Empty objects and arrays are kept as is so their keys aren't lost.
+func flatten(flattened map[string]interface{}, prefix string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			flattened[prefix] = v
+		}
+		for key, child := range v {
+			flatten(flattened, flattenKey(prefix, key), child)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			flattened[prefix] = v
+		}
+		for i, child := range v {
+			flatten(flattened, flattenKey(prefix, strconv.Itoa(i)), child)
+		}
+	default:
+		flattened[prefix] = v
+	}
+}
+
+func flattenKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + "." + key
+}
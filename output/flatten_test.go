@@ -0,0 +1,75 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Check that two JSON documents are equal, keeping numbers as written
+func assertJSONEqual(t *testing.T, expected string, actual []byte) {
+	t.Helper()
+
+	decode := func(content []byte) interface{} {
+		var decoded interface{}
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		if err := decoder.Decode(&decoded); err != nil {
+			t.Fatalf("unable to decode %s: %v", content, err)
+		}
+		return decoded
+	}
+
+	if !reflect.DeepEqual(decode([]byte(expected)), decode(actual)) {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
+
+func TestFlattenEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    string
+		expected string
+	}{
+		{"flat", `{"uuid": "1", "severity": "INFO"}`, `{"uuid": "1", "severity": "INFO"}`},
+		{"nested objects", `{"actor": {"id": "00u1", "detail": {"type": "User"}}}`, `{"actor.id": "00u1", "actor.detail.type": "User"}`},
+		{"arrays", `{"target": [{"id": "0oa1"}, {"id": "00u2"}]}`, `{"target.0.id": "0oa1", "target.1.id": "00u2"}`},
+		{"nested arrays", `{"matrix": [[1, 2], [], [{"a": [true]}]]}`, `{"matrix.0.0": 1, "matrix.0.1": 2, "matrix.1": [], "matrix.2.0.a.0": true}`},
+		{"empty object at the root", `{}`, `{}`},
+		{"empty object and array fields", `{"actor": {}, "target": []}`, `{"actor": {}, "target": []}`},
+		{"nested empty object and array", `{"debugContext": {"debugData": {}, "tags": []}}`, `{"debugContext.debugData": {}, "debugContext.tags": []}`},
+		{"null", `{"actor": {"detailEntry": null}}`, `{"actor.detailEntry": null}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flattened, err := flattenEvent([]byte(test.event))
+			if err != nil {
+				t.Fatalf("unable to flatten event: %v", err)
+			}
+
+			assertJSONEqual(t, test.expected, flattened)
+		})
+	}
+}
+
+func TestFlattenEventKeepsNumberPrecision(t *testing.T) {
+	flattened, err := flattenEvent([]byte(`{"securityContext": {"asNumber": 12345678901234567890}, "risk": [0.10000000000000001, 1e400]}`))
+	if err != nil {
+		t.Fatalf("unable to flatten event: %v", err)
+	}
+
+	for _, number := range []string{`"securityContext.asNumber":12345678901234567890`, `"risk.0":0.10000000000000001`, `"risk.1":1e400`} {
+		if !strings.Contains(string(flattened), number) {
+			t.Errorf("expected %s in %s", number, flattened)
+		}
+	}
+}
+
+func TestFlattenEventInvalid(t *testing.T) {
+	if _, err := flattenEvent([]byte(`{"actor": `)); err == nil {
+		t.Error("expected a truncated event to be invalid")
+	}
+}
@@ -0,0 +1,95 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// Fields extracted from an event for outputs with indexed columns
+type eventFields struct {
+	Uuid      string `json:"uuid"`
+	Published string `json:"published"`
+	EventType string `json:"eventType"`
+	Severity  string `json:"severity"`
+	Actor     struct {
+		AlternateId string `json:"alternateId"`
+	} `json:"actor"`
+}
+
+// Parse the indexed fields from a raw event
+func parseEventFields(event []byte) (*eventFields, time.Time, error) {
+	var fields eventFields
+
+	// Convert from JSON
+	if err := json.Unmarshal(event, &fields); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	// Parse published timestamp
+	published, err := time.Parse(time.RFC3339Nano, fields.Published)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return &fields, published, nil
+}
+
+// Read each event of a newline delimited batch file and pass it to fn
+func readEvents(src string, fn func(event []byte) error) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		// Skip blank lines
+		if len(trimNewline(line)) > 0 {
+			if err := fn(trimNewline(line)); err != nil {
+				return err
+			}
+		}
+
+		// Handle end of file or error
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Trim the trailing newline from a line
+func trimNewline(line []byte) []byte {
+	for len(line) > 0 && (line[len(line)-1] == '\n' || line[len(line)-1] == '\r') {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// Copy the file at src to dst
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,73 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/itchyny/gojq"
+)
+
+// Compile a jq expression that reshapes events
+func compileJq(expression string) (*gojq.Code, error) {
+	query, err := gojq.Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	return gojq.Compile(query)
+}
+
+// Build a processor that runs a compiled jq expression on events
+// Expressions that produce no value drop the event, such as `select(.outcome.result == "SUCCESS")`.
+func newJqProcessor(code *gojq.Code) processor {
+	return func(event []byte) ([]byte, error) {
+		var decoded interface{}
+		decoder := json.NewDecoder(bytes.NewReader(event))
+		decoder.UseNumber()
+		if err := decoder.Decode(&decoded); err != nil {
+			return nil, err
+		}
+
+		iter := code.Run(jqValue(decoded))
+		result, ok := iter.Next()
+		if !ok {
+			return nil, nil
+		}
+
+		if err, ok := result.(error); ok {
+			return nil, errors.New(fmt.Sprintf("Error running jq expression: %v", err))
+		}
+
+		if _, ok := iter.Next(); ok {
+			return nil, errors.New("Error running jq expression: more than one value produced for an event")
+		}
+
+		return json.Marshal(result)
+	}
+}
+
+// Convert decoded numbers to the integers and floats jq operates on
+func jqValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = jqValue(child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = jqValue(child)
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i)
+		}
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
+	}
+}
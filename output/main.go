@@ -0,0 +1,454 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rfizzle/okta-collector/filter"
+	"github.com/rfizzle/okta-collector/plugin"
+	"github.com/rfizzle/okta-collector/pool"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"log"
+	"time"
+)
+
+// Output is a destination that batches of events are delivered to.
+// Batches are newline delimited JSON files written by the sink of the output.
+type Output interface {
+	// Name of the output used in logs and errors
+	Name() string
+
+	// Write the batch at src to the output
+	// timestamp is the end of the collection window for the batch
+	Write(src string, timestamp string) error
+}
+
+// An output type that can be enabled through the CLI params
+type outputType struct {
+	name           string
+	initParams     func()
+	enabled        func() bool
+	validateParams func() error
+	setup          func() (Output, error)
+}
+
+// Output types whose outputs copy batch files verbatim and so support encoding,
+// mapped to a check of whether their current params allow it
+var encodableOutputTypes = map[string]func() error{
+	"builtin": builtinValidateEncoding,
+	"archive": archiveValidateEncoding,
+}
+
+// Output made of several outputs that are each delivered to through a sink of their own
+type multiOutput interface {
+	Output
+
+	// Outputs to create a sink for
+	outputs() []Output
+}
+
+// Output types writing through a single connection, file or database at a time, so they can't have several workers
+var serialOutputTypes = map[string]bool{
+	"builtin":     true,
+	"sqlite":      true,
+	"unix-socket": true,
+	"fifo":        true,
+	"plugin":      true,
+}
+
+// Every output type in this package
+var outputTypes = []outputType{
+	{"builtin", builtinInitParams, builtinEnabled, builtinValidateParams, newBuiltinOutput},
+	{"archive", archiveInitParams, archiveEnabled, archiveValidateParams, newArchiveOutput},
+	{"postgres", postgresInitParams, postgresEnabled, postgresValidateParams, newPostgresOutput},
+	{"sqlite", sqliteInitParams, sqliteEnabled, sqliteValidateParams, newSqliteOutput},
+	{"bigquery", bigqueryInitParams, bigqueryEnabled, bigqueryValidateParams, newBigqueryOutput},
+	{"snowflake", snowflakeInitParams, snowflakeEnabled, snowflakeValidateParams, newSnowflakeOutput},
+	{"adx", adxInitParams, adxEnabled, adxValidateParams, newAdxOutput},
+	{"security-lake", securityLakeInitParams, securityLakeEnabled, securityLakeValidateParams, newSecurityLakeOutput},
+	{"unix-socket", unixSocketInitParams, unixSocketEnabled, unixSocketValidateParams, newUnixSocketOutput},
+	{"fifo", fifoInitParams, fifoEnabled, fifoValidateParams, newFifoOutput},
+	{"plugin", pluginInitParams, pluginEnabled, pluginValidateParams, newPluginOutput},
+}
+
+// Sinks of the outputs that have been set up from the validated params
+var enabledSinks []*sink
+
+// Register the CLI params for every output in this package
+func InitCLIParams() {
+	flag.String("dead-letter-dir", "dead-letter", "directory to write batches that failed delivery to")
+	flag.String("spool-dir", "", "directory to persist pending batches of every output in (default temp directory)")
+	flag.Int("dead-letter-max-age", 0, "time in seconds to keep dead-lettered batches (0 for unlimited)")
+	flag.Int64("dead-letter-max-bytes", 0, "maximum size in bytes of dead-lettered batches of every output (0 for unlimited)")
+	flag.Int("temp-max-age", 0, "time in seconds after which leftover temp files of the collector are removed (0 to keep them)")
+	flag.StringSlice("alert-outputs", []string{}, "outputs that receive detection alerts instead of events (default alerts are logged)")
+	flag.Bool("dry-run", false, "process events for every output without writing them or saving the state, printing a summary instead")
+
+	for _, t := range outputTypes {
+		t.initParams()
+
+		// Params every output has for its sink
+		flag.String(t.name+"-on-failure", failureModeFatal, fmt.Sprintf("action when writing to the %s output fails (fatal, drop, dead-letter, spool)", t.name))
+		flag.Int64(t.name+"-spool-max-bytes", 1<<30, fmt.Sprintf("maximum size in bytes of batches spooled for the %s output", t.name))
+		flag.Int(t.name+"-spool-max-age", 0, fmt.Sprintf("time in seconds to keep batches spooled for the %s output (0 for unlimited)", t.name))
+		flag.Int(t.name+"-retries", 0, fmt.Sprintf("number of times to retry failed writes to the %s output", t.name))
+		flag.Int(t.name+"-retry-backoff", 1, fmt.Sprintf("time in seconds to wait before the first retry of the %s output, doubled every retry", t.name))
+		flag.Int(t.name+"-queue-size", 5000, fmt.Sprintf("number of events to queue for the %s output", t.name))
+		flag.Int(t.name+"-workers", 1, fmt.Sprintf("number of batches delivered to the %s output in parallel", t.name))
+		flag.Int(t.name+"-buffer-size", 10, fmt.Sprintf("number of batches to buffer for the %s output", t.name))
+		flag.String(t.name+"-format", formatNdjson, fmt.Sprintf("file format of batches written by the %s output (ndjson, json-array, parquet, avro)", t.name))
+		flag.String(t.name+"-compression", compressionNone, fmt.Sprintf("compression of batches written by the %s output (none, gzip, zstd)", t.name))
+		flag.Int(t.name+"-compression-level", -1, fmt.Sprintf("compression level of batches written by the %s output (-1 for the codec default)", t.name))
+		flag.String(t.name+"-encryption", encryptionNone, fmt.Sprintf("encryption of batches written by the %s output (none, age, pgp)", t.name))
+		flag.String(t.name+"-encryption-key-file", "", fmt.Sprintf("file of the age recipients or armored pgp public keys to encrypt batches written by the %s output to", t.name))
+		flag.StringArray(t.name+"-jq", []string{}, fmt.Sprintf("jq expressions run in order on events written by the %s output", t.name))
+		flag.String(t.name+"-timestamp-format", timestampRfc3339, fmt.Sprintf("format of timestamps of events written by the %s output (rfc3339, epoch-millis or a go time layout)", t.name))
+		flag.Bool(t.name+"-add-timestamp", false, fmt.Sprintf("add an @timestamp field from published to events written by the %s output", t.name))
+		flag.StringSlice(t.name+"-fields", []string{}, fmt.Sprintf("dotted paths of the fields kept in events written by the %s output (default all)", t.name))
+		flag.Bool(t.name+"-flatten", false, fmt.Sprintf("flatten nested fields of events written by the %s output into dotted keys", t.name))
+		flag.Bool(t.name+"-expand-targets", false, fmt.Sprintf("write one record per target of events written by the %s output", t.name))
+		flag.StringSlice(t.name+"-promote-debug-data", []string{}, fmt.Sprintf("debugContext.debugData keys promoted to parsed top-level fields of events written by the %s output", t.name))
+		flag.String(t.name+"-transform", transformNone, fmt.Sprintf("transform of events written by the %s output (none, ecs, ocsf, cim)", t.name))
+		flag.Int(t.name+"-max-events-per-second", 0, fmt.Sprintf("maximum rate of events delivered to the %s output (0 for unlimited)", t.name))
+		flag.Int64(t.name+"-max-bytes-per-second", 0, fmt.Sprintf("maximum rate of bytes delivered to the %s output (0 for unlimited)", t.name))
+		flag.StringSlice(t.name+"-event-types", []string{}, fmt.Sprintf("event types routed to the %s output, supports wildcards (default all)", t.name))
+		flag.Int(t.name+"-batch-max-events", 0, fmt.Sprintf("maximum number of events in a batch for the %s output (0 for unlimited)", t.name))
+		flag.Int64(t.name+"-batch-max-bytes", 0, fmt.Sprintf("maximum size in bytes of a batch for the %s output (0 for unlimited)", t.name))
+		flag.Int(t.name+"-batch-max-age", 0, fmt.Sprintf("time in seconds before a batch is flushed to the %s output (0 to flush after every poll)", t.name))
+		flag.StringSlice(t.name+"-severities", []string{}, fmt.Sprintf("severities routed to the %s output (default all)", t.name))
+		flag.StringSlice(t.name+"-outcomes", []string{}, fmt.Sprintf("outcome results routed to the %s output, such as FAILURE and DENY (default all)", t.name))
+		flag.String(t.name+"-min-severity", "", fmt.Sprintf("minimum severity routed to the %s output (DEBUG, INFO, WARN, ERROR) (default all)", t.name))
+	}
+}
+
+// Validate the CLI params of every enabled output
+func ValidateCLIParams() error {
+	if err := validateAlertOutputs(viper.GetStringSlice("alert-outputs")); err != nil {
+		return errors.New(fmt.Sprintf("invalid alert outputs param (--alert-outputs): %v", err))
+	}
+
+	if viper.GetInt("dead-letter-max-age") < 0 {
+		return errors.New("invalid dead letter max age param (--dead-letter-max-age)")
+	}
+
+	if viper.GetInt64("dead-letter-max-bytes") < 0 {
+		return errors.New("invalid dead letter max bytes param (--dead-letter-max-bytes)")
+	}
+
+	if viper.GetInt("temp-max-age") < 0 {
+		return errors.New("invalid temp max age param (--temp-max-age)")
+	}
+
+	for _, t := range outputTypes {
+		if !t.enabled() {
+			continue
+		}
+
+		if err := t.validateParams(); err != nil {
+			return err
+		}
+
+		mode := viper.GetString(t.name + "-on-failure")
+		if mode != failureModeFatal && mode != failureModeDrop && mode != failureModeDeadLetter && mode != failureModeSpool {
+			return errors.New(fmt.Sprintf("invalid %s on failure param (--%s-on-failure)", t.name, t.name))
+		}
+
+		if mode == failureModeDeadLetter && viper.GetString("dead-letter-dir") == "" {
+			return errors.New("missing dead letter directory param (--dead-letter-dir)")
+		}
+
+		if viper.GetInt(t.name+"-queue-size") < 1 {
+			return errors.New(fmt.Sprintf("invalid %s queue size param (--%s-queue-size)", t.name, t.name))
+		}
+
+		if viper.GetInt(t.name+"-workers") < 1 {
+			return errors.New(fmt.Sprintf("invalid %s workers param (--%s-workers)", t.name, t.name))
+		}
+
+		if serialOutputTypes[t.name] && viper.GetInt(t.name+"-workers") > 1 {
+			return errors.New(fmt.Sprintf("invalid %s workers param (--%s-workers): the %s output supports a single worker", t.name, t.name, t.name))
+		}
+
+		if viper.GetInt64(t.name+"-spool-max-bytes") < 0 {
+			return errors.New(fmt.Sprintf("invalid %s spool max bytes param (--%s-spool-max-bytes)", t.name, t.name))
+		}
+
+		if viper.GetInt(t.name+"-spool-max-age") < 0 {
+			return errors.New(fmt.Sprintf("invalid %s spool max age param (--%s-spool-max-age)", t.name, t.name))
+		}
+
+		if viper.GetInt(t.name+"-retries") < 0 {
+			return errors.New(fmt.Sprintf("invalid %s retries param (--%s-retries)", t.name, t.name))
+		}
+
+		if viper.GetInt(t.name+"-retry-backoff") < 1 {
+			return errors.New(fmt.Sprintf("invalid %s retry backoff param (--%s-retry-backoff)", t.name, t.name))
+		}
+
+		if viper.GetInt(t.name+"-buffer-size") < 1 {
+			return errors.New(fmt.Sprintf("invalid %s buffer size param (--%s-buffer-size)", t.name, t.name))
+		}
+
+		if viper.GetInt(t.name+"-batch-max-events") < 0 {
+			return errors.New(fmt.Sprintf("invalid %s batch max events param (--%s-batch-max-events)", t.name, t.name))
+		}
+
+		if viper.GetInt64(t.name+"-batch-max-bytes") < 0 {
+			return errors.New(fmt.Sprintf("invalid %s batch max bytes param (--%s-batch-max-bytes)", t.name, t.name))
+		}
+
+		if viper.GetInt(t.name+"-batch-max-age") < 0 {
+			return errors.New(fmt.Sprintf("invalid %s batch max age param (--%s-batch-max-age)", t.name, t.name))
+		}
+
+		if viper.GetInt(t.name+"-max-events-per-second") < 0 {
+			return errors.New(fmt.Sprintf("invalid %s max events per second param (--%s-max-events-per-second)", t.name, t.name))
+		}
+
+		if viper.GetInt64(t.name+"-max-bytes-per-second") < 0 {
+			return errors.New(fmt.Sprintf("invalid %s max bytes per second param (--%s-max-bytes-per-second)", t.name, t.name))
+		}
+
+		if err := validateEncodingParams(t.name); err != nil {
+			return err
+		}
+
+		if err := validateProcessingParams(t.name); err != nil {
+			return err
+		}
+
+		if err := validateEventTypePatterns(viper.GetStringSlice(t.name + "-event-types")); err != nil {
+			return errors.New(fmt.Sprintf("invalid %s event types param (--%s-event-types): %v", t.name, t.name, err))
+		}
+
+		if minSeverity := viper.GetString(t.name + "-min-severity"); minSeverity != "" && !filter.ValidSeverity(minSeverity) {
+			return errors.New(fmt.Sprintf("invalid %s min severity param (--%s-min-severity): unknown severity %s", t.name, t.name, minSeverity))
+		}
+	}
+
+	return nil
+}
+
+// Setup every enabled output and start delivering to it
+func Setup() error {
+	// Reset enabled sinks, stopping the plugins of earlier setups
+	enabledSinks = nil
+	Close()
+
+	// Clean up after previous runs
+	if err := cleanTempDir(); err != nil {
+		return errors.New(fmt.Sprintf("unable to clean temp directory: %v", err))
+	}
+
+	if err := trimDeadLetters(viper.GetString("dead-letter-dir")); err != nil {
+		return errors.New(fmt.Sprintf("unable to trim dead-letter directory: %v", err))
+	}
+
+	for _, t := range outputTypes {
+		if !t.enabled() {
+			continue
+		}
+
+		var output Output
+		if DryRun() {
+			output = newDryRunOutput(t.name)
+		} else {
+			var err error
+			if output, err = t.setup(); err != nil {
+				return errors.New(fmt.Sprintf("unable to setup %s output: %v", t.name, err))
+			}
+		}
+
+		// Deliver to each output of a multi output separately so one failing doesn't hold back or repeat the others
+		outputs := []Output{output}
+		if multi, ok := output.(multiOutput); ok {
+			outputs = multi.outputs()
+		}
+
+		for _, output := range outputs {
+			config := sinkConfigFromParams(t.name)
+			if DryRun() {
+				// Leave spooled batches of the output alone and never retry, spool or dead-letter
+				config.failureMode = failureModeDrop
+				config.retry = retryPolicy{}
+				config.spoolDir = ""
+			}
+
+			if encoder, ok := output.(encodingOutput); ok {
+				encoder.setEncoding(config.encoding)
+				config.encoding = encoding{format: formatNdjson, compression: compressionNone}
+			}
+
+			config.alerts = contains(viper.GetStringSlice("alert-outputs"), t.name)
+			s, err := newSink(output, config)
+			if err != nil {
+				return err
+			}
+
+			enabledSinks = append(enabledSinks, s)
+		}
+	}
+
+	return nil
+}
+
+// Stop the external processes of the outputs, once nothing is written to them anymore
+func Close() {
+	plugin.StopAll()
+}
+
+// Validate the encoding params of an output
+func validateEncodingParams(name string) error {
+	e := encodingFromParams(name)
+	if !e.enabled() {
+		return nil
+	}
+
+	if err := e.validate(); err != nil {
+		return errors.New(fmt.Sprintf("invalid %s encoding params (--%s-format, --%s-compression, --%s-encryption): %v", name, name, name, name, err))
+	}
+
+	validateOutput, ok := encodableOutputTypes[name]
+	if !ok {
+		return errors.New(fmt.Sprintf("%s output does not support encoding (--%s-format, --%s-compression, --%s-encryption)", name, name, name, name))
+	}
+
+	return validateOutput()
+}
+
+// Read the encoding of an output from its params
+func encodingFromParams(name string) encoding {
+	return encoding{
+		format:            viper.GetString(name + "-format"),
+		compression:       viper.GetString(name + "-compression"),
+		compressionLevel:  viper.GetInt(name + "-compression-level"),
+		encryption:        viper.GetString(name + "-encryption"),
+		encryptionKeyFile: viper.GetString(name + "-encryption-key-file"),
+	}
+}
+
+// Read the config of the sink of an output from its params
+func sinkConfigFromParams(name string) sinkConfig {
+	return sinkConfig{
+		outputType:  name,
+		failureMode: viper.GetString(name + "-on-failure"),
+		retry: retryPolicy{
+			retries:        viper.GetInt(name + "-retries"),
+			initialBackoff: time.Duration(viper.GetInt(name+"-retry-backoff")) * time.Second,
+		},
+		deadLetterDir: viper.GetString("dead-letter-dir"),
+		spoolDir:      viper.GetString("spool-dir"),
+		spoolMaxBytes: viper.GetInt64(name + "-spool-max-bytes"),
+		spoolMaxAge:   time.Duration(viper.GetInt(name+"-spool-max-age")) * time.Second,
+		route:         newRoute(viper.GetStringSlice(name+"-event-types"), viper.GetStringSlice(name+"-severities"), viper.GetString(name+"-min-severity"), viper.GetStringSlice(name+"-outcomes")),
+		encoding:      encodingFromParams(name),
+		processors:    processorsFromParams(name),
+		expandTargets: viper.GetBool(name + "-expand-targets"),
+		throttle:      newThrottle(viper.GetInt(name+"-max-events-per-second"), viper.GetInt64(name+"-max-bytes-per-second")),
+		limits: batchLimits{
+			maxEvents: viper.GetInt(name + "-batch-max-events"),
+			maxBytes:  viper.GetInt64(name + "-batch-max-bytes"),
+			maxAge:    time.Duration(viper.GetInt(name+"-batch-max-age")) * time.Second,
+		},
+		bufferSize: viper.GetInt(name + "-buffer-size"),
+		queueSize:  viper.GetInt(name + "-queue-size"),
+		workers:    viper.GetInt(name + "-workers"),
+	}
+}
+
+// Queue an event for every enabled output other than alert outputs
+// The outputs own the event, returning it to the pool once every output wrote it.
+// Blocks while the event queue of any output is full
+func WriteEvent(event []byte) {
+	WriteEventTo(event, nil)
+}
+
+// Queue an event for the enabled outputs named, or every output other than alert outputs when none are named
+// The outputs own the event, returning it to the pool once every output wrote it.
+// Blocks while the event queue of any of the outputs is full
+func WriteEventTo(event []byte, names []string) {
+	var sinks []*sink
+	for _, s := range enabledSinks {
+		if !s.alerts && (len(names) == 0 || contains(names, s.outputType)) {
+			sinks = append(sinks, s)
+		}
+	}
+
+	shared := pool.Share(event, len(sinks))
+	for _, s := range sinks {
+		s.items <- sinkItem{event: event, shared: shared}
+	}
+}
+
+// Check that the outputs are enabled outputs that receive events
+func ValidateEventOutputs(names []string) error {
+	for _, name := range names {
+		found := false
+		for _, t := range outputTypes {
+			if t.name == name {
+				found = true
+				if !t.enabled() {
+					return errors.New(fmt.Sprintf("%s output is not enabled", name))
+				}
+			}
+		}
+
+		if !found {
+			return errors.New(fmt.Sprintf("unknown output %s", name))
+		}
+
+		if contains(viper.GetStringSlice("alert-outputs"), name) {
+			return errors.New(fmt.Sprintf("%s output receives alerts instead of events", name))
+		}
+	}
+
+	return nil
+}
+
+// Latest poll ended when no outputs are enabled
+var lastEndedPoll uint64
+
+// Signal the end of a poll, flushing the open batches of outputs without a max age
+// Polls are numbered from 1 in the order they are collected
+func EndPoll(poll uint64) error {
+	lastEndedPoll = poll
+
+	// Queue the end of the poll behind its events
+	var done []chan error
+	for _, s := range enabledSinks {
+		d := make(chan error, 1)
+		s.items <- sinkItem{endPoll: poll, done: d}
+		done = append(done, d)
+	}
+
+	// Wait for every output to process the events of the poll
+	for i, s := range enabledSinks {
+		if err := <-done[i]; err != nil {
+			return errors.New(fmt.Sprintf("%s output: %v", s.output.Name(), err))
+		}
+	}
+
+	// Expire leftover temp files and dead letters on long running hosts
+	if err := cleanTempDir(); err != nil {
+		log.Printf("Unable to clean temp directory: %v\n", err)
+	}
+
+	if err := trimDeadLetters(viper.GetString("dead-letter-dir")); err != nil {
+		log.Printf("Unable to trim dead-letter directory: %v\n", err)
+	}
+
+	return nil
+}
+
+// Latest poll whose events every enabled output has acknowledged.
+// An output acknowledges a batch once it is written, or once it is dropped or dead-lettered by its failure mode.
+func AckedPoll() uint64 {
+	acked := lastEndedPoll
+	for _, s := range enabledSinks {
+		if sinkAcked := s.ackedPoll(); sinkAcked < acked {
+			acked = sinkAcked
+		}
+	}
+
+	return acked
+}
@@ -0,0 +1,128 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// Manifest of the files a batch was delivered as, so downstream loaders can verify integrity and completeness
+type manifest struct {
+	Output         string         `json:"output"`
+	Sequence       uint64         `json:"sequence"`
+	Timestamp      string         `json:"timestamp"`
+	Events         int            `json:"events"`
+	FirstPublished string         `json:"firstPublished,omitempty"`
+	LastPublished  string         `json:"lastPublished,omitempty"`
+	Files          []manifestFile `json:"files"`
+	HashChain      *manifestChain `json:"hashChain,omitempty"`
+	batchRange     eventRange
+}
+
+// A delivered file in a manifest
+type manifestFile struct {
+	Name           string `json:"name"`
+	Sha256         string `json:"sha256"`
+	Bytes          int64  `json:"bytes"`
+	Events         int    `json:"events"`
+	FirstPublished string `json:"firstPublished"`
+	LastPublished  string `json:"lastPublished"`
+	ChainHead      string `json:"chainHead,omitempty"`
+}
+
+// Event count and published time range of a set of events
+type eventRange struct {
+	events int
+	first  time.Time
+	last   time.Time
+}
+
+// Add an event to the range
+func (r *eventRange) add(published time.Time) {
+	if r.events == 0 || published.Before(r.first) {
+		r.first = published
+	}
+
+	if r.events == 0 || published.After(r.last) {
+		r.last = published
+	}
+
+	r.events++
+}
+
+// Add a delivered file to the manifest
+func (m *manifest) add(name string, path string, r eventRange, chainHead string) error {
+	sum, size, err := sha256File(path)
+	if err != nil {
+		return err
+	}
+
+	m.Files = append(m.Files, manifestFile{
+		Name:           name,
+		Sha256:         sum,
+		Bytes:          size,
+		Events:         r.events,
+		FirstPublished: r.first.UTC().Format(time.RFC3339Nano),
+		LastPublished:  r.last.UTC().Format(time.RFC3339Nano),
+		ChainHead:      chainHead,
+	})
+
+	// Extend the batch range
+	if m.batchRange.events == 0 || r.first.Before(m.batchRange.first) {
+		m.batchRange.first = r.first
+	}
+
+	if m.batchRange.events == 0 || r.last.After(m.batchRange.last) {
+		m.batchRange.last = r.last
+	}
+
+	m.batchRange.events += r.events
+	m.Events = m.batchRange.events
+	m.FirstPublished = m.batchRange.first.UTC().Format(time.RFC3339Nano)
+	m.LastPublished = m.batchRange.last.UTC().Format(time.RFC3339Nano)
+
+	return nil
+}
+
+// Write the manifest to a new temp file
+// Returns the path of the manifest
+func (m *manifest) write() (string, error) {
+	file, err := createTempFile("okta-manifest-*.json")
+	if err != nil {
+		return "", err
+	}
+
+	if err := json.NewEncoder(file).Encode(m); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
+
+// Hash the file at path with SHA-256
+// Returns the hex encoded hash and the size of the file
+func sha256File(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
@@ -0,0 +1,42 @@
+package output
+
+import (
+	"io"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Parquet compression codecs by compression param
+var parquetCodecs = map[string]parquet.CompressionCodec{
+	compressionNone: parquet.CompressionCodec_UNCOMPRESSED,
+	compressionGzip: parquet.CompressionCodec_GZIP,
+	compressionZstd: parquet.CompressionCodec_ZSTD,
+}
+
+// Write the batch at src as a parquet file compressed with the codec of the encoding
+func writeParquet(e encoding, src string, out io.Writer) error {
+	parquetWriter, err := writer.NewParquetWriterFromWriter(out, new(eventRow), 4)
+	if err != nil {
+		return err
+	}
+
+	parquetWriter.CompressionType = parquetCodecs[compressionNone]
+	if e.compressed() {
+		parquetWriter.CompressionType = parquetCodecs[e.compression]
+	}
+
+	err = readEvents(src, func(event []byte) error {
+		row, err := newEventRow(event)
+		if err != nil {
+			return err
+		}
+
+		return parquetWriter.Write(*row)
+	})
+	if err != nil {
+		return err
+	}
+
+	return parquetWriter.WriteStop()
+}
@@ -0,0 +1,174 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rfizzle/okta-collector/plugin"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"path/filepath"
+	"strings"
+)
+
+// Plugin output that writes to every configured external output plugin, each through a sink of its own
+type pluginOutput struct {
+	names   []string
+	clients map[string]*plugin.Client
+}
+
+// Output of a single external output plugin
+type pluginClientOutput struct {
+	name   string
+	client *plugin.Client
+}
+
+func pluginInitParams() {
+	flag.StringSlice("plugins", []string{}, "external output plugins as name=path pairs")
+	flag.StringSlice("plugin-config", []string{}, "output plugin config as name.key=value pairs")
+}
+
+func pluginEnabled() bool {
+	return len(viper.GetStringSlice("plugins")) > 0
+}
+
+func pluginValidateParams() error {
+	paths, err := pluginPaths()
+	if err != nil {
+		return err
+	}
+
+	configs, err := pluginConfigs()
+	if err != nil {
+		return err
+	}
+
+	for name := range configs {
+		if _, ok := paths[name]; !ok {
+			return errors.New(fmt.Sprintf("plugin config for unknown plugin %s (--plugin-config)", name))
+		}
+	}
+
+	return nil
+}
+
+// Start and configure every output plugin
+func newPluginOutput() (Output, error) {
+	paths, err := pluginPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := pluginConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	output := &pluginOutput{clients: make(map[string]*plugin.Client)}
+	for _, name := range pluginNames() {
+		client, err := plugin.Start(paths[name], configs[name])
+		if err != nil {
+			output.kill()
+			return nil, errors.New(fmt.Sprintf("unable to start plugin %s: %v", name, err))
+		}
+
+		output.names = append(output.names, name)
+		output.clients[name] = client
+	}
+
+	return output, nil
+}
+
+func (output *pluginOutput) Name() string {
+	return "plugin"
+}
+
+// Write the batch at src to every plugin in order
+func (output *pluginOutput) Write(src string, timestamp string) error {
+	for _, o := range output.outputs() {
+		if err := o.Write(src, timestamp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Output of every plugin in order
+func (output *pluginOutput) outputs() []Output {
+	var outputs []Output
+	for _, name := range output.names {
+		outputs = append(outputs, &pluginClientOutput{name: name, client: output.clients[name]})
+	}
+	return outputs
+}
+
+func (output *pluginClientOutput) Name() string {
+	return "plugin-" + output.name
+}
+
+func (output *pluginClientOutput) Write(src string, timestamp string) error {
+	if err := output.client.Write(src, timestamp); err != nil {
+		return errors.New(fmt.Sprintf("plugin %s: %v", output.name, err))
+	}
+
+	return nil
+}
+
+// Stop every started plugin
+func (output *pluginOutput) kill() {
+	for _, client := range output.clients {
+		client.Kill()
+	}
+}
+
+// Names of the plugins in the order they were given
+func pluginNames() []string {
+	var names []string
+	for _, pair := range viper.GetStringSlice("plugins") {
+		names = append(names, strings.SplitN(pair, "=", 2)[0])
+	}
+	return names
+}
+
+// Parse the name=path plugin pairs
+func pluginPaths() (map[string]string, error) {
+	paths := make(map[string]string)
+	for _, pair := range viper.GetStringSlice("plugins") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New(fmt.Sprintf("invalid plugin %s, expected name=path (--plugins)", pair))
+		}
+
+		if _, ok := paths[parts[0]]; ok {
+			return nil, errors.New(fmt.Sprintf("duplicate plugin %s (--plugins)", parts[0]))
+		}
+
+		path, err := filepath.Abs(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		paths[parts[0]] = path
+	}
+
+	return paths, nil
+}
+
+// Parse the name.key=value plugin config pairs
+func pluginConfigs() (map[string]map[string]string, error) {
+	configs := make(map[string]map[string]string)
+	for _, pair := range viper.GetStringSlice("plugin-config") {
+		parts := strings.SplitN(pair, "=", 2)
+		keyParts := strings.SplitN(parts[0], ".", 2)
+		if len(parts) != 2 || len(keyParts) != 2 || keyParts[0] == "" || keyParts[1] == "" {
+			return nil, errors.New(fmt.Sprintf("invalid plugin config %s, expected name.key=value (--plugin-config)", pair))
+		}
+
+		if configs[keyParts[0]] == nil {
+			configs[keyParts[0]] = make(map[string]string)
+		}
+		configs[keyParts[0]][keyParts[1]] = parts[1]
+	}
+
+	return configs, nil
+}
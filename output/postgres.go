@@ -0,0 +1,175 @@
+package output
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"regexp"
+)
+
+// Valid postgres table names
+var postgresTableRegex = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+// Postgres output that batches events into a JSONB column table
+type postgresOutput struct {
+	db        *sql.DB
+	table     string
+	batchSize int
+}
+
+func postgresInitParams() {
+	flag.Bool("postgres", false, "enable postgres output")
+	flag.String("postgres-dsn", "", "postgres connection string")
+	flag.String("postgres-table", "okta_events", "postgres table to write events to")
+	flag.Int("postgres-batch-size", 1000, "number of events to insert per postgres transaction")
+}
+
+func postgresEnabled() bool {
+	return viper.GetBool("postgres")
+}
+
+func postgresValidateParams() error {
+	if viper.GetString("postgres-dsn") == "" {
+		return errors.New("missing postgres connection string param (--postgres-dsn)")
+	}
+
+	if !postgresTableRegex.MatchString(viper.GetString("postgres-table")) {
+		return errors.New("invalid postgres table name param (--postgres-table)")
+	}
+
+	if viper.GetInt("postgres-batch-size") < 1 {
+		return errors.New("invalid postgres batch size param (--postgres-batch-size)")
+	}
+
+	return nil
+}
+
+// Create the postgres output and ensure the events table exists
+func newPostgresOutput() (Output, error) {
+	// Open connection
+	db, err := sql.Open("postgres", viper.GetString("postgres-dsn"))
+	if err != nil {
+		return nil, err
+	}
+
+	output := &postgresOutput{
+		db:        db,
+		table:     viper.GetString("postgres-table"),
+		batchSize: viper.GetInt("postgres-batch-size"),
+	}
+
+	// Create table
+	if err := output.createTable(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return output, nil
+}
+
+func (output *postgresOutput) Name() string {
+	return "postgres"
+}
+
+// Create the events table and its indexes if they don't exist
+func (output *postgresOutput) createTable() error {
+	table := pq.QuoteIdentifier(output.table)
+
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			uuid TEXT NOT NULL,
+			published TIMESTAMPTZ NOT NULL,
+			event_type TEXT NOT NULL,
+			actor TEXT,
+			event JSONB NOT NULL
+		)`, table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (published)", pq.QuoteIdentifier(output.table+"_published_idx"), table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (event_type)", pq.QuoteIdentifier(output.table+"_event_type_idx"), table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (actor)", pq.QuoteIdentifier(output.table+"_actor_idx"), table),
+	}
+
+	for _, statement := range statements {
+		if _, err := output.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write the batch at src to postgres using COPY in transactions of batch size
+func (output *postgresOutput) Write(src string, timestamp string) error {
+	var batch [][]byte
+
+	err := readEvents(src, func(event []byte) error {
+		batch = append(batch, event)
+
+		// Flush full batch
+		if len(batch) >= output.batchSize {
+			if err := output.copyBatch(batch); err != nil {
+				return err
+			}
+			batch = nil
+		}
+
+		return nil
+	})
+
+	// Handle error
+	if err != nil {
+		return err
+	}
+
+	// Flush remaining events
+	if len(batch) > 0 {
+		return output.copyBatch(batch)
+	}
+
+	return nil
+}
+
+// Insert a batch of events in a single COPY transaction
+func (output *postgresOutput) copyBatch(batch [][]byte) error {
+	// Start transaction
+	tx, err := output.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Prepare copy statement
+	stmt, err := tx.Prepare(pq.CopyIn(output.table, "uuid", "published", "event_type", "actor", "event"))
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	// Add events to copy
+	for _, event := range batch {
+		fields, published, err := parseEventFields(event)
+		if err != nil {
+			_ = tx.Rollback()
+			return errors.New(fmt.Sprintf("Error parsing event: %v", err))
+		}
+
+		if _, err := stmt.Exec(fields.Uuid, published, fields.EventType, fields.Actor.AlternateId, string(event)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	// Flush copy
+	if _, err := stmt.Exec(); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := stmt.Close(); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
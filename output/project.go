@@ -0,0 +1,97 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Tree of the dotted field paths kept by a projection
+// A nil subtree keeps the whole field.
+type projection map[string]projection
+
+// Build a projection from dotted field paths such as actor.alternateId
+func newProjection(paths []string) projection {
+	root := make(projection)
+	for _, path := range paths {
+		node := root
+		keys := strings.Split(path, ".")
+		for i, key := range keys {
+			sub, exists := node[key]
+
+			// The whole field is already kept
+			if exists && sub == nil {
+				break
+			}
+
+			if i == len(keys)-1 {
+				node[key] = nil
+				break
+			}
+
+			if !exists {
+				sub = make(projection)
+				node[key] = sub
+			}
+			node = sub
+		}
+	}
+
+	return root
+}
+
+// Validate dotted field paths
+func validateProjection(paths []string) error {
+	for _, path := range paths {
+		for _, key := range strings.Split(path, ".") {
+			if key == "" {
+				return errors.New(fmt.Sprintf("invalid field path %s", path))
+			}
+		}
+	}
+
+	return nil
+}
+
+// Keep only the fields of the projection in an event
+func (p projection) apply(event []byte) ([]byte, error) {
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(event))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(p.project(decoded))
+}
+
+// Project a value, applying the projection to every element of arrays
+func (p projection) project(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		projected := make(map[string]interface{})
+		for key, sub := range p {
+			child, ok := v[key]
+			if !ok {
+				continue
+			}
+
+			if sub == nil {
+				projected[key] = child
+			} else {
+				projected[key] = sub.project(child)
+			}
+		}
+		return projected
+	case []interface{}:
+		projected := make([]interface{}, len(v))
+		for i, element := range v {
+			projected[i] = p.project(element)
+		}
+		return projected
+	default:
+		return v
+	}
+}
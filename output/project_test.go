@@ -0,0 +1,79 @@
+package output
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProjectionApply(t *testing.T) {
+	event := `{
+		"uuid": "1",
+		"eventType": "user.session.start",
+		"actor": {"id": "00u1", "alternateId": "jane.doe@example.com", "detailEntry": null},
+		"target": [{"id": "0oa1", "type": "AppInstance"}, {"id": "00u2", "type": "User", "detailEntry": {"a": 1}}],
+		"debugContext": {"debugData": {}},
+		"client": {"zone": []}
+	}`
+
+	tests := []struct {
+		name     string
+		paths    []string
+		expected string
+	}{
+		{"top level fields", []string{"uuid", "eventType"}, `{"uuid": "1", "eventType": "user.session.start"}`},
+		{"nested field", []string{"actor.alternateId"}, `{"actor": {"alternateId": "jane.doe@example.com"}}`},
+		{"whole object", []string{"actor"}, `{"actor": {"id": "00u1", "alternateId": "jane.doe@example.com", "detailEntry": null}}`},
+		{"whole object wins over its fields", []string{"actor.id", "actor"}, `{"actor": {"id": "00u1", "alternateId": "jane.doe@example.com", "detailEntry": null}}`},
+		{"field of every array element", []string{"target.id"}, `{"target": [{"id": "0oa1"}, {"id": "00u2"}]}`},
+		{"missing fields of array elements", []string{"target.detailEntry"}, `{"target": [{}, {"detailEntry": {"a": 1}}]}`},
+		{"missing field", []string{"outcome.result"}, `{}`},
+		{"empty object", []string{"debugContext.debugData"}, `{"debugContext": {"debugData": {}}}`},
+		{"field of an empty object", []string{"debugContext.debugData.risk"}, `{"debugContext": {"debugData": {}}}`},
+		{"empty array", []string{"client.zone"}, `{"client": {"zone": []}}`},
+		{"field of an empty array", []string{"client.zone.id"}, `{"client": {"zone": []}}`},
+		{"field of a null", []string{"actor.detailEntry.id"}, `{"actor": {"detailEntry": null}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			projected, err := newProjection(test.paths).apply([]byte(event))
+			if err != nil {
+				t.Fatalf("unable to apply projection: %v", err)
+			}
+
+			assertJSONEqual(t, test.expected, projected)
+		})
+	}
+}
+
+func TestProjectionApplyEmptyEvent(t *testing.T) {
+	projected, err := newProjection([]string{"uuid"}).apply([]byte(`{}`))
+	if err != nil {
+		t.Fatalf("unable to apply projection: %v", err)
+	}
+
+	assertJSONEqual(t, `{}`, projected)
+}
+
+func TestProjectionApplyKeepsNumberPrecision(t *testing.T) {
+	projected, err := newProjection([]string{"securityContext.asNumber"}).apply([]byte(`{"securityContext": {"asNumber": 12345678901234567890, "asOrg": "acme"}}`))
+	if err != nil {
+		t.Fatalf("unable to apply projection: %v", err)
+	}
+
+	if expected := `{"securityContext":{"asNumber":12345678901234567890}}`; string(projected) != expected {
+		t.Errorf("expected %s, got %s", expected, projected)
+	}
+}
+
+func TestValidateProjection(t *testing.T) {
+	if err := validateProjection([]string{"uuid", "actor.alternateId"}); err != nil {
+		t.Errorf("expected paths to be valid: %v", err)
+	}
+
+	for _, path := range []string{"", "actor.", ".uuid", "actor..id"} {
+		if err := validateProjection([]string{path}); err == nil || !strings.Contains(err.Error(), "invalid field path") {
+			t.Errorf("expected %q to be invalid, got %v", path, err)
+		}
+	}
+}
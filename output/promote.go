@@ -0,0 +1,49 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/rfizzle/okta-collector/debugdata"
+)
+
+// Build a processor that promotes debugContext.debugData keys of events to top-level fields
+// Okta writes debug data as strings, so values are parsed into booleans and objects where possible.
+// Keys that would overwrite an existing top-level field aren't promoted.
+func newDebugDataPromotion(keys []string) processor {
+	return func(event []byte) ([]byte, error) {
+		var decoded map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(event))
+		decoder.UseNumber()
+		if err := decoder.Decode(&decoded); err != nil {
+			return nil, err
+		}
+
+		debugContext, _ := decoded["debugContext"].(map[string]interface{})
+		debugData, _ := debugContext["debugData"].(map[string]interface{})
+		if len(debugData) == 0 {
+			return event, nil
+		}
+
+		promoted := false
+		for _, key := range keys {
+			value, ok := debugData[key]
+			if _, exists := decoded[key]; !ok || exists {
+				continue
+			}
+
+			if s, ok := value.(string); ok {
+				decoded[key] = debugdata.ParseValue(s)
+			} else {
+				decoded[key] = value
+			}
+			promoted = true
+		}
+
+		if !promoted {
+			return event, nil
+		}
+
+		return json.Marshal(decoded)
+	}
+}
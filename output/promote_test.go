@@ -0,0 +1,76 @@
+package output
+
+import (
+	"testing"
+)
+
+func TestDebugDataPromotion(t *testing.T) {
+	promote := newDebugDataPromotion([]string{"risk", "threatSuspected", "requestUri", "uuid", "missing"})
+
+	tests := []struct {
+		name     string
+		event    string
+		expected string
+	}{
+		{
+			"parses promoted values",
+			`{"uuid": "1", "debugContext": {"debugData": {"risk": "{reasons=New Device, New IP, level=MEDIUM}", "threatSuspected": "false", "requestUri": "/idp/idx/identify", "behaviors": "{New IP=POSITIVE}"}}}`,
+			`{"uuid": "1", "risk": {"reasons": "New Device, New IP", "level": "MEDIUM"}, "threatSuspected": false, "requestUri": "/idp/idx/identify", "debugContext": {"debugData": {"risk": "{reasons=New Device, New IP, level=MEDIUM}", "threatSuspected": "false", "requestUri": "/idp/idx/identify", "behaviors": "{New IP=POSITIVE}"}}}`,
+		},
+		{
+			"keeps existing fields",
+			`{"uuid": "1", "debugContext": {"debugData": {"uuid": "2"}}}`,
+			`{"uuid": "1", "debugContext": {"debugData": {"uuid": "2"}}}`,
+		},
+		{
+			"keeps values that aren't strings",
+			`{"debugContext": {"debugData": {"risk": {"level": "LOW"}, "threatSuspected": true}}}`,
+			`{"risk": {"level": "LOW"}, "threatSuspected": true, "debugContext": {"debugData": {"risk": {"level": "LOW"}, "threatSuspected": true}}}`,
+		},
+		{
+			"keeps number precision",
+			`{"published": 12345678901234567890, "debugContext": {"debugData": {"threatSuspected": "true"}}}`,
+			`{"published": 12345678901234567890, "threatSuspected": true, "debugContext": {"debugData": {"threatSuspected": "true"}}}`,
+		},
+		{
+			"without debug data",
+			`{"uuid": "1", "debugContext": {}}`,
+			`{"uuid": "1", "debugContext": {}}`,
+		},
+		{
+			"with empty debug data",
+			`{"debugContext": {"debugData": {}}}`,
+			`{"debugContext": {"debugData": {}}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := promote([]byte(test.event))
+			if err != nil {
+				t.Fatalf("unable to promote debug data: %v", err)
+			}
+
+			assertJSONEqual(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDebugDataPromotionUnchangedEvent(t *testing.T) {
+	event := []byte(`{"uuid":"1","debugContext":{"debugData":{"behaviors":"{New IP=POSITIVE}"}}}`)
+
+	actual, err := newDebugDataPromotion([]string{"risk"})(event)
+	if err != nil {
+		t.Fatalf("unable to promote debug data: %v", err)
+	}
+
+	if string(actual) != string(event) {
+		t.Errorf("expected an event without promoted keys to be written as is, got %s", actual)
+	}
+}
+
+func TestDebugDataPromotionInvalidEvent(t *testing.T) {
+	if _, err := newDebugDataPromotion([]string{"risk"})([]byte(`{"uuid": `)); err == nil {
+		t.Error("expected an invalid event to fail")
+	}
+}
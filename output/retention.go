@@ -0,0 +1,180 @@
+package output
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Directory of the system temp directory holding a directory of temp files for every collector process
+const tempRootName = "okta-collector-temp"
+
+// Extension of dead-letter metadata files
+const deadLetterMetaExt = ".meta.json"
+
+// Serializes trimming of the dead-letter directory shared by every output
+var deadLetterMu sync.Mutex
+
+// A dead-lettered batch with its metadata
+type deadLetterFile struct {
+	path  string
+	name  string
+	bytes int64
+	time  time.Time
+}
+
+// Temp directory of this collector process, created when missing so a directory removed while idle is recreated
+// Temp files of the collector are only ever created in it, so cleaning up never touches files of other programs.
+func tempDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), tempRootName, strconv.Itoa(os.Getpid()))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// Create a temp file in the temp directory of the collector, like ioutil.TempFile
+func createTempFile(pattern string) (*os.File, error) {
+	dir, err := tempDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.TempFile(dir, pattern)
+}
+
+// Create a directory in the temp directory of the collector, like ioutil.TempDir
+func createTempDir(pattern string) (string, error) {
+	dir, err := tempDir()
+	if err != nil {
+		return "", err
+	}
+
+	return ioutil.TempDir(dir, pattern)
+}
+
+// Remove the temp directories of other collector processes that went unchanged for the temp max age, such as those
+// left behind by a crash
+// Only the temp root of the collector is cleaned, never spool directories, which are kept for their pending batches.
+func cleanTempDir() error {
+	maxAge := time.Duration(viper.GetInt("temp-max-age")) * time.Second
+	if maxAge <= 0 {
+		return nil
+	}
+
+	root := filepath.Join(os.TempDir(), tempRootName)
+	entries, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	own := strconv.Itoa(os.Getpid())
+	for _, entry := range entries {
+		path := filepath.Join(root, entry.Name())
+		if entry.Name() == own || time.Since(lastModified(path)) < maxAge {
+			continue
+		}
+
+		log.Printf("Removing expired temp directory %s\n", path)
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Latest modification time of a path or anything in it, since rewriting a file doesn't change its directory
+func lastModified(path string) time.Time {
+	var latest time.Time
+	_ = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+
+	return latest
+}
+
+// Drop the oldest dead-lettered batches of every output while they are older than the dead-letter max age or over
+// the dead-letter max bytes, keeping them in dry-run mode
+func trimDeadLetters(dir string) error {
+	maxAge := time.Duration(viper.GetInt("dead-letter-max-age")) * time.Second
+	maxBytes := viper.GetInt64("dead-letter-max-bytes")
+	if dir == "" || DryRun() || (maxAge <= 0 && maxBytes <= 0) {
+		return nil
+	}
+
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "*"+pendingBatchExt))
+	if err != nil {
+		return err
+	}
+
+	// Dead letters are named after when they failed, so they sort chronologically across outputs
+	var files []deadLetterFile
+	var total int64
+	for _, path := range paths {
+		if strings.HasSuffix(path, deadLetterMetaExt) {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		// Metadata is written when the batch failed
+		file := deadLetterFile{path: path, name: filepath.Base(path), bytes: info.Size(), time: info.ModTime()}
+		if metaInfo, err := os.Stat(file.metaPath()); err == nil {
+			file.bytes += metaInfo.Size()
+			file.time = metaInfo.ModTime()
+		}
+
+		files = append(files, file)
+		total += file.bytes
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+
+	for _, file := range files {
+		expired := maxAge > 0 && time.Since(file.time) >= maxAge
+		if !expired && (maxBytes <= 0 || total <= maxBytes) {
+			break
+		}
+
+		log.Printf("Removing expired dead-lettered batch %s\n", file.path)
+		if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if err := os.Remove(file.metaPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		total -= file.bytes
+	}
+
+	return nil
+}
+
+// Path of the metadata of a dead-lettered batch
+func (file deadLetterFile) metaPath() string {
+	return strings.TrimSuffix(file.path, pendingBatchExt) + deadLetterMetaExt
+}
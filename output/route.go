@@ -0,0 +1,93 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rfizzle/okta-collector/filter"
+	"github.com/rfizzle/okta-collector/match"
+	"strings"
+)
+
+// A route restricts the events delivered to an output by event type, severity and outcome
+type route struct {
+	eventTypes  []string
+	severities  []string
+	minSeverity string
+	outcomes    []string
+}
+
+// Fields of an event used for routing
+type routeFields struct {
+	EventType string `json:"eventType"`
+	Severity  string `json:"severity"`
+	Outcome   struct {
+		Result string `json:"result"`
+	} `json:"outcome"`
+}
+
+// Create the route of an output from its params
+// Returns nil when the output receives every event
+func newRoute(eventTypes []string, severities []string, minSeverity string, outcomes []string) *route {
+	if len(eventTypes) == 0 && len(severities) == 0 && minSeverity == "" && len(outcomes) == 0 {
+		return nil
+	}
+
+	r := &route{eventTypes: eventTypes, minSeverity: minSeverity}
+	for _, severity := range severities {
+		r.severities = append(r.severities, strings.ToUpper(severity))
+	}
+
+	for _, outcome := range outcomes {
+		r.outcomes = append(r.outcomes, strings.ToUpper(outcome))
+	}
+
+	return r
+}
+
+// Check that every event type pattern is valid
+func validateEventTypePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if !match.ValidPattern(pattern) {
+			return errors.New(fmt.Sprintf("invalid pattern %s", pattern))
+		}
+	}
+
+	return nil
+}
+
+// Check if an event is routed to the output
+func (r *route) matches(event []byte) (bool, error) {
+	var fields routeFields
+	if err := json.Unmarshal(event, &fields); err != nil {
+		return false, err
+	}
+
+	if len(r.eventTypes) > 0 && !match.Any(r.eventTypes, fields.EventType) {
+		return false, nil
+	}
+
+	if len(r.severities) > 0 && !contains(r.severities, fields.Severity) {
+		return false, nil
+	}
+
+	if r.minSeverity != "" && !filter.AtLeastSeverity(fields.Severity, r.minSeverity) {
+		return false, nil
+	}
+
+	if len(r.outcomes) > 0 && !contains(r.outcomes, fields.Outcome.Result) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Check if a slice contains a value
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package output
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/rfizzle/okta-collector/client"
+)
+
+// Columns of key System Log fields, with the raw event in its own column
+type eventRow struct {
+	Uuid             string `parquet:"name=uuid, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Published        int64  `parquet:"name=published, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	EventType        string `parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Version          string `parquet:"name=version, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Severity         string `parquet:"name=severity, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DisplayMessage   string `parquet:"name=display_message, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ActorId          string `parquet:"name=actor_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ActorType        string `parquet:"name=actor_type, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ActorAlternateId string `parquet:"name=actor_alternate_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ActorDisplayName string `parquet:"name=actor_display_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ClientIpAddress  string `parquet:"name=client_ip_address, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ClientUserAgent  string `parquet:"name=client_user_agent, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ClientCountry    string `parquet:"name=client_country, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ClientCity       string `parquet:"name=client_city, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OutcomeResult    string `parquet:"name=outcome_result, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OutcomeReason    string `parquet:"name=outcome_reason, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TransactionId    string `parquet:"name=transaction_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Raw              string `parquet:"name=raw, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// Convert a raw event to a row of columns
+func newEventRow(event []byte) (*eventRow, error) {
+	var response client.OktaResponse
+
+	// Convert from JSON
+	if err := json.Unmarshal(event, &response); err != nil {
+		return nil, err
+	}
+
+	// Parse published timestamp
+	published, err := time.Parse(time.RFC3339Nano, response.Published)
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventRow{
+		Uuid:             response.Uuid,
+		Published:        published.UnixNano() / int64(time.Millisecond),
+		EventType:        response.EventType,
+		Version:          response.Version,
+		Severity:         response.Severity,
+		DisplayMessage:   response.DisplayMessage,
+		ActorId:          response.Actor.Id,
+		ActorType:        response.Actor.Type,
+		ActorAlternateId: response.Actor.AlternateId,
+		ActorDisplayName: response.Actor.DisplayName,
+		ClientIpAddress:  response.Client.IpAddress,
+		ClientUserAgent:  response.Client.UserAgent.RawUserAgent,
+		ClientCountry:    response.Client.GeographicalContext.Country,
+		ClientCity:       response.Client.GeographicalContext.City,
+		OutcomeResult:    response.Outcome.Result,
+		OutcomeReason:    response.Outcome.Reason,
+		TransactionId:    response.Transaction.Id,
+		Raw:              string(event),
+	}, nil
+}
@@ -0,0 +1,249 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/rfizzle/okta-collector/ocsf"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+	"os"
+	"path"
+	"time"
+)
+
+// Amazon Security Lake output that writes OCSF parquet files to custom source locations
+type securityLakeOutput struct {
+	uploader      *s3manager.Uploader
+	bucket        string
+	region        string
+	accountId     string
+	sourceVersion string
+	sources       map[int32]string
+}
+
+// A partition of converted events destined for a single parquet file
+type securityLakePartition struct {
+	source   string
+	eventDay string
+}
+
+func securityLakeInitParams() {
+	flag.Bool("security-lake", false, "enable amazon security lake output")
+	flag.String("security-lake-bucket", "", "amazon security lake s3 bucket")
+	flag.String("security-lake-region", "", "amazon security lake region")
+	flag.String("security-lake-account-id", "", "aws account id of the security lake custom sources")
+	flag.String("security-lake-authentication-source", "okta-authentication", "security lake custom source for ocsf authentication events")
+	flag.String("security-lake-account-change-source", "okta-account-change", "security lake custom source for ocsf account change events")
+	flag.String("security-lake-group-management-source", "", "security lake custom source for ocsf group management events (default not written)")
+	flag.String("security-lake-source-version", "", "security lake custom source version path segment")
+	flag.String("security-lake-role-arn", "", "iam role arn to assume for writing to security lake")
+	flag.String("security-lake-external-id", "", "external id used when assuming the security lake role")
+	flag.String("security-lake-access-key-id", "", "aws access key id for writing to security lake")
+	flag.String("security-lake-secret-key", "", "aws secret key for writing to security lake")
+}
+
+func securityLakeEnabled() bool {
+	return viper.GetBool("security-lake")
+}
+
+func securityLakeValidateParams() error {
+	if viper.GetString("security-lake-bucket") == "" {
+		return errors.New("missing security lake bucket param (--security-lake-bucket)")
+	}
+
+	if viper.GetString("security-lake-region") == "" {
+		return errors.New("missing security lake region param (--security-lake-region)")
+	}
+
+	if viper.GetString("security-lake-account-id") == "" {
+		return errors.New("missing security lake account id param (--security-lake-account-id)")
+	}
+
+	if viper.GetString("security-lake-authentication-source") == "" {
+		return errors.New("missing security lake authentication source param (--security-lake-authentication-source)")
+	}
+
+	if viper.GetString("security-lake-account-change-source") == "" {
+		return errors.New("missing security lake account change source param (--security-lake-account-change-source)")
+	}
+
+	if (viper.GetString("security-lake-access-key-id") == "") != (viper.GetString("security-lake-secret-key") == "") {
+		return errors.New("security lake access key id and secret key params must be set together (--security-lake-access-key-id, --security-lake-secret-key)")
+	}
+
+	return nil
+}
+
+// Create the security lake output
+func newSecurityLakeOutput() (Output, error) {
+	// Setup AWS config
+	config := &aws.Config{
+		Region: aws.String(viper.GetString("security-lake-region")),
+	}
+
+	if viper.GetString("security-lake-access-key-id") != "" {
+		config.Credentials = credentials.NewStaticCredentials(viper.GetString("security-lake-access-key-id"), viper.GetString("security-lake-secret-key"), "")
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assume the security lake role
+	if viper.GetString("security-lake-role-arn") != "" {
+		roleCredentials := stscreds.NewCredentials(sess, viper.GetString("security-lake-role-arn"), func(provider *stscreds.AssumeRoleProvider) {
+			if viper.GetString("security-lake-external-id") != "" {
+				provider.ExternalID = aws.String(viper.GetString("security-lake-external-id"))
+			}
+		})
+
+		sess, err = session.NewSession(config.Copy().WithCredentials(roleCredentials))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &securityLakeOutput{
+		uploader:      s3manager.NewUploader(sess),
+		bucket:        viper.GetString("security-lake-bucket"),
+		region:        viper.GetString("security-lake-region"),
+		accountId:     viper.GetString("security-lake-account-id"),
+		sourceVersion: viper.GetString("security-lake-source-version"),
+		sources:       securityLakeSources(),
+	}, nil
+}
+
+// Custom sources of the OCSF classes written to security lake
+func securityLakeSources() map[int32]string {
+	sources := map[int32]string{
+		ocsf.AuthenticationClassUid: viper.GetString("security-lake-authentication-source"),
+		ocsf.AccountChangeClassUid:  viper.GetString("security-lake-account-change-source"),
+	}
+
+	// Group management events are only written when their source is configured
+	if viper.GetString("security-lake-group-management-source") != "" {
+		sources[ocsf.GroupManagementClassUid] = viper.GetString("security-lake-group-management-source")
+	}
+
+	return sources
+}
+
+func (output *securityLakeOutput) Name() string {
+	return "security-lake"
+}
+
+// Convert the batch at src to OCSF and upload a parquet file per source and event day
+func (output *securityLakeOutput) Write(src string, timestamp string) error {
+	partitions := make(map[securityLakePartition][]*ocsf.Event)
+
+	// Convert and partition events
+	err := readEvents(src, func(event []byte) error {
+		ocsfEvent, ok, err := ocsf.FromEvent(event)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error converting event to ocsf: %v", err))
+		}
+
+		// Skip events without a security lake class
+		if !ok {
+			return nil
+		}
+
+		source, ok := output.sources[ocsfEvent.ClassUid]
+		if !ok {
+			return nil
+		}
+
+		partition := securityLakePartition{
+			source:   source,
+			eventDay: time.Unix(0, ocsfEvent.Time*int64(time.Millisecond)).UTC().Format("20060102"),
+		}
+		partitions[partition] = append(partitions[partition], ocsfEvent)
+
+		return nil
+	})
+
+	// Handle error
+	if err != nil {
+		return err
+	}
+
+	// Upload partitions
+	for partition, events := range partitions {
+		if err := output.upload(partition, events, timestamp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write events to a parquet file and upload it to the partition location
+func (output *securityLakeOutput) upload(partition securityLakePartition, events []*ocsf.Event, timestamp string) error {
+	// Setup temp file
+	file, err := createTempFile("okta-security-lake-*.parquet")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	// Write parquet
+	if err := writeOcsfParquet(file, events); err != nil {
+		return err
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		return err
+	}
+
+	// Build the custom source object key
+	batchTime, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return err
+	}
+
+	key := path.Join(
+		"ext",
+		partition.source,
+		output.sourceVersion,
+		"region="+output.region,
+		"accountId="+output.accountId,
+		"eventDay="+partition.eventDay,
+		fmt.Sprintf("okta-%d.parquet", batchTime.UnixNano()),
+	)
+
+	// Upload
+	_, err = output.uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(output.bucket),
+		Key:    aws.String(key),
+		Body:   file,
+	})
+
+	return err
+}
+
+// Write OCSF events to a snappy compressed parquet file
+func writeOcsfParquet(file *os.File, events []*ocsf.Event) error {
+	parquetWriter, err := writer.NewParquetWriterFromWriter(file, new(ocsf.Event), 4)
+	if err != nil {
+		return err
+	}
+
+	parquetWriter.CompressionType = parquet.CompressionCodec_SNAPPY
+
+	for _, event := range events {
+		if err := parquetWriter.Write(*event); err != nil {
+			return err
+		}
+	}
+
+	return parquetWriter.WriteStop()
+}
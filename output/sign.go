@@ -0,0 +1,124 @@
+package output
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Signing algorithms of archived files
+const (
+	signingNone    = "none"
+	signingHmac    = "hmac-sha256"
+	signingEd25519 = "ed25519"
+)
+
+// Extension of detached signatures
+const signatureExt = ".sig"
+
+// Sign the content with the key in the key file
+type signer func(content []byte, keyFile string) ([]byte, error)
+
+// Supported signing algorithms
+var signers = map[string]signer{
+	signingHmac:    signHmac,
+	signingEd25519: signEd25519,
+}
+
+// Sign the file at src with the algorithm and key file
+// Returns the path of a temp file with the base64 encoded signature.
+// Keys are loaded for every file so key files can be rotated without a restart.
+func signFile(algorithm string, keyFile string, src string) (string, error) {
+	content, err := ioutil.ReadFile(src)
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := signers[algorithm](content, keyFile)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := createTempFile("okta-signature-*" + signatureExt)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := file.WriteString(base64.StdEncoding.EncodeToString(signature) + "\n"); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
+
+// Validate the signing algorithm and key file by signing an empty file
+func validateSigning(algorithm string, keyFile string) error {
+	sign, ok := signers[algorithm]
+	if !ok {
+		return errors.New(fmt.Sprintf("unsupported signing algorithm %s", algorithm))
+	}
+
+	if keyFile == "" {
+		return errors.New(fmt.Sprintf("missing %s signing key file", algorithm))
+	}
+
+	_, err := sign(nil, keyFile)
+	return err
+}
+
+// Sign with HMAC-SHA256, using the content of the key file as the secret
+func signHmac(content []byte, keyFile string) ([]byte, error) {
+	secret, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	secret = bytes.TrimSpace(secret)
+	if len(secret) == 0 {
+		return nil, errors.New(fmt.Sprintf("empty hmac secret in %s", keyFile))
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(content)
+	return mac.Sum(nil), nil
+}
+
+// Sign with the Ed25519 private key in the PKCS #8 PEM key file
+func signEd25519(content []byte, keyFile string) ([]byte, error) {
+	pemContent, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(pemContent)
+	if block == nil {
+		return nil, errors.New(fmt.Sprintf("no pem private key in %s", keyFile))
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("no ed25519 private key in %s", keyFile))
+	}
+
+	return ed25519.Sign(privateKey, content), nil
+}
@@ -0,0 +1,375 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/pool"
+	"github.com/rfizzle/okta-collector/quarantine"
+)
+
+// Failure modes of a sink
+const (
+	failureModeFatal      = "fatal"
+	failureModeDrop       = "drop"
+	failureModeDeadLetter = "dead-letter"
+	failureModeSpool      = "spool"
+)
+
+// Maximum time to wait between delivery retries
+const maxRetryBackoff = 32 * time.Second
+
+// Retry policy of a sink
+type retryPolicy struct {
+	retries        int
+	initialBackoff time.Duration
+}
+
+// A batch pending delivery to a sink
+type batch struct {
+	path      string
+	timestamp string
+}
+
+// Config of a sink, read from the params of its output
+type sinkConfig struct {
+	outputType    string
+	failureMode   string
+	retry         retryPolicy
+	deadLetterDir string
+	spoolDir      string
+	spoolMaxBytes int64
+	spoolMaxAge   time.Duration
+	route         *route
+	encoding      encoding
+	processors    []processor
+	expandTargets bool
+	alerts        bool
+	throttle      *throttle
+	limits        batchLimits
+	bufferSize    int
+	queueSize     int
+	workers       int
+}
+
+// An item of the event queue of a sink, either an event or the end of a poll
+type sinkItem struct {
+	event   []byte
+	shared  *pool.Shared
+	endPoll uint64
+	done    chan error
+}
+
+// A sink delivers batches to a single output from its own event queue and spool directory,
+// so a slow or failing output doesn't hold up collection or the other outputs
+type sink struct {
+	sinkConfig
+	output        Output
+	dir           string
+	sequence      uint64
+	items         chan sinkItem
+	notify        chan struct{}
+	slots         chan struct{}
+	mu            sync.Mutex
+	current       *openBatch
+	lastEndedPoll uint64
+	inflight      map[string]uint64
+}
+
+// Create a sink for the output and start delivering
+// Batches left in a persistent spool directory are delivered first
+func newSink(output Output, config sinkConfig) (*sink, error) {
+	// Setup spool directory
+	dir, err := setupSpoolDir(config.spoolDir, output.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sink{
+		sinkConfig: config,
+		output:     output,
+		dir:        dir,
+		items:      make(chan sinkItem, config.queueSize),
+		notify:     make(chan struct{}, 1),
+		slots:      make(chan struct{}, config.bufferSize),
+		inflight:   make(map[string]uint64),
+	}
+
+	// Continue sequence of spooled batches
+	if s.sequence, err = s.lastSequence(); err != nil {
+		return nil, err
+	}
+
+	go s.run()
+	go s.deliver()
+
+	if config.limits.maxAge > 0 {
+		go s.flushAged()
+	}
+
+	return s, nil
+}
+
+// Add an event to the sink if it is routed to it, processed for the output
+func (s *sink) write(event []byte) error {
+	if s.route != nil {
+		ok, err := s.route.matches(event)
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	// Quarantine events the output can't process rather than failing the batch
+	records := [][]byte{event}
+	if s.expandTargets {
+		expanded, err := expandTargets(event)
+		if err != nil {
+			quarantine.Event(event, s.output.Name()+" output", err)
+			return nil
+		}
+		records = expanded
+	}
+
+	for _, record := range records {
+		if err := s.writeRecord(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Process a record of an event for the output and add it to the open batch
+func (s *sink) writeRecord(record []byte) error {
+	for _, process := range s.processors {
+		processed, err := process(record)
+		if err != nil {
+			quarantine.Event(record, s.output.Name()+" output", err)
+			return nil
+		}
+		if processed == nil {
+			return nil
+		}
+		record = processed
+	}
+
+	return s.append(record)
+}
+
+// Make a closed batch pending delivery, tracking the first poll it has events of
+// Outside of spool mode, blocks while the sink has buffer size batches pending
+func (s *sink) enqueue(path string, firstPoll uint64) error {
+	if s.failureMode != failureModeSpool {
+		s.slots <- struct{}{}
+	}
+
+	// Make pending
+	s.sequence++
+	pendingPath := filepath.Join(s.dir, pendingBatchName(s.sequence, time.Now()))
+	if err := os.Rename(path, pendingPath); err != nil {
+		s.releaseSlot()
+		return err
+	}
+	s.inflight[pendingPath] = firstPoll
+
+	// Keep the spool within its bounds
+	if s.failureMode == failureModeSpool {
+		if err := s.trimSpool(); err != nil {
+			return err
+		}
+	}
+
+	// Wake deliverer
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Release the buffer slot of a delivered batch
+func (s *sink) releaseSlot() {
+	select {
+	case <-s.slots:
+	default:
+	}
+}
+
+// Process the event queue of the sink
+func (s *sink) run() {
+	for item := range s.items {
+		if item.done != nil {
+			item.done <- s.endPoll(item.endPoll)
+			continue
+		}
+
+		err := s.write(item.event)
+		item.shared.Release()
+		if err != nil {
+			s.handleError(err)
+		}
+	}
+}
+
+// Dispatch pending batches to the delivery workers of the sink in order
+func (s *sink) deliver() {
+	work := make(chan batch)
+	done := make(chan string, s.workers)
+	dispatched := make(map[string]bool)
+
+	// Start workers
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			for b := range work {
+				s.deliverBatch(b)
+				done <- b.path
+			}
+		}()
+	}
+
+	for {
+		batches, err := s.pending()
+		if err != nil {
+			s.handleError(err)
+		}
+
+		// Skip batches a worker is delivering
+		var undispatched []batch
+		for _, b := range batches {
+			if !dispatched[b.path] {
+				undispatched = append(undispatched, b)
+			}
+		}
+
+		// Wait for batches or workers
+		if len(undispatched) == 0 {
+			select {
+			case <-s.notify:
+			case path := <-done:
+				delete(dispatched, path)
+			}
+			continue
+		}
+
+		for _, b := range undispatched {
+			select {
+			case work <- b:
+				dispatched[b.path] = true
+			case path := <-done:
+				delete(dispatched, path)
+			}
+		}
+	}
+}
+
+// Deliver a batch, handling failure according to the failure mode of the sink
+func (s *sink) deliverBatch(b batch) {
+	// Keep within rate limits
+	if s.throttle != nil {
+		if err := s.throttle.wait(b.path); err != nil {
+			s.handleError(err)
+		}
+	}
+
+	attempts, err := s.writeWithRetry(b)
+
+	// Keep the batch spooled until the output recovers
+	if err != nil && s.failureMode == failureModeSpool {
+		log.Printf("Unable to write to %s output, spooling until it recovers: %v\n", s.output.Name(), err)
+		time.Sleep(spoolRetryInterval)
+		return
+	}
+
+	if err != nil {
+		s.handleFailure(b, err, attempts)
+	}
+
+	// Remove delivered batch
+	if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Unable to remove delivered batch for %s output: %v\n", s.output.Name(), err)
+	}
+	s.releaseSlot()
+	s.acknowledge(b)
+}
+
+// Stop tracking a batch that has been delivered or handled
+func (s *sink) acknowledge(b batch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inflight, b.path)
+}
+
+// Write a batch to the output, retrying with exponential backoff
+// Returns the number of attempts made
+func (s *sink) writeWithRetry(b batch) (int, error) {
+	backoff := s.retry.initialBackoff
+	attempts := 0
+	for {
+		attempts++
+		err := s.writeBatch(b)
+		if err == nil || attempts > s.retry.retries {
+			return attempts, err
+		}
+
+		log.Printf("Unable to write to %s output, retrying in %v: %v\n", s.output.Name(), backoff, err)
+		time.Sleep(backoff)
+
+		// Increase backoff
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// Write a batch to the output, encoding it first if the sink has an encoding
+func (s *sink) writeBatch(b batch) error {
+	if !s.encoding.enabled() {
+		return s.output.Write(b.path, b.timestamp)
+	}
+
+	// Encode batch
+	encodedPath, err := s.encoding.encode(b.path)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error encoding batch: %v", err))
+	}
+	defer os.Remove(encodedPath)
+
+	return s.output.Write(encodedPath, b.timestamp)
+}
+
+// Handle a batch that failed delivery according to the failure mode of the sink
+func (s *sink) handleFailure(b batch, err error, attempts int) {
+	if s.failureMode == failureModeDeadLetter {
+		path, dlqErr := deadLetter(s.deadLetterDir, s.output.Name(), b, err, attempts)
+		if dlqErr != nil {
+			log.Fatalf("Unable to write to %s output: %v (dead-letter failed: %v)", s.output.Name(), err, dlqErr)
+		}
+
+		log.Printf("Unable to write to %s output after %d attempts, dead-lettered batch to %s: %v\n", s.output.Name(), attempts, path, err)
+
+		// Keep the dead-letter directory within its bounds
+		if err := trimDeadLetters(s.deadLetterDir); err != nil {
+			log.Printf("Unable to trim dead-letter directory: %v\n", err)
+		}
+		return
+	}
+
+	s.handleError(err)
+}
+
+// Handle an error of the sink according to its failure mode
+func (s *sink) handleError(err error) {
+	if s.failureMode == failureModeFatal {
+		log.Fatalf("Unable to write to %s output: %v", s.output.Name(), err)
+	}
+
+	log.Printf("Unable to write to %s output, dropping batch: %v\n", s.output.Name(), err)
+}
@@ -0,0 +1,311 @@
+package output
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	_ "github.com/snowflakedb/gosnowflake"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Valid snowflake object names, optionally qualified with database and schema
+var snowflakeNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*){0,2}$`)
+
+// Snowflake output that uploads batches to a stage and loads them with Snowpipe or COPY
+type snowflakeOutput struct {
+	db         *sql.DB
+	stage      string
+	stagePath  string
+	table      string
+	pipe       string
+	account    string
+	user       string
+	privateKey *rsa.PrivateKey
+	httpClient *http.Client
+}
+
+func snowflakeInitParams() {
+	flag.Bool("snowflake", false, "enable snowflake output")
+	flag.String("snowflake-dsn", "", "snowflake connection string")
+	flag.String("snowflake-stage", "", "snowflake internal stage to upload batches to")
+	flag.String("snowflake-stage-path", "okta", "path inside the snowflake stage to upload batches to")
+	flag.String("snowflake-table", "OKTA_EVENTS", "snowflake table to copy batches into when no pipe is set")
+	flag.String("snowflake-pipe", "", "fully qualified snowpipe to notify of uploaded batches")
+	flag.String("snowflake-account", "", "snowflake account identifier for snowpipe authentication")
+	flag.String("snowflake-user", "", "snowflake user for snowpipe authentication")
+	flag.String("snowflake-private-key", "", "path to the snowflake user private key for snowpipe authentication")
+}
+
+func snowflakeEnabled() bool {
+	return viper.GetBool("snowflake")
+}
+
+func snowflakeValidateParams() error {
+	if viper.GetString("snowflake-dsn") == "" {
+		return errors.New("missing snowflake connection string param (--snowflake-dsn)")
+	}
+
+	if !snowflakeNameRegex.MatchString(viper.GetString("snowflake-stage")) {
+		return errors.New("missing or invalid snowflake stage param (--snowflake-stage)")
+	}
+
+	if strings.ContainsAny(viper.GetString("snowflake-stage-path"), "'\\ ") {
+		return errors.New("invalid snowflake stage path param (--snowflake-stage-path)")
+	}
+
+	// Snowpipe requires key pair authentication
+	if viper.GetString("snowflake-pipe") != "" {
+		if !snowflakeNameRegex.MatchString(viper.GetString("snowflake-pipe")) {
+			return errors.New("invalid snowflake pipe param (--snowflake-pipe)")
+		}
+
+		if viper.GetString("snowflake-account") == "" {
+			return errors.New("missing snowflake account param (--snowflake-account)")
+		}
+
+		if viper.GetString("snowflake-user") == "" {
+			return errors.New("missing snowflake user param (--snowflake-user)")
+		}
+
+		if viper.GetString("snowflake-private-key") == "" {
+			return errors.New("missing snowflake private key param (--snowflake-private-key)")
+		}
+
+		return nil
+	}
+
+	if !snowflakeNameRegex.MatchString(viper.GetString("snowflake-table")) {
+		return errors.New("missing or invalid snowflake table param (--snowflake-table)")
+	}
+
+	return nil
+}
+
+// Create the snowflake output, ensuring the table exists when loading with COPY
+func newSnowflakeOutput() (Output, error) {
+	// Open connection
+	db, err := sql.Open("snowflake", viper.GetString("snowflake-dsn"))
+	if err != nil {
+		return nil, err
+	}
+
+	output := &snowflakeOutput{
+		db:         db,
+		stage:      viper.GetString("snowflake-stage"),
+		stagePath:  strings.Trim(viper.GetString("snowflake-stage-path"), "/"),
+		table:      viper.GetString("snowflake-table"),
+		pipe:       viper.GetString("snowflake-pipe"),
+		account:    strings.ToUpper(viper.GetString("snowflake-account")),
+		user:       strings.ToUpper(viper.GetString("snowflake-user")),
+		httpClient: &http.Client{Timeout: time.Second * 30},
+	}
+
+	// Setup snowpipe authentication
+	if output.pipe != "" {
+		output.privateKey, err = snowflakeReadPrivateKey(viper.GetString("snowflake-private-key"))
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+
+		return output, nil
+	}
+
+	// Create table
+	_, err = db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		published TIMESTAMP_TZ,
+		event_type STRING,
+		actor STRING,
+		event VARIANT
+	)`, output.table))
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return output, nil
+}
+
+func (output *snowflakeOutput) Name() string {
+	return "snowflake"
+}
+
+// Upload the batch at src to the stage and load it
+func (output *snowflakeOutput) Write(src string, timestamp string) error {
+	// Name the batch after the collection window so staged files are unique
+	batchTime, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("okta-%d.json", batchTime.UnixNano())
+
+	// Copy batch to its staged name
+	tmpDir, err := createTempDir("okta-snowflake")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, name)
+	if err := copyFile(src, localPath); err != nil {
+		return err
+	}
+
+	// Upload compressed batch to the stage
+	putQuery := fmt.Sprintf("PUT 'file://%s' @%s/%s AUTO_COMPRESS=TRUE OVERWRITE=TRUE", filepath.ToSlash(localPath), output.stage, output.stagePath)
+	if _, err := output.db.Exec(putQuery); err != nil {
+		return errors.New(fmt.Sprintf("Error uploading to stage: %v", err))
+	}
+
+	stagedName := name + ".gz"
+
+	// Load with snowpipe
+	if output.pipe != "" {
+		return output.insertFiles(output.stagePath + "/" + stagedName)
+	}
+
+	// Load with COPY
+	copyQuery := fmt.Sprintf(`COPY INTO %s (event, published, event_type, actor)
+		FROM (SELECT $1, $1:published::TIMESTAMP_TZ, $1:eventType::STRING, $1:actor.alternateId::STRING FROM @%s/%s)
+		FILES = ('%s')
+		FILE_FORMAT = (TYPE = 'JSON')`, output.table, output.stage, output.stagePath, stagedName)
+	if _, err := output.db.Exec(copyQuery); err != nil {
+		return errors.New(fmt.Sprintf("Error copying into table: %v", err))
+	}
+
+	return nil
+}
+
+// Notify snowpipe of a staged file with the insertFiles REST endpoint
+func (output *snowflakeOutput) insertFiles(path string) error {
+	// Build body
+	body, err := json.Marshal(map[string]interface{}{
+		"files": []map[string]string{{"path": path}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Build token
+	token, err := output.snowpipeToken()
+	if err != nil {
+		return err
+	}
+
+	// Setup request
+	requestId := make([]byte, 16)
+	if _, err := rand.Read(requestId); err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("https://%s.snowflakecomputing.com/v1/data/pipes/%s/insertFiles?requestId=%s", strings.ToLower(output.account), output.pipe, hex.EncodeToString(requestId))
+	request, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("X-Snowflake-Authorization-Token-Type", "KEYPAIR_JWT")
+
+	// Conduct request
+	response, err := output.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		responseBody, _ := ioutil.ReadAll(response.Body)
+		return errors.New(fmt.Sprintf("snowpipe insertFiles response code: %v: %s", response.Status, responseBody))
+	}
+
+	return nil
+}
+
+// Build a key pair JWT for the snowpipe REST API
+func (output *snowflakeOutput) snowpipeToken() (string, error) {
+	// Fingerprint the public key
+	publicKey, err := x509.MarshalPKIXPublicKey(&output.privateKey.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	fingerprint := sha256.Sum256(publicKey)
+
+	// Build claims
+	now := time.Now()
+	subject := fmt.Sprintf("%s.%s", output.account, output.user)
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	claims := map[string]interface{}{
+		"iss": fmt.Sprintf("%s.SHA256:%s", subject, base64.StdEncoding.EncodeToString(fingerprint[:])),
+		"sub": subject,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Minute * 59).Unix(),
+	}
+
+	// Encode segments
+	headerJson, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	claimsJson, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(headerJson) + "." + base64.RawURLEncoding.EncodeToString(claimsJson)
+
+	// Sign
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, output.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Read an unencrypted PKCS8 or PKCS1 RSA private key
+func snowflakeReadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("snowflake private key is not PEM encoded")
+	}
+
+	// Try PKCS8
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("snowflake private key is not an RSA key")
+		}
+		return rsaKey, nil
+	}
+
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
@@ -0,0 +1,160 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time to wait before draining the spool of an unavailable output again
+const spoolRetryInterval = 30 * time.Second
+
+// Extensions of batch files in the spool directory of a sink
+const (
+	openBatchExt    = ".open"
+	pendingBatchExt = ".json"
+)
+
+// Setup the spool directory of a sink
+// The directory is persistent under spoolDir if set, otherwise temporary
+func setupSpoolDir(spoolDir string, name string) (string, error) {
+	if spoolDir == "" {
+		return ioutil.TempDir("", fmt.Sprintf("okta-collector-%s-", name))
+	}
+
+	dir := filepath.Join(spoolDir, name)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", err
+	}
+
+	// Remove batches that were still open when the collector stopped
+	openBatches, err := filepath.Glob(filepath.Join(dir, "*"+openBatchExt))
+	if err != nil {
+		return "", err
+	}
+
+	for _, path := range openBatches {
+		log.Printf("Removing incomplete batch %s\n", path)
+		if err := os.Remove(path); err != nil {
+			return "", err
+		}
+	}
+
+	return dir, nil
+}
+
+// Name of a pending batch file, ordered by sequence and carrying the batch timestamp
+func pendingBatchName(sequence uint64, timestamp time.Time) string {
+	return fmt.Sprintf("%020d-%d%s", sequence, timestamp.UnixNano(), pendingBatchExt)
+}
+
+// Parse the sequence and timestamp from the name of a pending batch file
+func parsePendingBatchName(name string) (uint64, string, error) {
+	parts := strings.SplitN(strings.TrimSuffix(name, pendingBatchExt), "-", 2)
+	if len(parts) != 2 {
+		return 0, "", errors.New(fmt.Sprintf("invalid batch name %s", name))
+	}
+
+	sequence, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return sequence, time.Unix(0, nanos).UTC().Format(time.RFC3339Nano), nil
+}
+
+// List the pending batches of a sink in delivery order
+func (s *sink) pending() ([]batch, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*"+pendingBatchExt))
+	if err != nil {
+		return nil, err
+	}
+
+	// Names sort by sequence
+	sort.Strings(paths)
+
+	var batches []batch
+	for _, path := range paths {
+		_, timestamp, err := parsePendingBatchName(filepath.Base(path))
+		if err != nil {
+			log.Printf("Skipping unknown file in %s output spool: %v\n", s.output.Name(), err)
+			continue
+		}
+
+		batches = append(batches, batch{path: path, timestamp: timestamp})
+	}
+
+	return batches, nil
+}
+
+// Highest sequence of the pending batches of a sink
+func (s *sink) lastSequence() (uint64, error) {
+	batches, err := s.pending()
+	if err != nil || len(batches) == 0 {
+		return 0, err
+	}
+
+	sequence, _, err := parsePendingBatchName(filepath.Base(batches[len(batches)-1].path))
+	return sequence, err
+}
+
+// Drop the oldest pending batches while the spool is over its max bytes or they are older than its max age
+func (s *sink) trimSpool() error {
+	if s.spoolMaxBytes <= 0 && s.spoolMaxAge <= 0 {
+		return nil
+	}
+
+	batches, err := s.pending()
+	if err != nil {
+		return err
+	}
+
+	// Total spool size
+	sizes := make([]int64, len(batches))
+	var total int64
+	for i, b := range batches {
+		info, err := os.Stat(b.path)
+		if err != nil {
+			continue
+		}
+		sizes[i] = info.Size()
+		total += sizes[i]
+	}
+
+	// Drop oldest, keeping the newest batch
+	for i := 0; i < len(batches)-1; i++ {
+		if s.spoolMaxBytes > 0 && total > s.spoolMaxBytes {
+			log.Printf("Spool of %s output is full, dropping oldest batch %s\n", s.output.Name(), batches[i].path)
+		} else if s.spoolMaxAge > 0 && s.expired(batches[i]) {
+			log.Printf("Dropping expired batch %s from spool of %s output\n", batches[i].path, s.output.Name())
+		} else {
+			break
+		}
+
+		if err := os.Remove(batches[i].path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(s.inflight, batches[i].path)
+		total -= sizes[i]
+	}
+
+	return nil
+}
+
+// Check if a pending batch is older than the spool max age
+func (s *sink) expired(b batch) bool {
+	timestamp, err := time.Parse(time.RFC3339Nano, b.timestamp)
+	return err == nil && time.Since(timestamp) >= s.spoolMaxAge
+}
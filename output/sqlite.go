@@ -0,0 +1,196 @@
+package output
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	_ "modernc.org/sqlite"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Layouts used to name rolling sqlite database files
+var sqliteRollLayouts = map[string]string{
+	"hourly":  "2006-01-02T15",
+	"daily":   "2006-01-02",
+	"monthly": "2006-01",
+}
+
+// SQLite output that archives events into rolling local database files
+type sqliteOutput struct {
+	dir      string
+	layout   string
+	maxFiles int
+	current  string
+	db       *sql.DB
+}
+
+func sqliteInitParams() {
+	flag.Bool("sqlite", false, "enable sqlite archive output")
+	flag.String("sqlite-dir", "", "directory to write sqlite archive files to")
+	flag.String("sqlite-roll", "daily", "sqlite archive file roll period (hourly, daily, monthly)")
+	flag.Int("sqlite-max-files", 0, "maximum number of sqlite archive files to retain (0 for unlimited)")
+}
+
+func sqliteEnabled() bool {
+	return viper.GetBool("sqlite")
+}
+
+func sqliteValidateParams() error {
+	if viper.GetString("sqlite-dir") == "" {
+		return errors.New("missing sqlite directory param (--sqlite-dir)")
+	}
+
+	if _, ok := sqliteRollLayouts[viper.GetString("sqlite-roll")]; !ok {
+		return errors.New("invalid sqlite roll period param (--sqlite-roll)")
+	}
+
+	if viper.GetInt("sqlite-max-files") < 0 {
+		return errors.New("invalid sqlite max files param (--sqlite-max-files)")
+	}
+
+	return nil
+}
+
+// Create the sqlite output and its archive directory
+func newSqliteOutput() (Output, error) {
+	if err := os.MkdirAll(viper.GetString("sqlite-dir"), 0750); err != nil {
+		return nil, err
+	}
+
+	return &sqliteOutput{
+		dir:      viper.GetString("sqlite-dir"),
+		layout:   sqliteRollLayouts[viper.GetString("sqlite-roll")],
+		maxFiles: viper.GetInt("sqlite-max-files"),
+	}, nil
+}
+
+func (output *sqliteOutput) Name() string {
+	return "sqlite"
+}
+
+// Write the batch at src to the archive file for the batch timestamp
+func (output *sqliteOutput) Write(src string, timestamp string) error {
+	// Parse batch timestamp
+	batchTime, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return err
+	}
+
+	// Open the archive file for the roll period
+	if err := output.open(batchTime); err != nil {
+		return err
+	}
+
+	// Start transaction
+	tx, err := output.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Prepare insert
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO events (uuid, published, event_type, severity, actor, event) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	err = readEvents(src, func(event []byte) error {
+		fields, published, err := parseEventFields(event)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error parsing event: %v", err))
+		}
+
+		_, err = stmt.Exec(fields.Uuid, published.UTC().Format(time.RFC3339Nano), fields.EventType, fields.Severity, fields.Actor.AlternateId, string(event))
+		return err
+	})
+
+	// Handle error
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Open the archive file for the roll period of t, closing the previous one
+func (output *sqliteOutput) open(t time.Time) error {
+	path := filepath.Join(output.dir, fmt.Sprintf("okta-%s.db", t.UTC().Format(output.layout)))
+
+	// Already open
+	if output.db != nil && output.current == path {
+		return nil
+	}
+
+	// Close previous file
+	if output.db != nil {
+		_ = output.db.Close()
+		output.db = nil
+	}
+
+	// Open database
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+
+	// Create table
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS events (
+			uuid TEXT NOT NULL PRIMARY KEY,
+			published TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			severity TEXT,
+			actor TEXT,
+			event TEXT NOT NULL
+		)`,
+		"CREATE INDEX IF NOT EXISTS events_published_idx ON events (published)",
+		"CREATE INDEX IF NOT EXISTS events_event_type_idx ON events (event_type)",
+		"CREATE INDEX IF NOT EXISTS events_actor_idx ON events (actor)",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			_ = db.Close()
+			return err
+		}
+	}
+
+	output.db = db
+	output.current = path
+
+	// Remove expired archive files
+	return output.prune()
+}
+
+// Remove the oldest archive files beyond the max files to retain
+func (output *sqliteOutput) prune() error {
+	if output.maxFiles == 0 {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(output.dir, "okta-*.db"))
+	if err != nil {
+		return err
+	}
+
+	// File names sort chronologically
+	sort.Strings(files)
+
+	for len(files) > output.maxFiles {
+		if files[0] != output.current {
+			if err := os.Remove(files[0]); err != nil {
+				return err
+			}
+		}
+		files = files[1:]
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package output
+
+import (
+	"encoding/json"
+)
+
+// Expand an event into one record per target, each with the fields of the event and its target as an object
+// Events without targets are kept as a single record.
+func expandTargets(event []byte) ([][]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(event, &fields); err != nil {
+		return nil, err
+	}
+
+	raw, ok := fields["target"]
+	if !ok || string(raw) == "null" {
+		return [][]byte{event}, nil
+	}
+
+	var targets []json.RawMessage
+	if err := json.Unmarshal(raw, &targets); err != nil {
+		return nil, err
+	}
+
+	if len(targets) == 0 {
+		return [][]byte{event}, nil
+	}
+
+	records := make([][]byte, 0, len(targets))
+	for _, target := range targets {
+		fields["target"] = target
+		record, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,79 @@
+package output
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Throttle limits the rate batches are delivered to an output in events and bytes per second.
+// Batches are delivered whole, so the rate is kept on average over consecutive batches.
+type throttle struct {
+	eventsPerSecond int
+	bytesPerSecond  int64
+	mu              sync.Mutex
+	next            time.Time
+}
+
+// Create a throttle, returning nil when no rate is limited
+func newThrottle(eventsPerSecond int, bytesPerSecond int64) *throttle {
+	if eventsPerSecond <= 0 && bytesPerSecond <= 0 {
+		return nil
+	}
+
+	return &throttle{
+		eventsPerSecond: eventsPerSecond,
+		bytesPerSecond:  bytesPerSecond,
+	}
+}
+
+// Wait until the batch at path can be delivered within the rate limits
+func (t *throttle) wait(path string) error {
+	// Measure batch
+	var events int
+	var bytes int64
+
+	if t.eventsPerSecond > 0 {
+		err := readEvents(path, func(event []byte) error {
+			events++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if t.bytesPerSecond > 0 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		bytes = info.Size()
+	}
+
+	// Time the batch takes at the limited rates
+	var cost time.Duration
+	if t.eventsPerSecond > 0 {
+		cost = time.Duration(events) * time.Second / time.Duration(t.eventsPerSecond)
+	}
+
+	if t.bytesPerSecond > 0 {
+		if bytesCost := time.Duration(float64(bytes) / float64(t.bytesPerSecond) * float64(time.Second)); bytesCost > cost {
+			cost = bytesCost
+		}
+	}
+
+	// Wait for the previous batches to have taken their time
+	t.mu.Lock()
+	now := time.Now()
+	start := t.next
+	if start.Before(now) {
+		start = now
+	}
+	t.next = start.Add(cost)
+	t.mu.Unlock()
+
+	time.Sleep(time.Until(start))
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Formats of event timestamps
+const (
+	timestampRfc3339     = "rfc3339"
+	timestampEpochMillis = "epoch-millis"
+)
+
+// Top-level timestamp fields of events that are formatted
+var timestampFields = []string{"published", "@timestamp"}
+
+// Validate a timestamp format, either a named format or a Go time layout
+// Layouts must have at least one element of the reference time, so misspelled named formats aren't taken as layouts.
+func validateTimestampFormat(format string) error {
+	if format == "" {
+		return errors.New("empty timestamp format")
+	}
+
+	if format == timestampRfc3339 || format == timestampEpochMillis {
+		return nil
+	}
+
+	if time.Unix(0, 0).UTC().Format(format) == format {
+		return errors.New(fmt.Sprintf("unknown timestamp format %s, expected %s, %s or a Go time layout", format, timestampRfc3339, timestampEpochMillis))
+	}
+
+	return nil
+}
+
+// Build a processor that formats the timestamps of events and adds an @timestamp field from published
+// Timestamps are formatted from RFC 3339. Values in other formats are left as is.
+func newTimestampProcessor(format string, addTimestamp bool) processor {
+	return func(event []byte) ([]byte, error) {
+		var decoded map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(event))
+		decoder.UseNumber()
+		if err := decoder.Decode(&decoded); err != nil {
+			return nil, err
+		}
+
+		if _, ok := decoded["@timestamp"]; addTimestamp && !ok {
+			if published, ok := decoded["published"]; ok {
+				decoded["@timestamp"] = published
+			}
+		}
+
+		for _, field := range timestampFields {
+			if value, ok := decoded[field]; ok {
+				decoded[field] = formatTimestamp(value, format)
+			}
+		}
+
+		// Receive time added by the received-time enrichment
+		if collector, ok := decoded["collector"].(map[string]interface{}); ok {
+			if value, ok := collector["receivedAt"]; ok {
+				collector["receivedAt"] = formatTimestamp(value, format)
+			}
+		}
+
+		return json.Marshal(decoded)
+	}
+}
+
+// Format an RFC 3339 timestamp value
+func formatTimestamp(value interface{}, format string) interface{} {
+	s, ok := value.(string)
+	if !ok || format == timestampRfc3339 {
+		return value
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return value
+	}
+
+	if format == timestampEpochMillis {
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+
+	return t.Format(format)
+}
@@ -0,0 +1,132 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/rfizzle/okta-collector/cim"
+	"github.com/rfizzle/okta-collector/ecs"
+	"github.com/rfizzle/okta-collector/ocsf"
+	"github.com/spf13/viper"
+)
+
+// Processes an event before it is batched for an output
+// Returns a nil event to drop events it can't represent.
+type processor func(event []byte) ([]byte, error)
+
+// Transforms of events before they are batched for an output
+const transformNone = "none"
+
+// Supported transforms by name
+var transforms = map[string]processor{
+	"ecs":  transformEcs,
+	"ocsf": transformOcsf,
+	"cim":  cim.AddFields,
+}
+
+// Output types that parse System Log fields from events, so can't receive processed events
+var rawEventOutputTypes = map[string]bool{
+	"archive":       true,
+	"postgres":      true,
+	"sqlite":        true,
+	"bigquery":      true,
+	"snowflake":     true,
+	"adx":           true,
+	"security-lake": true,
+}
+
+// Build the processors of an output from its params, in the order they apply
+func processorsFromParams(name string) []processor {
+	var processors []processor
+
+	if keys := viper.GetStringSlice(name + "-promote-debug-data"); len(keys) > 0 {
+		processors = append(processors, newDebugDataPromotion(keys))
+	}
+
+	if transform, ok := transforms[viper.GetString(name+"-transform")]; ok {
+		processors = append(processors, transform)
+	}
+
+	for _, expression := range viper.GetStringSlice(name + "-jq") {
+		if code, err := compileJq(expression); err == nil {
+			processors = append(processors, newJqProcessor(code))
+		}
+	}
+
+	if format := viper.GetString(name + "-timestamp-format"); format != timestampRfc3339 || viper.GetBool(name+"-add-timestamp") {
+		processors = append(processors, newTimestampProcessor(format, viper.GetBool(name+"-add-timestamp")))
+	}
+
+	if fields := viper.GetStringSlice(name + "-fields"); len(fields) > 0 {
+		processors = append(processors, newProjection(fields).apply)
+	}
+
+	if viper.GetBool(name + "-flatten") {
+		processors = append(processors, flattenEvent)
+	}
+
+	return processors
+}
+
+// Validate the processing params of an output
+func validateProcessingParams(name string) error {
+	transform := viper.GetString(name + "-transform")
+	if _, ok := transforms[transform]; !ok && transform != "" && transform != transformNone {
+		return errors.New(fmt.Sprintf("invalid %s transform param (--%s-transform): unsupported transform %s", name, name, transform))
+	}
+
+	for _, expression := range viper.GetStringSlice(name + "-jq") {
+		if _, err := compileJq(expression); err != nil {
+			return errors.New(fmt.Sprintf("invalid %s jq param (--%s-jq): %v", name, name, err))
+		}
+	}
+
+	if err := validateTimestampFormat(viper.GetString(name + "-timestamp-format")); err != nil {
+		return errors.New(fmt.Sprintf("invalid %s timestamp format param (--%s-timestamp-format): %v", name, name, err))
+	}
+
+	if err := validateProjection(viper.GetStringSlice(name + "-fields")); err != nil {
+		return errors.New(fmt.Sprintf("invalid %s fields param (--%s-fields): %v", name, name, err))
+	}
+
+	// Transforms read the targets of events as an array, so they can't follow expansion
+	expand := viper.GetBool(name + "-expand-targets")
+	if expand && transform != "" && transform != transformNone {
+		return errors.New(fmt.Sprintf("%s expand targets param (--%s-expand-targets) can't be combined with the %s transform param (--%s-transform)", name, name, name, name))
+	}
+
+	if len(processorsFromParams(name)) == 0 && !expand {
+		return nil
+	}
+
+	if rawEventOutputTypes[name] {
+		return errors.New(fmt.Sprintf("%s output does not support processing events (--%s-promote-debug-data, --%s-transform, --%s-jq, --%s-timestamp-format, --%s-fields, --%s-flatten, --%s-expand-targets) as it reads System Log fields from events", name, name, name, name, name, name, name, name))
+	}
+
+	if format := viper.GetString(name + "-format"); format == formatParquet || format == formatAvro {
+		return errors.New(fmt.Sprintf("%s processing params (--%s-promote-debug-data, --%s-transform, --%s-jq, --%s-timestamp-format, --%s-fields, --%s-flatten, --%s-expand-targets) can't be combined with the %s format", name, name, name, name, name, name, name, name, format))
+	}
+
+	return nil
+}
+
+// Map the event to Elastic Common Schema, keeping the original under okta
+func transformEcs(event []byte) ([]byte, error) {
+	ecsEvent, err := ecs.FromEvent(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ecsEvent)
+}
+
+// Map the event to an OCSF class, dropping events of types outside the supported classes
+func transformOcsf(event []byte) ([]byte, error) {
+	ocsfEvent, ok, err := ocsf.FromEvent(event)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return json.Marshal(ocsfEvent)
+}
@@ -0,0 +1,107 @@
+package output
+
+import (
+	"errors"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"net"
+	"time"
+)
+
+// Unix socket output that writes newline delimited events to a local listener
+type unixSocketOutput struct {
+	network string
+	path    string
+	timeout time.Duration
+	conn    net.Conn
+}
+
+func unixSocketInitParams() {
+	flag.Bool("unix-socket", false, "enable unix domain socket output")
+	flag.String("unix-socket-path", "", "path of the unix domain socket to write to")
+	flag.String("unix-socket-type", "stream", "unix domain socket type (stream, datagram)")
+	flag.Int("unix-socket-timeout", 10, "time in seconds to wait on unix domain socket connects and writes")
+}
+
+func unixSocketEnabled() bool {
+	return viper.GetBool("unix-socket")
+}
+
+func unixSocketValidateParams() error {
+	if viper.GetString("unix-socket-path") == "" {
+		return errors.New("missing unix socket path param (--unix-socket-path)")
+	}
+
+	if viper.GetString("unix-socket-type") != "stream" && viper.GetString("unix-socket-type") != "datagram" {
+		return errors.New("invalid unix socket type param (--unix-socket-type)")
+	}
+
+	if viper.GetInt("unix-socket-timeout") < 1 {
+		return errors.New("invalid unix socket timeout param (--unix-socket-timeout)")
+	}
+
+	return nil
+}
+
+// Create the unix socket output
+// The connection is made on the first write so the listener doesn't need to be up at startup
+func newUnixSocketOutput() (Output, error) {
+	network := "unix"
+	if viper.GetString("unix-socket-type") == "datagram" {
+		network = "unixgram"
+	}
+
+	return &unixSocketOutput{
+		network: network,
+		path:    viper.GetString("unix-socket-path"),
+		timeout: time.Duration(viper.GetInt("unix-socket-timeout")) * time.Second,
+	}, nil
+}
+
+func (output *unixSocketOutput) Name() string {
+	return "unix-socket"
+}
+
+// Write each event of the batch at src to the socket as a line
+func (output *unixSocketOutput) Write(src string, timestamp string) error {
+	return readEvents(src, func(event []byte) error {
+		line := append(event, '\n')
+
+		// Retry once on a fresh connection if the listener went away
+		err := output.writeLine(line)
+		if err != nil {
+			output.close()
+			err = output.writeLine(line)
+		}
+
+		return err
+	})
+}
+
+// Write a line to the socket, connecting if needed
+func (output *unixSocketOutput) writeLine(line []byte) error {
+	// Connect
+	if output.conn == nil {
+		conn, err := net.DialTimeout(output.network, output.path, output.timeout)
+		if err != nil {
+			return err
+		}
+		output.conn = conn
+	}
+
+	// Write
+	if err := output.conn.SetWriteDeadline(time.Now().Add(output.timeout)); err != nil {
+		return err
+	}
+
+	_, err := output.conn.Write(line)
+	return err
+}
+
+// Close the current connection
+func (output *unixSocketOutput) close() {
+	if output.conn != nil {
+		_ = output.conn.Close()
+		output.conn = nil
+	}
+}
@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	goplugin "github.com/hashicorp/go-plugin"
+	"os/exec"
+)
+
+// Client of a running output plugin executable
+type Client struct {
+	Output
+	client *goplugin.Client
+}
+
+// Start the plugin executable at path and configure its output
+func Start(path string, config map[string]string) (*Client, error) {
+	client := goplugin.NewClient(&goplugin.ClientConfig{
+		HandshakeConfig:  Handshake,
+		Plugins:          pluginMap,
+		Cmd:              exec.Command(path),
+		AllowedProtocols: []goplugin.Protocol{goplugin.ProtocolGRPC, goplugin.ProtocolNetRPC},
+		Managed:          true,
+	})
+
+	// Connect
+	rpcClient, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return nil, err
+	}
+
+	// Dispense output
+	raw, err := rpcClient.Dispense(outputPluginName)
+	if err != nil {
+		client.Kill()
+		return nil, err
+	}
+
+	output, ok := raw.(Output)
+	if !ok {
+		client.Kill()
+		return nil, errors.New(fmt.Sprintf("plugin %s does not serve an output", path))
+	}
+
+	// Configure output
+	if err := output.Configure(config); err != nil {
+		client.Kill()
+		return nil, err
+	}
+
+	return &Client{Output: output, client: client}, nil
+}
+
+// Stop the plugin executable
+func (c *Client) Kill() {
+	c.client.Kill()
+}
+
+// Stop every plugin executable that was started
+func StopAll() {
+	goplugin.CleanupClients()
+}
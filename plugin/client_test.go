@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Output of the test plugin, appending batches to the file of its path config
+type fileOutput struct {
+	path string
+}
+
+func (o *fileOutput) Configure(config map[string]string) error {
+	o.path = config["path"]
+	return nil
+}
+
+func (o *fileOutput) Write(src string, timestamp string) error {
+	content, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(o.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(content)
+	return err
+}
+
+// Serve the test plugin when the test binary is started as a plugin executable
+func TestMain(m *testing.M) {
+	if os.Getenv(Handshake.MagicCookieKey) == Handshake.MagicCookieValue {
+		Serve(&fileOutput{})
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestStartWritesAndStopAll(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plugin.log")
+
+	client, err := Start(os.Args[0], map[string]string{"path": path})
+	if err != nil {
+		t.Fatalf("unable to start plugin: %v", err)
+	}
+	defer client.Kill()
+
+	src := filepath.Join(dir, "batch")
+	if err := ioutil.WriteFile(src, []byte("{\"uuid\":\"1\"}\n"), 0600); err != nil {
+		t.Fatalf("unable to write batch: %v", err)
+	}
+
+	if err := client.Write(src, "2023-06-01T12:00:00Z"); err != nil {
+		t.Fatalf("unable to write batch: %v", err)
+	}
+
+	if content, err := ioutil.ReadFile(path); err != nil {
+		t.Fatalf("unable to read plugin output: %v", err)
+	} else if string(content) != "{\"uuid\":\"1\"}\n" {
+		t.Errorf("unexpected plugin output %q", content)
+	}
+
+	StopAll()
+	if !client.client.Exited() {
+		t.Error("expected the plugin executable to be stopped")
+	}
+}
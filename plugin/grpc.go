@@ -0,0 +1,103 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Full name of the output service, as defined in plugin.proto
+const outputServiceName = "okta_collector.plugin.v1.Output"
+
+// Client side of an Output plugin over gRPC
+type outputGRPCClient struct {
+	conn *grpc.ClientConn
+}
+
+func (c *outputGRPCClient) Configure(config map[string]string) error {
+	fields := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		fields[key] = value
+	}
+
+	return c.call("Configure", fields)
+}
+
+func (c *outputGRPCClient) Write(src string, timestamp string) error {
+	return c.call("Write", map[string]interface{}{"src": src, "timestamp": timestamp})
+}
+
+// Call a method of the output service with the fields as its request
+func (c *outputGRPCClient) call(method string, fields map[string]interface{}) error {
+	request, err := structpb.NewStruct(fields)
+	if err != nil {
+		return err
+	}
+
+	return c.conn.Invoke(context.Background(), "/"+outputServiceName+"/"+method, request, new(emptypb.Empty))
+}
+
+// Server side of an Output plugin over gRPC
+type outputGRPCServer struct {
+	Impl Output
+}
+
+// Handler of the output service, checked by the server on registration
+type outputServer interface {
+	handle(method string, fields map[string]interface{}) error
+}
+
+// Description of the output service, registered without generated code since its messages are well-known types
+var outputServiceDesc = grpc.ServiceDesc{
+	ServiceName: outputServiceName,
+	HandlerType: (*outputServer)(nil),
+	Methods: []grpc.MethodDesc{
+		outputMethod("Configure"),
+		outputMethod("Write"),
+	},
+	Metadata: "plugin.proto",
+}
+
+// Describe a method of the output service taking a struct and answering an empty message
+func outputMethod(name string) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(structpb.Struct)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				if err := srv.(outputServer).handle(name, req.(*structpb.Struct).AsMap()); err != nil {
+					return nil, err
+				}
+				return new(emptypb.Empty), nil
+			}
+			if interceptor == nil {
+				return handler(ctx, in)
+			}
+
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + outputServiceName + "/" + name}
+			return interceptor(ctx, in, info, handler)
+		},
+	}
+}
+
+func (s *outputGRPCServer) handle(method string, fields map[string]interface{}) error {
+	switch method {
+	case "Configure":
+		config := make(map[string]string, len(fields))
+		for key, value := range fields {
+			config[key] = fmt.Sprint(value)
+		}
+		return s.Impl.Configure(config)
+	default:
+		src, _ := fields["src"].(string)
+		timestamp, _ := fields["timestamp"].(string)
+		return s.Impl.Write(src, timestamp)
+	}
+}
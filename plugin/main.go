@@ -0,0 +1,76 @@
+// Package plugin defines the interface of external output plugins and serves them over hashicorp/go-plugin.
+//
+// A plugin is a separate executable that calls Serve with its Output implementation:
+//
+//	func main() {
+//		plugin.Serve(&myOutput{})
+//	}
+//
+// The collector starts the executable, configures it, and hands it every batch of events. Plugins are served over gRPC,
+// or net/rpc for collectors built before gRPC support, negotiated by go-plugin.
+package plugin
+
+import (
+	"context"
+	goplugin "github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+	"net/rpc"
+)
+
+// Name the output is dispensed under
+const outputPluginName = "output"
+
+// Handshake shared by the collector and its plugins
+var Handshake = goplugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "OKTA_COLLECTOR_PLUGIN",
+	MagicCookieValue: "output",
+}
+
+// Output is implemented by output plugins
+type Output interface {
+	// Configure the output with the key/value config given for the plugin
+	Configure(config map[string]string) error
+
+	// Write the newline delimited JSON batch at src to the output
+	// timestamp is the end of the collection window for the batch
+	Write(src string, timestamp string) error
+}
+
+// Map of the plugins a plugin executable serves
+var pluginMap = map[string]goplugin.Plugin{
+	outputPluginName: &outputPlugin{},
+}
+
+// Serve an output plugin. Called from the main function of the plugin executable.
+func Serve(impl Output) {
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins: map[string]goplugin.Plugin{
+			outputPluginName: &outputPlugin{Impl: impl},
+		},
+		GRPCServer: goplugin.DefaultGRPCServer,
+	})
+}
+
+// Plugin of an Output over gRPC and net/rpc
+type outputPlugin struct {
+	Impl Output
+}
+
+func (p *outputPlugin) Server(*goplugin.MuxBroker) (interface{}, error) {
+	return &outputRPCServer{Impl: p.Impl}, nil
+}
+
+func (p *outputPlugin) Client(b *goplugin.MuxBroker, c *rpc.Client) (interface{}, error) {
+	return &outputRPCClient{client: c}, nil
+}
+
+func (p *outputPlugin) GRPCServer(broker *goplugin.GRPCBroker, s *grpc.Server) error {
+	s.RegisterService(&outputServiceDesc, &outputGRPCServer{Impl: p.Impl})
+	return nil
+}
+
+func (p *outputPlugin) GRPCClient(ctx context.Context, broker *goplugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return &outputGRPCClient{conn: c}, nil
+}
@@ -0,0 +1,37 @@
+package plugin
+
+import (
+	"net/rpc"
+)
+
+// Args of the Write RPC
+type WriteArgs struct {
+	Src       string
+	Timestamp string
+}
+
+// Client side of an Output plugin
+type outputRPCClient struct {
+	client *rpc.Client
+}
+
+func (c *outputRPCClient) Configure(config map[string]string) error {
+	return c.client.Call("Plugin.Configure", config, new(interface{}))
+}
+
+func (c *outputRPCClient) Write(src string, timestamp string) error {
+	return c.client.Call("Plugin.Write", WriteArgs{Src: src, Timestamp: timestamp}, new(interface{}))
+}
+
+// Server side of an Output plugin
+type outputRPCServer struct {
+	Impl Output
+}
+
+func (s *outputRPCServer) Configure(config map[string]string, resp *interface{}) error {
+	return s.Impl.Configure(config)
+}
+
+func (s *outputRPCServer) Write(args WriteArgs, resp *interface{}) error {
+	return s.Impl.Write(args.Src, args.Timestamp)
+}
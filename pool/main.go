@@ -0,0 +1,74 @@
+// Package pool reuses the byte buffers events are passed through the collector in, so collecting large volumes doesn't
+// allocate a buffer for every event.
+package pool
+
+import (
+	"sync"
+)
+
+// Capacity of new buffers, enough for most System Log events
+const initialSize = 4 * 1024
+
+// Buffers larger than this are dropped rather than pooled, so a rare huge event doesn't stay allocated
+const maxPooledSize = 64 * 1024
+
+var buffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, initialSize)
+		return &b
+	},
+}
+
+// Get an empty buffer to append an event to
+func Get() []byte {
+	return (*buffers.Get().(*[]byte))[:0]
+}
+
+// Return a buffer to the pool once nothing reads it anymore
+func Put(b []byte) {
+	if cap(b) == 0 || cap(b) > maxPooledSize {
+		return
+	}
+
+	b = b[:0]
+	buffers.Put(&b)
+}
+
+// Check if two buffers share their backing array, such as an event returned unchanged
+func Same(a []byte, b []byte) bool {
+	return cap(a) > 0 && cap(b) > 0 && &a[:cap(a)][cap(a)-1] == &b[:cap(b)][cap(b)-1]
+}
+
+// An event shared by several readers, returned to the pool once every reader released it
+type Shared struct {
+	Event []byte
+	mu    sync.Mutex
+	refs  int
+}
+
+// Share an event with a number of readers
+// With no readers, the event is returned to the pool right away.
+func Share(event []byte, readers int) *Shared {
+	if readers == 0 {
+		Put(event)
+		return nil
+	}
+
+	return &Shared{Event: event, refs: readers}
+}
+
+// Release the event for a reader
+func (s *Shared) Release() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.refs--
+	last := s.refs == 0
+	s.mu.Unlock()
+
+	if last {
+		Put(s.Event)
+	}
+}
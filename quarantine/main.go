@@ -0,0 +1,75 @@
+// Package quarantine sets malformed events aside with the error they failed with, so the rest of their page or batch
+// is still processed.
+package quarantine
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rfizzle/okta-collector/metrics"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// A quarantined event, kept as JSON when it is valid JSON and as a string otherwise
+type record struct {
+	Time     string          `json:"time"`
+	Stage    string          `json:"stage"`
+	Error    string          `json:"error"`
+	Event    json.RawMessage `json:"event,omitempty"`
+	RawEvent string          `json:"rawEvent,omitempty"`
+}
+
+// Serializes appends to the quarantine file
+var mu sync.Mutex
+
+// Register the quarantine params
+func InitCLIParams() {
+	flag.String("quarantine-path", "quarantine.ndjson", "file malformed events are appended to with their error")
+}
+
+// Quarantine an event that failed a stage of processing, such as decoding or the transform of an output
+// The event is appended to the quarantine file, except in dry-run mode, and the caller carries on without it.
+func Event(event []byte, stage string, err error) {
+	metrics.QuarantinedEvents.Add(stage, 1)
+
+	path := viper.GetString("quarantine-path")
+	if viper.GetBool("dry-run") || path == "" {
+		log.Printf("Malformed event in %s, skipping it: %v\n", stage, err)
+		return
+	}
+
+	r := record{Time: time.Now().UTC().Format(time.RFC3339Nano), Stage: stage, Error: err.Error()}
+	if json.Valid(event) {
+		r.Event = event
+	} else {
+		r.RawEvent = string(event)
+	}
+
+	line, marshalErr := json.Marshal(r)
+	if marshalErr != nil {
+		log.Printf("Unable to quarantine malformed event in %s: %v (%v)\n", stage, marshalErr, err)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Events hold personal data, so the file is only readable by the collector
+	file, openErr := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if openErr != nil {
+		log.Printf("Unable to quarantine malformed event in %s: %v (%v)\n", stage, openErr, err)
+		return
+	}
+	defer file.Close()
+
+	if _, writeErr := file.Write(append(line, '\n')); writeErr != nil {
+		log.Printf("Unable to quarantine malformed event in %s: %v (%v)\n", stage, writeErr, err)
+		return
+	}
+
+	log.Printf("Malformed event in %s quarantined to %s: %v\n", stage, path, err)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rfizzle/okta-collector/collector"
+	"github.com/rfizzle/okta-collector/quarantine"
+	"github.com/tidwall/pretty"
+)
+
+// Extensions of the event files read from replay directories, optionally followed by .gz or .zst
+var replayExts = []string{".json", ".ndjson", ".jsonl", ".log"}
+
+// Feed the events of saved files through the filters, enrichments and outputs, without querying Okta or changing the
+// saved state
+// Detections aren't run, since they alerted when the events were collected.
+func replay(from string, timeout time.Duration) error {
+	files, err := replayFiles(from)
+	if err != nil {
+		return err
+	}
+
+	c, err := setupCollector(collector.WithOffline(), collector.WithAckTimeout(timeout))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for _, file := range files {
+		log.Printf("Replaying %s\n", file)
+
+		if _, err := replayFile(c, file); err != nil {
+			return errors.New(fmt.Sprintf("unable to replay %s: %v", file, err))
+		}
+	}
+
+	return c.Flush(context.Background())
+}
+
+// Files to replay, every event file of a directory and its subdirectories in name order
+func replayFiles(from string) ([]string, error) {
+	info, err := os.Stat(from)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{from}, nil
+	}
+
+	var files []string
+	err = filepath.Walk(from, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(strings.TrimSuffix(info.Name(), ".gz"), ".zst")
+		if !info.IsDir() && contains(replayExts, strings.ToLower(filepath.Ext(name))) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New(fmt.Sprintf("no event files in %s", from))
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// Deliver the events of a newline delimited or JSON array file, decompressing .gz and .zst files
+// Returns the number of events delivered.
+func replayFile(c *collector.Collector, path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return 0, err
+		}
+		defer gz.Close()
+		reader = gz
+	case strings.HasSuffix(path, ".zst"):
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			return 0, err
+		}
+		defer zr.Close()
+		reader = zr
+	}
+
+	buffered := bufio.NewReader(reader)
+	if start, err := firstByte(buffered); err != nil {
+		return 0, err
+	} else if start == '[' {
+		return replayJsonArray(c, buffered)
+	}
+
+	count := 0
+	for {
+		line, err := buffered.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			// Set aside invalid lines, replaying the rest of the file
+			if !json.Valid(line) {
+				quarantine.Event(line, "replay of "+path, errors.New(fmt.Sprintf("invalid event after %d events", count)))
+			} else {
+				c.Deliver(line)
+				count++
+			}
+		}
+
+		if err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, err
+		}
+	}
+}
+
+// Deliver the events of a JSON array
+func replayJsonArray(c *collector.Collector, reader io.Reader) (int, error) {
+	decoder := json.NewDecoder(reader)
+	if _, err := decoder.Token(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for decoder.More() {
+		var event json.RawMessage
+		if err := decoder.Decode(&event); err != nil {
+			return count, err
+		}
+
+		c.Deliver(pretty.Ugly(event))
+		count++
+	}
+
+	return count, nil
+}
+
+// Peek the first non-whitespace byte of a reader, 0 if it is empty
+func firstByte(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err == io.EOF {
+			return 0, nil
+		} else if err != nil {
+			return 0, err
+		}
+
+		if !strings.ContainsRune(" \t\r\n", rune(b[0])) {
+			return b[0], nil
+		}
+
+		if _, err := reader.Discard(1); err != nil {
+			return 0, err
+		}
+	}
+}
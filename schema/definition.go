@@ -0,0 +1,174 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A JSON Schema, supporting the type, enum, format (date-time), required, properties, additionalProperties and items
+// keywords
+// Other keywords are ignored, so schemas using them validate less strictly rather than failing to load.
+type definition struct {
+	types                []string
+	enum                 []interface{}
+	format               string
+	required             []string
+	properties           map[string]*definition
+	additionalProperties *definition
+	noAdditional         bool
+	items                *definition
+}
+
+func (d *definition) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type                 json.RawMessage        `json:"type"`
+		Enum                 []interface{}          `json:"enum"`
+		Format               string                 `json:"format"`
+		Required             []string               `json:"required"`
+		Properties           map[string]*definition `json:"properties"`
+		AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+		Items                *definition            `json:"items"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	d.enum, d.format, d.required, d.properties, d.items = raw.Enum, raw.Format, raw.Required, raw.Properties, raw.Items
+
+	// Type is a type name or a list of them
+	if len(raw.Type) > 0 {
+		var name string
+		if err := json.Unmarshal(raw.Type, &name); err == nil {
+			d.types = []string{name}
+		} else if err := json.Unmarshal(raw.Type, &d.types); err != nil {
+			return errors.New(fmt.Sprintf("invalid type %s", raw.Type))
+		}
+	}
+
+	// Additional properties are allowed or not, or a schema they must match
+	if len(raw.AdditionalProperties) > 0 {
+		var allowed bool
+		if err := json.Unmarshal(raw.AdditionalProperties, &allowed); err == nil {
+			d.noAdditional = !allowed
+		} else if err := json.Unmarshal(raw.AdditionalProperties, &d.additionalProperties); err != nil {
+			return errors.New(fmt.Sprintf("invalid additionalProperties %s", raw.AdditionalProperties))
+		}
+	}
+
+	return nil
+}
+
+// Validate a value decoded with numbers as json.Number, appending its violations at the path
+func (d *definition) validate(value interface{}, path string, violations []string) []string {
+	if len(d.types) > 0 && !d.matchesType(value) {
+		return append(violations, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(d.types, " or "), typeOf(value)))
+	}
+
+	if len(d.enum) > 0 && !d.inEnum(value) {
+		violations = append(violations, fmt.Sprintf("%s: unexpected value %v", path, value))
+	}
+
+	switch v := value.(type) {
+	case string:
+		if d.format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				violations = append(violations, fmt.Sprintf("%s: invalid date-time %s", path, v))
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range d.required {
+			if _, ok := v[name]; !ok {
+				violations = append(violations, fmt.Sprintf("%s.%s: missing", path, name))
+			}
+		}
+
+		// Properties in name order, so violations are reported in the same order every time
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if property, ok := d.properties[name]; ok {
+				violations = property.validate(v[name], path+"."+name, violations)
+			} else if d.noAdditional {
+				violations = append(violations, fmt.Sprintf("%s.%s: unexpected property", path, name))
+			} else if d.additionalProperties != nil {
+				violations = d.additionalProperties.validate(v[name], path+"."+name, violations)
+			}
+		}
+	case []interface{}:
+		if d.items != nil {
+			for i, item := range v {
+				violations = d.items.validate(item, fmt.Sprintf("%s[%d]", path, i), violations)
+			}
+		}
+	}
+
+	return violations
+}
+
+// Check if a value has one of the types of the schema
+func (d *definition) matchesType(value interface{}) bool {
+	actual := typeOf(value)
+	for _, t := range d.types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if a value is one of the values of the enum of the schema
+func (d *definition) inEnum(value interface{}) bool {
+	for _, e := range d.enum {
+		if reflect.DeepEqual(normalize(e), normalize(value)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// JSON Schema type of a decoded value
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	case float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// Numbers of enums are decoded as float64 and numbers of events as json.Number, so both compare as float64
+func normalize(value interface{}) interface{} {
+	if n, ok := value.(json.Number); ok {
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,112 @@
+// Package schema validates events against a JSON Schema, the bundled System Log schema by default, so events of
+// unexpected shapes are tagged or quarantined before they reach strict-schema stores.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Modes of schema validation
+const (
+	modeNone       = "none"
+	modeTag        = "tag"
+	modeQuarantine = "quarantine"
+)
+
+// Most violations reported for an event
+const maxViolations = 10
+
+// Schema events are validated against, set up from the params
+var eventSchema *definition
+
+// Register the schema validation params
+func InitCLIParams() {
+	flag.String("schema-validation", modeNone, "validation of events against the system log json schema (none, tag, quarantine)")
+	flag.String("schema-file", "", "path of a json schema file events are validated against instead of the bundled system log schema")
+}
+
+// Validate the schema validation params
+func ValidateCLIParams() error {
+	switch viper.GetString("schema-validation") {
+	case modeNone, modeTag, modeQuarantine:
+	default:
+		return errors.New(fmt.Sprintf("invalid schema validation param (--schema-validation): must be %s, %s or %s", modeNone, modeTag, modeQuarantine))
+	}
+
+	if path := viper.GetString("schema-file"); path != "" {
+		if _, err := loadSchema(path); err != nil {
+			return errors.New(fmt.Sprintf("invalid schema file param (--schema-file): %v", err))
+		}
+	}
+
+	return nil
+}
+
+// Load the schema events are validated against when validation is enabled
+func Setup() error {
+	eventSchema = nil
+	if viper.GetString("schema-validation") == modeNone {
+		return nil
+	}
+
+	var err error
+	eventSchema, err = loadSchema(viper.GetString("schema-file"))
+	return err
+}
+
+// Check if events of unexpected shapes are tagged with their violations
+func Tags() bool {
+	return eventSchema != nil && viper.GetString("schema-validation") == modeTag
+}
+
+// Check if events of unexpected shapes are quarantined
+func Quarantines() bool {
+	return eventSchema != nil && viper.GetString("schema-validation") == modeQuarantine
+}
+
+// Validate an event against the schema, returning its violations, at most 10, or none when validation is disabled
+func Validate(event []byte) []string {
+	if eventSchema == nil {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(event))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return []string{fmt.Sprintf("invalid json: %v", err)}
+	}
+
+	violations := eventSchema.validate(value, "$", nil)
+	if len(violations) > maxViolations {
+		violations = violations[:maxViolations]
+	}
+
+	return violations
+}
+
+// Load a schema file, or the bundled System Log schema when the path is empty
+func loadSchema(path string) (*definition, error) {
+	content := []byte(systemLogSchema)
+	if path != "" {
+		var err error
+		if content, err = ioutil.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var d definition
+	if err := json.Unmarshal(content, &d); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
@@ -0,0 +1,122 @@
+package schema
+
+// Bundled JSON Schema of System Log events
+// Fields Okta sends as null are nullable, and unknown fields are allowed, so new fields added by Okta aren't violations.
+const systemLogSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "Okta System Log event",
+  "type": "object",
+  "required": ["uuid", "published", "eventType", "version", "severity", "actor"],
+  "properties": {
+    "uuid": {"type": "string"},
+    "published": {"type": "string", "format": "date-time"},
+    "eventType": {"type": "string"},
+    "version": {"type": "string"},
+    "severity": {"type": "string", "enum": ["DEBUG", "INFO", "WARN", "ERROR"]},
+    "legacyEventType": {"type": ["string", "null"]},
+    "displayMessage": {"type": ["string", "null"]},
+    "actor": {"type": "object",
+      "required": ["id", "type"],
+      "properties": {
+        "id": {"type": "string"},
+        "type": {"type": "string"},
+        "alternateId": {"type": ["string", "null"]},
+        "displayName": {"type": ["string", "null"]},
+        "detailEntry": {"type": ["object", "null"]}
+      }
+    },
+    "client": {"type": ["object", "null"],
+      "properties": {
+        "id": {"type": ["string", "null"]},
+        "zone": {"type": ["string", "null"]},
+        "device": {"type": ["string", "null"]},
+        "ipAddress": {"type": ["string", "null"]},
+        "userAgent": {"type": ["object", "null"],
+          "properties": {
+            "rawUserAgent": {"type": ["string", "null"]},
+            "os": {"type": ["string", "null"]},
+            "browser": {"type": ["string", "null"]}
+          }
+        },
+        "geographicalContext": {"type": ["object", "null"],
+          "properties": {
+            "city": {"type": ["string", "null"]},
+            "state": {"type": ["string", "null"]},
+            "country": {"type": ["string", "null"]},
+            "postalCode": {"type": ["string", "null"]},
+            "geolocation": {"type": ["object", "null"],
+              "properties": {
+                "lat": {"type": ["number", "null"]},
+                "lon": {"type": ["number", "null"]}
+              }
+            }
+          }
+        }
+      }
+    },
+    "outcome": {"type": ["object", "null"],
+      "properties": {
+        "result": {"type": ["string", "null"]},
+        "reason": {"type": ["string", "null"]}
+      }
+    },
+    "target": {"type": ["array", "null"],
+      "items": {"type": "object",
+        "required": ["id", "type"],
+        "properties": {
+          "id": {"type": "string"},
+          "type": {"type": "string"},
+          "alternateId": {"type": ["string", "null"]},
+          "displayName": {"type": ["string", "null"]},
+          "detailEntry": {"type": ["object", "null"]}
+        }
+      }
+    },
+    "transaction": {"type": ["object", "null"],
+      "properties": {
+        "id": {"type": ["string", "null"]},
+        "type": {"type": ["string", "null"]},
+        "detail": {"type": ["object", "null"]}
+      }
+    },
+    "debugContext": {"type": ["object", "null"],
+      "properties": {
+        "debugData": {"type": ["object", "null"]}
+      }
+    },
+    "authenticationContext": {"type": ["object", "null"],
+      "properties": {
+        "authenticationProvider": {"type": ["string", "null"]},
+        "credentialProvider": {"type": ["string", "null"]},
+        "credentialType": {"type": ["string", "null"]},
+        "externalSessionId": {"type": ["string", "null"]},
+        "interface": {"type": ["string", "null"]},
+        "authenticationStep": {"type": ["integer", "null"]},
+        "issuer": {"type": ["object", "null"]}
+      }
+    },
+    "securityContext": {"type": ["object", "null"],
+      "properties": {
+        "asNumber": {"type": ["integer", "null"]},
+        "asOrg": {"type": ["string", "null"]},
+        "isp": {"type": ["string", "null"]},
+        "domain": {"type": ["string", "null"]},
+        "isProxy": {"type": ["boolean", "null"]}
+      }
+    },
+    "request": {"type": ["object", "null"],
+      "properties": {
+        "ipChain": {"type": ["array", "null"],
+          "items": {"type": "object",
+            "properties": {
+              "ip": {"type": ["string", "null"]},
+              "version": {"type": ["string", "null"]},
+              "source": {"type": ["string", "null"]},
+              "geographicalContext": {"type": ["object", "null"]}
+            }
+          }
+        }
+      }
+    }
+  }
+}`
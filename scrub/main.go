@@ -0,0 +1,122 @@
+// Package scrub removes secrets such as API keys and tokens from logs, errors and debug output.
+package scrub
+
+import (
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Replacement of scrubbed secrets
+const redacted = "[REDACTED]"
+
+// Secrets shorter than this are not scrubbed, so short values don't redact unrelated text
+const minSecretLength = 4
+
+// Authorization header values, scrubbed whether or not their token is registered
+// Tokens are at least 16 characters so prose such as "Basic authentication" is kept.
+var authPattern = regexp.MustCompile(`(?i)\b(SSWS|Bearer|Basic|Splunk)\s+[A-Za-z0-9._~+/=-]{16,}`)
+
+// Passwords of keyword and URL style DSNs
+var (
+	keywordPasswordPattern = regexp.MustCompile(`(?i)\bpassword=('[^']*'|\S+)`)
+	userPasswordPattern    = regexp.MustCompile(`^[^:@/]+:([^@]+)@`)
+)
+
+var (
+	mu      sync.RWMutex
+	secrets []string
+)
+
+// A secret that is redacted when formatted, so printing the struct holding it doesn't leak it
+type Secret string
+
+func (s Secret) String() string {
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return redacted
+}
+
+// Register a secret to scrub, along with its URL encoded form
+func Register(secret string) {
+	secret = strings.TrimSpace(secret)
+	if len(secret) < minSecretLength {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, s := range []string{secret, url.QueryEscape(secret), url.PathEscape(secret)} {
+		if !contains(secrets, s) {
+			secrets = append(secrets, s)
+		}
+	}
+
+	// Replace longer secrets first so a secret containing another is fully redacted
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+}
+
+// Register the password of a DSN, in URL, keyword or user:password@ form
+func RegisterDsn(dsn string) {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			Register(password)
+		}
+	}
+
+	if match := keywordPasswordPattern.FindStringSubmatch(dsn); match != nil {
+		Register(strings.Trim(match[1], "'"))
+	}
+
+	if match := userPasswordPattern.FindStringSubmatch(dsn); match != nil {
+		Register(match[1])
+	}
+}
+
+// Scrub the registered secrets and authorization header values from s
+func String(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, secret := range secrets {
+		s = strings.Replace(s, secret, redacted, -1)
+	}
+
+	return authPattern.ReplaceAllString(s, "$1 "+redacted)
+}
+
+// Writer that scrubs everything written to out, such as the log output
+func Writer(out io.Writer) io.Writer {
+	return &writer{out: out}
+}
+
+type writer struct {
+	out io.Writer
+}
+
+// Scrub and write p, reporting the length of p as written so callers don't see a short write
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, String(string(p))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,101 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// Check if the reference is a Secrets Manager secret ARN, optionally followed by #key to select a key of a JSON secret
+func isSecretsManagerArn(reference string) bool {
+	return strings.HasPrefix(reference, "arn:aws:secretsmanager:") || strings.HasPrefix(reference, "arn:aws-us-gov:secretsmanager:")
+}
+
+// Check if the reference is an SSM Parameter Store parameter ARN
+func isSsmArn(reference string) bool {
+	return strings.HasPrefix(reference, "arn:aws:ssm:") || strings.HasPrefix(reference, "arn:aws-us-gov:ssm:")
+}
+
+// Fetch a Secrets Manager secret with the default credentials, such as those of the IAM role of the host
+func fetchSecretsManager(reference string) (string, error) {
+	arn, key := reference, ""
+	if i := strings.LastIndex(reference, "#"); i >= 0 {
+		arn, key = reference[:i], reference[i+1:]
+	}
+
+	sess, err := arnSession(arn)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := secretsmanager.New(sess).GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(arn),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	value := string(output.SecretBinary)
+	if output.SecretString != nil {
+		value = *output.SecretString
+	}
+
+	if key == "" {
+		return value, nil
+	}
+
+	// Select a key of a JSON secret
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", errors.New(fmt.Sprintf("secret is not a json object: %v", err))
+	}
+
+	field, ok := fields[key].(string)
+	if !ok {
+		return "", errors.New(fmt.Sprintf("missing string key %s in secret", key))
+	}
+
+	return field, nil
+}
+
+// Fetch and decrypt an SSM Parameter Store parameter with the default credentials
+func fetchSsm(reference string) (string, error) {
+	sess, err := arnSession(reference)
+	if err != nil {
+		return "", err
+	}
+
+	// Hierarchical parameter names keep their leading slash, which their ARN drops
+	name := strings.TrimPrefix(strings.SplitN(reference, ":", 6)[5], "parameter/")
+	if strings.Contains(name, "/") {
+		name = "/" + name
+	}
+
+	output, err := ssm.New(sess).GetParameter(&ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(output.Parameter.Value), nil
+}
+
+// Session in the region of an ARN
+func arnSession(arn string) (*session.Session, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[3] == "" {
+		return nil, errors.New(fmt.Sprintf("invalid arn %s", arn))
+	}
+
+	return session.NewSession(&aws.Config{
+		Region: aws.String(parts[3]),
+	})
+}
@@ -0,0 +1,123 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Instance metadata endpoint of Azure managed identity tokens
+const azureImdsTokenUrl = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// Resource of Key Vault access tokens
+const azureKeyVaultResource = "https://vault.azure.net"
+
+var azureClient = &http.Client{Timeout: 30 * time.Second}
+
+// Managed identity token for Key Vault, reused until it expires
+var azureToken = struct {
+	sync.Mutex
+	token   string
+	expires time.Time
+}{}
+
+// Check if the reference is a Key Vault secret URI, such as https://acme.vault.azure.net/secrets/okta
+func isKeyVaultUri(reference string) bool {
+	u, err := url.Parse(reference)
+	return err == nil && u.Scheme == "https" && strings.HasSuffix(u.Hostname(), ".vault.azure.net") && strings.HasPrefix(u.Path, "/secrets/")
+}
+
+// Fetch a Key Vault secret, the latest version unless the URI has one, with the managed identity of the host
+func fetchKeyVault(reference string) (string, error) {
+	token, err := managedIdentityToken()
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequest("GET", reference+"?api-version=7.4", nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	var secret struct {
+		Value string `json:"value"`
+	}
+	if err := azureCall(request, &secret); err != nil {
+		return "", err
+	}
+
+	return secret.Value, nil
+}
+
+// Get a Key Vault token of the managed identity of the host, from App Service or the instance metadata endpoint
+func managedIdentityToken() (string, error) {
+	azureToken.Lock()
+	defer azureToken.Unlock()
+
+	// Reuse the token until shortly before it expires
+	if azureToken.token != "" && time.Now().Add(5*time.Minute).Before(azureToken.expires) {
+		return azureToken.token, nil
+	}
+
+	params := url.Values{}
+	params.Set("resource", azureKeyVaultResource)
+	if clientId := viper.GetString("azure-managed-identity-client-id"); clientId != "" {
+		params.Set("client_id", clientId)
+	}
+
+	// App Service and Functions have their own endpoint, set in their environment by the platform
+	endpoint, header, headerValue := azureImdsTokenUrl, "Metadata", "true"
+	params.Set("api-version", "2018-02-01")
+	if identityEndpoint := os.Getenv("IDENTITY_ENDPOINT"); identityEndpoint != "" {
+		endpoint, header, headerValue = identityEndpoint, "X-IDENTITY-HEADER", os.Getenv("IDENTITY_HEADER")
+		params.Set("api-version", "2019-08-01")
+	}
+
+	request, err := http.NewRequest("GET", endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set(header, headerValue)
+
+	var token struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresOn   json.Number `json:"expires_on"`
+	}
+	if err := azureCall(request, &token); err != nil {
+		return "", errors.New(fmt.Sprintf("unable to get managed identity token: %v", err))
+	}
+
+	expiresOn, err := strconv.ParseInt(token.ExpiresOn.String(), 10, 64)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("invalid managed identity token expiry %s", token.ExpiresOn))
+	}
+
+	azureToken.token = token.AccessToken
+	azureToken.expires = time.Unix(expiresOn, 0)
+	return azureToken.token, nil
+}
+
+// Make an Azure request, decoding the JSON response into result
+func azureCall(request *http.Request, result interface{}) error {
+	response, err := azureClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("HTTP response code: %v", response.Status))
+	}
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
@@ -0,0 +1,24 @@
+package secrets
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// Prefix of file secret references, such as file:/var/run/secrets/okta/api-key
+const filePrefix = "file:"
+
+// Check if the reference is a file
+func isFileReference(reference string) bool {
+	return strings.HasPrefix(reference, filePrefix)
+}
+
+// Read a secret from a file, such as a mounted Kubernetes secret, trimming surrounding whitespace
+func fetchFile(reference string) (string, error) {
+	content, err := ioutil.ReadFile(strings.TrimPrefix(reference, filePrefix))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
@@ -0,0 +1,198 @@
+// Package secrets resolves params that reference secrets in external stores, such as AWS Secrets Manager, Vault and
+// Azure Key Vault, so credentials don't have to be set in the environment or config.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rfizzle/okta-collector/scrub"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// A store of secrets, fetching the secrets it references
+type source struct {
+	name    string
+	matches func(reference string) bool
+	fetch   func(reference string) (string, error)
+}
+
+// Supported secret stores
+var sources = []source{
+	{"file", isFileReference, fetchFile},
+	{"aws secrets manager", isSecretsManagerArn, fetchSecretsManager},
+	{"aws ssm parameter store", isSsmArn, fetchSsm},
+	{"vault", isVaultReference, fetchVault},
+	{"azure key vault", isKeyVaultUri, fetchKeyVault},
+}
+
+var (
+	mu sync.RWMutex
+
+	// References of the resolved params
+	references = make(map[string]string)
+
+	// Latest values of the resolved params
+	values = make(map[string]string)
+)
+
+// Register the secrets params
+func InitCLIParams() {
+	flag.Int("secret-refresh", 3600, "time in seconds between refreshes of secrets referenced by params (0 to disable)")
+	flag.String("vault-address", "", "address of the vault server secrets are referenced from, such as https://vault:8200")
+	flag.String("vault-token", "", "vault token to read secrets with")
+	flag.String("vault-kubernetes-role", "", "vault role to log in as with the kubernetes auth method, instead of a token")
+	flag.String("vault-auth-mount", "kubernetes", "mount path of the vault kubernetes auth method")
+	flag.String("vault-namespace", "", "vault enterprise namespace of secrets")
+	flag.String("azure-managed-identity-client-id", "", "client id of the user assigned managed identity to read key vault secrets with (default system assigned)")
+}
+
+// Resolve the params that reference a secret, replacing their value with the secret
+func Resolve(params []string) error {
+	if viper.GetInt("secret-refresh") < 0 {
+		return errors.New("invalid secret refresh param (--secret-refresh)")
+	}
+
+	for _, param := range params {
+		reference := viper.GetString(param)
+		value, err := ResolveValue(param, reference)
+		if err != nil {
+			return err
+		}
+
+		if value != reference {
+			viper.Set(param, value)
+		}
+	}
+
+	return nil
+}
+
+// Resolve a value that may reference a secret, such as a setting of a config block, under a name
+// Returns the secret, or the value if it isn't a reference. The latest value is read with Value(name), refreshed like
+// the params.
+func ResolveValue(name string, reference string) (string, error) {
+	s, ok := sourceOf(reference)
+	if !ok {
+		mu.Lock()
+		values[name] = reference
+		mu.Unlock()
+
+		return reference, nil
+	}
+
+	if s.name == "vault" && viper.GetString("vault-address") == "" {
+		return "", errors.New(fmt.Sprintf("missing vault address param (--vault-address) to fetch %s", name))
+	}
+
+	value, err := s.fetch(reference)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("unable to fetch %s from %s: %v", name, s.name, err))
+	}
+
+	scrub.Register(value)
+
+	mu.Lock()
+	references[name] = reference
+	values[name] = value
+	mu.Unlock()
+
+	return value, nil
+}
+
+// Start refreshing the resolved params in the background, periodically and on SIGHUP
+func Setup() error {
+	if len(references) == 0 {
+		return nil
+	}
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	var ticks <-chan time.Time
+	if refresh := time.Duration(viper.GetInt("secret-refresh")) * time.Second; refresh > 0 {
+		ticks = time.Tick(refresh)
+	}
+
+	if referencesVault() {
+		go renewVault()
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ticks:
+			case <-hangups:
+				log.Println("Refreshing secrets...")
+			}
+
+			refreshAll()
+		}
+	}()
+
+	return nil
+}
+
+// Latest value of a param, refreshed if it references a secret
+// Params are read from viper otherwise, which isn't updated by refreshes since it isn't safe for concurrent use.
+func Value(param string) string {
+	mu.RLock()
+	value, ok := values[param]
+	mu.RUnlock()
+
+	if ok {
+		return value
+	}
+
+	return viper.GetString(param)
+}
+
+// Source of the latest value of a param, for clients that read it for every request
+func Source(param string) func() string {
+	return func() string {
+		return Value(param)
+	}
+}
+
+// Fetch every resolved param again, keeping the previous value of those that fail
+func refreshAll() {
+	mu.RLock()
+	resolved := make(map[string]string, len(references))
+	for param, reference := range references {
+		resolved[param] = reference
+	}
+	mu.RUnlock()
+
+	for param, reference := range resolved {
+		s, _ := sourceOf(reference)
+		value, err := s.fetch(reference)
+		if err != nil {
+			log.Printf("Unable to refresh %s from %s: %v\n", param, s.name, err)
+			continue
+		}
+
+		scrub.Register(value)
+
+		mu.Lock()
+		values[param] = value
+		mu.Unlock()
+	}
+}
+
+// Store of a secret reference
+func sourceOf(reference string) (source, bool) {
+	for _, s := range sources {
+		if s.matches(reference) {
+			return s, true
+		}
+	}
+
+	return source{}, false
+}
@@ -0,0 +1,362 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Prefix of Vault secret references, such as vault:secret/data/okta#api-key
+const vaultPrefix = "vault:"
+
+// Service account token used to log in with the Kubernetes auth method
+const kubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+var vaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// Vault token and the leases of dynamic secrets, shared by every reference
+var vault = struct {
+	sync.Mutex
+	token   string
+	renewAt time.Time
+	leases  map[string]*vaultLease
+}{leases: make(map[string]*vaultLease)}
+
+// Lease of a dynamic secret, renewed instead of reading new credentials until it can't be
+type vaultLease struct {
+	id        string
+	renewable bool
+	renewAt   time.Time
+	data      map[string]interface{}
+}
+
+// Longest wait between checks for tokens and leases to renew, so leases of secrets read later are renewed in time
+const vaultRenewCheck = time.Minute
+
+// Response of the Vault HTTP API
+type vaultResponse struct {
+	LeaseId       string                 `json:"lease_id"`
+	Renewable     bool                   `json:"renewable"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *vaultAuth             `json:"auth"`
+	Errors        []string               `json:"errors"`
+}
+
+// Token of a Vault login
+type vaultAuth struct {
+	ClientToken   string `json:"client_token"`
+	Renewable     bool   `json:"renewable"`
+	LeaseDuration int    `json:"lease_duration"`
+}
+
+// Check if the reference is a Vault secret path followed by #key
+func isVaultReference(reference string) bool {
+	return strings.HasPrefix(reference, vaultPrefix)
+}
+
+// Check if a resolved param references a Vault secret
+func referencesVault() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, reference := range references {
+		if isVaultReference(reference) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Read a key of a Vault secret, from a KV v2 engine or a dynamic secrets engine
+func fetchVault(reference string) (string, error) {
+	path := strings.TrimPrefix(reference, vaultPrefix)
+	i := strings.LastIndex(path, "#")
+	if i < 0 {
+		return "", errors.New(fmt.Sprintf("missing #key in vault reference %s", reference))
+	}
+	path, key := strings.Trim(path[:i], "/"), path[i+1:]
+
+	vault.Lock()
+	defer vault.Unlock()
+
+	data, err := vaultSecret(path)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := data[key].(string)
+	if !ok {
+		return "", errors.New(fmt.Sprintf("missing string key %s in vault secret %s", key, path))
+	}
+
+	return value, nil
+}
+
+// Data of the secret at path
+// Renewable leases of dynamic secrets read before are renewed, so every key of the secret keeps the same credentials.
+func vaultSecret(path string) (map[string]interface{}, error) {
+	if lease, ok := vault.leases[path]; ok && renewVaultLease(lease) {
+		return lease.data, nil
+	}
+
+	response, err := vaultRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// KV v2 engines nest the secret under data with its metadata
+	data := response.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+
+	if response.LeaseId != "" {
+		vault.leases[path] = &vaultLease{id: response.LeaseId, renewable: response.Renewable, renewAt: leaseRenewTime(response.LeaseDuration), data: data}
+	} else {
+		delete(vault.leases, path)
+	}
+
+	return data, nil
+}
+
+// Renew the lease of a dynamic secret
+// Returns false if it can't be renewed, so new credentials have to be read.
+func renewVaultLease(lease *vaultLease) bool {
+	if !lease.renewable {
+		return false
+	}
+
+	response, err := vaultRequest("PUT", "sys/leases/renew", map[string]string{"lease_id": lease.id})
+	if err != nil || response.LeaseDuration <= 0 {
+		return false
+	}
+
+	lease.renewAt = leaseRenewTime(response.LeaseDuration)
+	return true
+}
+
+// Renew the Vault token and the leases of dynamic secrets after two thirds of their TTL, in the background
+// Secrets whose lease can't be renewed are read again, so the params referencing them get new credentials.
+func renewVault() {
+	for {
+		time.Sleep(nextVaultRenewal())
+
+		if renewVaultDue() {
+			refreshAll()
+		}
+	}
+}
+
+// Time until the token or a lease is due for renewal
+func nextVaultRenewal() time.Duration {
+	vault.Lock()
+	defer vault.Unlock()
+
+	next := time.Now().Add(vaultRenewCheck)
+	if !vault.renewAt.IsZero() && vault.renewAt.Before(next) {
+		next = vault.renewAt
+	}
+
+	for _, lease := range vault.leases {
+		if !lease.renewAt.IsZero() && lease.renewAt.Before(next) {
+			next = lease.renewAt
+		}
+	}
+
+	return time.Until(next)
+}
+
+// Renew the token and the leases that are due
+// Returns true if a lease couldn't be renewed and was dropped, so its secret has to be read again.
+func renewVaultDue() bool {
+	vault.Lock()
+	defer vault.Unlock()
+
+	if err := vaultToken(); err != nil {
+		log.Printf("Unable to renew vault token: %v\n", err)
+	}
+
+	expired := false
+	for path, lease := range vault.leases {
+		if lease.renewAt.IsZero() || time.Now().Before(lease.renewAt) {
+			continue
+		}
+
+		if !renewVaultLease(lease) {
+			delete(vault.leases, path)
+			expired = true
+		}
+	}
+
+	return expired
+}
+
+// Make a request to the Vault HTTP API, logging in or renewing the token first when needed
+func vaultRequest(method string, path string, body interface{}) (*vaultResponse, error) {
+	if err := vaultToken(); err != nil {
+		return nil, err
+	}
+
+	response, status, err := vaultCall(method, path, body, vault.token)
+	if status == http.StatusForbidden && viper.GetString("vault-kubernetes-role") != "" {
+		// Log in again when the token was revoked or expired
+		vault.token = ""
+		if err := vaultToken(); err != nil {
+			return nil, err
+		}
+		response, _, err = vaultCall(method, path, body, vault.token)
+	}
+
+	return response, err
+}
+
+// Make sure there is a valid Vault token, from the token param or a Kubernetes auth login
+func vaultToken() error {
+	role := viper.GetString("vault-kubernetes-role")
+	if role == "" {
+		token := viper.GetString("vault-token")
+		if token == "" {
+			return errors.New("missing vault token param (--vault-token, --vault-kubernetes-role)")
+		}
+
+		// Look up the TTL of a new token, so it's renewed before it expires
+		if token != vault.token {
+			vault.token = token
+			vault.renewAt = lookupRenewTime(token)
+			return nil
+		}
+
+		if !renewVaultToken() {
+			log.Println("Unable to renew vault token, using it until it expires")
+			vault.renewAt = time.Time{}
+		}
+		return nil
+	}
+
+	// Renew the login token after two thirds of its TTL, logging in again if it can't be renewed
+	if vault.token != "" && renewVaultToken() {
+		return nil
+	}
+
+	jwt, err := ioutil.ReadFile(kubernetesTokenPath)
+	if err != nil {
+		return err
+	}
+
+	login := map[string]string{"role": role, "jwt": strings.TrimSpace(string(jwt))}
+	response, _, err := vaultCall("PUT", "auth/"+viper.GetString("vault-auth-mount")+"/login", login, "")
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to log in to vault: %v", err))
+	}
+
+	if response.Auth == nil || response.Auth.ClientToken == "" {
+		return errors.New("unable to log in to vault: missing token")
+	}
+
+	vault.token = response.Auth.ClientToken
+	vault.renewAt = renewTime(response.Auth)
+	return nil
+}
+
+// Renew the token once two thirds of its TTL have passed
+// Returns false if it is due but couldn't be renewed.
+func renewVaultToken() bool {
+	if vault.renewAt.IsZero() || time.Now().Before(vault.renewAt) {
+		return true
+	}
+
+	response, _, err := vaultCall("PUT", "auth/token/renew-self", nil, vault.token)
+	if err != nil || response.Auth == nil {
+		return false
+	}
+
+	vault.renewAt = renewTime(response.Auth)
+	return true
+}
+
+// Time to renew a token set by param, from its TTL and whether it is renewable
+func lookupRenewTime(token string) time.Time {
+	response, _, err := vaultCall("GET", "auth/token/lookup-self", nil, token)
+	if err != nil {
+		return time.Time{}
+	}
+
+	renewable, _ := response.Data["renewable"].(bool)
+	ttl, _ := response.Data["ttl"].(float64)
+	return renewTime(&vaultAuth{Renewable: renewable, LeaseDuration: int(ttl)})
+}
+
+// Time to renew a token, zero if it can't be renewed
+func renewTime(auth *vaultAuth) time.Time {
+	if !auth.Renewable || auth.LeaseDuration <= 0 {
+		return time.Time{}
+	}
+
+	return leaseRenewTime(auth.LeaseDuration)
+}
+
+// Time to renew a lease after two thirds of its duration in seconds, zero if it doesn't expire
+func leaseRenewTime(duration int) time.Time {
+	if duration <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(time.Duration(duration) * time.Second * 2 / 3)
+}
+
+// Make a single call to the Vault HTTP API
+// Returns the response and its status code.
+func vaultCall(method string, path string, body interface{}, token string) (*vaultResponse, int, error) {
+	var content io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, 0, err
+		}
+		content = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequest(method, strings.TrimRight(viper.GetString("vault-address"), "/")+"/v1/"+path, content)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if token != "" {
+		request.Header.Set("X-Vault-Token", token)
+	}
+
+	if namespace := viper.GetString("vault-namespace"); namespace != "" {
+		request.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	response, err := vaultClient.Do(request)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+
+	result := &vaultResponse{}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil && err != io.EOF {
+		return nil, response.StatusCode, err
+	}
+
+	if response.StatusCode/100 != 2 {
+		return nil, response.StatusCode, errors.New(fmt.Sprintf("vault %s %s: %s %s", method, path, response.Status, strings.Join(result.Errors, ", ")))
+	}
+
+	return result, response.StatusCode, nil
+}
@@ -0,0 +1,158 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Fake Vault server with a renewable token and a dynamic secret
+type fakeVault struct {
+	mu            sync.Mutex
+	leaseDuration int
+	renewFails    bool
+	reads         int
+	leaseRenewals int
+	tokenRenewals int
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != "hvs.test" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var response interface{}
+	switch r.URL.Path {
+	case "/v1/auth/token/lookup-self":
+		response = map[string]interface{}{"data": map[string]interface{}{"ttl": 30, "renewable": true}}
+	case "/v1/auth/token/renew-self":
+		v.tokenRenewals++
+		response = map[string]interface{}{"auth": map[string]interface{}{"client_token": "hvs.test", "renewable": true, "lease_duration": 30}}
+	case "/v1/database/creds/collector":
+		v.reads++
+		response = map[string]interface{}{
+			"lease_id":       fmt.Sprintf("database/creds/collector/%d", v.reads),
+			"renewable":      true,
+			"lease_duration": v.leaseDuration,
+			"data":           map[string]interface{}{"username": "collector", "password": fmt.Sprintf("password-%d", v.reads)},
+		}
+	case "/v1/sys/leases/renew":
+		if v.renewFails {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors": ["lease not found"]}`))
+			return
+		}
+		v.leaseRenewals++
+		response = map[string]interface{}{"renewable": true, "lease_duration": v.leaseDuration}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// Start a fake Vault server, resetting the token and leases of earlier tests
+func startFakeVault(t *testing.T, leaseDuration int) *fakeVault {
+	fake := &fakeVault{leaseDuration: leaseDuration}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("vault-address", server.URL)
+	viper.Set("vault-token", "hvs.test")
+
+	vault.Lock()
+	vault.token = ""
+	vault.renewAt = time.Time{}
+	vault.leases = make(map[string]*vaultLease)
+	vault.Unlock()
+
+	return fake
+}
+
+// Make the token and every lease due for renewal
+func expireVault() {
+	vault.Lock()
+	defer vault.Unlock()
+
+	vault.renewAt = time.Now().Add(-time.Second)
+	for _, lease := range vault.leases {
+		lease.renewAt = time.Now().Add(-time.Second)
+	}
+}
+
+func TestVaultLeaseRenewedFromItsDuration(t *testing.T) {
+	fake := startFakeVault(t, 30)
+
+	if _, err := fetchVault("vault:database/creds/collector#password"); err != nil {
+		t.Fatalf("unable to fetch secret: %v", err)
+	}
+
+	// Renewed after two thirds of the 30s lease, well before the default refresh
+	if next := nextVaultRenewal(); next < 19*time.Second || next > 20*time.Second {
+		t.Errorf("expected renewal in 20s, got %v", next)
+	}
+
+	expireVault()
+	if renewVaultDue() {
+		t.Error("expected the lease to be renewed without reading the secret again")
+	}
+
+	if fake.leaseRenewals != 1 || fake.reads != 1 {
+		t.Errorf("expected 1 lease renewal and 1 read, got %d renewals and %d reads", fake.leaseRenewals, fake.reads)
+	}
+
+	if fake.tokenRenewals != 1 {
+		t.Errorf("expected the token to be renewed once, got %d", fake.tokenRenewals)
+	}
+
+	if next := nextVaultRenewal(); next <= 0 {
+		t.Errorf("expected the next renewal to be scheduled from the renewed lease, got %v", next)
+	}
+}
+
+func TestVaultLeaseReadAgainWhenNotRenewed(t *testing.T) {
+	fake := startFakeVault(t, 30)
+
+	if _, err := ResolveValue("postgres-password", "vault:database/creds/collector#password"); err != nil {
+		t.Fatalf("unable to resolve secret: %v", err)
+	}
+
+	fake.mu.Lock()
+	fake.renewFails = true
+	fake.mu.Unlock()
+
+	expireVault()
+	if !renewVaultDue() {
+		t.Fatal("expected a lease that can't be renewed to be dropped")
+	}
+
+	refreshAll()
+	if value := Value("postgres-password"); value != "password-2" {
+		t.Errorf("expected new credentials after the lease couldn't be renewed, got %s", value)
+	}
+}
+
+func TestVaultLeaseWithoutDurationNotRenewed(t *testing.T) {
+	fake := startFakeVault(t, 0)
+
+	if _, err := fetchVault("vault:database/creds/collector#password"); err != nil {
+		t.Fatalf("unable to fetch secret: %v", err)
+	}
+
+	if renewVaultDue() || fake.leaseRenewals != 0 {
+		t.Errorf("expected a lease without a duration not to be renewed, got %d renewals", fake.leaseRenewals)
+	}
+}
@@ -0,0 +1,196 @@
+package sigma
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rfizzle/okta-collector/match"
+)
+
+// A node of a parsed condition
+type node func(e *evaluation) bool
+
+// Tokens of a condition
+var conditionTokens = regexp.MustCompile(`\(|\)|[^\s()]+`)
+
+// Parser of a condition over the selections of a rule
+type conditionParser struct {
+	tokens     []string
+	position   int
+	selections map[string]selection
+}
+
+// Parse a condition, such as "selection and not 1 of filter_*"
+// Aggregations such as "| count() by" are not supported.
+func parseCondition(condition string, selections map[string]selection) (node, error) {
+	p := &conditionParser{tokens: conditionTokens.FindAllString(condition, -1), selections: selections}
+	if len(p.tokens) == 0 {
+		return nil, errors.New("empty condition")
+	}
+
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if token := p.peek(); token != "" {
+		if token == "|" {
+			return nil, errors.New("aggregations are not supported")
+		}
+		return nil, errors.New(fmt.Sprintf("unexpected %s", token))
+	}
+
+	return n, nil
+}
+
+func (p *conditionParser) peek() string {
+	if p.position >= len(p.tokens) {
+		return ""
+	}
+
+	return p.tokens[p.position]
+}
+
+func (p *conditionParser) next() string {
+	token := p.peek()
+	p.position++
+	return token
+}
+
+// expression: and ("or" and)*
+func (p *conditionParser) or() (node, error) {
+	var nodes []node
+	for {
+		n, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+
+		if !strings.EqualFold(p.peek(), "or") {
+			return anyNode(nodes), nil
+		}
+		p.next()
+	}
+}
+
+// and: factor ("and" factor)*
+func (p *conditionParser) and() (node, error) {
+	var nodes []node
+	for {
+		n, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+
+		if !strings.EqualFold(p.peek(), "and") {
+			return allNode(nodes), nil
+		}
+		p.next()
+	}
+}
+
+// factor: "not" factor | "(" expression ")" | quantifier "of" pattern | selection
+func (p *conditionParser) factor() (node, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, errors.New("unexpected end of condition")
+	case strings.EqualFold(token, "not"):
+		n, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return func(e *evaluation) bool { return !n(e) }, nil
+	case token == "(":
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errors.New("missing )")
+		}
+		return n, nil
+	case token == "1" || strings.EqualFold(token, "any") || strings.EqualFold(token, "all"):
+		if !strings.EqualFold(p.next(), "of") {
+			return nil, errors.New(fmt.Sprintf("expected of after %s", token))
+		}
+		names, err := p.matchSelections(p.next())
+		if err != nil {
+			return nil, err
+		}
+
+		nodes := make([]node, len(names))
+		for i, name := range names {
+			nodes[i] = selectionNode(name)
+		}
+		if strings.EqualFold(token, "all") {
+			return allNode(nodes), nil
+		}
+		return anyNode(nodes), nil
+	default:
+		if _, ok := p.selections[token]; !ok {
+			return nil, errors.New(fmt.Sprintf("unknown selection %s", token))
+		}
+		return selectionNode(token), nil
+	}
+}
+
+// Names of the selections matching a pattern, or every selection for "them"
+func (p *conditionParser) matchSelections(pattern string) ([]string, error) {
+	if pattern == "" {
+		return nil, errors.New("unexpected end of condition")
+	}
+
+	var names []string
+	for name := range p.selections {
+		if match.Pattern(pattern, name) || pattern == "them" {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New(fmt.Sprintf("no selections match %s", pattern))
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func selectionNode(name string) node {
+	return func(e *evaluation) bool { return e.selection(name) }
+}
+
+func anyNode(nodes []node) node {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+
+	return func(e *evaluation) bool {
+		for _, n := range nodes {
+			if n(e) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func allNode(nodes []node) node {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+
+	return func(e *evaluation) bool {
+		for _, n := range nodes {
+			if !n(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
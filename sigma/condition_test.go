@@ -0,0 +1,115 @@
+package sigma
+
+import (
+	"testing"
+)
+
+// Selections of the condition tests, named after whether they match the event
+const conditionTestSelections = `
+  match_a:
+    eventType: user.session.start
+  match_b:
+    outcome.result: SUCCESS
+  miss_a:
+    eventType: user.session.end
+  miss_b:
+    outcome.result: FAILURE
+`
+
+// Parse a rule with the condition over the test selections
+func parseConditionRule(condition string) (*Rule, error) {
+	return Parse([]byte("title: test\nlogsource:\n  product: okta\ndetection:\n  condition: " + condition + conditionTestSelections))
+}
+
+func TestConditionMatches(t *testing.T) {
+	tests := []struct {
+		condition string
+		matches   bool
+	}{
+		{"match_a", true},
+		{"miss_a", false},
+		{"not miss_a", true},
+		{"not match_a", false},
+		{"match_a and match_b", true},
+		{"match_a and miss_a", false},
+		{"match_a or miss_a", true},
+		{"miss_a or miss_b", false},
+		{"match_a AND NOT miss_a", true},
+
+		// not binds tighter than and, which binds tighter than or
+		{"not match_a and miss_a", false},
+		{"not miss_a and match_a", true},
+		{"not (match_a and miss_a)", true},
+		{"match_a or miss_a and miss_b", true},
+		{"(match_a or miss_a) and miss_b", false},
+		{"miss_a and miss_b or match_a", true},
+		{"miss_a and (miss_b or match_a)", false},
+		{"not miss_a or miss_b and match_a", true},
+		{"not (miss_a or match_a)", false},
+
+		// Quantifiers over selection patterns
+		{"1 of match_*", true},
+		{"1 of miss_*", false},
+		{"any of miss_*", false},
+		{"all of match_*", true},
+		{"all of miss_*", false},
+		{"1 of them", true},
+		{"all of them", false},
+		{"not 1 of miss_*", true},
+		{"all of match_* and not 1 of miss_*", true},
+		{"1 of miss_* or all of match_*", true},
+	}
+
+	event := decodeTestEvent(t, selectionTestEvent)
+	for _, test := range tests {
+		t.Run(test.condition, func(t *testing.T) {
+			rule, err := parseConditionRule(test.condition)
+			if err != nil {
+				t.Fatalf("unable to parse rule: %v", err)
+			}
+
+			if matches := rule.Matches(event); matches != test.matches {
+				t.Errorf("expected match %v, got %v", test.matches, matches)
+			}
+		})
+	}
+}
+
+func TestConditionList(t *testing.T) {
+	rule, err := Parse([]byte("title: test\nlogsource:\n  product: okta\ndetection:\n  condition:\n    - miss_a\n    - match_b" + conditionTestSelections))
+	if err != nil {
+		t.Fatalf("unable to parse rule: %v", err)
+	}
+
+	if !rule.Matches(decodeTestEvent(t, selectionTestEvent)) {
+		t.Errorf("expected any of the conditions to match")
+	}
+}
+
+func TestConditionInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+	}{
+		{"count aggregation", "match_a | count() > 5"},
+		{"count by aggregation", "match_a | count(actor.alternateId) by client.ipAddress > 3"},
+		{"near aggregation", "match_a | near match_b"},
+		{"unknown selection", "match_c"},
+		{"unmatched pattern", "1 of other_*"},
+		{"missing of", "1 match_*"},
+		{"missing pattern", "all of"},
+		{"missing )", "(match_a or match_b"},
+		{"unexpected )", "match_a)"},
+		{"trailing operator", "match_a and"},
+		{"missing operator", "match_a match_b"},
+		{"empty", "''"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parseConditionRule(test.condition); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
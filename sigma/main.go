@@ -0,0 +1,163 @@
+// Package sigma evaluates Sigma rules of the okta logsource against System Log events.
+package sigma
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Logsource product and service of Okta rules
+const oktaLogSource = "okta"
+
+// A Sigma rule
+type Rule struct {
+	Id          string   `yaml:"id"`
+	Title       string   `yaml:"title"`
+	Description string   `yaml:"description"`
+	Status      string   `yaml:"status"`
+	Level       string   `yaml:"level"`
+	Tags        []string `yaml:"tags"`
+	LogSource   struct {
+		Product string `yaml:"product"`
+		Service string `yaml:"service"`
+	} `yaml:"logsource"`
+	Detection map[string]interface{} `yaml:"detection"`
+
+	selections map[string]selection
+	condition  node
+}
+
+// Load the Okta rules of rule files and directories of them
+// Rules of other logsources are skipped.
+func Load(paths []string) ([]*Rule, error) {
+	var rules []*Rule
+	for _, path := range paths {
+		files, err := ruleFiles(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+
+			rule, err := Parse(content)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("%s: %v", file, err))
+			}
+
+			if rule.Okta() {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	return rules, nil
+}
+
+// Find the rule files of a path, walking directories for .yml and .yaml files
+func ruleFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if ext := strings.ToLower(filepath.Ext(file)); !info.IsDir() && (ext == ".yml" || ext == ".yaml") {
+			files = append(files, file)
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+// Parse a rule, compiling its selections and condition
+func Parse(content []byte) (*Rule, error) {
+	var rule Rule
+	if err := yaml.Unmarshal(content, &rule); err != nil {
+		return nil, err
+	}
+
+	if rule.Title == "" {
+		return nil, errors.New("missing rule title")
+	}
+
+	conditions, err := ruleConditions(rule.Detection["condition"])
+	if err != nil {
+		return nil, err
+	}
+
+	rule.selections = make(map[string]selection)
+	for name, value := range rule.Detection {
+		if name == "condition" || name == "timeframe" {
+			continue
+		}
+
+		sel, err := parseSelection(value)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid selection %s: %v", name, err))
+		}
+		rule.selections[name] = sel
+	}
+
+	// Several conditions match if any of them does
+	var nodes []node
+	for _, condition := range conditions {
+		n, err := parseCondition(condition, rule.selections)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid condition %s: %v", condition, err))
+		}
+		nodes = append(nodes, n)
+	}
+	rule.condition = anyNode(nodes)
+
+	return &rule, nil
+}
+
+// Conditions of a rule, either a single condition or a list of them
+func ruleConditions(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		var conditions []string
+		for _, c := range v {
+			s, ok := c.(string)
+			if !ok {
+				return nil, errors.New("invalid condition")
+			}
+			conditions = append(conditions, s)
+		}
+		return conditions, nil
+	default:
+		return nil, errors.New("missing detection condition")
+	}
+}
+
+// Check if the rule is for the okta logsource
+func (r *Rule) Okta() bool {
+	return strings.EqualFold(r.LogSource.Product, oktaLogSource) || strings.EqualFold(r.LogSource.Service, oktaLogSource)
+}
+
+// Check if an event, decoded with numbers kept as json.Number, matches the rule
+func (r *Rule) Matches(event map[string]interface{}) bool {
+	return r.condition(newEvaluation(r.selections, event))
+}
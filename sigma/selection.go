@@ -0,0 +1,341 @@
+package sigma
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// A named selection of a rule's detection
+// Selections are either field maps, lists of field maps matching if any does, or keyword lists.
+type selection struct {
+	fieldMaps [][]fieldMatcher
+	keywords  []valueMatcher
+}
+
+// Matches the values of a field
+type fieldMatcher struct {
+	path   []string
+	all    bool
+	exists *bool
+	values []valueMatcher
+}
+
+// Matches a single value, with nil values only matching missing or null fields
+type valueMatcher struct {
+	null  bool
+	match func(value string) bool
+}
+
+// The evaluation of a rule against an event, caching the results of selections
+type evaluation struct {
+	selections map[string]selection
+	event      map[string]interface{}
+	results    map[string]bool
+	text       *string
+}
+
+func newEvaluation(selections map[string]selection, event map[string]interface{}) *evaluation {
+	return &evaluation{selections: selections, event: event, results: make(map[string]bool)}
+}
+
+// Check if a selection matches the event
+func (e *evaluation) selection(name string) bool {
+	if result, ok := e.results[name]; ok {
+		return result
+	}
+
+	result := e.evaluate(e.selections[name])
+	e.results[name] = result
+	return result
+}
+
+func (e *evaluation) evaluate(sel selection) bool {
+	if len(sel.keywords) > 0 {
+		text := e.eventText()
+		for _, keyword := range sel.keywords {
+			if keyword.match(text) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, fields := range sel.fieldMaps {
+		if e.matchesFields(fields) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if every field matcher of a field map matches the event
+func (e *evaluation) matchesFields(fields []fieldMatcher) bool {
+	for _, f := range fields {
+		if !f.matches(lookup(e.event, f.path)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// The event as text, matched by keywords
+func (e *evaluation) eventText() string {
+	if e.text == nil {
+		encoded, _ := json.Marshal(e.event)
+		text := string(encoded)
+		e.text = &text
+	}
+
+	return *e.text
+}
+
+// Check if the values of a field match
+func (f fieldMatcher) matches(values []interface{}) bool {
+	if f.exists != nil {
+		return (len(values) > 0) == *f.exists
+	}
+
+	matched := 0
+	for _, expected := range f.values {
+		if expected.matchesAny(values) {
+			matched++
+			if !f.all {
+				return true
+			}
+		}
+	}
+
+	return f.all && matched == len(f.values)
+}
+
+// Check if any value of a field matches
+func (v valueMatcher) matchesAny(values []interface{}) bool {
+	if v.null {
+		return len(values) == 0
+	}
+
+	for _, value := range values {
+		if s, ok := valueString(value); ok && v.match(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Values of a dotted field path in an event, descending into every element of arrays
+// Missing and null fields have no values.
+func lookup(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		if value == nil {
+			return nil
+		}
+		if list, ok := value.([]interface{}); ok {
+			return list
+		}
+		return []interface{}{value}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return lookup(v[path[0]], path[1:])
+	case []interface{}:
+		var values []interface{}
+		for _, element := range v {
+			values = append(values, lookup(element, path)...)
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// Text of a scalar value
+func valueString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		if v {
+			return "true", true
+		}
+		return "false", true
+	case int, int64, float64:
+		return fmt.Sprint(v), true
+	default:
+		return "", false
+	}
+}
+
+// Parse a selection of a rule's detection
+func parseSelection(value interface{}) (selection, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		fields, err := parseFieldMap(v)
+		return selection{fieldMaps: [][]fieldMatcher{fields}}, err
+	case []interface{}:
+		var sel selection
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				fields, err := parseFieldMap(m)
+				if err != nil {
+					return sel, err
+				}
+				sel.fieldMaps = append(sel.fieldMaps, fields)
+				continue
+			}
+
+			// Keywords are matched anywhere in the event
+			s, ok := valueString(item)
+			if !ok {
+				return sel, errors.New("invalid keyword")
+			}
+			keyword, err := wildcardMatcher("*" + s + "*")
+			if err != nil {
+				return sel, err
+			}
+			sel.keywords = append(sel.keywords, keyword)
+		}
+
+		if len(sel.fieldMaps) > 0 && len(sel.keywords) > 0 {
+			return sel, errors.New("mixed keywords and field maps")
+		}
+		return sel, nil
+	default:
+		return selection{}, errors.New("expected a map or list")
+	}
+}
+
+// Parse a map of field|modifiers keys to values
+func parseFieldMap(m map[string]interface{}) ([]fieldMatcher, error) {
+	var fields []fieldMatcher
+	for key, value := range m {
+		parts := strings.Split(key, "|")
+		f := fieldMatcher{path: strings.Split(parts[0], ".")}
+
+		var values []interface{}
+		if list, ok := value.([]interface{}); ok {
+			values = list
+		} else {
+			values = []interface{}{value}
+		}
+
+		var modifiers []string
+		for _, modifier := range parts[1:] {
+			switch modifier {
+			case "all":
+				f.all = true
+			case "exists":
+				exists, ok := value.(bool)
+				if !ok {
+					return nil, errors.New(fmt.Sprintf("%s expects a boolean", key))
+				}
+				f.exists = &exists
+			default:
+				modifiers = append(modifiers, modifier)
+			}
+		}
+
+		if f.exists == nil {
+			for _, v := range values {
+				matcher, err := parseValue(v, modifiers)
+				if err != nil {
+					return nil, errors.New(fmt.Sprintf("%s: %v", key, err))
+				}
+				f.values = append(f.values, matcher)
+			}
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+// Parse a value of a field with its modifiers
+func parseValue(value interface{}, modifiers []string) (valueMatcher, error) {
+	if value == nil {
+		return valueMatcher{null: true}, nil
+	}
+
+	s, ok := valueString(value)
+	if !ok {
+		return valueMatcher{}, errors.New("invalid value")
+	}
+
+	if len(modifiers) > 1 {
+		return valueMatcher{}, errors.New(fmt.Sprintf("unsupported modifiers %s", strings.Join(modifiers, "|")))
+	}
+
+	modifier := ""
+	if len(modifiers) == 1 {
+		modifier = modifiers[0]
+	}
+
+	switch modifier {
+	case "":
+		return wildcardMatcher(s)
+	case "contains":
+		return wildcardMatcher("*" + s + "*")
+	case "startswith":
+		return wildcardMatcher(s + "*")
+	case "endswith":
+		return wildcardMatcher("*" + s)
+	case "re":
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return valueMatcher{}, err
+		}
+		return valueMatcher{match: re.MatchString}, nil
+	case "cidr":
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return valueMatcher{}, err
+		}
+		return valueMatcher{match: func(value string) bool {
+			ip := net.ParseIP(value)
+			return ip != nil && network.Contains(ip)
+		}}, nil
+	default:
+		return valueMatcher{}, errors.New(fmt.Sprintf("unsupported modifier %s", modifier))
+	}
+}
+
+// Match a Sigma wildcard pattern, case insensitively
+// * matches any characters and ? a single character, unless escaped with a backslash.
+// A backslash only escapes *, ? and another backslash, and is kept as is before anything else.
+func wildcardMatcher(pattern string) (valueMatcher, error) {
+	var expression strings.Builder
+	expression.WriteString("(?is)^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes) && (runes[i+1] == '*' || runes[i+1] == '?' || runes[i+1] == '\\'):
+			i++
+			expression.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case r == '*':
+			expression.WriteString(".*")
+		case r == '?':
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expression.WriteString("$")
+
+	re, err := regexp.Compile(expression.String())
+	if err != nil {
+		return valueMatcher{}, err
+	}
+
+	return valueMatcher{match: re.MatchString}, nil
+}
@@ -0,0 +1,156 @@
+package sigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// Event that the selection tests are matched against
+const selectionTestEvent = `{
+	"eventType": "user.session.start",
+	"displayMessage": "User login to Okta",
+	"actor": {"alternateId": "Jane.Doe@example.com", "displayName": null},
+	"client": {"ipAddress": "10.1.2.3"},
+	"outcome": {"result": "SUCCESS"},
+	"target": [{"type": "AppInstance", "displayName": "C:\\Windows\\System32"}, {"type": "User", "displayName": "Wild*Card?"}],
+	"securityContext": {"isProxy": false, "asNumber": 64512}
+}`
+
+// Decode an event with numbers kept as json.Number, as the collector does
+func decodeTestEvent(t *testing.T, event string) map[string]interface{} {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(event)))
+	decoder.UseNumber()
+
+	var decoded map[string]interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		t.Fatalf("unable to decode event: %v", err)
+	}
+	return decoded
+}
+
+// Parse a rule with a single selection as its condition
+func parseSelectionRule(selection string) (*Rule, error) {
+	return Parse([]byte("title: test\nlogsource:\n  product: okta\ndetection:\n  condition: selection\n  selection:\n" + selection))
+}
+
+func TestSelectionMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		selection string
+		matches   bool
+	}{
+		{"exact", "    eventType: user.session.start", true},
+		{"exact is case insensitive", "    eventType: USER.SESSION.START", true},
+		{"exact mismatch", "    eventType: user.session.end", false},
+		{"list matches any", "    eventType:\n      - user.session.end\n      - user.session.start", true},
+		{"nested field", "    actor.alternateId: jane.doe@example.com", true},
+		{"array field", "    target.type: User", true},
+		{"number", "    securityContext.asNumber: 64512", true},
+		{"boolean", "    securityContext.isProxy: false", true},
+		{"several fields all match", "    eventType: user.session.start\n    outcome.result: SUCCESS", true},
+		{"several fields one mismatches", "    eventType: user.session.start\n    outcome.result: FAILURE", false},
+		{"wildcard", "    displayMessage: User * Okta", true},
+		{"single character wildcard", "    outcome.result: SUCC?SS", true},
+		{"contains", "    displayMessage|contains: login", true},
+		{"contains mismatch", "    displayMessage|contains: logout", false},
+		{"startswith", "    actor.alternateId|startswith: jane.", true},
+		{"startswith mismatch", "    actor.alternateId|startswith: doe", false},
+		{"endswith", "    actor.alternateId|endswith: '@example.com'", true},
+		{"endswith mismatch", "    actor.alternateId|endswith: '@example.org'", false},
+		{"re", "    actor.alternateId|re: '^[A-Z][a-z]+\\.Doe@'", true},
+		{"re mismatch", "    actor.alternateId|re: '^doe'", false},
+		{"cidr", "    client.ipAddress|cidr: 10.0.0.0/8", true},
+		{"cidr mismatch", "    client.ipAddress|cidr: 192.168.0.0/16", false},
+		{"cidr of a non address", "    eventType|cidr: 10.0.0.0/8", false},
+		{"all", "    displayMessage|contains|all:\n      - User\n      - Okta", true},
+		{"all one mismatches", "    displayMessage|contains|all:\n      - User\n      - logout", false},
+		{"exists", "    client.ipAddress|exists: true", true},
+		{"exists of a missing field", "    client.device|exists: true", false},
+		{"not exists of a missing field", "    client.device|exists: false", true},
+		{"not exists of a null field", "    actor.displayName|exists: false", true},
+		{"null of a null field", "    actor.displayName: null", true},
+		{"null of a missing field", "    client.device: null", true},
+		{"null of a present field", "    eventType: null", false},
+		{"escaped backslash is kept", "    target.displayName: 'C:\\Windows\\System32'", true},
+		{"escaped backslash before a letter", "    target.displayName|startswith: 'C:\\W'", true},
+		{"escaped double backslash matches one", "    target.displayName|endswith: '\\\\System32'", true},
+		{"escaped wildcard", "    target.displayName: 'Wild\\*Card\\?'", true},
+		{"escaped wildcard is literal", "    target.displayName: 'Wild\\*'", false},
+		{"list of field maps", "    - eventType: user.session.end\n    - outcome.result: SUCCESS", true},
+		{"keywords", "    - login to", true},
+		{"keywords mismatch", "    - logout", false},
+	}
+
+	event := decodeTestEvent(t, selectionTestEvent)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := parseSelectionRule(test.selection)
+			if err != nil {
+				t.Fatalf("unable to parse rule: %v", err)
+			}
+
+			if matches := rule.Matches(event); matches != test.matches {
+				t.Errorf("expected match %v, got %v", test.matches, matches)
+			}
+		})
+	}
+}
+
+func TestSelectionInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		selection string
+	}{
+		{"unknown modifier", "    eventType|base64: user"},
+		{"several modifiers", "    eventType|contains|startswith: user"},
+		{"invalid re", "    eventType|re: '('"},
+		{"invalid cidr", "    client.ipAddress|cidr: 10.0.0.0"},
+		{"exists without a boolean", "    client.ipAddress|exists: yes please"},
+		{"mixed keywords and field maps", "    - login\n    - eventType: user.session.start"},
+		{"scalar selection", "    user.session.start"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parseSelectionRule(test.selection); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestWildcardMatcher(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		matches bool
+	}{
+		{`C:\Windows`, `C:\Windows`, true},
+		{`C:\Windows`, `C:Windows`, false},
+		{`C:\\Windows`, `C:\Windows`, true},
+		{`a\*`, `a*`, true},
+		{`a\*`, `ab`, false},
+		{`a\?`, `a?`, true},
+		{`a\?`, `ab`, false},
+		{`a*`, `abc`, true},
+		{`a?c`, `abc`, true},
+		{`a?c`, `ac`, false},
+		{`a\`, `a\`, true},
+		{`a\\*`, `a\bc`, true},
+		{`a.c`, `abc`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.value, func(t *testing.T) {
+			matcher, err := wildcardMatcher(test.pattern)
+			if err != nil {
+				t.Fatalf("unable to compile pattern: %v", err)
+			}
+
+			if matches := matcher.match(test.value); matches != test.matches {
+				t.Errorf("expected match %v, got %v", test.matches, matches)
+			}
+		})
+	}
+}
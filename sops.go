@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/viper"
+	"go.mozilla.org/sops/v3/decrypt"
+)
+
+// Formats of SOPS encrypted config files, by config file type
+var sopsFormats = map[string]string{
+	"json":   "json",
+	"yaml":   "yaml",
+	"yml":    "yaml",
+	"env":    "dotenv",
+	"dotenv": "dotenv",
+}
+
+// Check if a config is encrypted with SOPS, which adds its metadata to the config
+func isSopsConfig(config *viper.Viper) bool {
+	return config.IsSet("sops") || config.IsSet("sops_version")
+}
+
+// Replace a SOPS encrypted config with its decrypted values, in memory only
+// The data key is decrypted with the age identities or AWS KMS credentials of the environment, as with the sops CLI.
+func decryptSopsConfig(path string, config *viper.Viper) error {
+	format, ok := sopsFormats[configFileType(path)]
+	if !ok {
+		return errors.New(fmt.Sprintf("unsupported sops encrypted config file type %s", configFileType(path)))
+	}
+
+	cleartext, err := decrypt.File(path, format)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to decrypt sops encrypted config file: %v", err))
+	}
+
+	return config.ReadConfig(bytes.NewReader(cleartext))
+}
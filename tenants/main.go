@@ -0,0 +1,290 @@
+// Package tenants reads the tenants config blocks, so one collector can collect the System Logs of several Okta orgs.
+package tenants
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/rfizzle/okta-collector/client"
+	"github.com/rfizzle/okta-collector/enrich"
+	"github.com/rfizzle/okta-collector/filter"
+	"github.com/rfizzle/okta-collector/match"
+	"github.com/rfizzle/okta-collector/output"
+	"github.com/rfizzle/okta-collector/scrub"
+	"github.com/rfizzle/okta-collector/secrets"
+	"github.com/spf13/viper"
+)
+
+// Field of collector derived fields that events are tagged with their tenant under
+const tenantField = "tenant"
+
+// Valid tenant names and state keys, which are used in state file names
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// An Okta org collected by the collector, with its own credentials, filters, state and outputs
+type Tenant struct {
+	Name              string   `mapstructure:"name"`
+	OktaDomain        string   `mapstructure:"okta-domain"`
+	OktaApiKey        string   `mapstructure:"okta-api-key"`
+	ApiBaseUrl        string   `mapstructure:"api-base-url"`
+	StateKey          string   `mapstructure:"state-key"`
+	IncludeEventTypes []string `mapstructure:"include-event-types"`
+	ExcludeEventTypes []string `mapstructure:"exclude-event-types"`
+	MinSeverity       string   `mapstructure:"min-severity"`
+	Outcomes          []string `mapstructure:"outcomes"`
+	Outputs           []string `mapstructure:"outputs"`
+}
+
+// Fields of an event used for tenant filters and routing
+type eventFields struct {
+	EventType string `json:"eventType"`
+	Severity  string `json:"severity"`
+	Outcome   struct {
+		Result string `json:"result"`
+	} `json:"outcome"`
+	Collector struct {
+		Tenant string `json:"tenant"`
+	} `json:"collector"`
+}
+
+// Tenants read from the config by Setup
+var tenants []*Tenant
+
+// Read the tenants config blocks
+func load() ([]*Tenant, error) {
+	var loaded []*Tenant
+	if err := viper.UnmarshalKey("tenants", &loaded); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid tenants config: %v", err))
+	}
+
+	for _, t := range loaded {
+		if t.StateKey == "" {
+			t.StateKey = t.Name
+		}
+
+		if domain, err := client.NormalizeDomain(t.OktaDomain); err == nil {
+			t.OktaDomain = domain
+		}
+	}
+
+	return loaded, nil
+}
+
+// Check if tenants are configured
+func Enabled() bool {
+	return viper.IsSet("tenants")
+}
+
+// Validate the tenants config blocks
+func ValidateCLIParams() error {
+	if !Enabled() {
+		return nil
+	}
+
+	loaded, err := load()
+	if err != nil {
+		return err
+	}
+
+	if len(loaded) == 0 {
+		return errors.New("invalid tenants config: no tenants")
+	}
+
+	for _, param := range []string{"okta-domain", "okta-api-key", "okta-api-key-file", "api-base-url"} {
+		if viper.GetString(param) != "" {
+			return errors.New(fmt.Sprintf("%s param (--%s) can't be combined with tenants", param, param))
+		}
+	}
+
+	// Users, groups, apps and api tokens are looked up in a single org
+	for _, param := range []string{"enrich-users", "enrich-groups", "enrich-apps"} {
+		if viper.GetBool(param) {
+			return errors.New(fmt.Sprintf("%s param (--%s) can't be combined with tenants", param, param))
+		}
+	}
+
+	if len(viper.GetStringSlice("suppress-api-tokens")) > 0 {
+		return errors.New("suppress api tokens param (--suppress-api-tokens) can't be combined with tenants")
+	}
+
+	names := make(map[string]bool)
+	stateKeys := make(map[string]bool)
+	for i, t := range loaded {
+		if !namePattern.MatchString(t.Name) {
+			return errors.New(fmt.Sprintf("invalid tenant %d: name must be letters, digits, dots, dashes or underscores", i+1))
+		}
+
+		if names[t.Name] {
+			return errors.New(fmt.Sprintf("invalid tenant %s: duplicate name", t.Name))
+		}
+		names[t.Name] = true
+
+		if !namePattern.MatchString(t.StateKey) {
+			return errors.New(fmt.Sprintf("invalid tenant %s: state key must be letters, digits, dots, dashes or underscores", t.Name))
+		}
+
+		if stateKeys[t.StateKey] {
+			return errors.New(fmt.Sprintf("invalid tenant %s: duplicate state key %s", t.Name, t.StateKey))
+		}
+		stateKeys[t.StateKey] = true
+
+		if err := t.validate(); err != nil {
+			return errors.New(fmt.Sprintf("invalid tenant %s: %v", t.Name, err))
+		}
+	}
+
+	return nil
+}
+
+// Validate the settings of a tenant
+func (t *Tenant) validate() error {
+	if t.ApiBaseUrl != "" {
+		if err := client.ValidateBaseUrl(t.ApiBaseUrl); err != nil {
+			return errors.New(fmt.Sprintf("invalid api-base-url: %v", err))
+		}
+	} else if t.OktaDomain == "" {
+		return errors.New("missing okta-domain")
+	} else if _, err := client.NormalizeDomain(t.OktaDomain); err != nil {
+		return err
+	}
+
+	if t.OktaApiKey == "" {
+		return errors.New("missing okta-api-key")
+	}
+
+	for _, pattern := range append(t.IncludeEventTypes, t.ExcludeEventTypes...) {
+		if !match.ValidPattern(pattern) {
+			return errors.New(fmt.Sprintf("invalid event type pattern %s", pattern))
+		}
+	}
+
+	if t.MinSeverity != "" && !filter.ValidSeverity(t.MinSeverity) {
+		return errors.New(fmt.Sprintf("unknown min-severity %s", t.MinSeverity))
+	}
+
+	return output.ValidateEventOutputs(t.Outputs)
+}
+
+// Read the validated tenants, resolving API keys that reference secrets
+func Setup() error {
+	if !Enabled() {
+		return nil
+	}
+
+	loaded, err := load()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range loaded {
+		key, err := secrets.ResolveValue(t.secretName(), t.OktaApiKey)
+		if err != nil {
+			return errors.New(fmt.Sprintf("invalid tenant %s: %v", t.Name, err))
+		}
+		scrub.Register(key)
+	}
+
+	tenants = loaded
+	return nil
+}
+
+// Read the config block of a tenant by name, without resolving its API key
+func Lookup(name string) (*Tenant, error) {
+	loaded, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range loaded {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+
+	return nil, errors.New(fmt.Sprintf("unknown tenant %s", name))
+}
+
+// Tenants read by Setup, in config order
+func All() []*Tenant {
+	return tenants
+}
+
+// Tenant an event was collected from, nil if it wasn't collected from a tenant
+func Of(event []byte) (*Tenant, error) {
+	if len(tenants) == 0 {
+		return nil, nil
+	}
+
+	var fields eventFields
+	if err := json.Unmarshal(event, &fields); err != nil {
+		return nil, err
+	}
+
+	for _, t := range tenants {
+		if t.Name == fields.Collector.Tenant {
+			return t, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Name the API key of the tenant is resolved under
+func (t *Tenant) secretName() string {
+	return "tenant " + t.Name + " okta-api-key"
+}
+
+// Create an Okta client of the tenant
+func (t *Tenant) NewClient() *client.OktaClient {
+	oktaClient := client.NewClient(t.OktaDomain, t.ApiKey())
+	oktaClient.BaseUrl = t.ApiBaseUrl
+	return oktaClient
+}
+
+// Latest API key of the tenant, refreshed if it references a secret
+func (t *Tenant) ApiKey() string {
+	return secrets.Value(t.secretName())
+}
+
+// System Log API filter expression of the included event types of the tenant
+func (t *Tenant) ServerFilter() string {
+	return filter.ServerFilterOf(t.IncludeEventTypes)
+}
+
+// State path of the tenant, next to the state path param and named after its state key
+func (t *Tenant) StatePath() string {
+	return viper.GetString("state-path") + "." + t.StateKey
+}
+
+// Check if an event passes the filters of the tenant
+func (t *Tenant) Keep(event []byte) (bool, error) {
+	var fields eventFields
+	if err := json.Unmarshal(event, &fields); err != nil {
+		return false, err
+	}
+
+	if len(t.IncludeEventTypes) > 0 && !match.Any(t.IncludeEventTypes, fields.EventType) {
+		return false, nil
+	}
+
+	if match.Any(t.ExcludeEventTypes, fields.EventType) {
+		return false, nil
+	}
+
+	if t.MinSeverity != "" && !filter.AtLeastSeverity(fields.Severity, t.MinSeverity) {
+		return false, nil
+	}
+
+	if len(t.Outcomes) > 0 && !match.ContainsFold(t.Outcomes, fields.Outcome.Result) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Tag an event with the name of the tenant under the collector derived fields
+func (t *Tenant) Tag(event []byte) ([]byte, error) {
+	return enrich.AddCollectorFields(event, map[string]interface{}{tenantField: t.Name})
+}
@@ -0,0 +1,55 @@
+package tenants
+
+import "testing"
+
+func TestTag(t *testing.T) {
+	tenant := &Tenant{Name: "acme"}
+
+	tagged, err := tenant.Tag([]byte(`{"uuid":"1","displayMessage":"User login <b>&</b>","collector":{"receivedAt":"2023-06-01T12:00:01Z"}}`))
+	if err != nil {
+		t.Fatalf("unable to tag event: %v", err)
+	}
+
+	expected := `{"uuid":"1","displayMessage":"User login <b>&</b>","collector":{"receivedAt":"2023-06-01T12:00:01Z","tenant":"acme"}}`
+	if string(tagged) != expected {
+		t.Errorf("expected %s, got %s", expected, tagged)
+	}
+}
+
+func TestKeep(t *testing.T) {
+	tenant := &Tenant{
+		Name:              "acme",
+		IncludeEventTypes: []string{"user.session.*", "user.authentication.*"},
+		ExcludeEventTypes: []string{"user.session.end"},
+		Outcomes:          []string{"failure"},
+	}
+
+	tests := []struct {
+		event string
+		keep  bool
+	}{
+		{`{"eventType":"user.session.start","outcome":{"result":"FAILURE"}}`, true},
+		{`{"eventType":"user.authentication.sso","outcome":{"result":"FAILURE"}}`, true},
+		{`{"eventType":"user.session.start","outcome":{"result":"SUCCESS"}}`, false},
+		{`{"eventType":"user.session.end","outcome":{"result":"FAILURE"}}`, false},
+		{`{"eventType":"policy.lifecycle.update","outcome":{"result":"FAILURE"}}`, false},
+	}
+
+	for _, test := range tests {
+		keep, err := tenant.Keep([]byte(test.event))
+		if err != nil {
+			t.Fatalf("unable to filter event: %v", err)
+		}
+
+		if keep != test.keep {
+			t.Errorf("expected keeping %s to be %v", test.event, test.keep)
+		}
+	}
+}
+
+func TestValidateEventTypePatterns(t *testing.T) {
+	tenant := &Tenant{Name: "acme", OktaDomain: "acme.okta.com", OktaApiKey: "key", IncludeEventTypes: []string{"user.[session"}}
+	if err := tenant.validate(); err == nil {
+		t.Error("expected an unclosed bracket to be an invalid pattern")
+	}
+}
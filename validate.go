@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/rfizzle/okta-collector/collector"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Keys of config blocks, which are lists rather than params
+var configBlockKeys = []string{"tenants"}
+
+// Config file types whose values are all strings, so their types can't be checked
+var untypedConfigTypes = []string{"properties", "props", "prop", "env", "dotenv"}
+
+// Run the validate-config command, checking the config file strictly and printing the effective config
+// Returns the exit code, 1 if the config is invalid.
+func validateConfig() int {
+	err := setupCliFlags()
+
+	// Check keys even when the config is otherwise invalid, since a typo is the likely cause
+	var problems []string
+	if viper.GetBool("config") && fileExists(viper.GetString("config-path")) {
+		keyProblems, keysErr := checkConfigKeys(viper.GetString("config-path"))
+		if keysErr != nil {
+			problems = append(problems, keysErr.Error())
+		}
+		problems = append(problems, keyProblems...)
+	}
+
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			_, _ = fmt.Fprintf(os.Stderr, "invalid config: %s\n", problem)
+		}
+		return 1
+	}
+
+	// Print the effective config of every param, with secrets masked
+	encoded, err := json.MarshalIndent(collector.EffectiveConfig(), "", "  ")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+		return 1
+	}
+
+	fmt.Println(string(encoded))
+	return 0
+}
+
+// Check that every key of the config file is a param and has a value of its type
+// Returns the problems found, sorted by key.
+func checkConfigKeys(path string) ([]string, error) {
+	configType := configFileType(path)
+	config, expanded, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := config.AllKeys()
+	sort.Strings(keys)
+
+	var problems []string
+	for _, key := range keys {
+		// Config blocks can only be set in the config file
+		if contains(configBlockKeys, key) {
+			if _, ok := config.Get(key).([]interface{}); !ok {
+				problems = append(problems, fmt.Sprintf("key %s must be a list", key))
+			}
+			continue
+		}
+
+		f := flag.Lookup(key)
+		if f == nil {
+			problems = append(problems, fmt.Sprintf("unknown key %s", key))
+			continue
+		}
+
+		// Environment variables are strings, like the values of untyped configs
+		if contains(untypedConfigTypes, configType) || expanded[key] {
+			continue
+		}
+
+		if !matchesFlagType(f.Value.Type(), config.Get(key)) {
+			problems = append(problems, fmt.Sprintf("key %s must be of type %s", key, f.Value.Type()))
+		}
+	}
+
+	return problems, nil
+}
+
+// Check if a config value has the type of a flag
+// Lists accept a comma separated string, like their environment variables.
+func matchesFlagType(flagType string, value interface{}) bool {
+	switch flagType {
+	case "bool":
+		_, ok := value.(bool)
+		return ok
+	case "int", "int64":
+		return isInteger(value)
+	case "float64":
+		_, ok := value.(float64)
+		return ok || isInteger(value)
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "stringSlice", "stringArray":
+		if _, ok := value.(string); ok {
+			return true
+		}
+
+		values, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+
+		for _, v := range values {
+			if _, ok := v.(string); !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	return true
+}
+
+// Check if a decoded config value is a whole number
+func isInteger(value interface{}) bool {
+	switch v := value.(type) {
+	case int, int32, int64, uint, uint32, uint64:
+		return true
+	case float64:
+		return v == math.Trunc(v)
+	}
+
+	return false
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/rfizzle/okta-collector/client"
+	"github.com/rfizzle/okta-collector/metrics"
+)
+
+// Build metadata of the collector, set at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// User-Agent of the collector, such as okta-collector/1.2.0 (abc1234; go1.14.15; linux/amd64)
+func userAgent() string {
+	return fmt.Sprintf("okta-collector/%s (%s; %s; %s/%s)", version, commit, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// Identify the collector build in requests, logs and metrics
+func setupVersion() {
+	client.UserAgent = userAgent()
+	metrics.SetBuildInfo(version, commit, buildDate, runtime.Version())
+}